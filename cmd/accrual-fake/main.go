@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/accrualfake"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Имитация системы расчёта начислений для локальной разработки.
+// Без сценария каждый заказ проходит REGISTERED -> PROCESSING -> PROCESSED.
+// Формат файла сценариев:
+//
+//	"3413042486":
+//	  - status: PROCESSING
+//	  - status: PROCESSED
+//	    accrual: 500
+//	"5798116405":
+//	  - code: 204
+func main() {
+	address := flag.String("a", "localhost:8097", "Listen address")
+	scriptFile := flag.String("script", "", "YAML file with per-order scripted responses")
+	accrual := flag.Float64("accrual", 100, "Accrual for orders without script")
+	latency := flag.Duration("latency", 0, "Latency before each response")
+	failureRate := flag.Float64("failure-rate", 0, "Share of requests (0..1) answered with failure code")
+	failureCode := flag.Int("failure-code", http.StatusInternalServerError, "HTTP status for injected failures")
+	rateLimit := flag.Int("rate-limit", 0, "Max requests per minute, 0 means unlimited")
+	flag.Parse()
+
+	opts := []accrualfake.Option{
+		accrualfake.WithLatency(*latency),
+		accrualfake.WithFailures(*failureRate, *failureCode, time.Now().UnixNano()),
+		accrualfake.WithRateLimit(*rateLimit),
+		accrualfake.WithRules(accrualfake.Lifecycle(*accrual)),
+	}
+	if *scriptFile != "" {
+		scripts, err := loadScripts(*scriptFile)
+		if err != nil {
+			log.WithError(err).Fatal("failed to load scripts")
+		}
+		for number, responses := range scripts {
+			opts = append(opts, accrualfake.WithScript(number, responses...))
+		}
+	}
+
+	server := &http.Server{
+		Addr:              *address,
+		Handler:           accrualfake.NewHandler(opts...),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	log.Infof("Starting fake accrual system on %s", *address)
+	if err := server.ListenAndServe(); err != nil {
+		log.WithError(err).Fatal("fake accrual server stopped")
+	}
+}
+
+func loadScripts(path string) (map[string][]accrualfake.Response, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var scripts map[string][]accrualfake.Response
+	if err := yaml.Unmarshal(data, &scripts); err != nil {
+		return nil, err
+	}
+
+	return scripts, nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/container"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+var errUsage = errors.New("usage")
+
+type ctl struct {
+	cfg   *config.Config
+	cnt   *container.Container
+	out   printer
+	actor *entity.Actor
+	stdin io.Reader
+}
+
+type userView struct {
+	ID      uuid.UUID       `json:"id"`
+	Login   string          `json:"login"`
+	Role    entity.UserRole `json:"role"`
+	Blocked bool            `json:"blocked"`
+}
+
+type balanceView struct {
+	Login     string  `json:"login"`
+	Current   float64 `json:"current"`
+	Withdrawn float64 `json:"withdrawn"`
+}
+
+type orderView struct {
+	Number     string             `json:"number"`
+	UserID     uuid.UUID          `json:"user_id"`
+	Status     entity.OrderStatus `json:"status"`
+	Accrual    *float64           `json:"accrual,omitempty"`
+	UploadedAt time.Time          `json:"uploaded_at"`
+}
+
+type resultView struct {
+	Target string `json:"target"`
+	Result string `json:"result"`
+}
+
+type configEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (c *ctl) run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	if args[0] != "config" && !c.cfg.UseDB() {
+		return errors.New("database is required: set DATABASE_URI or -d flag")
+	}
+
+	var result any
+	var err error
+	switch cmd, rest := args[0], args[1:]; cmd {
+	case "user":
+		result, err = c.user(ctx, rest)
+	case "orders":
+		result, err = c.orders(ctx, rest)
+	case "migrate":
+		result, err = c.migrate(ctx)
+	case "reconcile":
+		result, err = c.cnt.Admin().ReconcileBalances(ctx, c.actor)
+	case "config":
+		result = flattenConfig(c.cfg.Masked())
+	default:
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.out.Print(result)
+}
+
+func (c *ctl) user(ctx context.Context, args []string) (any, error) {
+	if len(args) == 0 {
+		return nil, errUsage
+	}
+	fs := flag.NewFlagSet("user "+args[0], flag.ContinueOnError)
+	role := fs.String("role", string(entity.UserRoleUser), "User role")
+	if err := fs.Parse(args[1:]); err != nil {
+		return nil, errUsage
+	}
+	if fs.NArg() != 1 {
+		return nil, errUsage
+	}
+	login := fs.Arg(0)
+
+	switch args[0] {
+	case "create":
+		password, err := readPassword(c.stdin)
+		if err != nil {
+			return nil, err
+		}
+		u, err := c.cnt.Admin().CreateUser(ctx, c.actor, &entity.RegisterRequest{Login: login, Password: password}, entity.UserRole(*role))
+		if err != nil {
+			return nil, err
+		}
+		return userView{ID: u.ID, Login: u.Login, Role: u.Role, Blocked: u.Blocked}, nil
+	case "reset-password":
+		password, err := readPassword(c.stdin)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.cnt.Admin().ResetPassword(ctx, c.actor, login, password); err != nil {
+			return nil, err
+		}
+		return resultView{Target: login, Result: "password reset"}, nil
+	case "balance":
+		u, balance, err := c.cnt.Admin().FindUser(ctx, c.actor, login)
+		if err != nil {
+			return nil, err
+		}
+		return balanceView{Login: u.Login, Current: balance.Current, Withdrawn: balance.Withdrawn}, nil
+	default:
+		return nil, errUsage
+	}
+}
+
+func (c *ctl) orders(ctx context.Context, args []string) (any, error) {
+	if len(args) == 0 {
+		return nil, errUsage
+	}
+	fs := flag.NewFlagSet("orders "+args[0], flag.ContinueOnError)
+	statuses := fs.String("status", "", "Comma separated order statuses")
+	limit := fs.Int("limit", 100, "Max number of orders")
+	if err := fs.Parse(args[1:]); err != nil {
+		return nil, errUsage
+	}
+
+	switch args[0] {
+	case "list":
+		if *statuses == "" {
+			*statuses = string(entity.OrderStatusNew) + "," + string(entity.OrderStatusProcessing)
+		}
+		orders, err := c.cnt.OrderRepo().GetOrdersByStatuses(ctx, strings.Split(*statuses, ","), nil, *limit)
+		if err != nil {
+			return nil, err
+		}
+		views := make([]orderView, 0, len(orders))
+		for _, o := range orders {
+			views = append(views, orderView{Number: o.Number, UserID: o.UserID, Status: o.Status, Accrual: o.Accrual, UploadedAt: o.CreatedAt})
+		}
+		return views, nil
+	case "requeue":
+		numbers := fs.Args()
+		if *statuses != "" {
+			orders, err := c.cnt.OrderRepo().GetOrdersByStatuses(ctx, strings.Split(*statuses, ","), nil, *limit)
+			if err != nil {
+				return nil, err
+			}
+			for _, o := range orders {
+				numbers = append(numbers, o.Number)
+			}
+		}
+		if len(numbers) == 0 {
+			return nil, errUsage
+		}
+		results := make([]resultView, 0, len(numbers))
+		for _, number := range numbers {
+			result := "requeued"
+			if err := c.cnt.Admin().RetriggerAccrual(ctx, c.actor, number); err != nil {
+				result = err.Error()
+			}
+			results = append(results, resultView{Target: number, Result: result})
+		}
+		return results, nil
+	default:
+		return nil, errUsage
+	}
+}
+
+func (c *ctl) migrate(ctx context.Context) (any, error) {
+	schema := c.cnt.SchemaCreator()
+	defined, err := schema.SchemaDefined(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := []resultView{{Target: "schema", Result: "exists"}}
+	if !defined {
+		if err := schema.CreateSchema(ctx); err != nil {
+			return nil, err
+		}
+		results[0].Result = "created"
+	}
+	if err := schema.MigrateSchema(ctx); err != nil {
+		return nil, err
+	}
+
+	return append(results, resultView{Target: "migrations", Result: "applied"}), nil
+}
+
+// readPassword первая строка stdin, чтобы пароль не попадал в историю команд и список процессов
+func readPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("password is expected on stdin")
+	}
+
+	return password, nil
+}
+
+// flattenConfig плоский список параметров с именами как в config.yaml
+func flattenConfig(cfg *config.Config) []configEntry {
+	var entries []configEntry
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if name == "" {
+				name = strings.ToLower(f.Name[:1]) + f.Name[1:]
+			}
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			if f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeOf(time.Time{}) {
+				walk(name, v.Field(i))
+				continue
+			}
+			entries = append(entries, configEntry{Key: name, Value: formatValue(v.Field(i))})
+		}
+	}
+	walk("", reflect.ValueOf(cfg).Elem())
+
+	return entries
+}
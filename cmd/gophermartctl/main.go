@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/container"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/accrual"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/internal/workers"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	log "github.com/sirupsen/logrus"
+)
+
+const actorPrefix = "gophermartctl:"
+
+const usageText = `Usage: gophermartctl [flags] <command> [args]
+
+Commands:
+  user create [-role user|support|admin] <login>   create user, password is read from stdin
+  user reset-password <login>                      set new password read from stdin
+  user balance <login>                             show user balance
+  orders list [-status NEW,PROCESSING] [-limit N]  list orders by status
+  orders requeue [-status S1,S2] [-limit N] [number...]
+                                                   send orders to accrual again
+  migrate                                          create schema and apply migrations
+  reconcile                                        check balances of all users
+  config                                           print effective config with secrets masked
+
+Flags:
+`
+
+func main() {
+	format := flag.String("o", formatTable, "Output format: table or json")
+	tenantID := flag.String("tenant", tenant.Default, "Tenant to operate on")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usageText)
+		flag.PrintDefaults()
+	}
+	log.SetLevel(log.WarnLevel)
+
+	cfg, err := config.Load(config.DefaultDir, config.WithServerFlags(), config.WithAuth())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	out, err := newPrinter(*format, os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !cfg.HasTenant(*tenantID) {
+		log.Fatalf("unknown tenant %q", *tenantID)
+	}
+
+	cnt := container.New(cfg)
+	// CLI только возвращает заказы в статус NEW, расчёт выполнит поллер запущенного сервера
+	cnt.SetAccrualService(deferredAccrual{})
+	ctl := &ctl{
+		cfg:   cfg,
+		cnt:   cnt,
+		out:   out,
+		actor: currentActor(),
+		stdin: os.Stdin,
+	}
+	err = ctl.run(tenant.ToContext(context.Background(), *tenantID), flag.Args())
+	if errors.Is(err, errUsage) {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// currentActor от имени кого записываются действия в журнал аудита
+func currentActor() *entity.Actor {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+
+	return &entity.Actor{ID: uuid.Nil, Login: actorPrefix + name, Role: entity.UserRoleAdmin}
+}
+
+type deferredAccrual struct{}
+
+func (deferredAccrual) Send(ctx context.Context, order *entity.Order) error {
+	return nil
+}
+
+func (deferredAccrual) CircuitState() accrual.CircuitState {
+	return accrual.CircuitClosed
+}
+
+func (deferredAccrual) QueueStats() workers.QueueStats {
+	return workers.QueueStats{}
+}
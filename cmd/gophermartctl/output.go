@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+type printer interface {
+	Print(v any) error
+}
+
+func newPrinter(format string, w io.Writer) (printer, error) {
+	switch format {
+	case formatTable:
+		return &tablePrinter{w: w}, nil
+	case formatJSON:
+		return &jsonPrinter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q, expected %s or %s", format, formatTable, formatJSON)
+	}
+}
+
+type jsonPrinter struct {
+	w io.Writer
+}
+
+func (p *jsonPrinter) Print(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
+
+// tablePrinter выводит структуру или срез структур таблицей, заголовки берутся из json-тегов
+type tablePrinter struct {
+	w io.Writer
+}
+
+func (p *tablePrinter) Print(v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	var rows []reflect.Value
+	switch rv.Kind() {
+	case reflect.Slice:
+		for i := 0; i < rv.Len(); i++ {
+			rows = append(rows, reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		rows = append(rows, rv)
+	default:
+		_, err := fmt.Fprintln(p.w, formatValue(rv))
+		return err
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	elemType := rv.Type()
+	if rv.Kind() == reflect.Slice {
+		elemType = elemType.Elem()
+		if elemType.Kind() == reflect.Pointer {
+			elemType = elemType.Elem()
+		}
+	}
+	var headers []string
+	for i := 0; i < elemType.NumField(); i++ {
+		headers = append(headers, strings.ToUpper(columnName(elemType.Field(i))))
+	}
+	if _, err := fmt.Fprintln(tw, strings.Join(headers, "\t")); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		values := make([]string, row.NumField())
+		for i := range values {
+			values[i] = formatValue(row.Field(i))
+		}
+		if _, err := fmt.Fprintln(tw, strings.Join(values, "\t")); err != nil {
+			return err
+		}
+	}
+
+	return tw.Flush()
+}
+
+func columnName(f reflect.StructField) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+
+	return f.Name
+}
+
+func formatValue(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch value := v.Interface().(type) {
+	case time.Time:
+		return value.Format(time.RFC3339)
+	case []byte:
+		return string(value)
+	case []string:
+		return strings.Join(value, ",")
+	case fmt.Stringer:
+		return value.String()
+	}
+
+	return fmt.Sprint(v.Interface())
+}
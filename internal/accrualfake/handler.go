@@ -0,0 +1,228 @@
+// Package accrualfake имитация системы расчёта начислений для локального запуска и тестов.
+// Реализует протокол GET /api/orders/{number}: 200 с телом, 204 для незарегистрированного заказа
+// и 429 с заголовком Retry-After при превышении лимита запросов.
+package accrualfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StatusRegistered = "REGISTERED"
+	StatusProcessing = "PROCESSING"
+	StatusInvalid    = "INVALID"
+	StatusProcessed  = "PROCESSED"
+
+	ordersPath = "/api/orders/"
+)
+
+// Response ответ на один запрос по заказу. Пустые Status и Body при нулевом Code означают 204.
+type Response struct {
+	// Code HTTP-статус; 0 означает 200, если задан Status или Body, и 204 иначе
+	Code       int           `yaml:"code"`
+	Status     string        `yaml:"status"`
+	Accrual    *float64      `yaml:"accrual"`
+	RetryAfter time.Duration `yaml:"retryAfter"`
+	Latency    time.Duration `yaml:"latency"`
+	// Body тело ответа 200 как есть вместо JSON по Status и Accrual, чтобы имитировать ошибки системы расчёта
+	Body string `yaml:"body"`
+}
+
+type orderResponse struct {
+	Order   string   `json:"order"`
+	Status  string   `json:"status"`
+	Accrual *float64 `json:"accrual,omitempty"`
+}
+
+// Rule ответ по номеру заказа и номеру попытки (с нуля); ok=false передаёт решение следующему правилу
+type Rule func(number string, attempt int) (resp Response, ok bool)
+
+type Option func(h *Handler)
+
+type Handler struct {
+	mu       sync.Mutex
+	scripts  map[string][]Response
+	rules    []Rule
+	attempts map[string]int
+
+	latency     time.Duration
+	failureRate float64
+	failureCode int
+	rnd         *rand.Rand
+
+	rateLimit   int
+	rateWindow  time.Time
+	rateCounter int
+	now         func() time.Time
+	sleep       func(d time.Duration)
+}
+
+func NewHandler(opts ...Option) *Handler {
+	h := &Handler{
+		scripts:     make(map[string][]Response),
+		attempts:    make(map[string]int),
+		failureCode: http.StatusInternalServerError,
+		rnd:         rand.New(rand.NewSource(1)), //nolint:gosec // детерминированная последовательность нужна для тестов
+		now:         time.Now,
+		sleep:       time.Sleep,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// WithScript задаёт последовательность ответов по заказу; последний ответ повторяется
+func WithScript(number string, responses ...Response) Option {
+	return func(h *Handler) {
+		h.scripts[number] = responses
+	}
+}
+
+// WithRules добавляет правила, которые применяются к заказам без сценария в порядке добавления
+func WithRules(rules ...Rule) Option {
+	return func(h *Handler) {
+		h.rules = append(h.rules, rules...)
+	}
+}
+
+// WithLatency задержка перед каждым ответом
+func WithLatency(d time.Duration) Option {
+	return func(h *Handler) {
+		h.latency = d
+	}
+}
+
+// WithFailures доля запросов (0..1), на которые отвечать кодом code; seed делает отказы воспроизводимыми
+func WithFailures(rate float64, code int, seed int64) Option {
+	return func(h *Handler) {
+		h.failureRate = rate
+		h.failureCode = code
+		h.rnd = rand.New(rand.NewSource(seed)) //nolint:gosec // детерминированная последовательность нужна для тестов
+	}
+}
+
+// WithRateLimit не более n запросов в минуту, сверх лимита 429 с Retry-After до конца минуты
+func WithRateLimit(n int) Option {
+	return func(h *Handler) {
+		h.rateLimit = n
+	}
+}
+
+// WithClock подменяет часы и ожидание, чтобы тесты не зависели от реального времени
+func WithClock(now func() time.Time, sleep func(d time.Duration)) Option {
+	return func(h *Handler) {
+		h.now = now
+		h.sleep = sleep
+	}
+}
+
+// Script заменяет сценарий заказа и сбрасывает счётчик попыток
+func (h *Handler) Script(number string, responses ...Response) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.scripts[number] = responses
+	delete(h.attempts, number)
+}
+
+// Attempts число запросов по заказу
+func (h *Handler) Attempts(number string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.attempts[number]
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	number, found := strings.CutPrefix(r.URL.Path, ordersPath)
+	if r.Method != http.MethodGet || !found || number == "" || strings.Contains(number, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	resp, limited := h.next(number)
+	if latency := h.latency + resp.Latency; latency > 0 {
+		h.sleep(latency)
+	}
+	if limited || resp.Code == http.StatusTooManyRequests {
+		h.tooManyRequests(w, resp.RetryAfter)
+		return
+	}
+
+	code := resp.Code
+	if code == 0 {
+		code = http.StatusOK
+		if resp.Status == "" && resp.Body == "" {
+			code = http.StatusNoContent
+		}
+	}
+	if code != http.StatusOK {
+		w.WriteHeader(code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Body != "" {
+		_, _ = w.Write([]byte(resp.Body))
+		return
+	}
+	_ = json.NewEncoder(w).Encode(orderResponse{Order: number, Status: resp.Status, Accrual: resp.Accrual})
+}
+
+// tooManyRequests ответ 429. Retry-After округляется вверх и не меньше секунды: клиент, получивший 0,
+// повторил бы запрос сразу и снова упёрся бы в лимит.
+func (h *Handler) tooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := max(int((retryAfter+time.Second-1)/time.Second), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusTooManyRequests)
+	// у ответа 429 из сценария без WithRateLimit лимита нет, называть его нечем
+	if h.rateLimit > 0 {
+		_, _ = fmt.Fprintf(w, "No more than %d requests per minute allowed", h.rateLimit)
+	} else {
+		_, _ = fmt.Fprint(w, "Too many requests")
+	}
+}
+
+// next выбирает ответ: лимит запросов, затем случайный отказ, сценарий и правила
+func (h *Handler) next(number string) (Response, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.rateLimit > 0 {
+		now := h.now()
+		if now.Sub(h.rateWindow) >= time.Minute {
+			h.rateWindow = now
+			h.rateCounter = 0
+		}
+		h.rateCounter++
+		if h.rateCounter > h.rateLimit {
+			return Response{RetryAfter: h.rateWindow.Add(time.Minute).Sub(now)}, true
+		}
+	}
+
+	attempt := h.attempts[number]
+	h.attempts[number]++
+	if h.failureRate > 0 && h.rnd.Float64() < h.failureRate {
+		return Response{Code: h.failureCode}, false
+	}
+	if script, ok := h.scripts[number]; ok && len(script) > 0 {
+		return script[min(attempt, len(script)-1)], false
+	}
+	for _, rule := range h.rules {
+		if resp, ok := rule(number, attempt); ok {
+			return resp, false
+		}
+	}
+
+	return Response{}, false
+}
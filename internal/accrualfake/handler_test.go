@@ -0,0 +1,104 @@
+package accrualfake_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/accrualfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(h http.Handler, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("Script then rules", func(t *testing.T) {
+		h := accrualfake.NewHandler(
+			accrualfake.WithScript("3413042486", accrualfake.Response{Status: accrualfake.StatusProcessing}, accrualfake.Processed(500)),
+			accrualfake.WithRules(accrualfake.BySuffix("89", accrualfake.Response{Status: accrualfake.StatusInvalid})),
+		)
+
+		w := get(h, "/api/orders/3413042486")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"order":"3413042486","status":"PROCESSING"}`, w.Body.String())
+		for i := 0; i < 2; i++ {
+			w = get(h, "/api/orders/3413042486")
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, `{"order":"3413042486","status":"PROCESSED","accrual":500}`, w.Body.String())
+		}
+		assert.Equal(t, 3, h.Attempts("3413042486"))
+
+		w = get(h, "/api/orders/9155976989")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"order":"9155976989","status":"INVALID"}`, w.Body.String())
+
+		assert.Equal(t, http.StatusNoContent, get(h, "/api/orders/5798116405").Code)
+		assert.Equal(t, http.StatusNotFound, get(h, "/api/orders/").Code)
+	})
+
+	t.Run("Lifecycle", func(t *testing.T) {
+		h := accrualfake.NewHandler(accrualfake.WithRules(accrualfake.Lifecycle(42)))
+		var statuses []string
+		for i := 0; i < 3; i++ {
+			var resp struct{ Status string }
+			require.NoError(t, json.NewDecoder(get(h, "/api/orders/3413042486").Body).Decode(&resp))
+			statuses = append(statuses, resp.Status)
+		}
+		assert.Equal(t, []string{accrualfake.StatusRegistered, accrualfake.StatusProcessing, accrualfake.StatusProcessed}, statuses)
+	})
+
+	t.Run("Rate limit", func(t *testing.T) {
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		h := accrualfake.NewHandler(
+			accrualfake.WithRateLimit(2),
+			accrualfake.WithClock(func() time.Time { return now }, func(time.Duration) {}),
+		)
+		assert.Equal(t, http.StatusNoContent, get(h, "/api/orders/1").Code)
+		now = now.Add(20 * time.Second)
+		assert.Equal(t, http.StatusNoContent, get(h, "/api/orders/1").Code)
+
+		w := get(h, "/api/orders/1")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "40", w.Header().Get("Retry-After"))
+		assert.Equal(t, "No more than 2 requests per minute allowed", w.Body.String())
+
+		// остаток окна меньше секунды округляется вверх, а не до нуля
+		now = now.Add(39*time.Second + 600*time.Millisecond)
+		w = get(h, "/api/orders/1")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+		now = now.Add(400 * time.Millisecond)
+		assert.Equal(t, http.StatusNoContent, get(h, "/api/orders/1").Code)
+	})
+
+	t.Run("Scripted rate limit", func(t *testing.T) {
+		h := accrualfake.NewHandler(
+			accrualfake.WithScript("1", accrualfake.Response{Code: http.StatusTooManyRequests}),
+			accrualfake.WithRateLimit(100),
+		)
+		w := get(h, "/api/orders/1")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+		assert.Equal(t, "No more than 100 requests per minute allowed", w.Body.String())
+	})
+
+	t.Run("Latency and failures", func(t *testing.T) {
+		var slept time.Duration
+		h := accrualfake.NewHandler(
+			accrualfake.WithLatency(100*time.Millisecond),
+			accrualfake.WithFailures(1, http.StatusBadGateway, 1),
+			accrualfake.WithClock(time.Now, func(d time.Duration) { slept += d }),
+		)
+		assert.Equal(t, http.StatusBadGateway, get(h, "/api/orders/1").Code)
+		assert.Equal(t, 100*time.Millisecond, slept)
+	})
+}
@@ -0,0 +1,38 @@
+package accrualfake
+
+import (
+	"strings"
+)
+
+// Processed ответ с окончательным расчётом
+func Processed(accrual float64) Response {
+	return Response{Status: StatusProcessed, Accrual: &accrual}
+}
+
+// Lifecycle проводит любой заказ через REGISTERED и PROCESSING к PROCESSED с начислением accrual
+func Lifecycle(accrual float64) Rule {
+	return func(number string, attempt int) (Response, bool) {
+		switch attempt {
+		case 0:
+			return Response{Status: StatusRegistered}, true
+		case 1:
+			return Response{Status: StatusProcessing}, true
+		default:
+			return Processed(accrual), true
+		}
+	}
+}
+
+// BySuffix применяет resp к заказам, номер которых оканчивается на suffix
+func BySuffix(suffix string, resp Response) Rule {
+	return func(number string, attempt int) (Response, bool) {
+		return resp, strings.HasSuffix(number, suffix)
+	}
+}
+
+// Unregistered отвечает 204 на любой заказ
+func Unregistered() Rule {
+	return func(number string, attempt int) (Response, bool) {
+		return Response{}, true
+	}
+}
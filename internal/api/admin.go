@@ -0,0 +1,226 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+const DefaultAuditLimit = 100
+
+var _ internal.AdminAPI = (*adminServer)(nil)
+
+type adminServer struct {
+	admin domain.Admin
+}
+
+func NewAdmin(admin domain.Admin) internal.AdminAPI {
+	return &adminServer{
+		admin: admin,
+	}
+}
+
+func (s *adminServer) FindUser(w http.ResponseWriter, r *http.Request) {
+	user, balance, err := s.admin.FindUser(r.Context(), actorFromRequest(r), chi.URLParam(r, "login"))
+	if err != nil {
+		domain.SendError(w, err)
+		return
+	}
+
+	utils.SendResponse(w, entity.UserInfoResponse{
+		ID:      user.ID,
+		Login:   user.Login,
+		Role:    user.Role,
+		Blocked: user.Blocked,
+		Balance: *balance,
+	}, http.StatusOK)
+}
+
+func (s *adminServer) GetUserOrders(w http.ResponseWriter, r *http.Request) {
+	orders, err := s.admin.GetUserOrders(r.Context(), actorFromRequest(r), chi.URLParam(r, "login"))
+	if err != nil {
+		domain.SendError(w, err)
+		return
+	}
+
+	ordersResp := make([]entity.OrderResponse, len(orders))
+	for i, o := range orders {
+		ordersResp[i] = entity.OrderResponse{
+			Number:    o.Number,
+			Status:    o.Status,
+			CreatedAt: o.CreatedAt,
+			Accrual:   o.Accrual,
+			Bonus:     o.Bonus,
+		}
+	}
+	utils.SendResponse(w, ordersResp, http.StatusOK)
+}
+
+func (s *adminServer) GetUserWithdrawals(w http.ResponseWriter, r *http.Request) {
+	withdrawals, err := s.admin.GetUserWithdrawals(r.Context(), actorFromRequest(r), chi.URLParam(r, "login"))
+	if err != nil {
+		domain.SendError(w, err)
+		return
+	}
+
+	withdrawalsResp := make([]entity.WithdrawalsResponse, len(withdrawals))
+	for i := range withdrawals {
+		withdrawalsResp[i] = entity.NewWithdrawalsResponse(&withdrawals[i])
+	}
+	utils.SendResponse(w, withdrawalsResp, http.StatusOK)
+}
+
+func (s *adminServer) RetriggerAccrual(w http.ResponseWriter, r *http.Request) {
+	if err := s.admin.RetriggerAccrual(r.Context(), actorFromRequest(r), chi.URLParam(r, "number")); err != nil {
+		domain.SendError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *adminServer) AdjustBalance(w http.ResponseWriter, r *http.Request) {
+	reqData, err := utils.ReadJSON[entity.BalanceAdjustmentRequest](r.Body)
+	if err != nil {
+		utils.SendBadRequest(w, err, "error reading balance adjustment request json")
+		return
+	}
+	if err := s.admin.AdjustBalance(r.Context(), actorFromRequest(r), chi.URLParam(r, "login"), reqData); err != nil {
+		domain.SendError(w, err)
+		return
+	}
+}
+
+func (s *adminServer) RefundWithdrawal(w http.ResponseWriter, r *http.Request) {
+	reqData, err := utils.ReadJSON[entity.RefundRequest](r.Body)
+	if err != nil {
+		utils.SendBadRequest(w, err, "error reading refund request json")
+		return
+	}
+	withdrawal, err := s.admin.RefundWithdrawal(r.Context(), actorFromRequest(r), chi.URLParam(r, "number"), reqData)
+	if err != nil {
+		domain.SendError(w, err)
+		return
+	}
+
+	utils.SendResponse(w, entity.NewWithdrawalsResponse(withdrawal), http.StatusOK)
+}
+
+func (s *adminServer) BlockUser(w http.ResponseWriter, r *http.Request) {
+	if err := s.admin.SetUserBlocked(r.Context(), actorFromRequest(r), chi.URLParam(r, "login"), true); err != nil {
+		domain.SendError(w, err)
+		return
+	}
+}
+
+func (s *adminServer) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	if err := s.admin.SetUserBlocked(r.Context(), actorFromRequest(r), chi.URLParam(r, "login"), false); err != nil {
+		domain.SendError(w, err)
+		return
+	}
+}
+
+func (s *adminServer) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	limit := DefaultAuditLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			utils.SendBadRequest(w, err, "bad limit value")
+			return
+		}
+	}
+	records, err := s.admin.GetAuditLog(r.Context(), limit)
+	if err != nil {
+		domain.SendError(w, err)
+		return
+	}
+
+	recordsResp := make([]entity.AuditRecordResponse, len(records))
+	for i, record := range records {
+		recordsResp[i] = entity.AuditRecordResponse{
+			ActorLogin: record.ActorLogin,
+			Action:     record.Action,
+			Target:     record.Target,
+			Details:    record.Details,
+			CreatedAt:  record.CreatedAt,
+		}
+	}
+	utils.SendResponse(w, recordsResp, http.StatusOK)
+}
+
+func (s *adminServer) GetAccrualQueueStats(w http.ResponseWriter, r *http.Request) {
+	utils.SendResponse(w, s.admin.GetAccrualQueueStats(r.Context()), http.StatusOK)
+}
+
+func (s *adminServer) GetOrderAccrualResponses(w http.ResponseWriter, r *http.Request) {
+	responses, err := s.admin.GetOrderAccrualResponses(r.Context(), actorFromRequest(r), chi.URLParam(r, "number"))
+	if err != nil {
+		domain.SendError(w, err)
+		return
+	}
+
+	sendAccrualResponses(w, responses)
+}
+
+func (s *adminServer) GetQuarantinedAccrualResponses(w http.ResponseWriter, r *http.Request) {
+	limit := DefaultAuditLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			utils.SendBadRequest(w, err, "bad limit value")
+			return
+		}
+	}
+	responses, err := s.admin.GetQuarantinedAccrualResponses(r.Context(), actorFromRequest(r), limit)
+	if err != nil {
+		domain.SendError(w, err)
+		return
+	}
+
+	sendAccrualResponses(w, responses)
+}
+
+func sendAccrualResponses(w http.ResponseWriter, responses []entity.AccrualResponse) {
+	records := make([]entity.AccrualResponseRecord, len(responses))
+	for i := range responses {
+		records[i] = entity.NewAccrualResponseRecord(&responses[i])
+	}
+	utils.SendResponse(w, records, http.StatusOK)
+}
+
+func (s *adminServer) GetAdjustmentsForReview(w http.ResponseWriter, r *http.Request) {
+	reviews, err := s.admin.GetAdjustmentsForReview(r.Context(), actorFromRequest(r))
+	if err != nil {
+		domain.SendError(w, err)
+		return
+	}
+
+	utils.SendResponse(w, reviews, http.StatusOK)
+}
+
+func (s *adminServer) MarkAdjustmentReviewed(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.FromString(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.SendBadRequest(w, err, "bad adjustment id")
+		return
+	}
+	if err := s.admin.MarkAdjustmentReviewed(r.Context(), actorFromRequest(r), id); err != nil {
+		domain.SendError(w, err)
+		return
+	}
+}
+
+func actorFromRequest(r *http.Request) *entity.Actor {
+	claims := auth.FromContext(r.Context())
+	if claims == nil {
+		return &entity.Actor{}
+	}
+
+	return claims.Actor()
+}
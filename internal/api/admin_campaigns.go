@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+func (s *adminServer) ListCampaigns(w http.ResponseWriter, r *http.Request) {
+	campaigns, err := s.admin.ListCampaigns(r.Context())
+	if err != nil {
+		domain.SendError(w, err)
+		return
+	}
+
+	campaignsResp := make([]entity.CampaignResponse, len(campaigns))
+	for i := range campaigns {
+		campaignsResp[i] = campaignResponse(&campaigns[i])
+	}
+	utils.SendResponse(w, campaignsResp, http.StatusOK)
+}
+
+func (s *adminServer) CreateCampaign(w http.ResponseWriter, r *http.Request) {
+	reqData, err := utils.ReadJSON[entity.CampaignRequest](r.Body)
+	if err != nil {
+		utils.SendBadRequest(w, err, "error reading campaign request json")
+		return
+	}
+	campaign, err := s.admin.CreateCampaign(r.Context(), actorFromRequest(r), reqData)
+	if err != nil {
+		domain.SendError(w, err)
+		return
+	}
+
+	utils.SendResponse(w, campaignResponse(campaign), http.StatusCreated)
+}
+
+func (s *adminServer) UpdateCampaign(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.FromString(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.SendBadRequest(w, err, "bad campaign id")
+		return
+	}
+	reqData, err := utils.ReadJSON[entity.CampaignRequest](r.Body)
+	if err != nil {
+		utils.SendBadRequest(w, err, "error reading campaign request json")
+		return
+	}
+	campaign, err := s.admin.UpdateCampaign(r.Context(), actorFromRequest(r), id, reqData)
+	if err != nil {
+		domain.SendError(w, err)
+		return
+	}
+
+	utils.SendResponse(w, campaignResponse(campaign), http.StatusOK)
+}
+
+func (s *adminServer) DeleteCampaign(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.FromString(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.SendBadRequest(w, err, "bad campaign id")
+		return
+	}
+	if err := s.admin.DeleteCampaign(r.Context(), actorFromRequest(r), id); err != nil {
+		domain.SendError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func campaignResponse(c *entity.Campaign) entity.CampaignResponse {
+	return entity.CampaignResponse{
+		ID:             c.ID,
+		Name:           c.Name,
+		Kind:           c.Kind,
+		Value:          c.Value,
+		StartsAt:       c.StartsAt,
+		EndsAt:         c.EndsAt,
+		FirstOrderOnly: c.FirstOrderOnly,
+		MinOrderCount:  c.MinOrderCount,
+		UserCap:        c.UserCap,
+		Enabled:        c.Enabled,
+		CreatedAt:      c.CreatedAt,
+	}
+}
@@ -14,7 +14,9 @@ type gophermartServer struct {
 	auth       *auth.Service
 	gophermart domain.Gophermart
 
-	dbPinger internal.Pinger
+	dbPinger       internal.Pinger
+	accrualMonitor internal.AccrualMonitor
+	leaderMonitor  internal.LeaderMonitor
 }
 
 func New(
@@ -22,11 +24,15 @@ func New(
 	authService *auth.Service,
 	service domain.Gophermart,
 	dbPinger internal.Pinger,
+	accrualMonitor internal.AccrualMonitor,
+	leaderMonitor internal.LeaderMonitor,
 ) internal.API {
 	return &gophermartServer{
-		cfg:        cfg,
-		auth:       authService,
-		gophermart: service,
-		dbPinger:   dbPinger,
+		cfg:            cfg,
+		auth:           authService,
+		gophermart:     service,
+		dbPinger:       dbPinger,
+		accrualMonitor: accrualMonitor,
+		leaderMonitor:  leaderMonitor,
 	}
 }
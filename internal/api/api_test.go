@@ -36,17 +36,20 @@ func TestSuiteRun(t *testing.T) {
 
 func (s *TestSuite) SetupSuite() {
 	s.cfg = testutils.GetConfig("../../" + config.DefaultDir)
+	s.cfg.Leader.Instance = "api-test"
 	s.cnt = container.New(s.cfg)
 	s.api = api.New(
 		s.cfg,
 		s.cnt.Auth(),
 		s.cnt.Gophermart(),
 		s.cnt.Pinger(),
+		s.cnt.AccrualService(),
+		s.cnt.Elector(),
 	)
 	s.router = app.NewRouter(s.cnt)
 	s.router.InitRoutes(s.api, false)
 
-	s.Require().NoError(s.cnt.Resetter().Reset())
+	s.Require().NoError(testutils.PrepareDB(s.cnt))
 }
 
 func (s *TestSuite) TestApi() {
@@ -61,6 +64,13 @@ func (s *TestSuite) TestApi() {
 		expectedBody string
 	}{
 		{name: "Ping", method: http.MethodGet, path: "/ping", expectedCode: http.StatusOK, expectedBody: empty},
+		{
+			name:         "Readiness",
+			method:       http.MethodGet,
+			path:         "/ready",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"database":"ok","accrual":"closed","instance":"api-test"}`,
+		},
 		{
 			name:         "Register",
 			method:       http.MethodPost,
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+func (s *gophermartServer) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	clientData := auth.FromContext(r.Context())
+	reqData, err := utils.ReadJSON[entity.ChangePasswordRequest](r.Body)
+	if err != nil {
+		utils.SendBadRequest(w, err, "error reading change password request json")
+		return
+	}
+	user, err := s.gophermart.ChangePassword(r.Context(), clientData.UserID, reqData)
+	if err != nil {
+		domain.SendError(w, err, "error changing password")
+		return
+	}
+
+	// старые токены отозваны, текущему клиенту выдаём новый
+	cookie, err := s.auth.CreateTokenCookie(user)
+	if err != nil {
+		utils.SendInternalError(w, err, "error creating token")
+		return
+	}
+	http.SetCookie(w, cookie)
+}
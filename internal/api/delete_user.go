@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+)
+
+func (s *gophermartServer) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	clientData := auth.FromContext(r.Context())
+	if err := s.gophermart.DeleteUser(r.Context(), clientData.UserID); err != nil {
+		domain.SendError(w, err, "error deleting user")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   s.cfg.Auth.CookieName,
+		Value:  "",
+		MaxAge: -1,
+	})
+}
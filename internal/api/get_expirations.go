@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+func (s *gophermartServer) GetExpirations(w http.ResponseWriter, r *http.Request) {
+	clientData := auth.FromContext(r.Context())
+	expirations, err := s.gophermart.GetExpirations(r.Context(), clientData.UserID)
+	if err != nil {
+		domain.SendError(w, err)
+		return
+	}
+
+	if len(expirations) == 0 {
+		utils.SendResponse(w, []struct{}{}, http.StatusNoContent)
+	} else {
+		resp := make([]entity.ExpirationResponse, len(expirations))
+		for i, e := range expirations {
+			resp[i] = entity.ExpirationResponse{
+				OrderNumber: e.OrderNumber,
+				Sum:         e.Value,
+				ExpiredAt:   e.ExpiredAt,
+			}
+		}
+		utils.SendResponse(w, resp, http.StatusOK)
+	}
+}
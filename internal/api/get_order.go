@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+func (s *gophermartServer) GetOrder(w http.ResponseWriter, r *http.Request) {
+	clientData := auth.FromContext(r.Context())
+	order, history, err := s.gophermart.GetOrder(r.Context(), clientData.UserID, chi.URLParam(r, "number"))
+	if err != nil {
+		domain.SendError(w, err)
+		return
+	}
+
+	resp := entity.OrderDetailsResponse{
+		OrderResponse: entity.OrderResponse{
+			Number:    order.Number,
+			Status:    order.Status,
+			CreatedAt: order.CreatedAt,
+			Accrual:   order.Accrual,
+			Bonus:     order.Bonus,
+		},
+		History: make([]entity.OrderStatusChangeResponse, len(history)),
+	}
+	for i, h := range history {
+		resp.History[i] = entity.OrderStatusChangeResponse{
+			From:    h.FromStatus,
+			To:      h.ToStatus,
+			Source:  h.Source,
+			Payload: h.Payload,
+			At:      h.CreatedAt,
+		}
+	}
+	utils.SendResponse(w, resp, http.StatusOK)
+}
@@ -27,6 +27,7 @@ func (s *gophermartServer) GetOrders(w http.ResponseWriter, r *http.Request) {
 				Status:    o.Status,
 				CreatedAt: o.CreatedAt,
 				Accrual:   o.Accrual,
+				Bonus:     o.Bonus,
 			}
 		}
 		utils.SendResponse(w, ordersResp, http.StatusOK)
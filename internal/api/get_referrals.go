@@ -0,0 +1,20 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+)
+
+func (s *gophermartServer) GetReferrals(w http.ResponseWriter, r *http.Request) {
+	clientData := auth.FromContext(r.Context())
+	referrals, err := s.gophermart.GetReferrals(r.Context(), clientData.UserID)
+	if err != nil {
+		domain.SendError(w, err)
+		return
+	}
+
+	utils.SendResponse(w, referrals, http.StatusOK)
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	StatementFormatJSON = "json"
+	StatementFormatCSV  = "csv"
+	// statementFlushRows через сколько строк выписка отправляется клиенту, не дожидаясь конца
+	statementFlushRows = 100
+)
+
+func (s *gophermartServer) GetStatement(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, err := parseStatementTime(query.Get("from"), false)
+	if err != nil {
+		utils.SendBadRequest(w, err, "bad from value")
+		return
+	}
+	to, err := parseStatementTime(query.Get("to"), true)
+	if err != nil {
+		utils.SendBadRequest(w, err, "bad to value")
+		return
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = StatementFormatJSON
+	}
+	if format != StatementFormatJSON && format != StatementFormatCSV {
+		utils.SendBadRequest(w, fmt.Errorf("unknown format %q", format), "bad format value")
+		return
+	}
+
+	clientData := auth.FromContext(r.Context())
+	sw := &statementWriter{w: w, format: format}
+	err = s.gophermart.StreamStatement(r.Context(), clientData.UserID, from, to, sw.Write)
+	if err != nil {
+		if !sw.started {
+			domain.SendError(w, err)
+			return
+		}
+		// статус уже отправлен, клиент получит оборванный документ
+		log.WithError(err).Error("statement streaming failed")
+		return
+	}
+	if err := sw.Close(); err != nil {
+		log.WithError(err).Error("unable to finish statement")
+	}
+}
+
+// parseStatementTime принимает RFC 3339 или дату; дата в конце периода включает весь день
+func parseStatementTime(value string, periodEnd bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if periodEnd {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return t, nil
+}
+
+// statementWriter пишет выписку прямо в ответ; заголовки отправляются с первой строкой,
+// чтобы ошибку до неё можно было вернуть обычным ответом
+type statementWriter struct {
+	w       http.ResponseWriter
+	format  string
+	csv     *csv.Writer
+	started bool
+	rows    int
+}
+
+func (sw *statementWriter) start() error {
+	sw.started = true
+	filename := "statement." + sw.format
+	sw.w.Header().Set(utils.ContentDisposition, `attachment; filename="`+filename+`"`)
+	if sw.format == StatementFormatCSV {
+		sw.w.Header().Set(utils.ContentType, utils.ContentTypeCSV)
+		sw.w.WriteHeader(http.StatusOK)
+		sw.csv = csv.NewWriter(sw.w)
+		return sw.csv.Write([]string{"at", "kind", "order", "amount", "balance"})
+	}
+	sw.w.Header().Set(utils.ContentType, utils.ContentTypeJSONUTF8)
+	sw.w.WriteHeader(http.StatusOK)
+	_, err := sw.w.Write([]byte("["))
+
+	return err
+}
+
+func (sw *statementWriter) Write(e *entity.StatementEntry) error {
+	if !sw.started {
+		if err := sw.start(); err != nil {
+			return err
+		}
+	}
+
+	if sw.csv != nil {
+		if err := sw.csv.Write([]string{
+			e.At.Format(time.RFC3339),
+			string(e.Kind),
+			e.OrderNumber,
+			strconv.FormatFloat(e.Amount, 'f', -1, 64),
+			strconv.FormatFloat(e.Balance, 'f', -1, 64),
+		}); err != nil {
+			return err
+		}
+	} else {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if sw.rows > 0 {
+			data = append([]byte(","), data...)
+		}
+		if _, err := sw.w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	sw.rows++
+	if sw.rows%statementFlushRows == 0 {
+		return sw.flush()
+	}
+
+	return nil
+}
+
+func (sw *statementWriter) Close() error {
+	if !sw.started {
+		if err := sw.start(); err != nil {
+			return err
+		}
+	}
+	if sw.csv == nil {
+		if _, err := sw.w.Write([]byte("]")); err != nil {
+			return err
+		}
+	}
+
+	return sw.flush()
+}
+
+func (sw *statementWriter) flush() error {
+	if sw.csv != nil {
+		sw.csv.Flush()
+		if err := sw.csv.Error(); err != nil {
+			return err
+		}
+	}
+	err := http.NewResponseController(sw.w).Flush()
+	if errors.Is(err, http.ErrNotSupported) {
+		return nil
+	}
+
+	return err
+}
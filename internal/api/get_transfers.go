@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+func (s *gophermartServer) GetTransfers(w http.ResponseWriter, r *http.Request) {
+	clientData := auth.FromContext(r.Context())
+	transfers, err := s.gophermart.GetTransfers(r.Context(), clientData.UserID)
+	if err != nil {
+		domain.SendError(w, err)
+		return
+	}
+
+	if len(transfers) == 0 {
+		utils.SendResponse(w, []struct{}{}, http.StatusNoContent)
+		return
+	}
+	resp := make([]entity.TransferResponse, len(transfers))
+	for i, t := range transfers {
+		resp[i] = entity.TransferResponse{
+			ID:        t.ID,
+			Direction: entity.TransferDirectionIn,
+			Login:     t.SenderLogin,
+			Amount:    t.Amount,
+			CreatedAt: t.CreatedAt,
+		}
+		if t.SenderID == clientData.UserID {
+			resp[i].Direction = entity.TransferDirectionOut
+			resp[i].Login = t.RecipientLogin
+		}
+	}
+	utils.SendResponse(w, resp, http.StatusOK)
+}
@@ -21,12 +21,8 @@ func (s *gophermartServer) GetWithdrawals(w http.ResponseWriter, r *http.Request
 		utils.SendResponse(w, []struct{}{}, http.StatusNoContent)
 	} else {
 		withdrawalsResp := make([]entity.WithdrawalsResponse, len(withdrawals))
-		for i, withdraw := range withdrawals {
-			withdrawalsResp[i] = entity.WithdrawalsResponse{
-				OrderNumber: withdraw.OrderNumber,
-				Sum:         withdraw.Value,
-				ProcessedAt: withdraw.CreatedAt,
-			}
+		for i := range withdrawals {
+			withdrawalsResp[i] = entity.NewWithdrawalsResponse(&withdrawals[i])
 		}
 		utils.SendResponse(w, withdrawalsResp, http.StatusOK)
 	}
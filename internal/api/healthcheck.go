@@ -3,7 +3,10 @@ package api
 import (
 	"net/http"
 
+	"github.com/k-zavarnitsyn/gophermart/internal/services/accrual"
 	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	log "github.com/sirupsen/logrus"
 )
 
 func (s *gophermartServer) Healthcheck(w http.ResponseWriter, r *http.Request) {
@@ -15,3 +18,26 @@ func (s *gophermartServer) Healthcheck(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// Readiness при разомкнутом circuit breaker заказы принимаются, но не рассчитываются,
+// поэтому экземпляр считается неготовым
+func (s *gophermartServer) Readiness(w http.ResponseWriter, r *http.Request) {
+	state := s.accrualMonitor.CircuitState()
+	resp := entity.ReadinessResponse{Database: "ok", Accrual: string(state), Instance: s.leaderMonitor.Instance()}
+	status := http.StatusOK
+	if err := s.dbPinger.Ping(r.Context()); err != nil {
+		log.WithError(err).Error("readiness check: database is unavailable")
+		resp.Database = "unavailable"
+		status = http.StatusServiceUnavailable
+	}
+	leader, err := s.leaderMonitor.Leader(r.Context())
+	if err != nil {
+		log.WithError(err).Error("readiness check: failed to get leader")
+	}
+	resp.Leader = leader
+	if state == accrual.CircuitOpen {
+		status = http.StatusServiceUnavailable
+	}
+
+	utils.SendResponse(w, resp, status)
+}
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"errors"
 	"net/http"
 
 	"github.com/k-zavarnitsyn/gophermart/internal/utils"
@@ -18,11 +17,7 @@ func (s *gophermartServer) Login(w http.ResponseWriter, r *http.Request) {
 
 	user, err := s.gophermart.Login(r.Context(), loginReq)
 	if err != nil {
-		if errors.Is(err, domain.ErrNotFound) {
-			utils.SendDomainError(w, err, http.StatusUnauthorized)
-		} else {
-			domain.SendError(w, err)
-		}
+		domain.SendError(w, err)
 		return
 	}
 
@@ -35,17 +35,13 @@ func (s *gophermartServer) PostOrder(w http.ResponseWriter, r *http.Request) {
 		UserID: authData.UserID,
 		Number: string(reqData),
 	})
+	if errors.Is(err, domain.ErrOrderCreatedByCurrentUser) {
+		// повторная загрузка своего заказа - не ошибка
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrBadOrderNumber):
-			utils.SendDomainError(w, err, http.StatusUnprocessableEntity)
-		case errors.Is(err, domain.ErrOrderCreatedByCurrentUser):
-			utils.SendDomainError(w, err, http.StatusOK)
-		case errors.Is(err, domain.ErrOrderCreatedByOtherUser):
-			utils.SendDomainError(w, err, http.StatusConflict)
-		default:
-			domain.SendError(w, err)
-		}
+		domain.SendError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusAccepted)
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"errors"
 	"net/http"
 
 	"github.com/k-zavarnitsyn/gophermart/internal/utils"
@@ -17,11 +16,7 @@ func (s *gophermartServer) Register(w http.ResponseWriter, r *http.Request) {
 	}
 	user, err := s.gophermart.Register(r.Context(), reqData)
 	if err != nil {
-		if errors.Is(err, domain.ErrLoginExists) {
-			utils.SendDomainError(w, err, http.StatusConflict)
-		} else {
-			domain.SendError(w, err, "error registering user")
-		}
+		domain.SendError(w, err, "error registering user")
 		return
 	}
 
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+func (s *gophermartServer) Transfer(w http.ResponseWriter, r *http.Request) {
+	clientData := auth.FromContext(r.Context())
+	reqData, err := utils.ReadJSON[entity.TransferRequest](r.Body)
+	if err != nil {
+		utils.SendBadRequest(w, err, "error reading transfer request json")
+		return
+	}
+	transfer, err := s.gophermart.Transfer(r.Context(), clientData.UserID, r.Header.Get(utils.IdempotencyKeyHeader), reqData)
+	if err != nil {
+		domain.SendError(w, err)
+		return
+	}
+
+	utils.SendResponse(w, entity.TransferResponse{
+		ID:        transfer.ID,
+		Direction: entity.TransferDirectionOut,
+		Login:     reqData.Login,
+		Amount:    transfer.Amount,
+		CreatedAt: transfer.CreatedAt,
+	}, http.StatusOK)
+}
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"errors"
 	"net/http"
 
 	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
@@ -23,14 +22,7 @@ func (s *gophermartServer) Withdraw(w http.ResponseWriter, r *http.Request) {
 		Value:       reqData.Sum,
 	})
 	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrNotEnoughAccruals):
-			utils.SendDomainError(w, err, http.StatusPaymentRequired)
-		case errors.Is(err, domain.ErrBadOrderNumber):
-			utils.SendDomainError(w, err, http.StatusUnprocessableEntity)
-		default:
-			domain.SendError(w, err)
-		}
+		domain.SendError(w, err)
 		return
 	}
 }
@@ -4,19 +4,30 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/gofrs/uuid"
 	"github.com/k-zavarnitsyn/gophermart/internal/api"
 	"github.com/k-zavarnitsyn/gophermart/internal/config"
 	"github.com/k-zavarnitsyn/gophermart/internal/container"
+	"github.com/k-zavarnitsyn/gophermart/internal/grpcapi"
 	"github.com/k-zavarnitsyn/gophermart/internal/middleware"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/eventbus"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
 )
 
+// schedulerLogin автор фоновых задач в журнале аудита
+const schedulerLogin = "gophermart:scheduler"
+
 type ServerApp struct {
 	cfg *config.Config
 	cnt *container.Container
@@ -35,18 +46,27 @@ func (s *ServerApp) Run(ctx context.Context) {
 	log.SetLevel(s.cfg.Log.Level)
 	log.SetReportCaller(s.cfg.Log.WithReportCaller)
 
-	if s.cfg.UseDB() {
-		defined, err := s.cnt.SchemaCreator().SchemaDefined(ctx)
-		if err != nil {
-			log.WithError(err).Fatal("failed to check DB schema")
-		}
-		if !defined {
-			if err := s.cnt.SchemaCreator().CreateSchema(ctx); err != nil {
-				log.WithError(err).Fatal("failed to create DB schema")
-			}
+	if !s.cfg.UseDB() {
+		log.Warn("DATABASE_URI is not set, using in-memory storage: data will be lost on restart")
+	}
+	defined, err := s.cnt.SchemaCreator().SchemaDefined(ctx)
+	if err != nil {
+		log.WithError(err).Fatal("failed to check DB schema")
+	}
+	if !defined {
+		if err := s.cnt.SchemaCreator().CreateSchema(ctx); err != nil {
+			log.WithError(err).Fatal("failed to create DB schema")
 		}
-	} else {
-		log.Fatal("DB is required")
+	}
+	if err := s.cnt.SchemaCreator().MigrateSchema(ctx); err != nil {
+		log.WithError(err).Fatal("failed to migrate DB schema")
+	}
+
+	jobCtx, stopJobs := context.WithCancel(ctx)
+	defer stopJobs()
+	// подписчики регистрируются до приёма запросов, иначе встроенная шина потеряет первые события
+	if err := s.cnt.SubscribeEventHandlers(jobCtx); err != nil {
+		log.WithError(err).Fatal("failed to subscribe event handlers")
 	}
 
 	serverAPI := api.New(
@@ -54,40 +74,222 @@ func (s *ServerApp) Run(ctx context.Context) {
 		s.cnt.Auth(),
 		s.cnt.Gophermart(),
 		s.cnt.Pinger(),
+		s.cnt.AccrualService(),
+		s.cnt.Elector(),
 	)
 	router := NewRouter(s.cnt)
+	router.Use(middleware.WithRequestID)
+	router.Use(middleware.NewTenant(s.cfg).WithTenant)
 	router.Use(middleware.WithGzipRequest)
 	router.Use(middleware.WithGzipResponse)
 	logger := middleware.NewLogger(&s.cfg.Log)
 	router.Use(logger.WithRequestLogging, logger.WithResponseLogging)
 	router.InitRoutes(serverAPI, true)
+	router.InitAdminRoutes(api.NewAdmin(s.cnt.Admin()), true)
 
 	server := &http.Server{
 		Addr:              s.cfg.Address,
 		Handler:           router,
 		ReadHeaderTimeout: s.cfg.Server.ReadHeaderTimeout,
 	}
-	fmt.Printf("Starting server with config: %+v\n", s.cfg)
+	fmt.Printf("Starting server with config: %+v\n", s.cfg.Masked())
 	go func() {
 		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
 			log.Errorf("server starting error: %v", err)
 		}
 		log.Println("Stopped serving new connections.")
 	}()
+	grpcServer := s.runGRPC()
+	electionDone := make(chan struct{})
+	go func() {
+		s.cnt.Elector().Run(jobCtx)
+		close(electionDone)
+	}()
+	s.runExpiryJob(jobCtx)
+	s.runReconcileJob(jobCtx)
+	s.runAccrualArchiveJob(jobCtx)
+	s.runEventCleanupJob(jobCtx)
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	<-sigChan
 
 	fmt.Println("Shutting down server...")
+	stopJobs()
+	// лидерство освобождается сразу, чтобы другая реплика подхватила задачи, не дожидаясь обрыва сессии
+	<-electionDone
 	shutdownCtx, shutdownRelease := context.WithTimeout(context.Background(), s.cfg.Server.ShutdownTimeout)
 	defer shutdownRelease()
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		log.Errorf("shutdown error: %v", err)
 	}
+	if grpcServer != nil {
+		shutdownGRPC(shutdownCtx, grpcServer)
+	}
 	if err := s.cnt.Shutdown(shutdownCtx); err != nil {
 		log.Errorf("container shutdown error: %v", err)
 	}
 
 	fmt.Println("Shutdown complete")
 }
+
+func (s *ServerApp) runGRPC() *grpc.Server {
+	if !s.cfg.UseGRPC() {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", s.cfg.GRPC.Address)
+	if err != nil {
+		log.WithError(err).Fatal("failed to listen gRPC address")
+	}
+	server := grpcapi.NewServer(s.cfg, s.cnt.Auth(), s.cnt.UserRepo(), s.cnt.Gophermart())
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Errorf("gRPC server starting error: %v", err)
+		}
+		log.Println("Stopped serving new gRPC connections.")
+	}()
+
+	return server
+}
+
+// runExpiryJob периодически списывает сгоревшие баллы, если сгорание включено
+func (s *ServerApp) runExpiryJob(ctx context.Context) {
+	if s.cfg.Expiry.JobInterval <= 0 || !domain.NewExpiryPolicy(&s.cfg.Expiry).Enabled() {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.cfg.Expiry.JobInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if !s.cnt.Elector().IsLeader() {
+					continue
+				}
+				for _, id := range s.cfg.TenantIDs() {
+					booked, err := s.cnt.PointsExpirer().ExpirePoints(tenant.ToContext(ctx, id))
+					if err != nil {
+						log.WithError(err).WithField("tenant", id).Error("Points expiry job failed")
+					} else if booked > 0 {
+						log.WithField("tenant", id).WithField("booked", booked).Info("Points expired")
+					}
+				}
+			}
+		}
+	}()
+}
+
+// runReconcileJob периодически сверяет балансы на лидере и пишет расхождения в лог
+func (s *ServerApp) runReconcileJob(ctx context.Context) {
+	if s.cfg.Reconcile.JobInterval <= 0 {
+		return
+	}
+
+	actor := &entity.Actor{ID: uuid.Nil, Login: schedulerLogin, Role: entity.UserRoleAdmin}
+	go func() {
+		ticker := time.NewTicker(s.cfg.Reconcile.JobInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if !s.cnt.Elector().IsLeader() {
+					continue
+				}
+				for _, id := range s.cfg.TenantIDs() {
+					reports, err := s.cnt.Admin().ReconcileBalances(tenant.ToContext(ctx, id), actor)
+					if err != nil {
+						log.WithError(err).WithField("tenant", id).Error("Reconcile job failed")
+					}
+					for _, report := range reports {
+						log.WithField("tenant", id).WithField("login", report.Login).WithField("problems", report.Problems).
+							Warn("Balance discrepancy")
+					}
+				}
+			}
+		}
+	}()
+}
+
+// runAccrualArchiveJob периодически удаляет на лидере ответы системы расчёта старше срока хранения.
+// Работает и с выключенным архивом: ответы в карантин сохраняются всегда.
+func (s *ServerApp) runAccrualArchiveJob(ctx context.Context) {
+	cfg := &s.cfg.Accrual.Archive
+	if cfg.Retention <= 0 || cfg.JobInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(cfg.JobInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if !s.cnt.Elector().IsLeader() {
+					continue
+				}
+				for _, id := range s.cfg.TenantIDs() {
+					deleted, err := s.cnt.AccrualArchive().DeleteBefore(tenant.ToContext(ctx, id), time.Now().Add(-cfg.Retention))
+					if err != nil {
+						log.WithError(err).WithField("tenant", id).Error("Accrual archive cleanup failed")
+					} else if deleted > 0 {
+						log.WithField("tenant", id).WithField("deleted", deleted).Info("Old accrual responses deleted")
+					}
+				}
+			}
+		}
+	}()
+}
+
+// shutdownGRPC дожидается завершения активных вызовов, но не дольше, чем позволяет ctx
+func shutdownGRPC(ctx context.Context, server *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Errorf("gRPC shutdown error: %v", ctx.Err())
+		server.Stop()
+	}
+}
+
+// runEventCleanupJob периодически удаляет на лидере события, доставленные всем группам, старше срока хранения
+func (s *ServerApp) runEventCleanupJob(ctx context.Context) {
+	cfg := &s.cfg.EventBus
+	cleaner, ok := s.cnt.EventBus().(eventbus.Cleaner)
+	if !ok || cfg.Retention <= 0 || cfg.JobInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(cfg.JobInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if !s.cnt.Elector().IsLeader() {
+					continue
+				}
+				deleted, err := cleaner.DeleteDelivered(ctx, time.Now().Add(-cfg.Retention))
+				if err != nil {
+					log.WithError(err).Error("Event cleanup failed")
+				} else if deleted > 0 {
+					log.WithField("deleted", deleted).Info("Delivered events deleted")
+				}
+			}
+		}
+	}()
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/k-zavarnitsyn/gophermart/internal"
 	"github.com/k-zavarnitsyn/gophermart/internal/container"
 	"github.com/k-zavarnitsyn/gophermart/internal/middleware"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
 )
 
 type Router struct {
@@ -22,19 +23,74 @@ func NewRouter(cnt *container.Container) *Router {
 
 func (r *Router) InitRoutes(a internal.API, withMiddlewares bool) {
 	r.Get("/ping", a.Healthcheck)
+	r.Get("/ready", a.Readiness)
 	r.Post("/api/user/register", a.Register)
 	r.Post("/api/user/login", a.Login)
 
 	r.Group(func(router chi.Router) {
 		if withMiddlewares {
-			authMiddleware := middleware.NewAuth(r.cnt.Auth())
+			authMiddleware := middleware.NewAuth(r.cnt.Auth(), r.cnt.UserRepo())
 			router.Use(authMiddleware.WithAuthentication)
 		}
 
+		router.Post("/api/user/password", a.ChangePassword)
+		router.Delete("/api/user", a.DeleteUser)
 		router.Post("/api/user/orders", a.PostOrder)
 		router.Get("/api/user/orders", a.GetOrders)
+		router.Get("/api/user/orders/{number}", a.GetOrder)
 		router.Get("/api/user/balance", a.GetBalance)
 		router.Post("/api/user/balance/withdraw", a.Withdraw)
 		router.Get("/api/user/withdrawals", a.GetWithdrawals)
+		router.Post("/api/user/balance/transfer", a.Transfer)
+		router.Get("/api/user/transfers", a.GetTransfers)
+		router.Get("/api/user/expirations", a.GetExpirations)
+		router.Get("/api/user/tier", a.GetTier)
+		router.Get("/api/user/referrals", a.GetReferrals)
+		router.Get("/api/user/statement", a.GetStatement)
+	})
+}
+
+func (r *Router) InitAdminRoutes(a internal.AdminAPI, withMiddlewares bool) {
+	r.Route("/api/admin", func(router chi.Router) {
+		authMiddleware := middleware.NewAuth(r.cnt.Auth(), r.cnt.UserRepo())
+		if withMiddlewares {
+			router.Use(authMiddleware.WithServiceAuthentication)
+			router.Use(authMiddleware.WithAuthentication)
+		}
+
+		router.Group(func(router chi.Router) {
+			if withMiddlewares {
+				router.Use(authMiddleware.WithRole(entity.UserRoleSupport, entity.UserRoleAdmin))
+			}
+			router.Get("/users/{login}", a.FindUser)
+			router.Get("/users/{login}/orders", a.GetUserOrders)
+			router.Get("/users/{login}/withdrawals", a.GetUserWithdrawals)
+			router.Post("/users/{login}/block", a.BlockUser)
+			router.Post("/users/{login}/unblock", a.UnblockUser)
+			router.Post("/orders/{number}/accrual", a.RetriggerAccrual)
+			router.Get("/audit", a.GetAuditLog)
+			router.Get("/accrual/queue", a.GetAccrualQueueStats)
+			router.Get("/accrual/quarantine", a.GetQuarantinedAccrualResponses)
+			router.Get("/orders/{number}/accrual/responses", a.GetOrderAccrualResponses)
+			router.Get("/campaigns", a.ListCampaigns)
+			router.Get("/adjustments/review", a.GetAdjustmentsForReview)
+			router.Group(func(router chi.Router) {
+				if withMiddlewares {
+					router.Use(authMiddleware.WithRole(entity.UserRoleAdmin))
+				}
+				router.Post("/users/{login}/balance/adjustments", a.AdjustBalance)
+				router.Post("/campaigns", a.CreateCampaign)
+				router.Put("/campaigns/{id}", a.UpdateCampaign)
+				router.Delete("/campaigns/{id}", a.DeleteCampaign)
+				router.Post("/adjustments/{id}/reviewed", a.MarkAdjustmentReviewed)
+			})
+		})
+		// возвраты доступны администраторам и доверенным сервисам магазина
+		router.Group(func(router chi.Router) {
+			if withMiddlewares {
+				router.Use(authMiddleware.WithRole(entity.UserRoleAdmin, entity.UserRoleService))
+			}
+			router.Post("/withdrawals/{number}/refund", a.RefundWithdrawal)
+		})
 	})
 }
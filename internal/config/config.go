@@ -6,12 +6,14 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/caarlos0/env/v10"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
@@ -46,6 +48,12 @@ var Default = &Config{
 		Leeway:          time.Second * 10,
 		ValidMethods:    []string{"ES256"},
 		CookieName:      "access_token",
+		Policy: CredentialsPolicy{
+			LoginMinLength:    1,
+			LoginMaxLength:    64,
+			LoginPattern:      `^[^\s\p{C}]+$`,
+			PasswordMinLength: 1,
+		},
 	},
 	Accrual: Accrual{
 		MaxActiveWorkers:    100,
@@ -53,6 +61,58 @@ var Default = &Config{
 		OverloadReportRPS:   50,
 		PollingInterval:     time.Second,
 		PollingCount:        1000,
+		CircuitBreaker: CircuitBreaker{
+			FailureThreshold: 5,
+			ProbeInterval:    10 * time.Second,
+		},
+		Reverify: Reverify{
+			Interval:  24 * time.Hour,
+			BatchSize: 100,
+		},
+		MinActiveWorkers: 4,
+		AdaptiveLimit: AdaptiveLimit{
+			Enabled:          true,
+			InitialLimit:     20,
+			LatencyThreshold: 2 * time.Second,
+			Backoff:          0.9,
+		},
+		Queue: AccrualQueue{
+			Size:        1000,
+			Overflow:    "reject",
+			TaskTimeout: 30 * time.Second,
+		},
+		Archive: AccrualArchive{
+			Enabled:     true,
+			Retention:   180 * 24 * time.Hour,
+			JobInterval: time.Hour,
+		},
+	},
+	GRPC: GRPC{
+		WatchInterval: time.Second,
+	},
+	Expiry: Expiry{
+		NotifyBefore: 30 * 24 * time.Hour,
+		JobInterval:  time.Hour,
+	},
+	Loyalty: Loyalty{
+		WindowMonths: 12,
+	},
+	OrderNumbers: OrderNumberValidator{
+		Type: "luhn",
+	},
+	TenantHeader: "X-Tenant-ID",
+	Leader: Leader{
+		RenewInterval: 2 * time.Second,
+		RetryInterval: 2 * time.Second,
+	},
+	EventBus: EventBus{
+		MaxAttempts:  10,
+		RetryDelay:   time.Second,
+		PollInterval: time.Second,
+		BatchSize:    100,
+		Lease:        time.Minute,
+		Retention:    7 * 24 * time.Hour,
+		JobInterval:  time.Hour,
 	},
 }
 
@@ -66,10 +126,24 @@ type Config struct {
 	Scheme  string `yaml:"scheme"`
 	Address string `env:"RUN_ADDRESS,expand"`
 
-	Log     Log     `yaml:"log"`
-	Server  Server  `yaml:"server"`
-	Auth    Auth    `yaml:"auth"`
-	Accrual Accrual `yaml:"accrual"`
+	Log      Log      `yaml:"log"`
+	Server   Server   `yaml:"server"`
+	Auth     Auth     `yaml:"auth"`
+	Accrual  Accrual  `yaml:"accrual"`
+	GRPC     GRPC     `yaml:"grpc"`
+	Expiry   Expiry   `yaml:"expiry"`
+	Loyalty  Loyalty  `yaml:"loyalty"`
+	Referral Referral `yaml:"referral"`
+	Transfer Transfer `yaml:"transfer"`
+	// OrderNumbers правило проверки номеров заказов и списаний
+	OrderNumbers OrderNumberValidator `yaml:"orderNumbers"`
+	Leader       Leader               `yaml:"leader"`
+	EventBus     EventBus             `yaml:"eventBus"`
+	Reconcile    Reconcile            `yaml:"reconcile"`
+	// Tenants магазины с отдельными программами лояльности, пустой список - один магазин tenant.Default
+	Tenants []Tenant `yaml:"tenants"`
+	// TenantHeader заголовок с ID тенанта для запросов, хост которых не привязан к тенанту
+	TenantHeader string `yaml:"tenantHeader"`
 
 	baseDir string
 }
@@ -95,15 +169,208 @@ type Auth struct {
 	Leeway          time.Duration
 	ValidMethods    []string
 	CookieName      string
+	Policy          CredentialsPolicy `yaml:"policy"`
+	// ServiceTokens токены доверенных сервисов по имени сервиса, передаются в заголовке Authorization: Bearer.
+	// В переменной окружения задаются как "name:token,name2:token2".
+	ServiceTokens map[string]string `yaml:"serviceTokens" env:"SERVICE_TOKENS"`
+}
+
+// CredentialsPolicy правила для логинов и паролей при регистрации и смене пароля.
+// LoginMaxLength не должен превышать размер колонки "user".login, PasswordMinClasses - сколько классов символов
+// (строчные, заглавные, цифры, прочие) должно быть в пароле, PasswordBlocklistFile - файл со скомпрометированными
+// паролями, по одному на строку.
+type CredentialsPolicy struct {
+	LoginMinLength        int    `yaml:"loginMinLength"`
+	LoginMaxLength        int    `yaml:"loginMaxLength"`
+	LoginPattern          string `yaml:"loginPattern"`
+	PasswordMinLength     int    `yaml:"passwordMinLength"`
+	PasswordMinClasses    int    `yaml:"passwordMinClasses"`
+	PasswordBlocklistFile string `yaml:"passwordBlocklistFile" env:"PASSWORD_BLOCKLIST_FILE"`
 }
 
 type Accrual struct {
-	AccrualSystemAddress string        `yaml:"accrualSystemAddress" env:"ACCRUAL_SYSTEM_ADDRESS"`
-	MaxActiveWorkers     int           `yaml:"maxActiveWorkers" env:"MAX_ACTIVE_WORKERS"`
-	OverloadReportCount  int           `yaml:"overloadReportCount"`
-	OverloadReportRPS    float64       `yaml:"overloadReportRps"`
-	PollingInterval      time.Duration `yaml:"pollingInterval"`
-	PollingCount         int           `yaml:"pollingCount"`
+	AccrualSystemAddress string         `yaml:"accrualSystemAddress" env:"ACCRUAL_SYSTEM_ADDRESS"`
+	MaxActiveWorkers     int            `yaml:"maxActiveWorkers" env:"MAX_ACTIVE_WORKERS"`
+	OverloadReportCount  int            `yaml:"overloadReportCount"`
+	OverloadReportRPS    float64        `yaml:"overloadReportRps"`
+	PollingInterval      time.Duration  `yaml:"pollingInterval"`
+	PollingCount         int            `yaml:"pollingCount"`
+	CircuitBreaker       CircuitBreaker `yaml:"circuitBreaker"`
+	Reverify             Reverify       `yaml:"reverify"`
+	// MinActiveWorkers нижняя граница адаптивного предела, верхняя - MaxActiveWorkers
+	MinActiveWorkers int            `yaml:"minActiveWorkers"`
+	AdaptiveLimit    AdaptiveLimit  `yaml:"adaptiveLimit"`
+	Queue            AccrualQueue   `yaml:"queue"`
+	Archive          AccrualArchive `yaml:"archive"`
+}
+
+// AccrualArchive хранение исходных ответов системы расчёта по заказам для разбора споров, включая ответы,
+// не прошедшие проверку. Ответы старше Retention удаляются задачей раз в JobInterval, нулевой Retention - хранятся
+// бессрочно. Без Enabled сохраняются только ответы, не прошедшие проверку: они не применяются к заказам и попадают в карантин.
+type AccrualArchive struct {
+	Enabled     bool          `yaml:"enabled" env:"ACCRUAL_ARCHIVE"`
+	Retention   time.Duration `yaml:"retention" env:"ACCRUAL_ARCHIVE_RETENTION"`
+	JobInterval time.Duration `yaml:"jobInterval"`
+}
+
+// AccrualQueue очередь заказов, ожидающих свободного обработчика: новые заказы забираются раньше
+// подобранных опросом по таймеру. При заполненной очереди Overflow "reject" отклоняет новый заказ,
+// "drop_oldest" вытесняет самый старый; такие заказы подберёт следующий опрос.
+// TaskTimeout ограничивает обработку одного заказа, 0 - без ограничения.
+type AccrualQueue struct {
+	Size        int           `yaml:"size" env:"ACCRUAL_QUEUE_SIZE"`
+	Overflow    string        `yaml:"overflow"`
+	TaskTimeout time.Duration `yaml:"taskTimeout"`
+}
+
+// AdaptiveLimit подстройка числа одновременных запросов к системе расчёта (AIMD): успешный ответ быстрее
+// LatencyThreshold увеличивает предел, отказ, 429 или медленный ответ умножает его на Backoff.
+// Без Enabled предел постоянный и равен MaxActiveWorkers.
+type AdaptiveLimit struct {
+	Enabled          bool          `yaml:"enabled" env:"ACCRUAL_ADAPTIVE_LIMIT"`
+	InitialLimit     int           `yaml:"initialLimit"`
+	LatencyThreshold time.Duration `yaml:"latencyThreshold"`
+	Backoff          float64       `yaml:"backoff"`
+}
+
+// Reverify повторная сверка заказов в PROCESSED, рассчитанных не раньше WindowDays дней назад: каждый заказ
+// запрашивается в системе расчёта не чаще раза в Interval, не больше BatchSize заказов за проход.
+// Нулевой WindowDays отключает сверку.
+type Reverify struct {
+	WindowDays int           `yaml:"windowDays"`
+	Interval   time.Duration `yaml:"interval"`
+	BatchSize  int           `yaml:"batchSize"`
+}
+
+// CircuitBreaker размыкается после FailureThreshold подряд неудачных запросов к системе расчёта
+// и через ProbeInterval пропускает один пробный запрос. FailureThreshold 0 отключает breaker.
+type CircuitBreaker struct {
+	FailureThreshold int           `yaml:"failureThreshold" env:"ACCRUAL_FAILURE_THRESHOLD"`
+	ProbeInterval    time.Duration `yaml:"probeInterval" env:"ACCRUAL_PROBE_INTERVAL"`
+}
+
+type GRPC struct {
+	// Address адрес gRPC-сервера, пустое значение отключает сервер
+	Address       string        `yaml:"address" env:"GRPC_ADDRESS"`
+	WatchInterval time.Duration `yaml:"watchInterval"`
+}
+
+// Expiry правила сгорания баллов. Начисление по заказу сгорает через LifetimeMonths месяцев и LifetimeDays дней
+// после расчёта, при RoundToMonthEnd - в конце этого месяца. Нулевой срок отключает сгорание.
+type Expiry struct {
+	LifetimeMonths  int  `yaml:"lifetimeMonths" env:"EXPIRY_LIFETIME_MONTHS"`
+	LifetimeDays    int  `yaml:"lifetimeDays"`
+	RoundToMonthEnd bool `yaml:"roundToMonthEnd"`
+	// NotifyBefore за сколько до сгорания баллы показываются в балансе как скоро сгорающие
+	NotifyBefore time.Duration `yaml:"notifyBefore"`
+	// JobInterval период фоновой задачи, списывающей сгоревшие баллы
+	JobInterval time.Duration `yaml:"jobInterval"`
+}
+
+// Loyalty уровни программы лояльности. Уровень определяется суммой начислений за последние WindowMonths месяцев,
+// пустой список уровней отключает повышающие коэффициенты.
+type Loyalty struct {
+	WindowMonths int    `yaml:"windowMonths"`
+	Tiers        []Tier `yaml:"tiers"`
+}
+
+type Tier struct {
+	Name string `yaml:"name"`
+	// Threshold минимальная сумма начислений за окно для получения уровня
+	Threshold float64 `yaml:"threshold"`
+	// Multiplier коэффициент к начислению системы расчёта, 1 - без бонуса
+	Multiplier float64 `yaml:"multiplier"`
+}
+
+// Referral реферальная программа. Бонусы начисляются обоим, когда первый заказ приглашённого переходит в PROCESSED.
+type Referral struct {
+	ReferrerBonus float64 `yaml:"referrerBonus"`
+	RefereeBonus  float64 `yaml:"refereeBonus"`
+	// MaxPerReferrer сколько приглашений приносят бонус пригласившему, 0 - без ограничения.
+	// Приглашённый получает свой бонус и сверх ограничения.
+	MaxPerReferrer int `yaml:"maxPerReferrer"`
+}
+
+// Transfer ограничения переводов баллов между пользователями за календарный день отправителя, 0 - без ограничения
+type Transfer struct {
+	DailyAmount float64 `yaml:"dailyAmount"`
+	DailyCount  int     `yaml:"dailyCount"`
+}
+
+// Leader выбор лидера среди реплик: только лидер опрашивает систему расчёта и запускает фоновые задачи.
+// Instance по умолчанию hostname:pid.
+type Leader struct {
+	Instance      string        `yaml:"instance" env:"INSTANCE_ID"`
+	RenewInterval time.Duration `yaml:"renewInterval"`
+	RetryInterval time.Duration `yaml:"retryInterval"`
+}
+
+// EventBus доставка доменных событий. Обработчик, вернувший ошибку, получит событие повторно через RetryDelay,
+// после MaxAttempts попыток событие откладывается с ошибкой в логе. PollInterval, BatchSize и Lease относятся
+// к хранению событий в Postgres: Lease - время на обработку, после которого событие доставляется снова.
+// События, подтверждённые всеми группами, удаляются через Retention задачей раз в JobInterval, нулевой Retention
+// или JobInterval отключает удаление.
+type EventBus struct {
+	MaxAttempts  int           `yaml:"maxAttempts"`
+	RetryDelay   time.Duration `yaml:"retryDelay"`
+	PollInterval time.Duration `yaml:"pollInterval"`
+	BatchSize    int           `yaml:"batchSize"`
+	Lease        time.Duration `yaml:"lease"`
+	Retention    time.Duration `yaml:"retention"`
+	JobInterval  time.Duration `yaml:"jobInterval"`
+}
+
+// Reconcile периодическая сверка балансов, нулевой JobInterval отключает задачу
+type Reconcile struct {
+	JobInterval time.Duration `yaml:"jobInterval"`
+}
+
+// OrderNumberValidator правило проверки номера заказа: luhn, gtin (EAN-8/13, UPC, GTIN-14 с контрольной цифрой mod-10),
+// regex по Pattern или any - номер проходит, если подходит хотя бы под одно из правил AnyOf
+type OrderNumberValidator struct {
+	Type    string                 `yaml:"type"`
+	Pattern string                 `yaml:"pattern"`
+	AnyOf   []OrderNumberValidator `yaml:"anyOf"`
+}
+
+// Tenant магазин на общем развёртывании. Запрос относится к тенанту по хосту из Hosts, заголовку TenantHeader
+// или тенанту в токене пользователя. Незаданные поля берутся из общих настроек. Логины общие для всех тенантов,
+// номера заказов уникальны в пределах тенанта. Данные, созданные до появления тенантов, относятся к tenant.Default.
+type Tenant struct {
+	ID                   string                `yaml:"id"`
+	Hosts                []string              `yaml:"hosts"`
+	AccrualSystemAddress string                `yaml:"accrualSystemAddress"`
+	OrderNumbers         *OrderNumberValidator `yaml:"orderNumbers"`
+	Transfer             *Transfer             `yaml:"transfer"`
+}
+
+// Tenant настройки тенанта id, nil - тенант не настроен
+func (c *Config) Tenant(id string) *Tenant {
+	for i := range c.Tenants {
+		if c.Tenants[i].ID == id {
+			return &c.Tenants[i]
+		}
+	}
+
+	return nil
+}
+
+// TenantIDs тенанты развёртывания, без настроенных тенантов - только tenant.Default
+func (c *Config) TenantIDs() []string {
+	if len(c.Tenants) == 0 {
+		return []string{tenant.Default}
+	}
+	ids := make([]string, len(c.Tenants))
+	for i := range c.Tenants {
+		ids[i] = c.Tenants[i].ID
+	}
+
+	return ids
+}
+
+// HasTenant проверяет, что тенант id есть в развёртывании
+func (c *Config) HasTenant(id string) bool {
+	return slices.Contains(c.TenantIDs(), id)
 }
 
 func LoadYaml(dir string) (*Config, error) {
@@ -206,8 +473,17 @@ func (c *Config) UseDB() bool {
 	return c.Server.DatabaseURI != ""
 }
 
+func (c *Config) UseGRPC() bool {
+	return c.GRPC.Address != ""
+}
+
 func WithServerFlags() Option {
 	return func(c *Config) error {
+		flag.Func("g", "gRPC-server endpoint address (host:port)", func(flagValue string) error {
+			c.GRPC.Address = flagValue
+
+			return nil
+		})
 		flag.Func("d", fmt.Sprintf("Database URI [default:%s]", DefaultDSN), func(flagValue string) error {
 			c.Server.DatabaseURI = flagValue
 
@@ -2,29 +2,50 @@ package container
 
 import (
 	"context"
+	"fmt"
+	"os"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/memstore"
 	"github.com/k-zavarnitsyn/gophermart/internal/pg"
 	"github.com/k-zavarnitsyn/gophermart/internal/services/accrual"
 	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/eventbus"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/leader"
 	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
 	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
 	log "github.com/sirupsen/logrus"
 )
 
 type Container struct {
-	cfg *config.Config
-	db  *pg.Pool
-	trx pg.Transactor
+	cfg      *config.Config
+	db       *pg.Pool
+	memStore *memstore.Store
+	trx      pg.Transactor
 
 	auth              *auth.Service
 	accrualService    accrual.Service
 	gophermartService domain.Gophermart
+	credentialsPolicy *domain.CredentialsPolicy
+	orderValidators   *domain.TenantValidators
+	adminService      domain.Admin
+	pointsExpirer     domain.PointsExpirer
+	loyalty           domain.Loyalty
+	campaignEngine    domain.CampaignEngine
+	referralProgram   domain.ReferralProgram
+	accrualCorrector  domain.AccrualCorrector
+	elector           leader.Elector
+	eventBus          eventbus.Bus
 
-	utilityRepo *pg.UtilityRepository
-	orderRepo   repository.Order
-	userRepo    repository.User
+	utilityRepo    utilityRepository
+	orderRepo      repository.Order
+	userRepo       repository.User
+	auditRepo      repository.Audit
+	campaignRepo   repository.Campaign
+	referralRepo   repository.Referral
+	accrualArchive repository.AccrualArchive
 }
 
 func New(cfg *config.Config) *Container {
@@ -58,7 +79,12 @@ func (c *Container) DB() *pg.Pool {
 
 func (c *Container) Transactor() pg.Transactor {
 	if c.trx == nil {
-		c.trx = pg.NewTransactor(c.DB())
+		if c.cfg.UseDB() {
+			c.trx = pg.NewTransactor(c.DB())
+		} else {
+			// события встроенной шины доставляются только после фиксации транзакции
+			c.trx = c.EventBus().(*eventbus.InProcess).Transactional(memstore.NewTransactor(c.MemStore()))
+		}
 	}
 
 	return c.trx
@@ -74,20 +100,175 @@ func (c *Container) Auth() *auth.Service {
 
 func (c *Container) Gophermart() domain.Gophermart {
 	if c.gophermartService == nil {
-		c.gophermartService = domain.NewGophermart(c.cfg, c.Transactor(), c.AccrualService(), c.OrderRepo(), c.UserRepo())
+		c.gophermartService = domain.NewGophermart(
+			c.cfg,
+			c.Transactor(),
+			c.EventBus(),
+			c.CredentialsPolicy(),
+			c.OrderNumberValidators(),
+			c.OrderRepo(),
+			c.UserRepo(),
+			c.ReferralRepo(),
+		)
 	}
 
 	return c.gophermartService
 }
 
+func (c *Container) CredentialsPolicy() *domain.CredentialsPolicy {
+	if c.credentialsPolicy == nil {
+		var err error
+		c.credentialsPolicy, err = domain.NewCredentialsPolicy(&c.cfg.Auth.Policy)
+		if err != nil {
+			log.WithError(err).Fatal("failed to load credentials policy")
+		}
+	}
+
+	return c.credentialsPolicy
+}
+
+func (c *Container) OrderNumberValidators() *domain.TenantValidators {
+	if c.orderValidators == nil {
+		var err error
+		c.orderValidators, err = domain.NewTenantValidators(c.cfg)
+		if err != nil {
+			log.WithError(err).Fatal("failed to load order number validator")
+		}
+	}
+
+	return c.orderValidators
+}
+
+func (c *Container) Admin() domain.Admin {
+	if c.adminService == nil {
+		c.adminService = domain.NewAdmin(
+			c.cfg,
+			c.Transactor(),
+			c.Gophermart(),
+			c.AccrualService(),
+			c.CredentialsPolicy(),
+			c.OrderRepo(),
+			c.UserRepo(),
+			c.AuditRepo(),
+			c.CampaignRepo(),
+			c.AccrualArchive(),
+		)
+	}
+
+	return c.adminService
+}
+
+func (c *Container) PointsExpirer() domain.PointsExpirer {
+	if c.pointsExpirer == nil {
+		c.pointsExpirer = domain.NewPointsExpirer(c.cfg, c.Transactor(), c.OrderRepo(), c.UserRepo())
+	}
+
+	return c.pointsExpirer
+}
+
+func (c *Container) Loyalty() domain.Loyalty {
+	if c.loyalty == nil {
+		c.loyalty = domain.NewLoyalty(c.cfg, c.OrderRepo(), c.UserRepo())
+	}
+
+	return c.loyalty
+}
+
+func (c *Container) CampaignEngine() domain.CampaignEngine {
+	if c.campaignEngine == nil {
+		c.campaignEngine = domain.NewCampaignEngine(c.OrderRepo(), c.UserRepo(), c.CampaignRepo())
+	}
+
+	return c.campaignEngine
+}
+
+func (c *Container) ReferralProgram() domain.ReferralProgram {
+	if c.referralProgram == nil {
+		c.referralProgram = domain.NewReferralProgram(c.cfg, c.OrderRepo(), c.UserRepo(), c.ReferralRepo())
+	}
+
+	return c.referralProgram
+}
+
+func (c *Container) AccrualCorrector() domain.AccrualCorrector {
+	if c.accrualCorrector == nil {
+		c.accrualCorrector = domain.NewAccrualCorrector(c.Gophermart(), c.OrderRepo())
+	}
+
+	return c.accrualCorrector
+}
+
 func (c *Container) AccrualService() accrual.Service {
 	if c.accrualService == nil {
-		c.accrualService = accrual.NewService(&c.cfg.Accrual, c.OrderRepo())
+		c.accrualService = accrual.NewService(
+			&c.cfg.Accrual,
+			c.OrderRepo(),
+			accrual.WithProcessedHook(c.Transactor(), accrual.ProcessedHookFunc(c.Loyalty().ApplyBonus)),
+			accrual.WithEventBus(c.Transactor(), c.EventBus()),
+			accrual.WithReverification(c.Transactor(), c.AccrualCorrector()),
+			accrual.WithLeadership(c.Elector().IsLeader),
+			accrual.WithTenants(c.cfg.Tenants),
+			accrual.WithArchive(c.AccrualArchive()),
+		)
 	}
 
 	return c.accrualService
 }
 
+// EventBus шина доменных событий: в Postgres, если он используется, иначе в памяти процесса
+func (c *Container) EventBus() eventbus.Bus {
+	if c.eventBus == nil {
+		if c.cfg.UseDB() {
+			c.eventBus = pg.NewEventBus(&c.cfg.EventBus, c.DB())
+		} else {
+			c.eventBus = eventbus.NewInProcess(&c.cfg.EventBus)
+		}
+	}
+
+	return c.eventBus
+}
+
+// SubscribeEventHandlers подписывает обработчики доменных событий до отмены ctx
+func (c *Container) SubscribeEventHandlers(ctx context.Context) error {
+	if err := c.EventBus().Subscribe(ctx, entity.EventOrderUploaded, "accrual", accrual.HandleOrderUploaded(c.AccrualService())); err != nil {
+		return err
+	}
+
+	for group, h := range map[string]accrual.OrderProcessedHook{
+		"referral": c.ReferralProgram(),
+		"loyalty":  c.Loyalty(),
+		"campaign": c.CampaignEngine(),
+	} {
+		if err := c.EventBus().Subscribe(ctx, entity.EventOrderProcessed, group, domain.HandleOrderProcessed(c.Transactor(), h)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Elector выбор лидера; выборы запускаются в ServerApp.Run
+func (c *Container) Elector() leader.Elector {
+	if c.elector == nil {
+		if c.cfg.Leader.Instance == "" {
+			hostname, err := os.Hostname()
+			if err != nil {
+				log.WithError(err).Fatal("failed to get hostname for leader election")
+			}
+			c.cfg.Leader.Instance = fmt.Sprintf("%s:%d", hostname, os.Getpid())
+		}
+		var lock leader.Lock
+		if c.cfg.UseDB() {
+			lock = pg.NewLeaderLock(c.DB(), c.cfg.Leader.Instance)
+		} else {
+			lock = memstore.NewLeaderLock(c.MemStore(), c.cfg.Leader.Instance)
+		}
+		c.elector = leader.NewElector(&c.cfg.Leader, lock)
+	}
+
+	return c.elector
+}
+
 func (c *Container) SetAccrualService(s accrual.Service) *Container {
 	c.accrualService = s
 
@@ -2,13 +2,33 @@ package container
 
 import (
 	"github.com/k-zavarnitsyn/gophermart/internal"
+	"github.com/k-zavarnitsyn/gophermart/internal/memstore"
 	"github.com/k-zavarnitsyn/gophermart/internal/pg"
 	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
 )
 
-func (c *Container) getUtilityRepo() *pg.UtilityRepository {
+type utilityRepository interface {
+	internal.Pinger
+	internal.SchemaCreator
+	internal.Resetter
+}
+
+// MemStore хранилище в памяти, используется вместо Postgres, если не задан DatabaseURI
+func (c *Container) MemStore() *memstore.Store {
+	if c.memStore == nil {
+		c.memStore = memstore.New()
+	}
+
+	return c.memStore
+}
+
+func (c *Container) getUtilityRepo() utilityRepository {
 	if c.utilityRepo == nil {
-		c.utilityRepo = pg.NewUtilityRepository(c.DB())
+		if c.cfg.UseDB() {
+			c.utilityRepo = pg.NewUtilityRepository(c.DB())
+		} else {
+			c.utilityRepo = memstore.NewUtilityRepository(c.MemStore())
+		}
 	}
 
 	return c.utilityRepo
@@ -28,7 +48,11 @@ func (c *Container) Resetter() internal.Resetter {
 
 func (c *Container) OrderRepo() repository.Order {
 	if c.orderRepo == nil {
-		c.orderRepo = pg.NewOrderRepository(c.DB())
+		if c.cfg.UseDB() {
+			c.orderRepo = pg.NewOrderRepository(c.DB())
+		} else {
+			c.orderRepo = memstore.NewOrderRepository(c.MemStore())
+		}
 	}
 
 	return c.orderRepo
@@ -36,8 +60,60 @@ func (c *Container) OrderRepo() repository.Order {
 
 func (c *Container) UserRepo() repository.User {
 	if c.userRepo == nil {
-		c.userRepo = pg.NewUserRepository(c.DB())
+		if c.cfg.UseDB() {
+			c.userRepo = pg.NewUserRepository(c.DB())
+		} else {
+			c.userRepo = memstore.NewUserRepository(c.MemStore())
+		}
 	}
 
 	return c.userRepo
 }
+
+func (c *Container) AuditRepo() repository.Audit {
+	if c.auditRepo == nil {
+		if c.cfg.UseDB() {
+			c.auditRepo = pg.NewAuditRepository(c.DB())
+		} else {
+			c.auditRepo = memstore.NewAuditRepository(c.MemStore())
+		}
+	}
+
+	return c.auditRepo
+}
+
+func (c *Container) CampaignRepo() repository.Campaign {
+	if c.campaignRepo == nil {
+		if c.cfg.UseDB() {
+			c.campaignRepo = pg.NewCampaignRepository(c.DB())
+		} else {
+			c.campaignRepo = memstore.NewCampaignRepository(c.MemStore())
+		}
+	}
+
+	return c.campaignRepo
+}
+
+func (c *Container) ReferralRepo() repository.Referral {
+	if c.referralRepo == nil {
+		if c.cfg.UseDB() {
+			c.referralRepo = pg.NewReferralRepository(c.DB())
+		} else {
+			c.referralRepo = memstore.NewReferralRepository(c.MemStore())
+		}
+	}
+
+	return c.referralRepo
+}
+
+func (c *Container) AccrualArchive() repository.AccrualArchive {
+	if c.accrualArchive == nil {
+		if c.cfg.UseDB() {
+			c.accrualArchive = pg.NewAccrualArchive(c.DB())
+		} else {
+			c.accrualArchive = memstore.NewAccrualArchive(c.MemStore())
+		}
+	}
+
+	return c.accrualArchive
+}
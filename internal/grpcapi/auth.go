@@ -0,0 +1,117 @@
+package grpcapi
+
+import (
+	"context"
+	"strings"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+	pb "github.com/k-zavarnitsyn/gophermart/pkg/grpc/gophermartpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	AuthorizationKey = "authorization"
+	BearerPrefix     = "Bearer "
+)
+
+// publicMethods методы, доступные без аутентификации
+var publicMethods = map[string]struct{}{
+	pb.Gophermart_Register_FullMethodName: {},
+	pb.Gophermart_Login_FullMethodName:    {},
+}
+
+type AuthInterceptor struct {
+	authService *auth.Service
+	userRepo    repository.User
+}
+
+// NewAuthInterceptor создаёт перехватчик; при непустом userRepo блокировка и роль пользователя сверяются с БД
+func NewAuthInterceptor(authService *auth.Service, userRepo repository.User) *AuthInterceptor {
+	return &AuthInterceptor{
+		authService: authService,
+		userRepo:    userRepo,
+	}
+}
+
+func (a *AuthInterceptor) Unary(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	if _, ok := publicMethods[info.FullMethod]; ok {
+		return handler(ctx, req)
+	}
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (a *AuthInterceptor) Stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if _, ok := publicMethods[info.FullMethod]; ok {
+		return handler(srv, ss)
+	}
+	ctx, err := a.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+func (a *AuthInterceptor) authenticate(ctx context.Context) (context.Context, error) {
+	claims, err := a.authService.ParseToken(TokenFromMetadata(ctx))
+	if err != nil {
+		return nil, toStatus(err, "unable to authenticate user")
+	}
+	ctx, err = auth.WithTenant(ctx, claims)
+	if err != nil {
+		return nil, toStatus(err, "unable to authenticate user")
+	}
+	if a.userRepo != nil {
+		user, err := a.userRepo.FindByID(ctx, claims.UserID)
+		if err != nil {
+			return nil, toStatus(err, "unable to authenticate user")
+		}
+		if err := auth.VerifyUser(claims, user); err != nil {
+			return nil, toStatus(err, "unable to authenticate user")
+		}
+	}
+
+	return auth.ToContext(ctx, claims), nil
+}
+
+// TokenFromMetadata извлекает JWT из метаданных "authorization: Bearer <token>"
+func TokenFromMetadata(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, value := range md.Get(AuthorizationKey) {
+		if token, found := strings.CutPrefix(value, BearerPrefix); found {
+			return strings.TrimSpace(token)
+		}
+	}
+
+	return ""
+}
+
+// WithToken добавляет JWT в исходящие метаданные клиента
+func WithToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, AuthorizationKey, BearerPrefix+token)
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,76 @@
+package grpcapi
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+const ErrorInfoDomain = "gophermart"
+
+// toStatus переводит ошибку домена в gRPC-статус по тем же правилам, что и HTTP API.
+// Стабильный код ошибки домена передаётся в errdetails.ErrorInfo.Reason.
+func toStatus(err error, msg string) error {
+	var e *domain.Err
+	if !errors.As(err, &e) {
+		log.WithError(err).Error(msg)
+		return status.Error(codes.Internal, utils.InternalErrorDetail)
+	}
+
+	log.WithError(err).Info(msg)
+	st := status.New(grpcCode(err), fmt.Sprintf("%s: %s", msg, err.Error()))
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: domain.Code(err), Domain: ErrorInfoDomain}}
+	var verr *domain.ValidationError
+	if errors.As(err, &verr) {
+		details = append(details, badRequest(verr))
+	}
+	if withDetails, detailsErr := st.WithDetails(details...); detailsErr == nil {
+		st = withDetails
+	}
+
+	return st.Err()
+}
+
+func grpcCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, domain.ErrInvalidCredentials):
+		return codes.Unauthenticated
+	case errors.Is(err, domain.ErrBadOrderNumber):
+		return codes.InvalidArgument
+	case errors.Is(err, domain.ErrOrderNumberExists), errors.Is(err, domain.ErrLoginExists):
+		return codes.AlreadyExists
+	case errors.Is(err, domain.ErrNotEnoughAccruals):
+		return codes.FailedPrecondition
+	case errors.Is(err, domain.ErrNotFound):
+		return codes.NotFound
+	case errors.Is(err, domain.ErrAuthentication):
+		return codes.Unauthenticated
+	case errors.Is(err, domain.ErrForbidden):
+		return codes.PermissionDenied
+	default:
+		return codes.InvalidArgument
+	}
+}
+
+func badRequest(verr *domain.ValidationError) *errdetails.BadRequest {
+	result := &errdetails.BadRequest{}
+	for _, v := range verr.Violations {
+		result.FieldViolations = append(result.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Message,
+		})
+	}
+
+	return result
+}
+
+func isAlreadyUploaded(err error) bool {
+	return errors.Is(err, domain.ErrOrderCreatedByCurrentUser)
+}
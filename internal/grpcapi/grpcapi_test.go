@@ -0,0 +1,188 @@
+package grpcapi_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/grpcapi"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	pb "github.com/k-zavarnitsyn/gophermart/pkg/grpc/gophermartpb"
+	"github.com/k-zavarnitsyn/gophermart/tests/stubs"
+	"github.com/k-zavarnitsyn/gophermart/tests/testutils"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const bufSize = 1024 * 1024
+
+type TestSuite struct {
+	suite.Suite
+
+	cfg        *config.Config
+	gophermart *stubs.GophermartStub
+	server     *grpc.Server
+	conn       *grpc.ClientConn
+	client     pb.GophermartClient
+}
+
+func TestSuiteRun(t *testing.T) {
+	suite.Run(t, new(TestSuite))
+}
+
+func (s *TestSuite) SetupSuite() {
+	s.cfg = testutils.GetConfig("../../" + config.DefaultDir)
+	s.cfg.GRPC.WatchInterval = 10 * time.Millisecond
+	s.gophermart = stubs.NewGophermartStub()
+
+	listener := bufconn.Listen(bufSize)
+	s.server = grpcapi.NewServer(s.cfg, auth.New(&s.cfg.Auth), nil, s.gophermart)
+	go func() {
+		_ = s.server.Serve(listener)
+	}()
+
+	var err error
+	s.conn, err = grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	s.Require().NoError(err)
+	s.client = pb.NewGophermartClient(s.conn)
+}
+
+func (s *TestSuite) TearDownSuite() {
+	s.Require().NoError(s.conn.Close())
+	s.server.GracefulStop()
+}
+
+func (s *TestSuite) register(login string) context.Context {
+	resp, err := s.client.Register(context.Background(), &pb.RegisterRequest{Login: login, Password: "test"})
+	s.Require().NoError(err)
+	s.Require().NotEmpty(resp.GetToken())
+
+	return grpcapi.WithToken(context.Background(), resp.GetToken())
+}
+
+func (s *TestSuite) TestRegisterAndLogin() {
+	s.register("grpc-login")
+
+	_, err := s.client.Register(context.Background(), &pb.RegisterRequest{Login: "grpc-login", Password: "test"})
+	s.Require().Equal(codes.AlreadyExists, status.Code(err))
+
+	resp, err := s.client.Login(context.Background(), &pb.LoginRequest{Login: "grpc-login", Password: "test"})
+	s.Require().NoError(err)
+	s.Require().NotEmpty(resp.GetToken())
+
+	_, err = s.client.Login(context.Background(), &pb.LoginRequest{Login: "grpc-login", Password: "wrong"})
+	s.Require().Equal(codes.Unauthenticated, status.Code(err))
+}
+
+func (s *TestSuite) TestUnauthenticated() {
+	_, err := s.client.GetBalance(context.Background(), &emptypb.Empty{})
+	s.Require().Equal(codes.Unauthenticated, status.Code(err))
+
+	ctx := grpcapi.WithToken(context.Background(), "bad token")
+	_, err = s.client.GetOrders(ctx, &emptypb.Empty{})
+	s.Require().Equal(codes.Unauthenticated, status.Code(err))
+
+	stream, err := s.client.WatchOrders(context.Background(), &pb.WatchOrdersRequest{})
+	s.Require().NoError(err)
+	_, err = stream.Recv()
+	s.Require().Equal(codes.Unauthenticated, status.Code(err))
+}
+
+func (s *TestSuite) TestOrdersAndBalance() {
+	ctx := s.register("grpc-orders")
+
+	resp, err := s.client.PostOrder(ctx, &pb.PostOrderRequest{Number: "3413042486"})
+	s.Require().NoError(err)
+	s.Require().False(resp.GetAlreadyUploaded())
+	resp, err = s.client.PostOrder(ctx, &pb.PostOrderRequest{Number: "3413042486"})
+	s.Require().NoError(err)
+	s.Require().True(resp.GetAlreadyUploaded())
+
+	otherCtx := s.register("grpc-orders-other")
+	_, err = s.client.PostOrder(otherCtx, &pb.PostOrderRequest{Number: "3413042486"})
+	s.Require().Equal(codes.AlreadyExists, status.Code(err))
+
+	s.gophermart.SetOrder("3413042486", entity.OrderStatusProcessed, utils.ToPointer(50.0))
+	orders, err := s.client.GetOrders(ctx, &emptypb.Empty{})
+	s.Require().NoError(err)
+	s.Require().Len(orders.GetOrders(), 1)
+	s.Require().Equal(string(entity.OrderStatusProcessed), orders.GetOrders()[0].GetStatus())
+	s.Require().Equal(50.0, orders.GetOrders()[0].GetAccrual())
+
+	_, err = s.client.Withdraw(ctx, &pb.WithdrawRequest{Order: "2377225624", Sum: 20})
+	s.Require().NoError(err)
+	_, err = s.client.Withdraw(ctx, &pb.WithdrawRequest{Order: "2377225624", Sum: 100})
+	s.Require().Equal(codes.FailedPrecondition, status.Code(err))
+
+	balance, err := s.client.GetBalance(ctx, &emptypb.Empty{})
+	s.Require().NoError(err)
+	s.Require().Equal(30.0, balance.GetCurrent())
+	s.Require().Equal(20.0, balance.GetWithdrawn())
+
+	withdrawals, err := s.client.GetWithdrawals(ctx, &emptypb.Empty{})
+	s.Require().NoError(err)
+	s.Require().Len(withdrawals.GetWithdrawals(), 1)
+	s.Require().Equal("2377225624", withdrawals.GetWithdrawals()[0].GetOrder())
+}
+
+func (s *TestSuite) TestWatchOrders() {
+	ctx := s.register("grpc-watch")
+	_, err := s.client.PostOrder(ctx, &pb.PostOrderRequest{Number: "5798116405"})
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	stream, err := s.client.WatchOrders(ctx, &pb.WatchOrdersRequest{Numbers: []string{"5798116405"}})
+	s.Require().NoError(err)
+
+	order, err := stream.Recv()
+	s.Require().NoError(err)
+	s.Require().Equal(string(entity.OrderStatusNew), order.GetStatus())
+
+	s.gophermart.SetOrder("5798116405", entity.OrderStatusProcessing, nil)
+	order, err = stream.Recv()
+	s.Require().NoError(err)
+	s.Require().Equal(string(entity.OrderStatusProcessing), order.GetStatus())
+
+	s.gophermart.SetOrder("5798116405", entity.OrderStatusProcessed, utils.ToPointer(10.0))
+	order, err = stream.Recv()
+	s.Require().NoError(err)
+	s.Require().Equal(string(entity.OrderStatusProcessed), order.GetStatus())
+	s.Require().Equal(10.0, order.GetAccrual())
+
+	// все отслеживаемые заказы в конечном статусе - поток завершён сервером
+	_, err = stream.Recv()
+	s.Require().ErrorIs(err, io.EOF)
+}
+
+func (s *TestSuite) TestWatchUnknownOrders() {
+	ctx := s.register("grpc-watch-unknown")
+	otherCtx := s.register("grpc-watch-unknown-other")
+	_, err := s.client.PostOrder(otherCtx, &pb.PostOrderRequest{Number: "9155976989"})
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	// чужой и несуществующий номера не отслеживаются
+	for _, number := range []string{"9155976989", "1587579366"} {
+		stream, err := s.client.WatchOrders(ctx, &pb.WatchOrdersRequest{Numbers: []string{number}})
+		s.Require().NoError(err)
+		_, err = stream.Recv()
+		s.Require().Equal(codes.NotFound, status.Code(err), number)
+	}
+}
@@ -0,0 +1,172 @@
+package grpcapi
+
+import (
+	"context"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+	pb "github.com/k-zavarnitsyn/gophermart/pkg/grpc/gophermartpb"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var _ pb.GophermartServer = (*gophermartServer)(nil)
+
+type gophermartServer struct {
+	pb.UnimplementedGophermartServer
+
+	cfg        *config.Config
+	auth       *auth.Service
+	gophermart domain.Gophermart
+}
+
+// NewServer создаёт gRPC-сервер с зарегистрированным сервисом Gophermart и JWT-перехватчиками
+func NewServer(
+	cfg *config.Config,
+	authService *auth.Service,
+	userRepo repository.User,
+	service domain.Gophermart,
+	opts ...grpc.ServerOption,
+) *grpc.Server {
+	interceptor := NewAuthInterceptor(authService, userRepo)
+	tenantInterceptor := NewTenantInterceptor(cfg)
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(tenantInterceptor.Unary, interceptor.Unary),
+		grpc.ChainStreamInterceptor(tenantInterceptor.Stream, interceptor.Stream),
+	)
+	server := grpc.NewServer(opts...)
+	pb.RegisterGophermartServer(server, &gophermartServer{
+		cfg:        cfg,
+		auth:       authService,
+		gophermart: service,
+	})
+
+	return server
+}
+
+func (s *gophermartServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.AuthResponse, error) {
+	user, err := s.gophermart.Register(ctx, &entity.RegisterRequest{
+		Login:    req.GetLogin(),
+		Password: req.GetPassword(),
+	})
+	if err != nil {
+		return nil, toStatus(err, "error registering user")
+	}
+
+	return s.authResponse(user)
+}
+
+func (s *gophermartServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.AuthResponse, error) {
+	user, err := s.gophermart.Login(ctx, &entity.LoginRequest{
+		Login:    req.GetLogin(),
+		Password: req.GetPassword(),
+	})
+	if err != nil {
+		return nil, toStatus(err, "error logging in")
+	}
+
+	return s.authResponse(user)
+}
+
+func (s *gophermartServer) PostOrder(ctx context.Context, req *pb.PostOrderRequest) (*pb.PostOrderResponse, error) {
+	claims := auth.FromContext(ctx)
+	err := s.gophermart.PostOrder(ctx, &entity.Order{
+		UserID: claims.UserID,
+		Number: req.GetNumber(),
+	})
+	if err != nil {
+		if isAlreadyUploaded(err) {
+			return &pb.PostOrderResponse{AlreadyUploaded: true}, nil
+		}
+		return nil, toStatus(err, "error posting order")
+	}
+
+	return &pb.PostOrderResponse{}, nil
+}
+
+func (s *gophermartServer) GetOrders(ctx context.Context, _ *emptypb.Empty) (*pb.GetOrdersResponse, error) {
+	claims := auth.FromContext(ctx)
+	orders, err := s.gophermart.GetOrders(ctx, claims.UserID)
+	if err != nil {
+		return nil, toStatus(err, "error getting orders")
+	}
+
+	resp := &pb.GetOrdersResponse{Orders: make([]*pb.Order, len(orders))}
+	for i := range orders {
+		resp.Orders[i] = toOrder(&orders[i])
+	}
+
+	return resp, nil
+}
+
+func (s *gophermartServer) GetBalance(ctx context.Context, _ *emptypb.Empty) (*pb.Balance, error) {
+	claims := auth.FromContext(ctx)
+	balance, err := s.gophermart.GetBalance(ctx, claims.UserID)
+	if err != nil {
+		return nil, toStatus(err, "error getting balance")
+	}
+
+	return &pb.Balance{
+		Current:   balance.Current,
+		Withdrawn: balance.Withdrawn,
+	}, nil
+}
+
+func (s *gophermartServer) Withdraw(ctx context.Context, req *pb.WithdrawRequest) (*emptypb.Empty, error) {
+	claims := auth.FromContext(ctx)
+	err := s.gophermart.Withdraw(ctx, &entity.Withdraw{
+		UserID:      claims.UserID,
+		OrderNumber: req.GetOrder(),
+		Value:       req.GetSum(),
+	})
+	if err != nil {
+		return nil, toStatus(err, "error withdrawing")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *gophermartServer) GetWithdrawals(ctx context.Context, _ *emptypb.Empty) (*pb.GetWithdrawalsResponse, error) {
+	claims := auth.FromContext(ctx)
+	withdrawals, err := s.gophermart.GetWithdrawals(ctx, claims.UserID)
+	if err != nil {
+		return nil, toStatus(err, "error getting withdrawals")
+	}
+
+	resp := &pb.GetWithdrawalsResponse{Withdrawals: make([]*pb.Withdrawal, len(withdrawals))}
+	for i, w := range withdrawals {
+		resp.Withdrawals[i] = &pb.Withdrawal{
+			Order:       w.OrderNumber,
+			Sum:         w.Value,
+			ProcessedAt: timestamppb.New(w.CreatedAt),
+		}
+	}
+
+	return resp, nil
+}
+
+func (s *gophermartServer) authResponse(user *entity.User) (*pb.AuthResponse, error) {
+	claims := s.auth.NewClaims(user)
+	token, err := s.auth.CreateToken(claims)
+	if err != nil {
+		return nil, toStatus(err, "error creating token")
+	}
+
+	return &pb.AuthResponse{
+		Token:     token,
+		ExpiresAt: timestamppb.New(claims.ExpiresAt.Time),
+	}, nil
+}
+
+func toOrder(o *entity.Order) *pb.Order {
+	return &pb.Order{
+		Number:     o.Number,
+		Status:     string(o.Status),
+		Accrual:    o.Accrual,
+		UploadedAt: timestamppb.New(o.CreatedAt),
+	}
+}
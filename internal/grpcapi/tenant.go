@@ -0,0 +1,61 @@
+package grpcapi
+
+import (
+	"context"
+	"strings"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// TenantInterceptor берёт тенант из метаданных с ключом cfg.TenantHeader, должен идти перед AuthInterceptor
+type TenantInterceptor struct {
+	cfg *config.Config
+}
+
+func NewTenantInterceptor(cfg *config.Config) *TenantInterceptor {
+	return &TenantInterceptor{cfg: cfg}
+}
+
+func (t *TenantInterceptor) Unary(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	ctx, err := t.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (t *TenantInterceptor) Stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := t.resolve(ss.Context())
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+func (t *TenantInterceptor) resolve(ctx context.Context) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || t.cfg.TenantHeader == "" {
+		return ctx, nil
+	}
+	values := md.Get(t.cfg.TenantHeader)
+	if len(values) == 0 || strings.TrimSpace(values[0]) == "" {
+		return ctx, nil
+	}
+	id := strings.TrimSpace(values[0])
+	if !t.cfg.HasTenant(id) {
+		return nil, toStatus(domain.ErrUnknownTenant, "unable to resolve tenant")
+	}
+
+	return tenant.ToContext(ctx, id), nil
+}
@@ -0,0 +1,90 @@
+package grpcapi
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	pb "github.com/k-zavarnitsyn/gophermart/pkg/grpc/gophermartpb"
+)
+
+type orderState struct {
+	status  entity.OrderStatus
+	accrual *float64
+}
+
+// WatchOrders опрашивает заказы пользователя с интервалом cfg.GRPC.WatchInterval и отправляет
+// клиенту только изменившиеся. Первым сообщением уходит текущее состояние каждого заказа.
+// Номера, которых нет среди заказов пользователя, завершают вызов с NotFound: иначе поток ждал бы их вечно.
+func (s *gophermartServer) WatchOrders(req *pb.WatchOrdersRequest, stream pb.Gophermart_WatchOrdersServer) error {
+	ctx := stream.Context()
+	claims := auth.FromContext(ctx)
+	numbers := slices.Clone(req.GetNumbers())
+	slices.Sort(numbers)
+	numbers = slices.Compact(numbers)
+	sent := make(map[string]orderState)
+
+	ticker := time.NewTicker(s.cfg.GRPC.WatchInterval)
+	defer ticker.Stop()
+	for {
+		orders, err := s.gophermart.GetOrders(ctx, claims.UserID)
+		if err != nil {
+			return toStatus(err, "error getting orders")
+		}
+		if missing := missingOrders(numbers, orders); len(missing) > 0 {
+			return toStatus(fmt.Errorf("orders %s: %w", strings.Join(missing, ", "), domain.ErrNotFound), "error watching orders")
+		}
+		finished := 0
+		for i := range orders {
+			order := &orders[i]
+			if len(numbers) > 0 && !utils.Contains(numbers, order.Number) {
+				continue
+			}
+			if isFinalStatus(order.Status) {
+				finished++
+			}
+			state := orderState{status: order.Status, accrual: order.Accrual}
+			if prev, ok := sent[order.Number]; ok && prev.equal(state) {
+				continue
+			}
+			if err := stream.Send(toOrder(order)); err != nil {
+				return err
+			}
+			sent[order.Number] = state
+		}
+		if len(numbers) > 0 && finished == len(numbers) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// missingOrders номера из numbers, которых нет среди заказов пользователя
+func missingOrders(numbers []string, orders []entity.Order) []string {
+	var missing []string
+	for _, number := range numbers {
+		if !utils.ContainsWhere(orders, func(o entity.Order) bool { return o.Number == number }) {
+			missing = append(missing, number)
+		}
+	}
+
+	return missing
+}
+
+func (s orderState) equal(other orderState) bool {
+	return s.status == other.status && utils.FromPointer(s.accrual) == utils.FromPointer(other.accrual)
+}
+
+func isFinalStatus(status entity.OrderStatus) bool {
+	return status == entity.OrderStatusProcessed || status == entity.OrderStatusInvalid
+}
@@ -3,40 +3,139 @@ package internal
 import (
 	"context"
 	"net/http"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/services/accrual"
 )
 
 type API interface {
 	Healthcheck(w http.ResponseWriter, r *http.Request)
 
+	// Readiness готовность принимать заказы: доступность БД и состояние circuit breaker системы расчёта,
+	// также сообщает текущего лидера среди реплик
+	Readiness(w http.ResponseWriter, r *http.Request)
+
 	// Register регистрация пользователя
 	Register(w http.ResponseWriter, r *http.Request)
 
 	// Login аутентификация пользователя
 	Login(w http.ResponseWriter, r *http.Request)
 
+	// ChangePassword смена пароля пользователя
+	ChangePassword(w http.ResponseWriter, r *http.Request)
+
+	// DeleteUser удаление учётной записи пользователя
+	DeleteUser(w http.ResponseWriter, r *http.Request)
+
 	// PostOrder загрузка пользователем номера заказа для расчёта
 	PostOrder(w http.ResponseWriter, r *http.Request)
 
 	// GetOrders получение списка загруженных пользователем номеров заказов, статусов их обработки и информации о начислениях
 	GetOrders(w http.ResponseWriter, r *http.Request)
 
+	// GetOrder заказ пользователя с историей статусов
+	GetOrder(w http.ResponseWriter, r *http.Request)
+
 	// GetBalance получение текущего баланса счёта баллов лояльности пользователя
 	GetBalance(w http.ResponseWriter, r *http.Request)
 
 	// Withdraw запрос на списание баллов с накопительного счёта в счёт оплаты нового заказа
 	Withdraw(w http.ResponseWriter, r *http.Request)
 
+	// Transfer перевод баллов другому пользователю
+	Transfer(w http.ResponseWriter, r *http.Request)
+
+	// GetTransfers история переводов пользователя
+	GetTransfers(w http.ResponseWriter, r *http.Request)
+
 	// Withdrawals - получение информации о выводе средств с накопительного счёта пользователем
 	GetWithdrawals(w http.ResponseWriter, r *http.Request)
+
+	// GetExpirations история сгорания баллов пользователя
+	GetExpirations(w http.ResponseWriter, r *http.Request)
+
+	// GetTier текущий уровень лояльности пользователя
+	GetTier(w http.ResponseWriter, r *http.Request)
+
+	// GetReferrals реферальный код и приглашённые пользователи
+	GetReferrals(w http.ResponseWriter, r *http.Request)
+
+	// GetStatement выписка по счёту в CSV или JSON
+	GetStatement(w http.ResponseWriter, r *http.Request)
+}
+
+type AdminAPI interface {
+	// FindUser поиск пользователя по логину
+	FindUser(w http.ResponseWriter, r *http.Request)
+
+	// GetUserOrders заказы пользователя
+	GetUserOrders(w http.ResponseWriter, r *http.Request)
+
+	// GetUserWithdrawals списания пользователя
+	GetUserWithdrawals(w http.ResponseWriter, r *http.Request)
+
+	// RetriggerAccrual повторный запрос начисления по заказу
+	RetriggerAccrual(w http.ResponseWriter, r *http.Request)
+
+	// AdjustBalance ручная корректировка баланса пользователя
+	AdjustBalance(w http.ResponseWriter, r *http.Request)
+
+	// RefundWithdrawal возврат баллов по списанию
+	RefundWithdrawal(w http.ResponseWriter, r *http.Request)
+
+	// BlockUser блокировка пользователя
+	BlockUser(w http.ResponseWriter, r *http.Request)
+
+	// UnblockUser разблокировка пользователя
+	UnblockUser(w http.ResponseWriter, r *http.Request)
+
+	// GetAuditLog журнал действий администраторов
+	GetAuditLog(w http.ResponseWriter, r *http.Request)
+
+	// GetAccrualQueueStats состояние очереди запросов в систему расчёта
+	GetAccrualQueueStats(w http.ResponseWriter, r *http.Request)
+
+	// ListCampaigns список промо-акций
+	ListCampaigns(w http.ResponseWriter, r *http.Request)
+
+	// CreateCampaign создание промо-акции
+	CreateCampaign(w http.ResponseWriter, r *http.Request)
+
+	// UpdateCampaign изменение промо-акции
+	UpdateCampaign(w http.ResponseWriter, r *http.Request)
+
+	// DeleteCampaign удаление промо-акции
+	DeleteCampaign(w http.ResponseWriter, r *http.Request)
+
+	// GetAdjustmentsForReview корректировки, ожидающие проверки
+	GetAdjustmentsForReview(w http.ResponseWriter, r *http.Request)
+
+	// MarkAdjustmentReviewed отметка о проверке корректировки
+	MarkAdjustmentReviewed(w http.ResponseWriter, r *http.Request)
+
+	// GetOrderAccrualResponses ответы системы расчёта по заказу
+	GetOrderAccrualResponses(w http.ResponseWriter, r *http.Request)
+
+	// GetQuarantinedAccrualResponses ответы системы расчёта в карантине
+	GetQuarantinedAccrualResponses(w http.ResponseWriter, r *http.Request)
 }
 
 type Pinger interface {
 	Ping(ctx context.Context) error
 }
 
+type AccrualMonitor interface {
+	CircuitState() accrual.CircuitState
+}
+
+type LeaderMonitor interface {
+	Instance() string
+	Leader(ctx context.Context) (string, error)
+}
+
 type SchemaCreator interface {
 	SchemaDefined(ctx context.Context) (bool, error)
 	CreateSchema(ctx context.Context) error
+	MigrateSchema(ctx context.Context) error
 }
 
 type Resetter interface {
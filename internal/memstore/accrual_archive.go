@@ -0,0 +1,88 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+)
+
+var _ repository.AccrualArchive = (*AccrualArchive)(nil)
+
+type AccrualArchive struct {
+	store *Store
+}
+
+func NewAccrualArchive(store *Store) repository.AccrualArchive {
+	return &AccrualArchive{store: store}
+}
+
+func (r *AccrualArchive) Insert(ctx context.Context, resp *entity.AccrualResponse) error {
+	if resp.ID.IsNil() {
+		var err error
+		if resp.ID, err = uuid.NewV6(); err != nil {
+			return err
+		}
+	}
+	resp.TenantID = tenant.FromContext(ctx)
+
+	return r.store.write(ctx, func(d *data) error {
+		value := *resp
+		value.CreatedAt = time.Now()
+		d.accrualResponses = append(d.accrualResponses, value)
+
+		return nil
+	})
+}
+
+func (r *AccrualArchive) GetOrderResponses(ctx context.Context, orderNumber string) ([]entity.AccrualResponse, error) {
+	id := tenant.FromContext(ctx)
+	var values []entity.AccrualResponse
+	err := r.store.read(func(d *data) error {
+		for _, resp := range d.accrualResponses {
+			if resp.TenantID == id && resp.OrderNumber == orderNumber {
+				values = append(values, resp)
+			}
+		}
+		return nil
+	})
+
+	return values, err
+}
+
+func (r *AccrualArchive) GetQuarantined(ctx context.Context, limit int) ([]entity.AccrualResponse, error) {
+	id := tenant.FromContext(ctx)
+	var values []entity.AccrualResponse
+	err := r.store.read(func(d *data) error {
+		for i := len(d.accrualResponses) - 1; i >= 0 && len(values) < limit; i-- {
+			if resp := d.accrualResponses[i]; resp.TenantID == id && resp.Error != nil {
+				values = append(values, resp)
+			}
+		}
+		return nil
+	})
+
+	return values, err
+}
+
+func (r *AccrualArchive) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.write(ctx, func(d *data) error {
+		kept := d.accrualResponses[:0]
+		for _, resp := range d.accrualResponses {
+			if resp.TenantID == tenantID && resp.CreatedAt.Before(before) {
+				deleted++
+				continue
+			}
+			kept = append(kept, resp)
+		}
+		d.accrualResponses = kept
+		return nil
+	})
+
+	return deleted, err
+}
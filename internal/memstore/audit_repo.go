@@ -0,0 +1,55 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+)
+
+var _ repository.Audit = (*AuditRepo)(nil)
+
+// AuditRepo журнал действий администраторов, только на добавление
+type AuditRepo struct {
+	store *Store
+}
+
+func NewAuditRepository(store *Store) repository.Audit {
+	return &AuditRepo{store: store}
+}
+
+func (r *AuditRepo) Insert(ctx context.Context, record *entity.AuditRecord) error {
+	if record.ID.IsNil() {
+		var err error
+		if record.ID, err = uuid.NewV6(); err != nil {
+			return err
+		}
+	}
+
+	return r.store.write(ctx, func(d *data) error {
+		value := *record
+		value.CreatedAt = time.Now()
+		value.TenantID = tenant.FromContext(ctx)
+		d.audit = append(d.audit, value)
+
+		return nil
+	})
+}
+
+func (r *AuditRepo) GetLast(ctx context.Context, limit int) ([]entity.AuditRecord, error) {
+	var values []entity.AuditRecord
+	id := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for i := len(d.audit) - 1; i >= 0 && len(values) < limit; i-- {
+			if d.audit[i].TenantID == id {
+				values = append(values, d.audit[i])
+			}
+		}
+		return nil
+	})
+
+	return values, err
+}
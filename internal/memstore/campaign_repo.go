@@ -0,0 +1,105 @@
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+)
+
+var _ repository.Campaign = (*CampaignRepo)(nil)
+
+type CampaignRepo struct {
+	store *Store
+}
+
+func NewCampaignRepository(store *Store) repository.Campaign {
+	return &CampaignRepo{store: store}
+}
+
+func (r *CampaignRepo) Insert(ctx context.Context, c *entity.Campaign) error {
+	if c.ID.IsNil() {
+		var err error
+		if c.ID, err = uuid.NewV6(); err != nil {
+			return err
+		}
+	}
+
+	return r.store.write(ctx, func(d *data) error {
+		c.CreatedAt = time.Now()
+		c.TenantID = tenant.FromContext(ctx)
+		d.campaigns[c.ID] = *c
+
+		return nil
+	})
+}
+
+func (r *CampaignRepo) Update(ctx context.Context, c *entity.Campaign) error {
+	return r.store.write(ctx, func(d *data) error {
+		existing, ok := d.campaigns[c.ID]
+		if !ok || existing.TenantID != tenant.FromContext(ctx) {
+			return fmt.Errorf("campaign id: %w", domain.ErrNotFound)
+		}
+		value := *c
+		value.CreatedAt = existing.CreatedAt
+		value.TenantID = existing.TenantID
+		d.campaigns[c.ID] = value
+
+		return nil
+	})
+}
+
+func (r *CampaignRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.store.write(ctx, func(d *data) error {
+		if c, ok := d.campaigns[id]; !ok || c.TenantID != tenant.FromContext(ctx) {
+			return fmt.Errorf("campaign id: %w", domain.ErrNotFound)
+		}
+		delete(d.campaigns, id)
+
+		return nil
+	})
+}
+
+func (r *CampaignRepo) FindByID(ctx context.Context, id uuid.UUID) (*entity.Campaign, error) {
+	var value entity.Campaign
+	err := r.store.read(func(d *data) error {
+		var ok bool
+		if value, ok = d.campaigns[id]; !ok || value.TenantID != tenant.FromContext(ctx) {
+			return fmt.Errorf("campaign id: %w", domain.ErrNotFound)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &value, nil
+}
+
+func (r *CampaignRepo) List(ctx context.Context) ([]entity.Campaign, error) {
+	var values []entity.Campaign
+	id := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, c := range d.campaigns {
+			if c.TenantID == id {
+				values = append(values, c)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(values, func(a, b entity.Campaign) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.ID.Bytes(), b.ID.Bytes())
+	})
+
+	return values, err
+}
@@ -0,0 +1,63 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/services/leader"
+)
+
+var _ leader.Lock = (*LeaderLock)(nil)
+
+var errLeaderLockLost = errors.New("leader lock is not held")
+
+// LeaderLock блокировка лидера среди экземпляров, разделяющих одно хранилище (в пределах процесса)
+type LeaderLock struct {
+	store    *Store
+	instance string
+}
+
+func NewLeaderLock(store *Store, instance string) *LeaderLock {
+	return &LeaderLock{store: store, instance: instance}
+}
+
+func (l *LeaderLock) TryAcquire(ctx context.Context) (bool, error) {
+	l.store.leaderMu.Lock()
+	defer l.store.leaderMu.Unlock()
+
+	if l.store.leader != "" && l.store.leader != l.instance {
+		return false, nil
+	}
+	l.store.leader = l.instance
+
+	return true, nil
+}
+
+func (l *LeaderLock) Renew(ctx context.Context) error {
+	l.store.leaderMu.Lock()
+	defer l.store.leaderMu.Unlock()
+
+	if l.store.leader != l.instance {
+		return errLeaderLockLost
+	}
+
+	return nil
+}
+
+func (l *LeaderLock) Release(ctx context.Context) error {
+	l.store.leaderMu.Lock()
+	defer l.store.leaderMu.Unlock()
+
+	if l.store.leader == l.instance {
+		l.store.leader = ""
+	}
+
+	return nil
+}
+
+func (l *LeaderLock) Holder(ctx context.Context) (string, error) {
+	l.store.leaderMu.Lock()
+	defer l.store.leaderMu.Unlock()
+
+	return l.store.leader, nil
+}
@@ -0,0 +1,28 @@
+package memstore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/memstore"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/eventbus"
+	"github.com/k-zavarnitsyn/gophermart/tests/conformance"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestRepositoryConformance(t *testing.T) {
+	store := memstore.New()
+	bus := eventbus.NewInProcess(&config.EventBus{MaxAttempts: 3, RetryDelay: 10 * time.Millisecond})
+	suite.Run(t, conformance.NewRepositorySuite(&conformance.Backend{
+		Transactor: bus.Transactional(memstore.NewTransactor(store)),
+		Orders:     memstore.NewOrderRepository(store),
+		Users:      memstore.NewUserRepository(store),
+		Audit:      memstore.NewAuditRepository(store),
+		Campaigns:  memstore.NewCampaignRepository(store),
+		Referrals:  memstore.NewReferralRepository(store),
+		Events:     bus,
+		Archive:    memstore.NewAccrualArchive(store),
+		Resetter:   memstore.NewUtilityRepository(store),
+	}))
+}
@@ -0,0 +1,793 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+)
+
+var _ repository.Order = (*OrderRepo)(nil)
+
+type OrderRepo struct {
+	store *Store
+}
+
+func NewOrderRepository(store *Store) repository.Order {
+	return &OrderRepo{store: store}
+}
+
+func (r *OrderRepo) Insert(ctx context.Context, order *entity.Order) error {
+	if order.ID.IsNil() {
+		var err error
+		if order.ID, err = uuid.NewV6(); err != nil {
+			return err
+		}
+	}
+	if order.Status == "" {
+		order.Status = entity.OrderStatusNew
+	}
+	order.TenantID = tenant.FromContext(ctx)
+	historyID, err := uuid.NewV6()
+	if err != nil {
+		return err
+	}
+	key := numberKey(order.TenantID, order.Number)
+
+	return r.store.write(ctx, func(d *data) error {
+		if id, ok := d.orderNumbers[key]; ok {
+			if d.orders[id].UserID == order.UserID {
+				return domain.ErrOrderCreatedByCurrentUser
+			}
+			return domain.ErrOrderCreatedByOtherUser
+		}
+		value := *order
+		value.CreatedAt = time.Now()
+		d.orders[order.ID] = value
+		d.orderNumbers[key] = order.ID
+		d.history = append(d.history, entity.OrderStatusChange{
+			ID:        historyID,
+			OrderID:   order.ID,
+			ToStatus:  order.Status,
+			Source:    entity.OrderSourceUser,
+			CreatedAt: value.CreatedAt,
+		})
+
+		return nil
+	})
+}
+
+func (r *OrderRepo) FindByNumber(ctx context.Context, orderNumber string) (*entity.Order, error) {
+	var value entity.Order
+	err := r.store.read(func(d *data) error {
+		id, ok := d.orderNumbers[numberKey(tenant.FromContext(ctx), orderNumber)]
+		if !ok {
+			return domain.ErrNotFound
+		}
+		value = d.orders[id]
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &value, nil
+}
+
+func (r *OrderRepo) GetUserOrders(ctx context.Context, userID uuid.UUID) ([]entity.Order, error) {
+	return r.selectOrders(ctx, func(o *entity.Order) bool {
+		return o.UserID == userID
+	}, 0)
+}
+
+func (r *OrderRepo) GetAccrualsSum(ctx context.Context, userID uuid.UUID) (float64, error) {
+	var sum float64
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, o := range d.orders {
+			if o.UserID == userID && o.TenantID == tenantID && o.Status == entity.OrderStatusProcessed && o.Accrual != nil {
+				sum += *o.Accrual
+				if o.Bonus != nil {
+					sum += *o.Bonus
+				}
+			}
+		}
+		return nil
+	})
+
+	return sum, err
+}
+
+func (r *OrderRepo) GetAccrualsSumSince(ctx context.Context, userID uuid.UUID, since time.Time) (float64, error) {
+	var sum float64
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, o := range d.orders {
+			if o.UserID == userID && o.TenantID == tenantID && o.Status == entity.OrderStatusProcessed && o.Accrual != nil &&
+				o.ProcessedAt != nil && !o.ProcessedAt.Before(since) {
+				sum += *o.Accrual
+			}
+		}
+		return nil
+	})
+
+	return sum, err
+}
+
+func (r *OrderRepo) GetWithdrawnSum(ctx context.Context, userID uuid.UUID) (float64, error) {
+	var sum float64
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, w := range d.withdrawals {
+			if w.UserID == userID && w.TenantID == tenantID {
+				sum += w.Value
+				if w.Refunded != nil {
+					sum -= *w.Refunded
+				}
+			}
+		}
+		return nil
+	})
+
+	return sum, err
+}
+
+func (r *OrderRepo) GetAdjustmentsSum(ctx context.Context, userID uuid.UUID) (float64, error) {
+	var sum float64
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, a := range d.adjustments {
+			if a.UserID == userID && a.TenantID == tenantID {
+				sum += a.Amount
+			}
+		}
+		return nil
+	})
+
+	return sum, err
+}
+
+func (r *OrderRepo) AddBalanceAdjustment(ctx context.Context, a *entity.BalanceAdjustment) error {
+	if a.ID.IsNil() {
+		var err error
+		if a.ID, err = uuid.NewV6(); err != nil {
+			return err
+		}
+	}
+
+	a.TenantID = tenant.FromContext(ctx)
+
+	return r.store.write(ctx, func(d *data) error {
+		value := *a
+		value.CreatedAt = time.Now()
+		d.adjustments = append(d.adjustments, value)
+
+		return nil
+	})
+}
+
+func (r *OrderRepo) GetUserAdjustments(ctx context.Context, userID uuid.UUID) ([]entity.BalanceAdjustment, error) {
+	var values []entity.BalanceAdjustment
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, a := range d.adjustments {
+			if a.UserID == userID && a.TenantID == tenantID {
+				values = append(values, a)
+			}
+		}
+		return nil
+	})
+
+	return values, err
+}
+
+func (r *OrderRepo) GetOrderAdjustmentsSum(ctx context.Context, orderNumber string) (float64, error) {
+	var sum float64
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, a := range d.adjustments {
+			if a.OrderNumber != nil && *a.OrderNumber == orderNumber && a.TenantID == tenantID {
+				sum += a.Amount
+			}
+		}
+		return nil
+	})
+
+	return sum, err
+}
+
+func (r *OrderRepo) GetAdjustmentsForReview(ctx context.Context) ([]entity.BalanceAdjustment, error) {
+	var values []entity.BalanceAdjustment
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, a := range d.adjustments {
+			if a.NeedsReview && a.TenantID == tenantID {
+				values = append(values, a)
+			}
+		}
+		return nil
+	})
+
+	return values, err
+}
+
+func (r *OrderRepo) MarkAdjustmentReviewed(ctx context.Context, id uuid.UUID) (bool, error) {
+	reviewed := false
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.write(ctx, func(d *data) error {
+		for i := range d.adjustments {
+			if d.adjustments[i].ID == id && d.adjustments[i].NeedsReview && d.adjustments[i].TenantID == tenantID {
+				d.adjustments[i].NeedsReview = false
+				reviewed = true
+				break
+			}
+		}
+		return nil
+	})
+
+	return reviewed, err
+}
+
+func (r *OrderRepo) GetExpiredSum(ctx context.Context, userID uuid.UUID) (float64, error) {
+	var sum float64
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, e := range d.expirations {
+			if e.UserID == userID && e.TenantID == tenantID {
+				sum += e.Value
+			}
+		}
+		return nil
+	})
+
+	return sum, err
+}
+
+func (r *OrderRepo) AddExpiry(ctx context.Context, e *entity.PointsExpiry) error {
+	if e.ID.IsNil() {
+		var err error
+		if e.ID, err = uuid.NewV6(); err != nil {
+			return err
+		}
+	}
+
+	e.TenantID = tenant.FromContext(ctx)
+
+	return r.store.write(ctx, func(d *data) error {
+		for _, existing := range d.expirations {
+			if existing.TenantID == e.TenantID && existing.UserID == e.UserID && existing.OrderNumber == e.OrderNumber {
+				return nil
+			}
+		}
+		value := *e
+		value.CreatedAt = time.Now()
+		d.expirations = append(d.expirations, value)
+
+		return nil
+	})
+}
+
+func (r *OrderRepo) GetUserExpirations(ctx context.Context, userID uuid.UUID) ([]entity.PointsExpiry, error) {
+	var values []entity.PointsExpiry
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, e := range d.expirations {
+			if e.UserID == userID && e.TenantID == tenantID {
+				values = append(values, e)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(values, func(a, b entity.PointsExpiry) int {
+		return a.ExpiredAt.Compare(b.ExpiredAt)
+	})
+
+	return values, err
+}
+
+func (r *OrderRepo) AddCampaignCredit(ctx context.Context, c *entity.CampaignCredit) error {
+	if c.ID.IsNil() {
+		var err error
+		if c.ID, err = uuid.NewV6(); err != nil {
+			return err
+		}
+	}
+
+	c.TenantID = tenant.FromContext(ctx)
+
+	return r.store.write(ctx, func(d *data) error {
+		for _, existing := range d.credits {
+			if existing.TenantID == c.TenantID && existing.CampaignID == c.CampaignID && existing.UserID == c.UserID && existing.OrderNumber == c.OrderNumber {
+				return nil
+			}
+		}
+		value := *c
+		value.CreatedAt = time.Now()
+		d.credits = append(d.credits, value)
+
+		return nil
+	})
+}
+
+func (r *OrderRepo) GetCampaignCreditsSum(ctx context.Context, userID uuid.UUID) (float64, error) {
+	var sum float64
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, c := range d.credits {
+			if c.UserID == userID && c.TenantID == tenantID {
+				sum += c.Amount
+			}
+		}
+		return nil
+	})
+
+	return sum, err
+}
+
+func (r *OrderRepo) GetUserCampaignCredits(ctx context.Context, userID uuid.UUID) ([]entity.CampaignCredit, error) {
+	var values []entity.CampaignCredit
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, c := range d.credits {
+			if c.UserID == userID && c.TenantID == tenantID {
+				values = append(values, c)
+			}
+		}
+		return nil
+	})
+
+	return values, err
+}
+
+func (r *OrderRepo) AddTransfer(ctx context.Context, t *entity.Transfer) error {
+	if t.ID.IsNil() {
+		var err error
+		if t.ID, err = uuid.NewV6(); err != nil {
+			return err
+		}
+	}
+
+	t.TenantID = tenant.FromContext(ctx)
+
+	return r.store.write(ctx, func(d *data) error {
+		for _, existing := range d.transfers {
+			if existing.TenantID == t.TenantID && existing.SenderID == t.SenderID && existing.IdempotencyKey == t.IdempotencyKey {
+				return domain.ErrIdempotencyConflict
+			}
+		}
+		t.CreatedAt = time.Now()
+		value := *t
+		value.SenderLogin, value.RecipientLogin = "", ""
+		d.transfers = append(d.transfers, value)
+
+		return nil
+	})
+}
+
+func (r *OrderRepo) FindTransferByKey(ctx context.Context, senderID uuid.UUID, key string) (*entity.Transfer, error) {
+	var value *entity.Transfer
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, t := range d.transfers {
+			if t.SenderID == senderID && t.IdempotencyKey == key && t.TenantID == tenantID {
+				value = &t
+				return nil
+			}
+		}
+		return fmt.Errorf("transfer idempotency key: %w", domain.ErrNotFound)
+	})
+
+	return value, err
+}
+
+func (r *OrderRepo) GetTransfersSum(ctx context.Context, userID uuid.UUID) (float64, error) {
+	var sum float64
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, t := range d.transfers {
+			if t.TenantID != tenantID {
+				continue
+			}
+			if t.RecipientID == userID {
+				sum += t.Amount
+			}
+			if t.SenderID == userID {
+				sum -= t.Amount
+			}
+		}
+		return nil
+	})
+
+	return sum, err
+}
+
+func (r *OrderRepo) GetSentTransfersSince(ctx context.Context, senderID uuid.UUID, since time.Time) (int, float64, error) {
+	var count int
+	var sum float64
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, t := range d.transfers {
+			if t.SenderID == senderID && !t.CreatedAt.Before(since) && t.TenantID == tenantID {
+				count++
+				sum += t.Amount
+			}
+		}
+		return nil
+	})
+
+	return count, sum, err
+}
+
+func (r *OrderRepo) GetUserTransfers(ctx context.Context, userID uuid.UUID) ([]entity.Transfer, error) {
+	var values []entity.Transfer
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, t := range d.transfers {
+			if t.TenantID != tenantID || t.SenderID != userID && t.RecipientID != userID {
+				continue
+			}
+			t.SenderLogin = d.users[t.SenderID].Login
+			t.RecipientLogin = d.users[t.RecipientID].Login
+			values = append(values, t)
+		}
+		return nil
+	})
+
+	return values, err
+}
+
+func (r *OrderRepo) Withdraw(ctx context.Context, w *entity.Withdraw) error {
+	if w.ID.IsNil() {
+		var err error
+		if w.ID, err = uuid.NewV6(); err != nil {
+			return err
+		}
+	}
+
+	w.TenantID = tenant.FromContext(ctx)
+
+	return r.store.write(ctx, func(d *data) error {
+		value := *w
+		value.CreatedAt = time.Now()
+		d.withdrawals = append(d.withdrawals, value)
+
+		return nil
+	})
+}
+
+func (r *OrderRepo) GetUserWithdrawals(ctx context.Context, userID uuid.UUID) ([]entity.Withdraw, error) {
+	var values []entity.Withdraw
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, w := range d.withdrawals {
+			if w.UserID == userID && w.TenantID == tenantID {
+				values = append(values, w)
+			}
+		}
+		return nil
+	})
+
+	return values, err
+}
+
+func (r *OrderRepo) FindWithdrawalByNumber(ctx context.Context, orderNumber string) (*entity.Withdraw, error) {
+	var value *entity.Withdraw
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, w := range d.withdrawals {
+			if w.OrderNumber != orderNumber || w.TenantID != tenantID {
+				continue
+			}
+			if value != nil {
+				return domain.ErrAmbiguousWithdrawal
+			}
+			value = &w
+		}
+		if value == nil {
+			return fmt.Errorf("withdrawal order number: %w", domain.ErrNotFound)
+		}
+		return nil
+	})
+
+	return value, err
+}
+
+func (r *OrderRepo) RefundWithdrawal(ctx context.Context, id uuid.UUID, amount float64) (bool, error) {
+	refunded := false
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.write(ctx, func(d *data) error {
+		for i := range d.withdrawals {
+			w := &d.withdrawals[i]
+			if w.ID != id || w.TenantID != tenantID || w.Refunded != nil {
+				continue
+			}
+			now := time.Now()
+			w.Refunded, w.RefundedAt = &amount, &now
+			refunded = true
+			break
+		}
+		return nil
+	})
+
+	return refunded, err
+}
+
+func (r *OrderRepo) SetOrderStatus(
+	ctx context.Context,
+	orderNumber string,
+	status entity.OrderStatus,
+	source string,
+	payload []byte,
+) (bool, error) {
+	historyID, err := uuid.NewV6()
+	if err != nil {
+		return false, err
+	}
+
+	updated := false
+	err = r.store.write(ctx, func(d *data) error {
+		id, ok := d.orderNumbers[numberKey(tenant.FromContext(ctx), orderNumber)]
+		if !ok {
+			return nil
+		}
+		order := d.orders[id]
+		if updated = transition(d, historyID, &order, status, source, payload); updated {
+			d.orders[id] = order
+		}
+
+		return nil
+	})
+
+	return updated, err
+}
+
+func (r *OrderRepo) UpdateAttributes(ctx context.Context, order *entity.Order, source string, payload []byte) (bool, error) {
+	if order.ID.IsNil() {
+		return false, domain.NewError("unable to update order: ID not set")
+	}
+	historyID, err := uuid.NewV6()
+	if err != nil {
+		return false, err
+	}
+
+	updated := false
+	err = r.store.write(ctx, func(d *data) error {
+		value, ok := d.orders[order.ID]
+		if !ok || value.TenantID != tenant.FromContext(ctx) {
+			return nil
+		}
+		if updated = transition(d, historyID, &value, order.Status, source, payload); !updated {
+			return nil
+		}
+		if order.Status == entity.OrderStatusProcessed && value.ProcessedAt == nil {
+			now := time.Now()
+			value.ProcessedAt = &now
+		}
+		value.Accrual = order.Accrual
+		value.Bonus = order.Bonus
+		d.orders[order.ID] = value
+
+		return nil
+	})
+
+	return updated, err
+}
+
+// transition меняет статус заказа и пишет историю, если переход допустим
+func transition(d *data, historyID uuid.UUID, order *entity.Order, status entity.OrderStatus, source string, payload []byte) bool {
+	if !slices.Contains(domain.AllowedFrom(status), order.Status) {
+		return false
+	}
+	from := order.Status
+	order.Status = status
+	d.history = append(d.history, entity.OrderStatusChange{
+		ID:         historyID,
+		OrderID:    order.ID,
+		FromStatus: &from,
+		ToStatus:   status,
+		Source:     source,
+		Payload:    slices.Clone(payload),
+		CreatedAt:  time.Now(),
+	})
+
+	return true
+}
+
+func (r *OrderRepo) GetOrderStatusHistory(ctx context.Context, orderID uuid.UUID) ([]entity.OrderStatusChange, error) {
+	var values []entity.OrderStatusChange
+	err := r.store.read(func(d *data) error {
+		for _, h := range d.history {
+			if h.OrderID == orderID {
+				values = append(values, h)
+			}
+		}
+		return nil
+	})
+
+	return values, err
+}
+
+func (r *OrderRepo) GetOrdersByStatuses(ctx context.Context, statuses []string, exceptNumbers []string, limit int) ([]entity.Order, error) {
+	if len(statuses) == 0 {
+		return nil, fmt.Errorf("unable to get orders: no statuses provided")
+	}
+
+	return r.selectOrders(ctx, func(o *entity.Order) bool {
+		return slices.Contains(statuses, string(o.Status)) && !slices.Contains(exceptNumbers, o.Number)
+	}, limit)
+}
+
+func (r *OrderRepo) GetOrdersToReverify(
+	ctx context.Context,
+	processedSince, verifiedBefore time.Time,
+	limit int,
+) ([]entity.Order, error) {
+	values, err := r.selectOrders(ctx, func(o *entity.Order) bool {
+		return o.Status == entity.OrderStatusProcessed && o.ProcessedAt != nil &&
+			!o.ProcessedAt.Before(processedSince) && lastVerified(o).Before(verifiedBefore)
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(values, func(a, b entity.Order) int {
+		return lastVerified(&a).Compare(lastVerified(&b))
+	})
+	if limit > 0 && len(values) > limit {
+		values = values[:limit]
+	}
+
+	return values, nil
+}
+
+// lastVerified время последней сверки заказа, до первой сверки - время расчёта
+func lastVerified(o *entity.Order) time.Time {
+	if o.VerifiedAt != nil {
+		return *o.VerifiedAt
+	}
+
+	return *o.ProcessedAt
+}
+
+func (r *OrderRepo) SetVerifiedAt(ctx context.Context, orderID uuid.UUID, at time.Time) error {
+	return r.store.write(ctx, func(d *data) error {
+		order, ok := d.orders[orderID]
+		if !ok || order.TenantID != tenant.FromContext(ctx) {
+			return nil
+		}
+		order.VerifiedAt = &at
+		d.orders[orderID] = order
+
+		return nil
+	})
+}
+
+// selectOrders выборка заказов тенанта из ctx в порядке загрузки; limit <= 0 означает без ограничения
+func (r *OrderRepo) selectOrders(ctx context.Context, filter func(o *entity.Order) bool, limit int) ([]entity.Order, error) {
+	var values []entity.Order
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, o := range d.orders {
+			if o.TenantID == tenantID && filter(&o) {
+				values = append(values, o)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(values, func(a, b entity.Order) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	if limit > 0 && len(values) > limit {
+		values = values[:limit]
+	}
+
+	return values, nil
+}
+
+// StreamStatement хранилище в памяти собирает выписку целиком, контракт с курсором Postgres тот же
+func (r *OrderRepo) StreamStatement(
+	ctx context.Context,
+	userID uuid.UUID,
+	before time.Time,
+	f func(e *entity.StatementEntry) error,
+) error {
+	var entries []entity.StatementEntry
+	tenantID := tenant.FromContext(ctx)
+	add := func(at time.Time, kind entity.StatementEntryKind, number string, amount float64) {
+		if at.Before(before) {
+			entries = append(entries, entity.StatementEntry{At: at, Kind: kind, OrderNumber: number, Amount: amount})
+		}
+	}
+	err := r.store.read(func(d *data) error {
+		for _, o := range d.orders {
+			if o.UserID != userID || o.TenantID != tenantID || o.Status != entity.OrderStatusProcessed || o.Accrual == nil {
+				continue
+			}
+			at := o.CreatedAt
+			if o.ProcessedAt != nil {
+				at = *o.ProcessedAt
+			}
+			amount := *o.Accrual
+			if o.Bonus != nil {
+				amount += *o.Bonus
+			}
+			add(at, entity.StatementAccrual, o.Number, amount)
+		}
+		for _, w := range d.withdrawals {
+			if w.UserID == userID && w.TenantID == tenantID {
+				add(w.CreatedAt, entity.StatementWithdrawal, w.OrderNumber, -w.Value)
+				if w.Refunded != nil {
+					add(*w.RefundedAt, entity.StatementRefund, w.OrderNumber, *w.Refunded)
+				}
+			}
+		}
+		for _, a := range d.adjustments {
+			if a.UserID == userID && a.TenantID == tenantID {
+				number := ""
+				if a.OrderNumber != nil {
+					number = *a.OrderNumber
+				}
+				add(a.CreatedAt, entity.StatementAdjustment, number, a.Amount)
+			}
+		}
+		for _, e := range d.expirations {
+			if e.UserID == userID && e.TenantID == tenantID {
+				add(e.ExpiredAt, entity.StatementExpiry, e.OrderNumber, -e.Value)
+			}
+		}
+		for _, c := range d.credits {
+			if c.UserID == userID && c.TenantID == tenantID {
+				add(c.CreatedAt, entity.StatementCampaign, c.OrderNumber, c.Amount)
+			}
+		}
+		for _, t := range d.transfers {
+			if t.TenantID != tenantID {
+				continue
+			}
+			if t.RecipientID == userID {
+				add(t.CreatedAt, entity.StatementTransfer, "", t.Amount)
+			}
+			if t.SenderID == userID {
+				add(t.CreatedAt, entity.StatementTransfer, "", -t.Amount)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(entries, func(a, b entity.StatementEntry) int {
+		if c := a.At.Compare(b.At); c != 0 {
+			return c
+		}
+		if c := strings.Compare(string(a.Kind), string(b.Kind)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.OrderNumber, b.OrderNumber)
+	})
+
+	for i := range entries {
+		if err := f(&entries[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// numberKey ключ номера заказа: номера уникальны в пределах тенанта
+func numberKey(tenantID, number string) string {
+	return tenantID + "/" + number
+}
@@ -0,0 +1,119 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+)
+
+var _ repository.Referral = (*ReferralRepo)(nil)
+
+type ReferralRepo struct {
+	store *Store
+}
+
+func NewReferralRepository(store *Store) repository.Referral {
+	return &ReferralRepo{store: store}
+}
+
+func (r *ReferralRepo) Insert(ctx context.Context, referral *entity.Referral) error {
+	if referral.ID.IsNil() {
+		var err error
+		if referral.ID, err = uuid.NewV6(); err != nil {
+			return err
+		}
+	}
+
+	referral.TenantID = tenant.FromContext(ctx)
+
+	return r.store.write(ctx, func(d *data) error {
+		for _, existing := range d.referrals {
+			if existing.TenantID == referral.TenantID && existing.RefereeID == referral.RefereeID {
+				return fmt.Errorf("referee %s already referred", referral.RefereeID)
+			}
+		}
+		value := *referral
+		value.CreatedAt = time.Now()
+		d.referrals = append(d.referrals, value)
+
+		return nil
+	})
+}
+
+func (r *ReferralRepo) FindByReferee(ctx context.Context, refereeID uuid.UUID) (*entity.Referral, error) {
+	var value *entity.Referral
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, referral := range d.referrals {
+			if referral.RefereeID == refereeID && referral.TenantID == tenantID {
+				value = &referral
+				return nil
+			}
+		}
+		return fmt.Errorf("referral referee id: %w", domain.ErrNotFound)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func (r *ReferralRepo) MarkRewarded(ctx context.Context, referral *entity.Referral) (bool, error) {
+	var updated bool
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.write(ctx, func(d *data) error {
+		for i := range d.referrals {
+			value := &d.referrals[i]
+			if value.ID != referral.ID || value.TenantID != tenantID || value.RewardedAt != nil {
+				continue
+			}
+			now := time.Now()
+			value.OrderNumber = referral.OrderNumber
+			value.ReferrerBonus = referral.ReferrerBonus
+			value.RefereeBonus = referral.RefereeBonus
+			value.RewardedAt = &now
+			updated = true
+		}
+		return nil
+	})
+
+	return updated, err
+}
+
+func (r *ReferralRepo) GetUserReferrals(ctx context.Context, referrerID uuid.UUID) ([]entity.Referral, error) {
+	var values []entity.Referral
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, referral := range d.referrals {
+			if referral.ReferrerID == referrerID && referral.TenantID == tenantID {
+				referral.RefereeLogin = d.users[referral.RefereeID].Login
+				values = append(values, referral)
+			}
+		}
+		return nil
+	})
+
+	return values, err
+}
+
+func (r *ReferralRepo) CountRewarded(ctx context.Context, referrerID uuid.UUID) (int, error) {
+	var count int
+	tenantID := tenant.FromContext(ctx)
+	err := r.store.read(func(d *data) error {
+		for _, referral := range d.referrals {
+			if referral.ReferrerID == referrerID && referral.TenantID == tenantID && referral.RewardedAt != nil {
+				count++
+			}
+		}
+		return nil
+	})
+
+	return count, err
+}
@@ -0,0 +1,137 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+type trxKey struct{}
+
+/*
+Store хранилище в памяти для локального запуска без Postgres и для тестов.
+Транзакции выполняются по одной: на время транзакции берётся txMu, состояние копируется
+и при ошибке или панике восстанавливается из копии. Запись вне транзакции тоже берёт txMu,
+поэтому откат не затирает чужие изменения. Чтение идёт без ожидания транзакций (read uncommitted).
+*/
+type Store struct {
+	mu   sync.RWMutex
+	txMu sync.Mutex
+	data *data
+
+	// leader экземпляр, удерживающий блокировку лидера; вне data, так как не откатывается транзакциями
+	leaderMu sync.Mutex
+	leader   string
+}
+
+type data struct {
+	users        map[uuid.UUID]entity.User
+	orders       map[uuid.UUID]entity.Order
+	orderNumbers map[string]uuid.UUID
+	withdrawals  []entity.Withdraw
+	adjustments  []entity.BalanceAdjustment
+	expirations  []entity.PointsExpiry
+	campaigns    map[uuid.UUID]entity.Campaign
+	credits      []entity.CampaignCredit
+	referrals    []entity.Referral
+	transfers    []entity.Transfer
+	history      []entity.OrderStatusChange
+	audit        []entity.AuditRecord
+	// accrualResponses в порядке получения
+	accrualResponses []entity.AccrualResponse
+}
+
+func New() *Store {
+	return &Store{data: newData()}
+}
+
+func newData() *data {
+	return &data{
+		users:        make(map[uuid.UUID]entity.User),
+		orders:       make(map[uuid.UUID]entity.Order),
+		orderNumbers: make(map[string]uuid.UUID),
+		campaigns:    make(map[uuid.UUID]entity.Campaign),
+	}
+}
+
+func (d *data) clone() *data {
+	c := newData()
+	for k, v := range d.users {
+		c.users[k] = v
+	}
+	for k, v := range d.orders {
+		c.orders[k] = v
+	}
+	for k, v := range d.orderNumbers {
+		c.orderNumbers[k] = v
+	}
+	for k, v := range d.campaigns {
+		c.campaigns[k] = v
+	}
+	c.withdrawals = append(c.withdrawals, d.withdrawals...)
+	c.adjustments = append(c.adjustments, d.adjustments...)
+	c.expirations = append(c.expirations, d.expirations...)
+	c.credits = append(c.credits, d.credits...)
+	c.referrals = append(c.referrals, d.referrals...)
+	c.transfers = append(c.transfers, d.transfers...)
+	c.history = append(c.history, d.history...)
+	c.audit = append(c.audit, d.audit...)
+	c.accrualResponses = append(c.accrualResponses, d.accrualResponses...)
+
+	return c
+}
+
+// Transaction запускает f в транзакции со снимком состояния; возврат ошибки или паника приводят к откату
+func (s *Store) Transaction(ctx context.Context, f func(ctx context.Context) error) (err error) {
+	if inTransaction(ctx) {
+		return f(ctx)
+	}
+
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	s.mu.RLock()
+	snapshot := s.data.clone()
+	s.mu.RUnlock()
+
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = errors.Join(err, fmt.Errorf("panic: %v", panicErr))
+		}
+		if err != nil {
+			s.mu.Lock()
+			s.data = snapshot
+			s.mu.Unlock()
+		}
+	}()
+
+	return f(context.WithValue(ctx, trxKey{}, true))
+}
+
+func (s *Store) read(f func(d *data) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return f(s.data)
+}
+
+func (s *Store) write(ctx context.Context, f func(d *data) error) error {
+	if !inTransaction(ctx) {
+		s.txMu.Lock()
+		defer s.txMu.Unlock()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return f(s.data)
+}
+
+func inTransaction(ctx context.Context) bool {
+	inTx, _ := ctx.Value(trxKey{}).(bool)
+
+	return inTx
+}
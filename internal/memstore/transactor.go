@@ -0,0 +1,21 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+)
+
+var _ domain.Transactor = (*Transactor)(nil)
+
+type Transactor struct {
+	store *Store
+}
+
+func NewTransactor(store *Store) *Transactor {
+	return &Transactor{store: store}
+}
+
+func (t *Transactor) Transaction(ctx context.Context, f func(ctx context.Context) error) error {
+	return t.store.Transaction(ctx, f)
+}
@@ -0,0 +1,224 @@
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+)
+
+var _ repository.User = (*UserRepo)(nil)
+
+type UserRepo struct {
+	store *Store
+}
+
+func NewUserRepository(store *Store) repository.User {
+	return &UserRepo{store: store}
+}
+
+func (r *UserRepo) Insert(ctx context.Context, user *entity.User) error {
+	if user.Role == "" {
+		user.Role = entity.UserRoleUser
+	}
+	if user.ReferralCode == "" {
+		user.ReferralCode = domain.NewReferralCode()
+	}
+
+	user.TenantID = tenant.FromContext(ctx)
+
+	return r.store.write(ctx, func(d *data) error {
+		if findByLogin(d, user.TenantID, user.Login) != nil {
+			return domain.ErrLoginExists
+		}
+		d.users[user.ID] = *user
+
+		return nil
+	})
+}
+
+// LoginExists проверяет логин в пределах тенанта: в разных магазинах логины могут совпадать
+func (r *UserRepo) LoginExists(ctx context.Context, login string) (bool, error) {
+	var exists bool
+	err := r.store.read(func(d *data) error {
+		exists = findByLogin(d, tenant.FromContext(ctx), login) != nil
+		return nil
+	})
+
+	return exists, err
+}
+
+func (r *UserRepo) FindByLoginAndPassword(ctx context.Context, login string, hashedPassword []byte) (*entity.User, error) {
+	var user *entity.User
+	err := r.store.read(func(d *data) error {
+		user = findByLogin(d, tenant.FromContext(ctx), login)
+		if user == nil || user.DeletedAt != nil || !bytes.Equal(user.PasswordSHA, hashedPassword) {
+			return fmt.Errorf("login and password: %w", domain.ErrNotFound)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (r *UserRepo) FindByID(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	var user entity.User
+	err := r.store.read(func(d *data) error {
+		var ok bool
+		if user, ok = d.users[id]; !ok || !inTenant(ctx, &user) {
+			return fmt.Errorf("user id: %w", domain.ErrNotFound)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *UserRepo) FindByLogin(ctx context.Context, login string) (*entity.User, error) {
+	var user *entity.User
+	err := r.store.read(func(d *data) error {
+		if user = findByLogin(d, tenant.FromContext(ctx), login); user == nil {
+			return fmt.Errorf("user login: %w", domain.ErrNotFound)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (r *UserRepo) SetBlocked(ctx context.Context, id uuid.UUID, blocked bool) error {
+	return r.update(ctx, id, func(user *entity.User) {
+		user.Blocked = blocked
+	})
+}
+
+func (r *UserRepo) SetRole(ctx context.Context, id uuid.UUID, role entity.UserRole) error {
+	return r.update(ctx, id, func(user *entity.User) {
+		user.Role = role
+	})
+}
+
+func (r *UserRepo) UpdatePassword(ctx context.Context, id uuid.UUID, hashedPassword []byte) (int, error) {
+	var version int
+	err := r.update(ctx, id, func(user *entity.User) {
+		user.PasswordSHA = hashedPassword
+		user.SessionVersion++
+		version = user.SessionVersion
+	}, notDeleted)
+
+	return version, err
+}
+
+func (r *UserRepo) Anonymize(ctx context.Context, id uuid.UUID, login string) error {
+	return r.update(ctx, id, func(user *entity.User) {
+		now := time.Now()
+		user.Login = login
+		user.PasswordSHA = []byte{}
+		user.SessionVersion++
+		user.DeletedAt = &now
+	}, notDeleted)
+}
+
+func (r *UserRepo) List(ctx context.Context) ([]entity.User, error) {
+	var values []entity.User
+	err := r.store.read(func(d *data) error {
+		for _, user := range d.users {
+			if inTenant(ctx, &user) {
+				values = append(values, user)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(values, func(a, b entity.User) int {
+		return strings.Compare(a.Login, b.Login)
+	})
+
+	return values, err
+}
+
+func (r *UserRepo) SetTier(ctx context.Context, id uuid.UUID, tier string) error {
+	return r.update(ctx, id, func(user *entity.User) {
+		user.Tier = tier
+	})
+}
+
+func (r *UserRepo) FindByReferralCode(ctx context.Context, code string) (*entity.User, error) {
+	var value *entity.User
+	err := r.store.read(func(d *data) error {
+		for _, user := range d.users {
+			if strings.EqualFold(user.ReferralCode, code) && inTenant(ctx, &user) {
+				value = &user
+				return nil
+			}
+		}
+		return fmt.Errorf("user referral code: %w", domain.ErrNotFound)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func (r *UserRepo) update(ctx context.Context, id uuid.UUID, f func(user *entity.User), filters ...func(user *entity.User) bool) error {
+	return r.store.write(ctx, func(d *data) error {
+		user, ok := d.users[id]
+		ok = ok && inTenant(ctx, &user)
+		for _, filter := range filters {
+			ok = ok && filter(&user)
+		}
+		if !ok {
+			return fmt.Errorf("user id: %w", domain.ErrNotFound)
+		}
+		f(&user)
+		d.users[id] = user
+
+		return nil
+	})
+}
+
+func notDeleted(user *entity.User) bool {
+	return user.DeletedAt == nil
+}
+
+func inTenant(ctx context.Context, user *entity.User) bool {
+	return user.TenantID == tenant.FromContext(ctx)
+}
+
+// findByLogin поиск в тенанте без учёта регистра, как уникальный индекс по (tenant_id, lower(login)) в Postgres
+func findByLogin(d *data, tenantID, login string) *entity.User {
+	for _, user := range d.users {
+		if user.TenantID == tenantID && strings.EqualFold(user.Login, login) {
+			return &user
+		}
+	}
+
+	return nil
+}
+
+// LockForUpdate транзакции хранилища и так выполняются по одной, остаётся проверить, что пользователь есть
+func (r *UserRepo) LockForUpdate(ctx context.Context, id uuid.UUID) error {
+	_, err := r.FindByID(ctx, id)
+
+	return err
+}
@@ -0,0 +1,44 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/k-zavarnitsyn/gophermart/internal"
+)
+
+var (
+	_ internal.Pinger        = (*UtilityRepository)(nil)
+	_ internal.SchemaCreator = (*UtilityRepository)(nil)
+	_ internal.Resetter      = (*UtilityRepository)(nil)
+)
+
+type UtilityRepository struct {
+	store *Store
+}
+
+func NewUtilityRepository(store *Store) *UtilityRepository {
+	return &UtilityRepository{store: store}
+}
+
+func (r *UtilityRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (r *UtilityRepository) SchemaDefined(ctx context.Context) (bool, error) {
+	return true, nil
+}
+
+func (r *UtilityRepository) CreateSchema(ctx context.Context) error {
+	return nil
+}
+
+func (r *UtilityRepository) MigrateSchema(ctx context.Context) error {
+	return nil
+}
+
+func (r *UtilityRepository) Reset() error {
+	return r.store.write(context.Background(), func(d *data) error {
+		*d = *newData()
+		return nil
+	})
+}
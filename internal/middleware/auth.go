@@ -5,27 +5,86 @@ import (
 
 	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
 	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
 )
 
 type Auth struct {
 	authService *auth.Service
+	userRepo    repository.User
 }
 
-func NewAuth(authService *auth.Service) *Auth {
+func NewAuth(authService *auth.Service, userRepo repository.User) *Auth {
 	return &Auth{
 		authService: authService,
+		userRepo:    userRepo,
 	}
 }
 
 func (a *Auth) WithAuthentication(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claims := auth.FromContext(r.Context()); claims != nil && claims.Role == entity.UserRoleService {
+			h.ServeHTTP(w, r)
+			return
+		}
 		claims, err := a.authService.Authenticate(r)
 		if err != nil {
-			utils.SendErrorMsg(w, err, "unable to authenticate user", http.StatusUnauthorized)
+			domain.SendError(w, err, "unable to authenticate user")
+			return
+		}
+		ctx, err := auth.WithTenant(r.Context(), claims)
+		if err != nil {
+			domain.SendError(w, err)
 			return
 		}
-		ctx := auth.ToContext(r.Context(), claims)
+		if a.userRepo != nil {
+			// токен живёт долго, поэтому блокировка, отзыв сессий и смена роли проверяются по БД на каждом запросе
+			user, err := a.userRepo.FindByID(ctx, claims.UserID)
+			if err != nil {
+				domain.SendError(w, err, "unable to authenticate user")
+				return
+			}
+			if err := auth.VerifyUser(claims, user); err != nil {
+				domain.SendError(w, err)
+				return
+			}
+		}
+		ctx = auth.ToContext(ctx, claims)
 
 		h.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// WithServiceAuthentication аутентифицирует доверенный сервис по заголовку Authorization.
+// Запросы без заголовка передаются дальше для аутентификации пользователя в WithAuthentication.
+func (a *Auth) WithServiceAuthentication(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(utils.AuthorizationHeader) == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		claims, err := a.authService.AuthenticateService(r)
+		if err != nil {
+			domain.SendError(w, err, "unable to authenticate service")
+			return
+		}
+
+		h.ServeHTTP(w, r.WithContext(auth.ToContext(r.Context(), claims)))
+	})
+}
+
+// WithRole пропускает только пользователей с одной из перечисленных ролей, должен идти после WithAuthentication
+func (a *Auth) WithRole(roles ...entity.UserRole) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := auth.FromContext(r.Context())
+			if claims == nil || !claims.HasRole(roles...) {
+				domain.SendError(w, domain.ErrForbidden)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
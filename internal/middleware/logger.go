@@ -39,7 +39,7 @@ func (l *Logger) WithRequestLogging(h http.Handler) http.Handler {
 		h.ServeHTTP(w, r)
 		duration := time.Since(start)
 
-		log.Infoln(r.Method, r.RequestURI, string(body), duration)
+		log.WithField("request_id", RequestIDFromContext(r.Context())).Infoln(r.Method, r.RequestURI, string(body), duration)
 	})
 }
 
@@ -2,16 +2,21 @@ package middleware_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
 	"github.com/k-zavarnitsyn/gophermart/internal/config"
 	"github.com/k-zavarnitsyn/gophermart/internal/container"
 	"github.com/k-zavarnitsyn/gophermart/internal/middleware"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
 	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
 	"github.com/k-zavarnitsyn/gophermart/tests/testutils"
 	"github.com/stretchr/testify/suite"
 )
@@ -100,3 +105,191 @@ func (s *TestSuite) TestGzip() {
 		})
 	}
 }
+
+func (s *TestSuite) TestWithRole() {
+	authMiddleware := middleware.NewAuth(s.cnt.Auth(), nil)
+	router := chi.NewRouter()
+	router.Use(authMiddleware.WithAuthentication, authMiddleware.WithRole(entity.UserRoleAdmin))
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	testCases := []struct {
+		name         string
+		role         entity.UserRole
+		expectedCode int
+	}{
+		{name: "Admin", role: entity.UserRoleAdmin, expectedCode: http.StatusOK},
+		{name: "Support", role: entity.UserRoleSupport, expectedCode: http.StatusForbidden},
+		{name: "User", role: entity.UserRoleUser, expectedCode: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			cookie, err := s.cnt.Auth().CreateTokenCookie(&entity.User{
+				ID:    uuid.Must(uuid.NewV6()),
+				Login: "test",
+				Role:  tc.role,
+			})
+			s.Require().NoError(err)
+			r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			r.AddCookie(cookie)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			s.Assert().Equal(tc.expectedCode, w.Code, "Код ответа не совпадает с ожидаемым")
+		})
+	}
+
+	s.Run("No token", func() {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+		s.Assert().Equal(http.StatusUnauthorized, w.Code)
+	})
+}
+
+func (s *TestSuite) TestServiceAuthentication() {
+	s.cfg.Auth.ServiceTokens = map[string]string{"shop": "shop-secret"}
+	defer func() { s.cfg.Auth.ServiceTokens = nil }()
+
+	authMiddleware := middleware.NewAuth(s.cnt.Auth(), nil)
+	router := chi.NewRouter()
+	router.Use(authMiddleware.WithServiceAuthentication, authMiddleware.WithAuthentication)
+	router.With(authMiddleware.WithRole(entity.UserRoleAdmin, entity.UserRoleService)).Get("/refund", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(auth.FromContext(r.Context()).Login))
+	})
+	router.With(authMiddleware.WithRole(entity.UserRoleAdmin)).Get("/admin", func(w http.ResponseWriter, r *http.Request) {})
+
+	testCases := []struct {
+		name          string
+		path          string
+		authorization string
+		expectedCode  int
+		expectedBody  string
+	}{
+		{name: "Service", path: "/refund", authorization: "Bearer shop-secret", expectedCode: http.StatusOK, expectedBody: "service:shop"},
+		{name: "Service on admin only route", path: "/admin", authorization: "Bearer shop-secret", expectedCode: http.StatusForbidden},
+		{name: "Wrong token", path: "/refund", authorization: "Bearer wrong", expectedCode: http.StatusUnauthorized},
+		{name: "Not bearer", path: "/refund", authorization: "Basic shop-secret", expectedCode: http.StatusUnauthorized},
+		{name: "No header", path: "/refund", expectedCode: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			r := httptest.NewRequest(http.MethodGet, tc.path, http.NoBody)
+			if tc.authorization != "" {
+				r.Header.Set(utils.AuthorizationHeader, tc.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			s.Assert().Equal(tc.expectedCode, w.Code, "Код ответа не совпадает с ожидаемым")
+			if tc.expectedBody != "" {
+				s.Assert().Equal(tc.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func (s *TestSuite) TestRequestID() {
+	router := chi.NewRouter()
+	router.Use(middleware.WithRequestID)
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		utils.JSONError(w, middleware.RequestIDFromContext(r.Context()), http.StatusTeapot)
+	})
+
+	testCases := []struct {
+		name       string
+		requestID  string
+		expectSame bool
+	}{
+		{name: "Generated", requestID: ""},
+		{name: "Passed through", requestID: "abc-123", expectSame: true},
+		{name: "Invalid replaced", requestID: "bad\nid"},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			if tc.requestID != "" {
+				r.Header.Set(utils.RequestIDHeader, tc.requestID)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			requestID := w.Header().Get(utils.RequestIDHeader)
+			s.Require().NotEmpty(requestID)
+			if tc.expectSame {
+				s.Require().Equal(tc.requestID, requestID)
+			} else {
+				s.Require().NotEqual(tc.requestID, requestID)
+			}
+			var problem utils.Problem
+			s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &problem))
+			s.Require().Equal(requestID, problem.RequestID)
+			s.Require().Equal(requestID, problem.Detail)
+		})
+	}
+}
+
+func (s *TestSuite) TestTenant() {
+	cfg := *s.cfg
+	cfg.Tenants = []config.Tenant{
+		{ID: "shop-a", Hosts: []string{"a.example.com"}},
+		{ID: "shop-b"},
+	}
+	authMiddleware := middleware.NewAuth(s.cnt.Auth(), nil)
+	router := chi.NewRouter()
+	router.Use(middleware.NewTenant(&cfg).WithTenant)
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(tenant.FromContext(r.Context())))
+	})
+	router.With(authMiddleware.WithAuthentication).Get("/user", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(tenant.FromContext(r.Context())))
+	})
+
+	testCases := []struct {
+		name         string
+		path         string
+		host         string
+		header       string
+		userTenant   string
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "By host", path: "/", host: "a.example.com:8080", expectedCode: http.StatusOK, expectedBody: "shop-a"},
+		{name: "Host goes before header", path: "/", host: "a.example.com", header: "shop-b", expectedCode: http.StatusOK, expectedBody: "shop-a"},
+		{name: "By header", path: "/", header: "shop-b", expectedCode: http.StatusOK, expectedBody: "shop-b"},
+		{name: "Unknown", path: "/", header: "shop-c", expectedCode: http.StatusBadRequest},
+		{name: "Not resolved", path: "/", expectedCode: http.StatusOK, expectedBody: tenant.Default},
+		{name: "From token", path: "/user", userTenant: "shop-b", expectedCode: http.StatusOK, expectedBody: "shop-b"},
+		{name: "Token matches host", path: "/user", host: "a.example.com", userTenant: "shop-a", expectedCode: http.StatusOK, expectedBody: "shop-a"},
+		{name: "Token of another tenant", path: "/user", header: "shop-a", userTenant: "shop-b", expectedCode: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			r := httptest.NewRequest(http.MethodGet, tc.path, http.NoBody)
+			if tc.host != "" {
+				r.Host = tc.host
+			}
+			if tc.header != "" {
+				r.Header.Set(cfg.TenantHeader, tc.header)
+			}
+			if tc.userTenant != "" {
+				cookie, err := s.cnt.Auth().CreateTokenCookie(&entity.User{
+					ID:       uuid.Must(uuid.NewV6()),
+					Login:    "test",
+					TenantID: tc.userTenant,
+				})
+				s.Require().NoError(err)
+				r.AddCookie(cookie)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			s.Assert().Equal(tc.expectedCode, w.Code, "Код ответа не совпадает с ожидаемым")
+			if tc.expectedBody != "" {
+				s.Assert().Equal(tc.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
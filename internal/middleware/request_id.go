@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+)
+
+var requestIDKey = struct{}{}
+
+// validRequestID ограничивает входящий ID, чтобы клиент не мог подсунуть в логи и ответы произвольный текст
+var validRequestID = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+// WithRequestID берёт ID запроса из заголовка X-Request-ID или генерирует новый,
+// возвращает его в заголовке ответа и кладёт в контекст
+func WithRequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(utils.RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.Must(uuid.NewV4()).String()
+		}
+		w.Header().Set(utils.RequestIDHeader, requestID)
+		ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+
+	return requestID
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+)
+
+type Tenant struct {
+	cfg *config.Config
+}
+
+func NewTenant(cfg *config.Config) *Tenant {
+	return &Tenant{cfg: cfg}
+}
+
+// WithTenant определяет тенант запроса по хосту, затем по заголовку cfg.TenantHeader.
+// Если ни то ни другое не задано, тенант возьмётся из токена в WithAuthentication.
+func (t *Tenant) WithTenant(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := t.byHost(r.Host)
+		if id == "" && t.cfg.TenantHeader != "" {
+			id = strings.TrimSpace(r.Header.Get(t.cfg.TenantHeader))
+		}
+		if id == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if !t.cfg.HasTenant(id) {
+			domain.SendError(w, domain.ErrUnknownTenant)
+			return
+		}
+
+		h.ServeHTTP(w, r.WithContext(tenant.ToContext(r.Context(), id)))
+	})
+}
+
+func (t *Tenant) byHost(hostport string) string {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	for _, cfg := range t.cfg.Tenants {
+		for _, h := range cfg.Hosts {
+			if strings.EqualFold(h, host) {
+				return cfg.ID
+			}
+		}
+	}
+
+	return ""
+}
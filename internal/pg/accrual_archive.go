@@ -0,0 +1,75 @@
+package pg
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+)
+
+var _ repository.AccrualArchive = (*AccrualArchive)(nil)
+
+type AccrualArchive struct {
+	db *Pool
+}
+
+func NewAccrualArchive(db *Pool) repository.AccrualArchive {
+	return &AccrualArchive{db: db}
+}
+
+func (r *AccrualArchive) Insert(ctx context.Context, resp *entity.AccrualResponse) error {
+	if resp.ID.IsNil() {
+		var err error
+		if resp.ID, err = uuid.NewV6(); err != nil {
+			return err
+		}
+	}
+	resp.TenantID = tenant.FromContext(ctx)
+
+	sql := `
+		INSERT INTO accrual_response (id, tenant_id, order_number, status_code, payload, error)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := r.db.Exec(ctx, sql, resp.ID, resp.TenantID, resp.OrderNumber, resp.StatusCode, resp.Payload, resp.Error)
+
+	return err
+}
+
+func (r *AccrualArchive) GetOrderResponses(ctx context.Context, orderNumber string) ([]entity.AccrualResponse, error) {
+	var values []entity.AccrualResponse
+	sql := `SELECT * FROM accrual_response WHERE tenant_id = $1 AND order_number = $2 ORDER BY created_at;`
+	err := pgxscan.Select(ctx, r.db, &values, sql, tenant.FromContext(ctx), orderNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (r *AccrualArchive) GetQuarantined(ctx context.Context, limit int) ([]entity.AccrualResponse, error) {
+	var values []entity.AccrualResponse
+	sql := `
+		SELECT * FROM accrual_response
+		WHERE tenant_id = $1 AND error IS NOT NULL
+		ORDER BY created_at DESC
+		LIMIT $2;`
+	err := pgxscan.Select(ctx, r.db, &values, sql, tenant.FromContext(ctx), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (r *AccrualArchive) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM accrual_response WHERE tenant_id = $1 AND created_at < $2`,
+		tenant.FromContext(ctx), before)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
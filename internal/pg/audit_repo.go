@@ -0,0 +1,52 @@
+package pg
+
+import (
+	"context"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+)
+
+var _ repository.Audit = &AuditRepo{}
+
+// AuditRepo журнал действий администраторов. Таблица только на добавление: изменение и удаление строк запрещено триггером.
+type AuditRepo struct {
+	db *Pool
+}
+
+func NewAuditRepository(db *Pool) repository.Audit {
+	return &AuditRepo{db: db}
+}
+
+func (r *AuditRepo) Insert(ctx context.Context, record *entity.AuditRecord) error {
+	if record.ID.IsNil() {
+		var err error
+		if record.ID, err = uuid.NewV6(); err != nil {
+			return err
+		}
+	}
+
+	record.TenantID = tenant.FromContext(ctx)
+
+	sql := `
+		INSERT INTO audit_log (id, actor_id, actor_login, action, target, details, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := r.db.Exec(ctx, sql,
+		record.ID, record.ActorID, record.ActorLogin, record.Action, record.Target, record.Details, record.TenantID)
+
+	return err
+}
+
+func (r *AuditRepo) GetLast(ctx context.Context, limit int) ([]entity.AuditRecord, error) {
+	var values []entity.AuditRecord
+	sql := `SELECT * FROM audit_log WHERE tenant_id = $1 ORDER BY created_at DESC LIMIT $2;`
+	err := pgxscan.Select(ctx, r.db, &values, sql, tenant.FromContext(ctx), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return values, err
+}
@@ -0,0 +1,99 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+)
+
+var _ repository.Campaign = &CampaignRepo{}
+
+type CampaignRepo struct {
+	db *Pool
+}
+
+func NewCampaignRepository(db *Pool) repository.Campaign {
+	return &CampaignRepo{db: db}
+}
+
+func (r *CampaignRepo) Insert(ctx context.Context, c *entity.Campaign) error {
+	if c.ID.IsNil() {
+		var err error
+		if c.ID, err = uuid.NewV6(); err != nil {
+			return err
+		}
+	}
+
+	c.TenantID = tenant.FromContext(ctx)
+
+	sql := `
+		INSERT INTO campaign (id, name, kind, value, starts_at, ends_at, first_order_only, min_order_count, user_cap, enabled, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING created_at`
+
+	return pgxscan.Get(ctx, r.db, &c.CreatedAt, sql,
+		c.ID, c.Name, c.Kind, c.Value, c.StartsAt, c.EndsAt, c.FirstOrderOnly, c.MinOrderCount, c.UserCap, c.Enabled, c.TenantID)
+}
+
+func (r *CampaignRepo) Update(ctx context.Context, c *entity.Campaign) error {
+	sql := `
+		UPDATE campaign SET (name, kind, value, starts_at, ends_at, first_order_only, min_order_count, user_cap, enabled) =
+			($2, $3, $4, $5, $6, $7, $8, $9, $10)
+		WHERE id = $1 AND tenant_id = $11`
+	tag, err := r.db.Exec(ctx, sql,
+		c.ID, c.Name, c.Kind, c.Value, c.StartsAt, c.EndsAt, c.FirstOrderOnly, c.MinOrderCount, c.UserCap, c.Enabled,
+		tenant.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("campaign id: %w", domain.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *CampaignRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM campaign WHERE id = $1 AND tenant_id = $2`, id, tenant.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("campaign id: %w", domain.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *CampaignRepo) FindByID(ctx context.Context, id uuid.UUID) (*entity.Campaign, error) {
+	var value entity.Campaign
+	sql := `SELECT * FROM campaign WHERE id = $1 AND tenant_id = $2;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, id, tenant.FromContext(ctx))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("campaign id: %w", domain.ErrNotFound)
+		}
+		return nil, err
+	}
+
+	return &value, nil
+}
+
+func (r *CampaignRepo) List(ctx context.Context) ([]entity.Campaign, error) {
+	var values []entity.Campaign
+	sql := `SELECT * FROM campaign WHERE tenant_id = $1 ORDER BY created_at, id;`
+	err := pgxscan.Select(ctx, r.db, &values, sql, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
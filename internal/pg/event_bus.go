@@ -0,0 +1,161 @@
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/eventbus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	_ eventbus.Bus     = (*EventBus)(nil)
+	_ eventbus.Cleaner = (*EventBus)(nil)
+)
+
+/*
+EventBus хранит события в таблице event, а очередь каждой группы - в event_delivery. Строки доставки
+создаются при публикации для групп из event_subscription, поэтому событие, опубликованное в транзакции,
+появится у потребителей только после её фиксации. Потребители на разных репликах разбирают доставки
+через SKIP LOCKED; взятая доставка откладывается на Lease, и если её не подтвердили, выдаётся снова.
+*/
+type EventBus struct {
+	cfg *config.EventBus
+	db  *Pool
+}
+
+type eventDelivery struct {
+	ID        uuid.UUID       `db:"id"`
+	Topic     string          `db:"topic"`
+	Payload   json.RawMessage `db:"payload"`
+	CreatedAt time.Time       `db:"created_at"`
+	Attempts  int             `db:"attempts"`
+}
+
+func NewEventBus(cfg *config.EventBus, db *Pool) *EventBus {
+	return &EventBus{cfg: cfg, db: db}
+}
+
+func (b *EventBus) Publish(ctx context.Context, topic string, payload any) error {
+	e, err := eventbus.NewEvent(topic, payload)
+	if err != nil {
+		return err
+	}
+
+	sql := `
+		WITH inserted AS (
+			INSERT INTO event (id, topic, payload) VALUES ($1, $2, $3)
+			RETURNING id, topic
+		)
+		INSERT INTO event_delivery (event_id, consumer_group)
+		SELECT i.id, s.consumer_group FROM inserted i
+		JOIN event_subscription s ON s.topic = i.topic`
+	_, err = b.db.Exec(ctx, sql, e.ID, e.Topic, e.Payload)
+
+	return err
+}
+
+func (b *EventBus) Subscribe(ctx context.Context, topic, group string, h eventbus.Handler) error {
+	sql := `INSERT INTO event_subscription (topic, consumer_group) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	if _, err := b.db.Exec(ctx, sql, topic, group); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(b.cfg.PollInterval)
+		defer ticker.Stop()
+		for {
+			b.consume(ctx, topic, group, h)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// consume разбирает доступные доставки группы, пока они не закончатся
+func (b *EventBus) consume(ctx context.Context, topic, group string, h eventbus.Handler) {
+	for ctx.Err() == nil {
+		deliveries, err := b.claim(ctx, topic, group)
+		if err != nil {
+			log.WithError(err).WithField("topic", topic).WithField("group", group).Error("Failed to claim events")
+			return
+		}
+		if len(deliveries) == 0 {
+			return
+		}
+		for _, d := range deliveries {
+			e := &eventbus.Event{ID: d.ID, Topic: d.Topic, Payload: d.Payload, CreatedAt: d.CreatedAt, Attempt: d.Attempts}
+			if err := b.settle(ctx, e, group, h(ctx, e)); err != nil {
+				log.WithError(err).WithField("event", e.ID).WithField("group", group).Error("Failed to settle event")
+			}
+		}
+	}
+}
+
+func (b *EventBus) claim(ctx context.Context, topic, group string) ([]eventDelivery, error) {
+	var values []eventDelivery
+	sql := `
+		WITH claimed AS (
+			SELECT d.event_id FROM event_delivery d
+			JOIN event e ON e.id = d.event_id
+			WHERE d.consumer_group = $1 AND e.topic = $2
+				AND d.acked_at IS NULL AND d.dead_at IS NULL AND d.available_at <= now()
+			ORDER BY e.created_at
+			LIMIT $3
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE event_delivery d SET (attempts, available_at) = (d.attempts + 1, now() + make_interval(secs => $4))
+		FROM claimed c, event e
+		WHERE d.event_id = c.event_id AND d.consumer_group = $1 AND e.id = d.event_id
+		RETURNING e.id, e.topic, e.payload, e.created_at, d.attempts`
+	err := pgxscan.Select(ctx, b.db, &values, sql, group, topic, b.cfg.BatchSize, b.cfg.Lease.Seconds())
+
+	return values, err
+}
+
+// settle подтверждает событие или откладывает его повторную доставку на RetryDelay
+func (b *EventBus) settle(ctx context.Context, e *eventbus.Event, group string, handlerErr error) error {
+	if handlerErr == nil {
+		sql := `UPDATE event_delivery SET acked_at = now() WHERE event_id = $1 AND consumer_group = $2`
+		_, err := b.db.Exec(ctx, sql, e.ID, group)
+		return err
+	}
+
+	logger := log.WithError(handlerErr).WithField("event", e.ID).WithField("topic", e.Topic).WithField("group", group)
+	if b.cfg.MaxAttempts > 0 && e.Attempt >= b.cfg.MaxAttempts {
+		logger.Error("Event handling failed, giving up")
+		sql := `UPDATE event_delivery SET (dead_at, last_error) = (now(), $3) WHERE event_id = $1 AND consumer_group = $2`
+		_, err := b.db.Exec(ctx, sql, e.ID, group, handlerErr.Error())
+		return err
+	}
+	logger.WithField("attempt", e.Attempt).Warn("Event handling failed, will retry")
+	sql := `
+		UPDATE event_delivery SET (available_at, last_error) = (now() + make_interval(secs => $3), $4)
+		WHERE event_id = $1 AND consumer_group = $2`
+	_, err := b.db.Exec(ctx, sql, e.ID, group, b.cfg.RetryDelay.Seconds(), handlerErr.Error())
+
+	return err
+}
+
+// DeleteDelivered строки event_delivery удаляются вместе с событием каскадом
+func (b *EventBus) DeleteDelivered(ctx context.Context, before time.Time) (int64, error) {
+	sql := `
+		DELETE FROM event e
+		WHERE e.created_at < $1
+			AND NOT EXISTS (SELECT 1 FROM event_delivery d WHERE d.event_id = e.id AND d.acked_at IS NULL)`
+	tag, err := b.db.Exec(ctx, sql, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,107 @@
+package pg
+
+import (
+	"context"
+	"errors"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/leader"
+)
+
+// LeaderLockKey ключ advisory lock лидера ("gmar"), помещается в 32 бита, поэтому в pg_locks лежит в objid
+const LeaderLockKey = 0x676d6172
+
+var _ leader.Lock = (*LeaderLock)(nil)
+
+var errLeaderLockLost = errors.New("leader lock is not held")
+
+/*
+LeaderLock advisory lock на выделенном соединении: блокировка живёт, пока открыта сессия.
+Идентификатор экземпляра записывается в application_name сессии, по нему остальные реплики видят лидера.
+*/
+type LeaderLock struct {
+	db       *Pool
+	instance string
+	conn     *pgxpool.Conn
+}
+
+func NewLeaderLock(db *Pool, instance string) *LeaderLock {
+	return &LeaderLock{db: db, instance: instance}
+}
+
+func (l *LeaderLock) TryAcquire(ctx context.Context) (bool, error) {
+	if l.conn != nil {
+		return true, nil
+	}
+	conn, err := l.db.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	if _, err := conn.Exec(ctx, `SELECT set_config('application_name', $1, false);`, l.instance); err != nil {
+		conn.Release()
+		return false, err
+	}
+	var acquired bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1);`, LeaderLockKey).Scan(&acquired); err != nil {
+		conn.Release()
+		return false, err
+	}
+	if !acquired {
+		conn.Release()
+		return false, nil
+	}
+	l.conn = conn
+
+	return true, nil
+}
+
+func (l *LeaderLock) Renew(ctx context.Context) error {
+	if l.conn == nil {
+		return errLeaderLockLost
+	}
+	var held bool
+	sql := `
+		SELECT EXISTS(SELECT 1 FROM pg_locks
+			WHERE locktype = 'advisory' AND pid = pg_backend_pid() AND objid = $1::int4::oid AND granted);`
+	if err := l.conn.QueryRow(ctx, sql, LeaderLockKey).Scan(&held); err != nil {
+		return err
+	}
+	if !held {
+		return errLeaderLockLost
+	}
+
+	return nil
+}
+
+func (l *LeaderLock) Release(ctx context.Context) error {
+	if l.conn == nil {
+		return nil
+	}
+	conn := l.conn
+	l.conn = nil
+	_, err := conn.Exec(ctx, `SELECT pg_advisory_unlock($1);`, LeaderLockKey)
+	if err != nil {
+		// сессию с неизвестным состоянием в пул не возвращаем, закрытие соединения снимает блокировку
+		err = errors.Join(err, conn.Conn().Close(ctx))
+	}
+	conn.Release()
+
+	return err
+}
+
+func (l *LeaderLock) Holder(ctx context.Context) (string, error) {
+	var holders []string
+	sql := `
+		SELECT a.application_name FROM pg_locks l
+		JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'advisory' AND l.objid = $1::int4::oid AND l.granted;`
+	if err := pgxscan.Select(ctx, l.db, &holders, sql, LeaderLockKey); err != nil {
+		return "", err
+	}
+	if len(holders) == 0 {
+		return "", nil
+	}
+
+	return holders[0], nil
+}
@@ -4,19 +4,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/georgysavva/scany/v2/pgxscan"
 	"github.com/gofrs/uuid"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
 	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
 	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
 	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
 )
 
 const (
-	OrderNumberUniqueContraint = "order_number_uindex"
+	OrderNumberUniqueContraint = "order_tenant_number_uindex"
+	TransferKeyUniqueContraint = "transfer_sender_id_idempotency_key_uindex"
 )
 
 var _ repository.Order = &OrderRepo{}
@@ -36,11 +39,25 @@ func (r *OrderRepo) Insert(ctx context.Context, order *entity.Order) error {
 			return err
 		}
 	}
+	if order.Status == "" {
+		order.Status = entity.OrderStatusNew
+	}
+	order.TenantID = tenant.FromContext(ctx)
+	historyID, err := uuid.NewV6()
+	if err != nil {
+		return err
+	}
 
 	sql := `
-		INSERT INTO "order" (id, user_id, number, status, accrual)
-		VALUES ($1, $2, $3, $4, $5)`
-	_, err := r.db.Exec(ctx, sql, order.ID, order.UserID, order.Number, order.Status, order.Accrual)
+		WITH inserted AS (
+			INSERT INTO "order" (id, user_id, number, status, accrual, processed_at, bonus, tenant_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $10)
+			RETURNING id, status
+		)
+		INSERT INTO order_status_history (id, order_id, to_status, source)
+		SELECT $8::uuid, id, status, $9::varchar FROM inserted`
+	_, err = r.db.Exec(ctx, sql, order.ID, order.UserID, order.Number, order.Status, order.Accrual, order.ProcessedAt, order.Bonus,
+		historyID, entity.OrderSourceUser, order.TenantID)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation && pgErr.ConstraintName == OrderNumberUniqueContraint {
@@ -61,8 +78,8 @@ func (r *OrderRepo) Insert(ctx context.Context, order *entity.Order) error {
 
 func (r *OrderRepo) FindByNumber(ctx context.Context, orderNumber string) (*entity.Order, error) {
 	var value entity.Order
-	sql := `SELECT * FROM "order" WHERE number = $1;`
-	err := pgxscan.Get(ctx, r.db, &value, sql, orderNumber)
+	sql := `SELECT * FROM "order" WHERE number = $1 AND tenant_id = $2;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, orderNumber, tenant.FromContext(ctx))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, domain.ErrNotFound
@@ -75,8 +92,8 @@ func (r *OrderRepo) FindByNumber(ctx context.Context, orderNumber string) (*enti
 
 func (r *OrderRepo) GetUserOrders(ctx context.Context, userID uuid.UUID) ([]entity.Order, error) {
 	var values []entity.Order
-	sql := `SELECT * FROM "order" WHERE user_id = $1;`
-	err := pgxscan.Select(ctx, r.db, &values, sql, userID)
+	sql := `SELECT * FROM "order" WHERE user_id = $1 AND tenant_id = $2;`
+	err := pgxscan.Select(ctx, r.db, &values, sql, userID, tenant.FromContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -86,8 +103,8 @@ func (r *OrderRepo) GetUserOrders(ctx context.Context, userID uuid.UUID) ([]enti
 
 func (r *OrderRepo) GetAccrualsSum(ctx context.Context, userID uuid.UUID) (float64, error) {
 	var value *float64
-	sql := `SELECT sum(accrual) FROM "order" WHERE user_id = $1 AND status = $2;`
-	err := pgxscan.Get(ctx, r.db, &value, sql, userID, entity.OrderStatusProcessed)
+	sql := `SELECT sum(accrual + coalesce(bonus, 0)) FROM "order" WHERE user_id = $1 AND status = $2 AND tenant_id = $3;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, userID, entity.OrderStatusProcessed, tenant.FromContext(ctx))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return 0, domain.ErrNotFound
@@ -101,10 +118,24 @@ func (r *OrderRepo) GetAccrualsSum(ctx context.Context, userID uuid.UUID) (float
 	return *value, err
 }
 
+func (r *OrderRepo) GetAccrualsSumSince(ctx context.Context, userID uuid.UUID, since time.Time) (float64, error) {
+	var value *float64
+	sql := `SELECT sum(accrual) FROM "order" WHERE user_id = $1 AND status = $2 AND processed_at >= $3 AND tenant_id = $4;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, userID, entity.OrderStatusProcessed, since, tenant.FromContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	if value == nil {
+		return 0, nil
+	}
+
+	return *value, nil
+}
+
 func (r *OrderRepo) GetWithdrawnSum(ctx context.Context, userID uuid.UUID) (float64, error) {
 	var value *float64
-	sql := `SELECT sum(value) FROM withdrawn WHERE user_id = $1;`
-	err := pgxscan.Get(ctx, r.db, &value, sql, userID)
+	sql := `SELECT sum(value - coalesce(refunded, 0)) FROM withdrawn WHERE user_id = $1 AND tenant_id = $2;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, userID, tenant.FromContext(ctx))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return 0, domain.ErrNotFound
@@ -118,6 +149,265 @@ func (r *OrderRepo) GetWithdrawnSum(ctx context.Context, userID uuid.UUID) (floa
 	return *value, err
 }
 
+func (r *OrderRepo) GetAdjustmentsSum(ctx context.Context, userID uuid.UUID) (float64, error) {
+	var value *float64
+	sql := `SELECT sum(amount) FROM balance_adjustment WHERE user_id = $1 AND tenant_id = $2;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, userID, tenant.FromContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	if value == nil {
+		return 0, nil
+	}
+
+	return *value, err
+}
+
+func (r *OrderRepo) AddBalanceAdjustment(ctx context.Context, a *entity.BalanceAdjustment) error {
+	if a.ID.IsNil() {
+		var err error
+		if a.ID, err = uuid.NewV6(); err != nil {
+			return err
+		}
+	}
+
+	a.TenantID = tenant.FromContext(ctx)
+
+	sql := `
+		INSERT INTO balance_adjustment (id, user_id, amount, reason, created_by, order_number, needs_review, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := r.db.Exec(ctx, sql, a.ID, a.UserID, a.Amount, a.Reason, a.CreatedBy, a.OrderNumber, a.NeedsReview, a.TenantID)
+
+	return err
+}
+
+func (r *OrderRepo) GetUserAdjustments(ctx context.Context, userID uuid.UUID) ([]entity.BalanceAdjustment, error) {
+	var values []entity.BalanceAdjustment
+	sql := `SELECT * FROM balance_adjustment WHERE user_id = $1 AND tenant_id = $2 ORDER BY created_at;`
+	err := pgxscan.Select(ctx, r.db, &values, sql, userID, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (r *OrderRepo) GetOrderAdjustmentsSum(ctx context.Context, orderNumber string) (float64, error) {
+	var value *float64
+	sql := `
+		SELECT sum(amount) FROM balance_adjustment WHERE order_number = $1 AND tenant_id = $2;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, orderNumber, tenant.FromContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	if value == nil {
+		return 0, nil
+	}
+
+	return *value, nil
+}
+
+func (r *OrderRepo) GetAdjustmentsForReview(ctx context.Context) ([]entity.BalanceAdjustment, error) {
+	var values []entity.BalanceAdjustment
+	sql := `
+		SELECT * FROM balance_adjustment WHERE needs_review AND tenant_id = $1 ORDER BY created_at;`
+	err := pgxscan.Select(ctx, r.db, &values, sql, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (r *OrderRepo) MarkAdjustmentReviewed(ctx context.Context, id uuid.UUID) (bool, error) {
+	sql := `
+		UPDATE balance_adjustment SET needs_review = false
+		WHERE id = $1 AND needs_review AND tenant_id = $2`
+	tag, err := r.db.Exec(ctx, sql, id, tenant.FromContext(ctx))
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *OrderRepo) GetExpiredSum(ctx context.Context, userID uuid.UUID) (float64, error) {
+	var value *float64
+	sql := `SELECT sum(value) FROM points_expiry WHERE user_id = $1 AND tenant_id = $2;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, userID, tenant.FromContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	if value == nil {
+		return 0, nil
+	}
+
+	return *value, nil
+}
+
+func (r *OrderRepo) AddExpiry(ctx context.Context, e *entity.PointsExpiry) error {
+	if e.ID.IsNil() {
+		var err error
+		if e.ID, err = uuid.NewV6(); err != nil {
+			return err
+		}
+	}
+
+	e.TenantID = tenant.FromContext(ctx)
+
+	sql := `
+		INSERT INTO points_expiry (id, user_id, order_number, value, expired_at, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (tenant_id, user_id, order_number) DO NOTHING`
+	_, err := r.db.Exec(ctx, sql, e.ID, e.UserID, e.OrderNumber, e.Value, e.ExpiredAt, e.TenantID)
+
+	return err
+}
+
+func (r *OrderRepo) GetUserExpirations(ctx context.Context, userID uuid.UUID) ([]entity.PointsExpiry, error) {
+	var values []entity.PointsExpiry
+	sql := `SELECT * FROM points_expiry WHERE user_id = $1 AND tenant_id = $2 ORDER BY expired_at;`
+	err := pgxscan.Select(ctx, r.db, &values, sql, userID, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (r *OrderRepo) AddCampaignCredit(ctx context.Context, c *entity.CampaignCredit) error {
+	if c.ID.IsNil() {
+		var err error
+		if c.ID, err = uuid.NewV6(); err != nil {
+			return err
+		}
+	}
+
+	c.TenantID = tenant.FromContext(ctx)
+
+	sql := `
+		INSERT INTO campaign_credit (id, campaign_id, user_id, order_number, amount, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (tenant_id, campaign_id, user_id, order_number) DO NOTHING`
+	_, err := r.db.Exec(ctx, sql, c.ID, c.CampaignID, c.UserID, c.OrderNumber, c.Amount, c.TenantID)
+
+	return err
+}
+
+func (r *OrderRepo) GetCampaignCreditsSum(ctx context.Context, userID uuid.UUID) (float64, error) {
+	var value *float64
+	sql := `SELECT sum(amount) FROM campaign_credit WHERE user_id = $1 AND tenant_id = $2;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, userID, tenant.FromContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	if value == nil {
+		return 0, nil
+	}
+
+	return *value, nil
+}
+
+func (r *OrderRepo) GetUserCampaignCredits(ctx context.Context, userID uuid.UUID) ([]entity.CampaignCredit, error) {
+	var values []entity.CampaignCredit
+	sql := `SELECT * FROM campaign_credit WHERE user_id = $1 AND tenant_id = $2 ORDER BY created_at;`
+	err := pgxscan.Select(ctx, r.db, &values, sql, userID, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (r *OrderRepo) AddTransfer(ctx context.Context, t *entity.Transfer) error {
+	if t.ID.IsNil() {
+		var err error
+		if t.ID, err = uuid.NewV6(); err != nil {
+			return err
+		}
+	}
+
+	t.TenantID = tenant.FromContext(ctx)
+
+	sql := `
+		INSERT INTO transfer (id, sender_id, recipient_id, amount, idempotency_key, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at`
+	err := r.db.QueryRow(ctx, sql, t.ID, t.SenderID, t.RecipientID, t.Amount, t.IdempotencyKey, t.TenantID).Scan(&t.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation && pgErr.ConstraintName == TransferKeyUniqueContraint {
+			return domain.ErrIdempotencyConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (r *OrderRepo) FindTransferByKey(ctx context.Context, senderID uuid.UUID, key string) (*entity.Transfer, error) {
+	var value entity.Transfer
+	sql := `SELECT * FROM transfer WHERE sender_id = $1 AND idempotency_key = $2 AND tenant_id = $3;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, senderID, key, tenant.FromContext(ctx))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("transfer idempotency key: %w", domain.ErrNotFound)
+		}
+		return nil, err
+	}
+
+	return &value, nil
+}
+
+func (r *OrderRepo) GetTransfersSum(ctx context.Context, userID uuid.UUID) (float64, error) {
+	var value *float64
+	sql := `
+		SELECT sum(CASE WHEN recipient_id = $1 THEN amount ELSE -amount END)
+		FROM transfer WHERE (sender_id = $1 OR recipient_id = $1) AND tenant_id = $2;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, userID, tenant.FromContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	if value == nil {
+		return 0, nil
+	}
+
+	return *value, nil
+}
+
+func (r *OrderRepo) GetSentTransfersSince(ctx context.Context, senderID uuid.UUID, since time.Time) (int, float64, error) {
+	var value struct {
+		Count int     `db:"count"`
+		Sum   float64 `db:"sum"`
+	}
+	sql := `
+		SELECT count(*) AS count, coalesce(sum(amount), 0) AS sum FROM transfer
+		WHERE sender_id = $1 AND created_at >= $2 AND tenant_id = $3;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, senderID, since, tenant.FromContext(ctx))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return value.Count, value.Sum, nil
+}
+
+func (r *OrderRepo) GetUserTransfers(ctx context.Context, userID uuid.UUID) ([]entity.Transfer, error) {
+	var values []entity.Transfer
+	sql := `
+		SELECT t.*, s.login AS sender_login, r.login AS recipient_login
+		FROM transfer t
+			JOIN "user" s ON s.id = t.sender_id
+			JOIN "user" r ON r.id = t.recipient_id
+		WHERE (t.sender_id = $1 OR t.recipient_id = $1) AND t.tenant_id = $2
+		ORDER BY t.created_at;`
+	err := pgxscan.Select(ctx, r.db, &values, sql, userID, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
 func (r *OrderRepo) Withdraw(ctx context.Context, w *entity.Withdraw) error {
 	if w.ID.IsNil() {
 		var err error
@@ -126,10 +416,12 @@ func (r *OrderRepo) Withdraw(ctx context.Context, w *entity.Withdraw) error {
 		}
 	}
 
+	w.TenantID = tenant.FromContext(ctx)
+
 	sql := `
-		INSERT INTO withdrawn (id, user_id, order_number, value)
-		VALUES ($1, $2, $3, $4)`
-	_, err := r.db.Exec(ctx, sql, w.ID, w.UserID, w.OrderNumber, w.Value)
+		INSERT INTO withdrawn (id, user_id, order_number, value, tenant_id)
+		VALUES ($1, $2, $3, $4, $5)`
+	_, err := r.db.Exec(ctx, sql, w.ID, w.UserID, w.OrderNumber, w.Value, w.TenantID)
 	if err != nil {
 		return err
 	}
@@ -139,8 +431,8 @@ func (r *OrderRepo) Withdraw(ctx context.Context, w *entity.Withdraw) error {
 
 func (r *OrderRepo) GetUserWithdrawals(ctx context.Context, userID uuid.UUID) ([]entity.Withdraw, error) {
 	var values []entity.Withdraw
-	sql := `SELECT * FROM withdrawn WHERE user_id = $1;`
-	err := pgxscan.Select(ctx, r.db, &values, sql, userID)
+	sql := `SELECT * FROM withdrawn WHERE user_id = $1 AND tenant_id = $2;`
+	err := pgxscan.Select(ctx, r.db, &values, sql, userID, tenant.FromContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -148,31 +440,112 @@ func (r *OrderRepo) GetUserWithdrawals(ctx context.Context, userID uuid.UUID) ([
 	return values, err
 }
 
-func (r *OrderRepo) SetOrderStatus(ctx context.Context, orderNumber string, status entity.OrderStatus) error {
+func (r *OrderRepo) FindWithdrawalByNumber(ctx context.Context, orderNumber string) (*entity.Withdraw, error) {
+	var values []entity.Withdraw
+	sql := `SELECT * FROM withdrawn WHERE order_number = $1 AND tenant_id = $2 LIMIT 2;`
+	err := pgxscan.Select(ctx, r.db, &values, sql, orderNumber, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	switch len(values) {
+	case 0:
+		return nil, fmt.Errorf("withdrawal order number: %w", domain.ErrNotFound)
+	case 1:
+		return &values[0], nil
+	default:
+		return nil, domain.ErrAmbiguousWithdrawal
+	}
+}
+
+func (r *OrderRepo) RefundWithdrawal(ctx context.Context, id uuid.UUID, amount float64) (bool, error) {
+	sql := `UPDATE withdrawn SET refunded = $2, refunded_at = now() WHERE id = $1 AND refunded IS NULL AND tenant_id = $3`
+	tag, err := r.db.Exec(ctx, sql, id, amount, tenant.FromContext(ctx))
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *OrderRepo) SetOrderStatus(
+	ctx context.Context,
+	orderNumber string,
+	status entity.OrderStatus,
+	source string,
+	payload []byte,
+) (bool, error) {
+	historyID, err := uuid.NewV6()
+	if err != nil {
+		return false, err
+	}
+
 	sql := `
-		UPDATE "order" SET status = $1
-		WHERE number = $2`
-	_, err := r.db.Exec(ctx, sql, status, orderNumber)
+		WITH previous AS (
+			SELECT id, status FROM "order" WHERE number = $1 AND tenant_id = $7 AND status = ANY($3) FOR UPDATE
+		), updated AS (
+			UPDATE "order" o SET status = $2
+			FROM previous p WHERE o.id = p.id
+			RETURNING o.id, p.status AS from_status
+		)
+		INSERT INTO order_status_history (id, order_id, from_status, to_status, source, payload)
+		SELECT $4::uuid, id, from_status, $2::order_status, $5::varchar, $6::jsonb FROM updated`
+	tag, err := r.db.Exec(ctx, sql, orderNumber, status, allowedFrom(status), historyID, source, payload, tenant.FromContext(ctx))
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	return tag.RowsAffected() == 1, nil
 }
-func (r *OrderRepo) UpdateAttributes(ctx context.Context, order *entity.Order) error {
+
+func (r *OrderRepo) UpdateAttributes(ctx context.Context, order *entity.Order, source string, payload []byte) (bool, error) {
 	if order.ID.IsNil() {
-		return domain.NewError("unable to update order: ID not set")
+		return false, domain.NewError("unable to update order: ID not set")
+	}
+	historyID, err := uuid.NewV6()
+	if err != nil {
+		return false, err
 	}
 
 	sql := `
-		UPDATE "order" SET (status, accrual) = ($2, $3)
-		WHERE id = $1`
-	_, err := r.db.Exec(ctx, sql, order.ID, order.Status, order.Accrual)
+		WITH previous AS (
+			SELECT id, status FROM "order" WHERE id = $1 AND tenant_id = $9 AND status = ANY($5) FOR UPDATE
+		), updated AS (
+			UPDATE "order" o SET (status, accrual, bonus, processed_at) = ($2, $3, $4,
+				CASE WHEN $2 = 'PROCESSED'::order_status THEN coalesce(o.processed_at, now()) ELSE o.processed_at END)
+			FROM previous p WHERE o.id = p.id
+			RETURNING o.id, p.status AS from_status
+		)
+		INSERT INTO order_status_history (id, order_id, from_status, to_status, source, payload)
+		SELECT $6::uuid, id, from_status, $2::order_status, $7::varchar, $8::jsonb FROM updated`
+	tag, err := r.db.Exec(ctx, sql, order.ID, order.Status, order.Accrual, order.Bonus, allowedFrom(order.Status),
+		historyID, source, payload, tenant.FromContext(ctx))
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *OrderRepo) GetOrderStatusHistory(ctx context.Context, orderID uuid.UUID) ([]entity.OrderStatusChange, error) {
+	var values []entity.OrderStatusChange
+	sql := `SELECT * FROM order_status_history WHERE order_id = $1 ORDER BY created_at, id;`
+	err := pgxscan.Select(ctx, r.db, &values, sql, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// allowedFrom статусы для условия status = ANY(...), из которых допустим переход в status
+func allowedFrom(status entity.OrderStatus) []string {
+	statuses := domain.AllowedFrom(status)
+	result := make([]string, len(statuses))
+	for i, s := range statuses {
+		result[i] = string(s)
+	}
+
+	return result
 }
 
 func (r *OrderRepo) GetOrdersByStatuses(ctx context.Context, statuses []string, exceptNumbers []string, limit int) ([]entity.Order, error) {
@@ -182,11 +555,11 @@ func (r *OrderRepo) GetOrdersByStatuses(ctx context.Context, statuses []string,
 		return nil, fmt.Errorf("unable to get orders: no statuses provided")
 	}
 	if len(exceptNumbers) == 0 {
-		sql := `SELECT * FROM "order" WHERE status = ANY($1) LIMIT $2;`
-		err = pgxscan.Select(ctx, r.db, &values, sql, statuses, limit)
+		sql := `SELECT * FROM "order" WHERE tenant_id = $1 AND status = ANY($2) LIMIT $3;`
+		err = pgxscan.Select(ctx, r.db, &values, sql, tenant.FromContext(ctx), statuses, limit)
 	} else {
-		sql := `SELECT * FROM "order" WHERE status = ANY($1) and number <> ANY ($2) LIMIT $3;`
-		err = pgxscan.Select(ctx, r.db, &values, sql, statuses, exceptNumbers, limit)
+		sql := `SELECT * FROM "order" WHERE tenant_id = $1 AND status = ANY($2) AND NOT (number = ANY($3)) LIMIT $4;`
+		err = pgxscan.Select(ctx, r.db, &values, sql, tenant.FromContext(ctx), statuses, exceptNumbers, limit)
 	}
 	if err != nil {
 		return nil, err
@@ -194,3 +567,72 @@ func (r *OrderRepo) GetOrdersByStatuses(ctx context.Context, statuses []string,
 
 	return values, err
 }
+
+func (r *OrderRepo) GetOrdersToReverify(
+	ctx context.Context,
+	processedSince, verifiedBefore time.Time,
+	limit int,
+) ([]entity.Order, error) {
+	var values []entity.Order
+	sql := `
+		SELECT * FROM "order"
+		WHERE tenant_id = $5 AND status = $1 AND processed_at >= $2 AND coalesce(verified_at, processed_at) < $3
+		ORDER BY coalesce(verified_at, processed_at)
+		LIMIT $4;`
+	err := pgxscan.Select(ctx, r.db, &values, sql, entity.OrderStatusProcessed, processedSince, verifiedBefore, limit,
+		tenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (r *OrderRepo) SetVerifiedAt(ctx context.Context, orderID uuid.UUID, at time.Time) error {
+	sql := `UPDATE "order" SET verified_at = $2 WHERE id = $1 AND tenant_id = $3`
+	_, err := r.db.Exec(ctx, sql, orderID, at, tenant.FromContext(ctx))
+
+	return err
+}
+
+func (r *OrderRepo) StreamStatement(
+	ctx context.Context,
+	userID uuid.UUID,
+	before time.Time,
+	f func(e *entity.StatementEntry) error,
+) error {
+	sql := `
+		SELECT at, kind, order_number, amount FROM (
+			SELECT coalesce(processed_at, created_at) AS at, 'accrual' AS kind, number AS order_number,
+				accrual + coalesce(bonus, 0) AS amount
+			FROM "order" WHERE user_id = $1 AND tenant_id = $4 AND status = $3 AND accrual IS NOT NULL
+			UNION ALL
+			SELECT created_at, 'withdrawal', order_number, -value FROM withdrawn WHERE user_id = $1 AND tenant_id = $4
+			UNION ALL
+			SELECT refunded_at, 'refund', order_number, refunded FROM withdrawn
+			WHERE user_id = $1 AND tenant_id = $4 AND refunded IS NOT NULL
+			UNION ALL
+			SELECT created_at, 'adjustment', coalesce(order_number, ''), amount FROM balance_adjustment
+			WHERE user_id = $1 AND tenant_id = $4
+			UNION ALL
+			SELECT expired_at, 'expiry', order_number, -value FROM points_expiry WHERE user_id = $1 AND tenant_id = $4
+			UNION ALL
+			SELECT created_at, 'campaign', order_number, amount FROM campaign_credit WHERE user_id = $1 AND tenant_id = $4
+			UNION ALL
+			SELECT created_at, 'transfer', '', CASE WHEN recipient_id = $1 THEN amount ELSE -amount END
+			FROM transfer WHERE (sender_id = $1 OR recipient_id = $1) AND tenant_id = $4
+		) s
+		WHERE at < $2
+		ORDER BY at, kind, order_number`
+	rows, err := r.db.Query(ctx, sql, userID, before, entity.OrderStatusProcessed, tenant.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+	// строки читаются из соединения по мере обработки, без буферизации всего результата
+	var e entity.StatementEntry
+	_, err = pgx.ForEachRow(rows, []any{&e.At, &e.Kind, &e.OrderNumber, &e.Amount}, func() error {
+		return f(&e)
+	})
+
+	return err
+}
@@ -0,0 +1,101 @@
+package pg_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/container"
+	"github.com/k-zavarnitsyn/gophermart/internal/pg"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/tests/conformance"
+	"github.com/k-zavarnitsyn/gophermart/tests/testutils"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// prepareDB контейнер с чистой схемой в базе из DATABASE_URI, без неё тест пропускается
+func prepareDB(t *testing.T) *container.Container {
+	cfg := testutils.GetConfig("../../" + config.DefaultDir)
+	if !cfg.UseDB() {
+		t.Skip("DATABASE_URI is not set")
+	}
+	cnt := container.New(cfg)
+	if err := testutils.PrepareDB(cnt); err != nil {
+		t.Fatal(err)
+	}
+
+	return cnt
+}
+
+func TestRepositoryConformance(t *testing.T) {
+	cnt := prepareDB(t)
+	db := cnt.DB()
+	suite.Run(t, conformance.NewRepositorySuite(&conformance.Backend{
+		Transactor: pg.NewTransactor(db),
+		Orders:     pg.NewOrderRepository(db),
+		Users:      pg.NewUserRepository(db),
+		Audit:      pg.NewAuditRepository(db),
+		Campaigns:  pg.NewCampaignRepository(db),
+		Referrals:  pg.NewReferralRepository(db),
+		Events: pg.NewEventBus(&config.EventBus{
+			MaxAttempts: 3, RetryDelay: 10 * time.Millisecond, PollInterval: 10 * time.Millisecond, BatchSize: 10, Lease: time.Minute,
+		}, db),
+		Archive:  pg.NewAccrualArchive(db),
+		Resetter: cnt.Resetter(),
+	}))
+}
+
+// TestUserForeignKeys внешние ключи order_user_id_fk и withdrawn_user_id_fk не дают удалить строку
+// пользователя физически: удаление пользователя только обезличивает запись
+func TestUserForeignKeys(t *testing.T) {
+	cnt := prepareDB(t)
+	ctx := context.Background()
+	users := pg.NewUserRepository(cnt.DB())
+	orders := pg.NewOrderRepository(cnt.DB())
+
+	for name, insert := range map[string]func(userID uuid.UUID) error{
+		"order": func(userID uuid.UUID) error {
+			return orders.Insert(ctx, &entity.Order{UserID: userID, Number: "3413042486", Status: entity.OrderStatusNew})
+		},
+		"withdrawn": func(userID uuid.UUID) error {
+			return orders.Withdraw(ctx, &entity.Withdraw{UserID: userID, OrderNumber: "2377225624", Value: 5})
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			u := &entity.User{ID: uuid.Must(uuid.NewV6()), Login: "fk-" + name, PasswordSHA: []byte("sha")}
+			require.NoError(t, users.Insert(ctx, u))
+			require.NoError(t, insert(u.ID))
+
+			_, err := cnt.DB().Exec(ctx, `DELETE FROM "user" WHERE id = $1`, u.ID)
+			var pgErr *pgconn.PgError
+			require.ErrorAs(t, err, &pgErr)
+			require.Equal(t, pgerrcode.ForeignKeyViolation, pgErr.Code)
+		})
+	}
+}
+
+// TestMigrateSchemaReportsLoginDuplicates база с логинами, различающимися только регистром, не обновляется:
+// миграция называет такие логины вместо ошибки построения уникального индекса
+func TestMigrateSchemaReportsLoginDuplicates(t *testing.T) {
+	cnt := prepareDB(t)
+	ctx := context.Background()
+	users := pg.NewUserRepository(cnt.DB())
+	_, err := cnt.DB().Exec(ctx, `DROP INDEX user_login_lower_uindex`)
+	require.NoError(t, err)
+	for _, login := range []string{"Alice", "alice", "bob"} {
+		require.NoError(t, users.Insert(ctx, &entity.User{ID: uuid.Must(uuid.NewV6()), Login: login, PasswordSHA: []byte("sha")}))
+	}
+
+	err = cnt.SchemaCreator().MigrateSchema(ctx)
+	require.ErrorContains(t, err, "Alice, alice")
+	require.NotContains(t, err.Error(), "bob")
+
+	_, err = cnt.DB().Exec(ctx, `UPDATE "user" SET login = 'alice2' WHERE login = 'alice'`)
+	require.NoError(t, err)
+	require.NoError(t, cnt.SchemaCreator().MigrateSchema(ctx))
+}
@@ -0,0 +1,93 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+)
+
+var _ repository.Referral = &ReferralRepo{}
+
+type ReferralRepo struct {
+	db *Pool
+}
+
+func NewReferralRepository(db *Pool) repository.Referral {
+	return &ReferralRepo{db: db}
+}
+
+func (r *ReferralRepo) Insert(ctx context.Context, referral *entity.Referral) error {
+	if referral.ID.IsNil() {
+		var err error
+		if referral.ID, err = uuid.NewV6(); err != nil {
+			return err
+		}
+	}
+
+	referral.TenantID = tenant.FromContext(ctx)
+
+	sql := `
+		INSERT INTO referral (id, referrer_id, referee_id, tenant_id)
+		VALUES ($1, $2, $3, $4)`
+	_, err := r.db.Exec(ctx, sql, referral.ID, referral.ReferrerID, referral.RefereeID, referral.TenantID)
+
+	return err
+}
+
+func (r *ReferralRepo) FindByReferee(ctx context.Context, refereeID uuid.UUID) (*entity.Referral, error) {
+	var value entity.Referral
+	sql := `SELECT * FROM referral WHERE referee_id = $1 AND tenant_id = $2;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, refereeID, tenant.FromContext(ctx))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("referral referee id: %w", domain.ErrNotFound)
+		}
+		return nil, err
+	}
+
+	return &value, nil
+}
+
+func (r *ReferralRepo) MarkRewarded(ctx context.Context, referral *entity.Referral) (bool, error) {
+	sql := `
+		UPDATE referral SET (order_number, referrer_bonus, referee_bonus, rewarded_at) = ($2, $3, $4, now())
+		WHERE id = $1 AND rewarded_at IS NULL AND tenant_id = $5`
+	tag, err := r.db.Exec(ctx, sql, referral.ID, referral.OrderNumber, referral.ReferrerBonus, referral.RefereeBonus,
+		tenant.FromContext(ctx))
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *ReferralRepo) GetUserReferrals(ctx context.Context, referrerID uuid.UUID) ([]entity.Referral, error) {
+	var values []entity.Referral
+	sql := `
+		SELECT r.*, u.login AS referee_login FROM referral r
+		JOIN "user" u ON u.id = r.referee_id
+		WHERE r.referrer_id = $1 AND r.tenant_id = $2
+		ORDER BY r.created_at;`
+	err := pgxscan.Select(ctx, r.db, &values, sql, referrerID, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (r *ReferralRepo) CountRewarded(ctx context.Context, referrerID uuid.UUID) (int, error) {
+	var value int
+	sql := `SELECT count(*) FROM referral WHERE referrer_id = $1 AND tenant_id = $2 AND rewarded_at IS NOT NULL;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, referrerID, tenant.FromContext(ctx))
+
+	return value, err
+}
@@ -6,12 +6,20 @@ import (
 	"fmt"
 
 	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
 	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
 	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
 	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
 )
 
+const (
+	UserLoginUniqueConstraint = "user_login_lower_uindex"
+)
+
 type UserRepo struct {
 	db *Pool
 }
@@ -21,18 +29,33 @@ func NewUserRepository(db *Pool) repository.User {
 }
 
 func (r *UserRepo) Insert(ctx context.Context, user *entity.User) error {
+	if user.Role == "" {
+		user.Role = entity.UserRoleUser
+	}
+	if user.ReferralCode == "" {
+		user.ReferralCode = domain.NewReferralCode()
+	}
+	user.TenantID = tenant.FromContext(ctx)
 	sql := `
-		INSERT INTO "user" (id, login, password_sha)
-		VALUES ($1, $2, $3)`
-	_, err := r.db.Exec(ctx, sql, user.ID, user.Login, user.PasswordSHA)
+		INSERT INTO "user" (id, login, password_sha, role, referral_code, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := r.db.Exec(ctx, sql, user.ID, user.Login, user.PasswordSHA, user.Role, user.ReferralCode, user.TenantID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation && pgErr.ConstraintName == UserLoginUniqueConstraint {
+			return domain.ErrLoginExists
+		}
+		return err
+	}
 
-	return err
+	return nil
 }
 
+// LoginExists проверяет логин в пределах тенанта: в разных магазинах логины могут совпадать
 func (r *UserRepo) LoginExists(ctx context.Context, login string) (bool, error) {
 	var value int
-	sql := `select 1 from "user" where login = $1;`
-	err := pgxscan.Get(ctx, r.db, &value, sql, login)
+	sql := `select 1 from "user" where lower(login) = lower($1) and tenant_id = $2;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, login, tenant.FromContext(ctx))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return false, nil
@@ -45,8 +68,8 @@ func (r *UserRepo) LoginExists(ctx context.Context, login string) (bool, error)
 
 func (r *UserRepo) FindByLoginAndPassword(ctx context.Context, login string, hashedPassword []byte) (*entity.User, error) {
 	var value entity.User
-	sql := `SELECT * FROM "user" WHERE login = $1 AND password_sha = $2;`
-	err := pgxscan.Get(ctx, r.db, &value, sql, login, hashedPassword)
+	sql := `SELECT * FROM "user" WHERE lower(login) = lower($1) AND password_sha = $2 AND deleted_at IS NULL AND tenant_id = $3;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, login, hashedPassword, tenant.FromContext(ctx))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, fmt.Errorf("login and password: %w", domain.ErrNotFound)
@@ -56,3 +79,144 @@ func (r *UserRepo) FindByLoginAndPassword(ctx context.Context, login string, has
 
 	return &value, nil
 }
+
+func (r *UserRepo) FindByID(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	var value entity.User
+	sql := `SELECT * FROM "user" WHERE id = $1 AND tenant_id = $2;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, id, tenant.FromContext(ctx))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("user id: %w", domain.ErrNotFound)
+		}
+		return nil, err
+	}
+
+	return &value, nil
+}
+
+func (r *UserRepo) FindByLogin(ctx context.Context, login string) (*entity.User, error) {
+	var value entity.User
+	sql := `SELECT * FROM "user" WHERE lower(login) = lower($1) AND tenant_id = $2;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, login, tenant.FromContext(ctx))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("user login: %w", domain.ErrNotFound)
+		}
+		return nil, err
+	}
+
+	return &value, nil
+}
+
+func (r *UserRepo) SetBlocked(ctx context.Context, id uuid.UUID, blocked bool) error {
+	sql := `UPDATE "user" SET blocked = $2 WHERE id = $1 AND tenant_id = $3`
+	tag, err := r.db.Exec(ctx, sql, id, blocked, tenant.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("user id: %w", domain.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *UserRepo) SetRole(ctx context.Context, id uuid.UUID, role entity.UserRole) error {
+	sql := `UPDATE "user" SET role = $2 WHERE id = $1 AND tenant_id = $3`
+	tag, err := r.db.Exec(ctx, sql, id, role, tenant.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("user id: %w", domain.ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdatePassword меняет пароль и увеличивает версию сессии, тем самым отзывая все выданные токены
+func (r *UserRepo) UpdatePassword(ctx context.Context, id uuid.UUID, hashedPassword []byte) (int, error) {
+	var version int
+	sql := `
+		UPDATE "user" SET password_sha = $2, session_version = session_version + 1
+		WHERE id = $1 AND deleted_at IS NULL AND tenant_id = $3
+		RETURNING session_version`
+	err := pgxscan.Get(ctx, r.db, &version, sql, id, hashedPassword, tenant.FromContext(ctx))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("user id: %w", domain.ErrNotFound)
+		}
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// Anonymize заменяет логин, стирает пароль и помечает пользователя удалённым.
+// Строка остаётся в таблице, чтобы заказы и списания сохранили ссылку на неё.
+func (r *UserRepo) Anonymize(ctx context.Context, id uuid.UUID, login string) error {
+	sql := `
+		UPDATE "user" SET login = $2, password_sha = ''::bytea, session_version = session_version + 1, deleted_at = now()
+		WHERE id = $1 AND deleted_at IS NULL AND tenant_id = $3`
+	tag, err := r.db.Exec(ctx, sql, id, login, tenant.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("user id: %w", domain.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *UserRepo) List(ctx context.Context) ([]entity.User, error) {
+	var values []entity.User
+	sql := `SELECT * FROM "user" WHERE tenant_id = $1 ORDER BY login;`
+	err := pgxscan.Select(ctx, r.db, &values, sql, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (r *UserRepo) SetTier(ctx context.Context, id uuid.UUID, tier string) error {
+	sql := `UPDATE "user" SET tier = $2 WHERE id = $1 AND tenant_id = $3`
+	tag, err := r.db.Exec(ctx, sql, id, tier, tenant.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("user id: %w", domain.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *UserRepo) FindByReferralCode(ctx context.Context, code string) (*entity.User, error) {
+	var value entity.User
+	sql := `SELECT * FROM "user" WHERE referral_code = upper($1) AND tenant_id = $2;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, code, tenant.FromContext(ctx))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("user referral code: %w", domain.ErrNotFound)
+		}
+		return nil, err
+	}
+
+	return &value, nil
+}
+
+func (r *UserRepo) LockForUpdate(ctx context.Context, id uuid.UUID) error {
+	var value uuid.UUID
+	sql := `SELECT id FROM "user" WHERE id = $1 AND tenant_id = $2 FOR UPDATE;`
+	err := pgxscan.Get(ctx, r.db, &value, sql, id, tenant.FromContext(ctx))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("user id: %w", domain.ErrNotFound)
+		}
+		return err
+	}
+
+	return nil
+}
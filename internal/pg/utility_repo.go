@@ -74,6 +74,343 @@ func (r *UtilityRepository) CreateSchema(ctx context.Context) error {
 	return err
 }
 
+// migrations идемпотентные изменения схемы, применяются по порядку при каждом старте поверх CreateSchema
+var migrations = []string{
+	`alter table "user" add column if not exists role varchar(16) default 'user' not null;
+	alter table "user" add column if not exists blocked boolean default false not null;`,
+	`create table if not exists balance_adjustment
+	(
+		id uuid not null
+			constraint balance_adjustment_pk
+				primary key,
+		user_id uuid not null
+			constraint balance_adjustment_user_id_fk
+				references "user",
+		amount double precision not null,
+		reason text not null
+			constraint balance_adjustment_reason_check
+				check (reason <> ''),
+		created_by uuid not null,
+		created_at timestamp default now() not null
+	);
+	create index if not exists balance_adjustment_user_id_index
+		on balance_adjustment (user_id);`,
+	`create table if not exists audit_log
+	(
+		id uuid not null
+			constraint audit_log_pk
+				primary key,
+		actor_id uuid not null,
+		actor_login varchar(64) not null,
+		action varchar(64) not null,
+		target varchar not null,
+		details jsonb,
+		created_at timestamp default now() not null
+	);
+	create index if not exists audit_log_created_at_index
+		on audit_log (created_at);
+	create or replace function audit_log_immutable() returns trigger as $$
+	begin
+		raise exception 'audit_log is append-only';
+	end;
+	$$ language plpgsql;
+	drop trigger if exists audit_log_immutable_trigger on audit_log;
+	create trigger audit_log_immutable_trigger
+		before update or delete on audit_log
+		for each row execute function audit_log_immutable();`,
+	`alter table "user" add column if not exists session_version integer default 0 not null;
+	alter table "user" add column if not exists deleted_at timestamp;`,
+	`create unique index if not exists user_login_lower_uindex
+		on "user" (lower(login));`,
+	`alter table "order" add column if not exists processed_at timestamp;
+	update "order" set processed_at = created_at where status = 'PROCESSED' and processed_at is null;
+	create table if not exists points_expiry
+	(
+		id uuid not null
+			constraint points_expiry_pk
+				primary key,
+		user_id uuid not null
+			constraint points_expiry_user_id_fk
+				references "user",
+		order_number varchar not null,
+		value double precision not null,
+		expired_at timestamp not null,
+		created_at timestamp default now() not null
+	);
+	create unique index if not exists points_expiry_order_number_uindex
+		on points_expiry (order_number);
+	create index if not exists points_expiry_user_id_index
+		on points_expiry (user_id);`,
+	`alter table "order" add column if not exists bonus double precision;
+	alter table "user" add column if not exists tier varchar(32) default '' not null;`,
+	`create table if not exists campaign
+	(
+		id uuid not null
+			constraint campaign_pk
+				primary key,
+		name varchar not null,
+		kind varchar(32) not null,
+		value double precision not null,
+		starts_at timestamp,
+		ends_at timestamp,
+		first_order_only boolean default false not null,
+		min_order_count integer default 0 not null,
+		user_cap double precision default 0 not null,
+		enabled boolean default true not null,
+		created_at timestamp default now() not null
+	);
+	create table if not exists campaign_credit
+	(
+		id uuid not null
+			constraint campaign_credit_pk
+				primary key,
+		campaign_id uuid not null,
+		user_id uuid not null
+			constraint campaign_credit_user_id_fk
+				references "user",
+		order_number varchar not null,
+		amount double precision not null,
+		created_at timestamp default now() not null
+	);
+	create unique index if not exists campaign_credit_campaign_order_uindex
+		on campaign_credit (campaign_id, order_number);
+	create index if not exists campaign_credit_user_id_index
+		on campaign_credit (user_id);`,
+	`alter table "user" add column if not exists referral_code varchar(16)
+		default upper(substr(md5(random()::text), 1, 10)) not null;
+	create unique index if not exists user_referral_code_uindex
+		on "user" (referral_code);
+	create table if not exists referral
+	(
+		id uuid not null
+			constraint referral_pk
+				primary key,
+		referrer_id uuid not null
+			constraint referral_referrer_id_fk
+				references "user",
+		referee_id uuid not null
+			constraint referral_referee_id_fk
+				references "user",
+		order_number varchar,
+		referrer_bonus double precision default 0 not null,
+		referee_bonus double precision default 0 not null,
+		created_at timestamp default now() not null,
+		rewarded_at timestamp
+	);
+	create unique index if not exists referral_referee_id_uindex
+		on referral (referee_id);
+	create index if not exists referral_referrer_id_index
+		on referral (referrer_id);`,
+	`create table if not exists transfer
+	(
+		id uuid not null
+			constraint transfer_pk
+				primary key,
+		sender_id uuid not null
+			constraint transfer_sender_id_fk
+				references "user",
+		recipient_id uuid not null
+			constraint transfer_recipient_id_fk
+				references "user",
+		amount double precision not null
+			constraint transfer_amount_check
+				check (amount > 0),
+		idempotency_key varchar(128) not null,
+		created_at timestamp default now() not null
+	);
+	create unique index if not exists transfer_sender_id_idempotency_key_uindex
+		on transfer (sender_id, idempotency_key);
+	create index if not exists transfer_recipient_id_index
+		on transfer (recipient_id);`,
+	`alter table withdrawn add column if not exists refunded double precision;
+	alter table withdrawn add column if not exists refunded_at timestamp;
+	create index if not exists withdrawn_order_number_index
+		on withdrawn (order_number);`,
+	`create table if not exists order_status_history
+	(
+		id uuid not null
+			constraint order_status_history_pk
+				primary key,
+		order_id uuid not null
+			constraint order_status_history_order_id_fk
+				references "order",
+		from_status order_status,
+		to_status order_status not null,
+		source varchar(32) not null,
+		payload jsonb,
+		created_at timestamp default clock_timestamp() not null
+	);
+	create index if not exists order_status_history_order_id_index
+		on order_status_history (order_id, created_at);`,
+	`create table if not exists event
+	(
+		id uuid not null
+			constraint event_pk
+				primary key,
+		topic varchar(64) not null,
+		payload jsonb not null,
+		created_at timestamp default clock_timestamp() not null
+	);
+	create table if not exists event_subscription
+	(
+		topic varchar(64) not null,
+		consumer_group varchar(64) not null,
+		constraint event_subscription_pk
+			primary key (topic, consumer_group)
+	);
+	create table if not exists event_delivery
+	(
+		event_id uuid not null
+			constraint event_delivery_event_id_fk
+				references event
+					on delete cascade,
+		consumer_group varchar(64) not null,
+		attempts integer default 0 not null,
+		available_at timestamp default clock_timestamp() not null,
+		acked_at timestamp,
+		dead_at timestamp,
+		last_error text,
+		constraint event_delivery_pk
+			primary key (event_id, consumer_group)
+	);
+	create index if not exists event_delivery_pending_index
+		on event_delivery (consumer_group, available_at)
+		where acked_at is null and dead_at is null;`,
+	`alter table "order" add column if not exists verified_at timestamp;
+	alter table balance_adjustment add column if not exists order_number varchar;
+	alter table balance_adjustment add column if not exists needs_review boolean default false not null;
+	create index if not exists balance_adjustment_order_number_index
+		on balance_adjustment (order_number);
+	create index if not exists balance_adjustment_needs_review_index
+		on balance_adjustment (created_at)
+		where needs_review;`,
+	// логины и номера заказов уникальны в пределах тенанта, поэтому и сгорание с бонусами акций различаются по
+	// тенанту и пользователю. Прежние уникальные индексы пересоздаются под тем же именем: предыдущие миграции выполняются
+	// при каждом старте и вернули бы индекс без тенанта, если бы его просто удалили.
+	`alter table "user" add column if not exists tenant_id varchar(64) default 'default' not null;
+	alter table "order" add column if not exists tenant_id varchar(64) default 'default' not null;
+	alter table withdrawn add column if not exists tenant_id varchar(64) default 'default' not null;
+	alter table campaign add column if not exists tenant_id varchar(64) default 'default' not null;
+	alter table audit_log add column if not exists tenant_id varchar(64) default 'default' not null;
+	alter table balance_adjustment add column if not exists tenant_id varchar(64) default 'default' not null;
+	alter table points_expiry add column if not exists tenant_id varchar(64) default 'default' not null;
+	alter table campaign_credit add column if not exists tenant_id varchar(64) default 'default' not null;
+	alter table transfer add column if not exists tenant_id varchar(64) default 'default' not null;
+	alter table referral add column if not exists tenant_id varchar(64) default 'default' not null;
+	create unique index if not exists order_tenant_number_uindex
+		on "order" (tenant_id, number);
+	drop index if exists order_number_uindex;
+	create index if not exists order_tenant_status_index
+		on "order" (tenant_id, status);
+	create index if not exists withdrawn_tenant_order_number_index
+		on withdrawn (tenant_id, order_number);
+	create index if not exists campaign_tenant_id_index
+		on campaign (tenant_id);
+	create index if not exists audit_log_tenant_created_at_index
+		on audit_log (tenant_id, created_at);
+	create index if not exists balance_adjustment_tenant_user_id_index
+		on balance_adjustment (tenant_id, user_id);
+	do $$
+	begin
+		if exists (select from pg_indexes
+			where indexname = 'user_login_lower_uindex' and indexdef not like '%(tenant_id, lower(%') then
+			drop index user_login_lower_uindex;
+		end if;
+		if exists (select from pg_indexes
+			where indexname = 'points_expiry_order_number_uindex'
+				and indexdef not like '%(tenant_id, user_id, order_number)') then
+			drop index points_expiry_order_number_uindex;
+		end if;
+		if exists (select from pg_indexes
+			where indexname = 'campaign_credit_campaign_order_uindex'
+				and indexdef not like '%(tenant_id, campaign_id, user_id, order_number)') then
+			drop index campaign_credit_campaign_order_uindex;
+		end if;
+		if exists (select from pg_indexes
+			where indexname = 'transfer_sender_id_idempotency_key_uindex'
+				and indexdef not like '%(tenant_id, sender_id, idempotency_key)') then
+			drop index transfer_sender_id_idempotency_key_uindex;
+		end if;
+		if exists (select from pg_indexes
+			where indexname = 'referral_referee_id_uindex' and indexdef not like '%(tenant_id, referee_id)') then
+			drop index referral_referee_id_uindex;
+		end if;
+	end $$;
+	create unique index if not exists user_login_lower_uindex
+		on "user" (tenant_id, lower(login));
+	create unique index if not exists points_expiry_order_number_uindex
+		on points_expiry (tenant_id, user_id, order_number);
+	create unique index if not exists campaign_credit_campaign_order_uindex
+		on campaign_credit (tenant_id, campaign_id, user_id, order_number);
+	create unique index if not exists transfer_sender_id_idempotency_key_uindex
+		on transfer (tenant_id, sender_id, idempotency_key);
+	create unique index if not exists referral_referee_id_uindex
+		on referral (tenant_id, referee_id);`,
+	// тело ответа хранится как есть, в карантин попадают и ответы, которые не разбираются как JSON
+	`create table if not exists accrual_response
+	(
+		id uuid not null
+			constraint accrual_response_pk
+				primary key,
+		tenant_id varchar(64) default 'default' not null,
+		order_number varchar not null,
+		status_code integer not null,
+		payload bytea,
+		error text,
+		created_at timestamp default clock_timestamp() not null
+	);
+	create index if not exists accrual_response_tenant_order_number_index
+		on accrual_response (tenant_id, order_number, created_at);
+	create index if not exists accrual_response_quarantine_index
+		on accrual_response (tenant_id, created_at)
+		where error is not null;
+	create index if not exists accrual_response_created_at_index
+		on accrual_response (created_at);`,
+	`create index if not exists event_created_at_index
+		on event (created_at);`,
+}
+
+func (r *UtilityRepository) MigrateSchema(ctx context.Context) error {
+	if err := r.checkLoginDuplicates(ctx); err != nil {
+		return err
+	}
+	for i, sql := range migrations {
+		if _, err := r.db.Exec(ctx, sql); err != nil {
+			return fmt.Errorf("migration %d failed: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// checkLoginDuplicates до создания уникального индекса по lower(login) ищет логины, различающиеся только регистром.
+// Без проверки миграция упала бы на построении индекса, не назвав учётные записи, которые нужно переименовать.
+func (r *UtilityRepository) checkLoginDuplicates(ctx context.Context) error {
+	var indexed bool
+	err := pgxscan.Get(ctx, r.db, &indexed, `SELECT EXISTS (SELECT FROM pg_indexes WHERE indexname = 'user_login_lower_uindex');`)
+	if err != nil || indexed {
+		return err
+	}
+	if exists, err := r.TableExists(ctx, "user"); err != nil || !exists {
+		return err
+	}
+
+	var duplicates []string
+	sql := `
+		SELECT string_agg(login, ', ' ORDER BY login) FROM "user"
+		GROUP BY lower(login) HAVING count(*) > 1
+		ORDER BY lower(login);`
+	if err := pgxscan.Select(ctx, r.db, &duplicates, sql); err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("logins must be unique regardless of case, rename all but one account in each group "+
+			"(UPDATE \"user\" SET login = ... WHERE login = ...) and restart: %s", strings.Join(duplicates, "; "))
+	}
+
+	return nil
+}
+
 func (r *UtilityRepository) SchemaDefined(ctx context.Context) (bool, error) {
 	if exists, err := r.TableExists(ctx, "order"); err != nil || !exists {
 		return exists, err
@@ -101,7 +438,7 @@ func (r *UtilityRepository) TableExists(ctx context.Context, name string) (bool,
 }
 
 func (r *UtilityRepository) Reset() error {
-	if err := r.Truncate(context.Background(), "withdrawn", "order", "user"); err != nil {
+	if err := r.Truncate(context.Background(), "accrual_response", "event_delivery", "event", "audit_log", "transfer", "referral", "campaign_credit", "campaign", "points_expiry", "balance_adjustment", "withdrawn", "order_status_history", "order", "user"); err != nil {
 		return err
 	}
 
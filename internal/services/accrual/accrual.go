@@ -3,13 +3,18 @@ package accrual
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/eventbus"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
 	"github.com/k-zavarnitsyn/gophermart/internal/utils"
 	"github.com/k-zavarnitsyn/gophermart/internal/workers"
 	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
@@ -20,12 +25,99 @@ import (
 /*
 Сервис обрабатывает запросы в том же процессе, стартуя и завершая горутины, но при превышении
 допустимого числа потоков происходит переход в состояние перегрузки и заказы не обрабатываются,
-пока их не подцепит функция обработки по таймеру.
-TODO: Отправлять события в брокер сообщений для параллельной обработки на нескольких нодах
+пока их не подцепит функция обработки по таймеру. Новые заказы приходят событием OrderUploaded,
+о расчёте заказа сервис сообщает событием OrderProcessed. Если включена повторная сверка, недавно
+рассчитанные заказы медленно перезапрашиваются, а изменившееся начисление передаётся AccrualChangedHook.
+Ответ, не прошедший проверку (parseResponse), не меняет заказ и сохраняется в архив ответов как карантинный.
 */
 
 type Service interface {
 	Send(ctx context.Context, order *entity.Order) error
+	// CircuitState состояние circuit breaker системы расчёта для readiness
+	CircuitState() CircuitState
+	// QueueStats состояние очереди заказов, ожидающих запроса в систему расчёта
+	QueueStats() workers.QueueStats
+}
+
+// OrderProcessedHook дообработка заказа, перешедшего в PROCESSED, в одной транзакции с его сохранением
+type OrderProcessedHook interface {
+	OrderProcessed(ctx context.Context, order *entity.Order) error
+}
+
+// ProcessedHookFunc функция как OrderProcessedHook
+type ProcessedHookFunc func(ctx context.Context, order *entity.Order) error
+
+func (f ProcessedHookFunc) OrderProcessed(ctx context.Context, order *entity.Order) error {
+	return f(ctx, order)
+}
+
+// AccrualChangedHook учитывает начисление, которое система расчёта вернула для уже рассчитанного заказа.
+// Вызывается при каждой сверке, сравнение с учтённым начислением остаётся за hook.
+type AccrualChangedHook interface {
+	AccrualChanged(ctx context.Context, order *entity.Order, accrual float64) error
+}
+
+type Transactor interface {
+	Transaction(ctx context.Context, f func(ctx context.Context) error) error
+}
+
+type Option func(s *service)
+
+// WithEventBus публикует OrderProcessed в транзакции сохранения рассчитанного заказа
+func WithEventBus(trx Transactor, bus eventbus.Bus) Option {
+	return func(s *service) {
+		s.trx = trx
+		s.bus = bus
+	}
+}
+
+// WithLeadership опрос заказов по таймеру идёт, только пока isLeader возвращает true,
+// чтобы реплики не запрашивали систему расчёта по одним и тем же заказам
+func WithLeadership(isLeader func() bool) Option {
+	return func(s *service) {
+		s.isLeader = isLeader
+	}
+}
+
+// WithClock подменяет часы сервиса: circuit breaker, паузу по Retry-After и перепроверку заказов,
+// чтобы тесты не ждали реальные интервалы
+func WithClock(now func() time.Time) Option {
+	return func(s *service) {
+		s.now = now
+	}
+}
+
+// WithProcessedHook вызывает hook перед сохранением заказа со статусом PROCESSED.
+// Несколько hook вызываются в порядке добавления.
+func WithProcessedHook(trx Transactor, hook OrderProcessedHook) Option {
+	return func(s *service) {
+		s.trx = trx
+		s.processedHooks = append(s.processedHooks, hook)
+	}
+}
+
+// WithReverification включает повторную сверку рассчитанных заказов по cfg.Reverify,
+// hook и отметка о сверке выполняются в одной транзакции
+func WithReverification(trx Transactor, hook AccrualChangedHook) Option {
+	return func(s *service) {
+		s.trx = trx
+		s.changedHook = hook
+	}
+}
+
+// WithTenants опрашивает заказы каждого из tenants и запрашивает их начисления в системе расчёта тенанта.
+// Без опции обрабатываются только заказы tenant.Default.
+func WithTenants(tenants []config.Tenant) Option {
+	return func(s *service) {
+		s.tenants = tenants
+	}
+}
+
+// WithArchive сохраняет исходные ответы системы расчёта, включая помещённые в карантин, если включён cfg.Archive
+func WithArchive(archive repository.AccrualArchive) Option {
+	return func(s *service) {
+		s.archive = archive
+	}
 }
 
 type service struct {
@@ -33,28 +125,108 @@ type service struct {
 	mainWorker        *workers.OverloadableWorker[*entity.Order]
 	overloadCounter   int
 	overloadStartTime time.Time
-	processingOrders  sync.Map
-	tickMu            sync.Mutex
-	ticker            *time.Ticker
+	// processingOrders заказы в очереди и в обработке по processingKey
+	processingOrders sync.Map
+	tickMu           sync.Mutex
+	ticker           *time.Ticker
+	// pausedUntil время в UnixNano, до которого система расчёта просила не присылать запросы (429)
+	pausedUntil atomic.Int64
+	now         func() time.Time
+	breaker     *circuitBreaker
+	isLeader    func() bool
+	tenants     []config.Tenant
 
-	orderRepo repository.Order
+	trx            Transactor
+	processedHooks []OrderProcessedHook
+	changedHook    AccrualChangedHook
+	bus            eventbus.Bus
+	orderRepo      repository.Order
+	archive        repository.AccrualArchive
 }
 
 type accrualResponse struct {
-	OrderNumber string  `json:"order"`
-	Status      string  `json:"status"`
-	Accrual     float64 `json:"accrual"`
+	OrderNumber string   `json:"order"`
+	Status      string   `json:"status"`
+	Accrual     *float64 `json:"accrual"`
+
+	// raw исходное тело ответа для истории статусов заказа
+	raw []byte
+}
+
+// maxResponseSize ограничение тела ответа; обрезанный ответ не разбирается и попадает в карантин
+const maxResponseSize = 64 << 10
+
+const statusProcessed = `PROCESSED`
+
+// accrualStatuses статусы системы расчёта и соответствующие им статусы заказа
+var accrualStatuses = map[string]entity.OrderStatus{
+	`REGISTERED`:    entity.OrderStatusNew,        // заказ зарегистрирован, но начисление не рассчитано
+	`PROCESSING`:    entity.OrderStatusProcessing, // расчёт начисления в процессе
+	`INVALID`:       entity.OrderStatusInvalid,    // заказ не принят к расчёту, вознаграждение не будет начислено
+	statusProcessed: entity.OrderStatusProcessed,  // расчёт начисления окончен
+}
+
+// errBadResponse ответ системы расчёта не прошёл проверку и не применяется к заказу
+var errBadResponse = errors.New("bad accrual response")
+
+// parseResponse разбирает ответ на запрос по заказу number. Ответ должен относиться к этому заказу,
+// иметь известный статус, а неотрицательное начисление допускается только в статусе PROCESSED.
+func parseResponse(data []byte, number string) (*accrualResponse, error) {
+	var resp accrualResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("%w: %w", errBadResponse, err)
+	}
+	resp.raw = data
+	if resp.OrderNumber != number {
+		return nil, fmt.Errorf("%w: order %q instead of %q", errBadResponse, resp.OrderNumber, number)
+	}
+	if _, ok := accrualStatuses[resp.Status]; !ok {
+		return nil, fmt.Errorf("%w: unknown status %q", errBadResponse, resp.Status)
+	}
+	if resp.Accrual == nil {
+		return &resp, nil
+	}
+	if resp.Status != statusProcessed {
+		return nil, fmt.Errorf("%w: accrual in status %s", errBadResponse, resp.Status)
+	}
+	if *resp.Accrual < 0 {
+		return nil, fmt.Errorf("%w: negative accrual %v", errBadResponse, *resp.Accrual)
+	}
+
+	return &resp, nil
 }
 
-func NewService(cfg *config.Accrual, orderRepo repository.Order) Service {
+// errTransitionRejected текущий статус заказа не допускает перехода, изменения hook откатываются
+var errTransitionRejected = errors.New("order status transition rejected")
+
+func NewService(cfg *config.Accrual, orderRepo repository.Order, opts ...Option) Service {
 	s := &service{
-		cfg:               cfg,
-		orderRepo:         orderRepo,
-		overloadStartTime: time.Now(),
-		ticker:            time.NewTicker(cfg.PollingInterval),
+		cfg:       cfg,
+		orderRepo: orderRepo,
+		ticker:    time.NewTicker(cfg.PollingInterval),
+		now:       time.Now,
+		isLeader:  func() bool { return true },
 	}
-	s.mainWorker = workers.NewOverloadableWorker(cfg.MaxActiveWorkers, s.ProcessOrder, s.ProcessOrderOnOverload)
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.overloadStartTime = s.now()
+	s.breaker = newCircuitBreaker(&cfg.CircuitBreaker, s.now)
+	var limiter workers.Limiter = workers.FixedLimit(cfg.MaxActiveWorkers)
+	if cfg.AdaptiveLimit.Enabled {
+		limiter = workers.NewAIMDLimiter(cfg.MinActiveWorkers, cfg.MaxActiveWorkers, cfg.AdaptiveLimit.InitialLimit,
+			cfg.AdaptiveLimit.LatencyThreshold, cfg.AdaptiveLimit.Backoff)
+	}
+	queueCfg := workers.QueueConfig{
+		Size:        cfg.Queue.Size,
+		Overflow:    workers.OverflowPolicy(cfg.Queue.Overflow),
+		TaskTimeout: cfg.Queue.TaskTimeout,
+	}
+	s.mainWorker = workers.NewQueuedWorker(queueCfg, limiter, s.ProcessOrder, s.ProcessOrderOnOverload)
 	s.runTicker()
+	if s.changedHook != nil && cfg.Reverify.WindowDays > 0 && cfg.Reverify.Interval > 0 {
+		s.runReverification()
+	}
 
 	return s
 }
@@ -67,101 +239,264 @@ func (s *service) runTicker() {
 	}()
 }
 
+func (s *service) runReverification() {
+	go func() {
+		ticker := time.NewTicker(s.cfg.Reverify.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.reverifyTick()
+		}
+	}()
+}
+
 func (s *service) Send(ctx context.Context, order *entity.Order) error {
 	// для масштабируемости событию хорошо бы уходить в кафку, но пока обработка в том же процессе
-	s.processingOrders.Store(order.Number, struct{}{})
-	s.mainWorker.Add(ctx, order)
+	s.enqueue(ctx, order, workers.PriorityHigh)
 
 	return nil
 }
 
+func (s *service) QueueStats() workers.QueueStats {
+	return s.mainWorker.Stats()
+}
+
+// enqueue заказы, подобранные опросом по таймеру, ставятся в младшую полосу, чтобы не задерживать новые.
+// Заказ обрабатывается в тенанте, к которому относится. Контекст отвязывается от отмены: Send вызывают
+// из HTTP-обработчиков, контекст которых отменяется сразу после ответа клиенту.
+func (s *service) enqueue(ctx context.Context, order *entity.Order, priority workers.Priority) {
+	ctx = context.WithoutCancel(ctx)
+	if order.TenantID != "" {
+		ctx = tenant.ToContext(ctx, order.TenantID)
+	}
+	s.processingOrders.Store(newProcessingKey(ctx, order.Number), struct{}{})
+	s.mainWorker.AddWithPriority(ctx, order, priority)
+}
+
+type processingKey struct {
+	tenant string
+	number string
+}
+
+func newProcessingKey(ctx context.Context, number string) processingKey {
+	return processingKey{tenant: tenant.FromContext(ctx), number: number}
+}
+
+// tenantIDs тенанты, заказы которых опрашиваются по таймеру
+func (s *service) tenantIDs() []string {
+	if len(s.tenants) == 0 {
+		return []string{tenant.Default}
+	}
+	ids := make([]string, len(s.tenants))
+	for i := range s.tenants {
+		ids[i] = s.tenants[i].ID
+	}
+
+	return ids
+}
+
+// accrualAddress адрес системы расчёта тенанта из ctx
+func (s *service) accrualAddress(ctx context.Context) string {
+	id := tenant.FromContext(ctx)
+	for i := range s.tenants {
+		if s.tenants[i].ID == id && s.tenants[i].AccrualSystemAddress != "" {
+			return s.tenants[i].AccrualSystemAddress
+		}
+	}
+
+	return s.cfg.AccrualSystemAddress
+}
+
+func (s *service) CircuitState() CircuitState {
+	return s.breaker.State()
+}
+
 func (s *service) ProcessOrder(ctx context.Context, order *entity.Order) {
+	// заказ снова доступен для обработки по таймеру, в том числе после ошибки
+	defer s.processingOrders.Delete(newProcessingKey(ctx, order.Number))
+	if s.paused() || !s.breaker.Allow() {
+		return
+	}
+
+	// 0 - ошибка запроса или ответ в карантине, причина уже записана в лог
 	status, resp := s.getResponse(ctx, order)
 	if status == http.StatusOK {
-		if resp == nil {
-			s.processError(ctx, fmt.Errorf("status is ok, but response is empty"), order, "unexpected behaviour")
-			return
-		}
-		var orderStatus entity.OrderStatus
-		switch resp.Status {
-		case `REGISTERED`: // — заказ зарегистрирован, но не начисление не рассчитано;
-			orderStatus = entity.OrderStatusNew
-		case `PROCESSING`: // — расчёт начисления в процессе;
-			orderStatus = entity.OrderStatusProcessing
-		case `INVALID`: // — заказ не принят к расчёту, и вознаграждение не будет начислено;
-			orderStatus = entity.OrderStatusInvalid
-		case `PROCESSED`: // — расчёт начисления окончен;
-			orderStatus = entity.OrderStatusProcessed
-		default:
-			s.processError(ctx, fmt.Errorf("invalid status %s", resp.Status), order, "unexpected behaviour")
-			return
-		}
-		if resp.Accrual <= 0 {
-			err := s.orderRepo.SetOrderStatus(ctx, order.Number, orderStatus)
+		orderStatus := accrualStatuses[resp.Status]
+		// переход в PROCESSED всегда идёт через updateOrder, даже без начисления: подписчики OrderProcessed
+		// (первый заказ для реферальной программы и акций) должны увидеть каждый обработанный заказ
+		if orderStatus != entity.OrderStatusProcessed && utils.FromPointer(resp.Accrual) == 0 {
+			updated, err := s.orderRepo.SetOrderStatus(ctx, order.Number, orderStatus, entity.OrderSourceAccrual, resp.raw)
 			if err != nil {
 				log.WithError(err).WithField("order", order.Number).WithField("resp", resp).Error("Failed to set order status")
+			} else if !updated {
+				logRejected(order, orderStatus)
 			}
 			return
 		}
 		order.Status = orderStatus
-		if orderStatus == entity.OrderStatusProcessed || orderStatus == entity.OrderStatusInvalid {
-			order.Accrual = utils.ToPointer(resp.Accrual)
-		}
+		order.Accrual = resp.Accrual
 
-		err := s.orderRepo.UpdateAttributes(ctx, order)
-		if err != nil {
+		if err := s.updateOrder(ctx, order, resp.raw); errors.Is(err, errTransitionRejected) {
+			logRejected(order, orderStatus)
+		} else if err != nil {
 			log.WithError(err).WithField("order", order).WithField("resp", resp).Error("Failed to update order")
 		}
 	} else if status == http.StatusNoContent {
 		// `204` - заказ не зарегистрирован в системе расчета.
-		err := s.orderRepo.SetOrderStatus(ctx, order.Number, entity.OrderStatusInvalid)
+		updated, err := s.orderRepo.SetOrderStatus(ctx, order.Number, entity.OrderStatusInvalid, entity.OrderSourceAccrual, nil)
 		if err != nil {
 			log.WithError(err).WithField("order", order.Number).Error("Failed to set order status")
+		} else if !updated {
+			logRejected(order, entity.OrderStatusInvalid)
 		}
-	} else {
+	} else if status != 0 && status != http.StatusTooManyRequests {
 		s.processError(ctx, fmt.Errorf("bad status %d", status), order, "")
 	}
-	s.processingOrders.Delete(order.Number)
+}
+
+func (s *service) updateOrder(ctx context.Context, order *entity.Order, payload []byte) error {
+	update := func(ctx context.Context) error {
+		updated, err := s.orderRepo.UpdateAttributes(ctx, order, entity.OrderSourceAccrual, payload)
+		if err == nil && !updated {
+			return errTransitionRejected
+		}
+		return err
+	}
+	if order.Status != entity.OrderStatusProcessed || (len(s.processedHooks) == 0 && s.bus == nil) {
+		return update(ctx)
+	}
+
+	return s.trx.Transaction(ctx, func(ctx context.Context) error {
+		for _, hook := range s.processedHooks {
+			if err := hook.OrderProcessed(ctx, order); err != nil {
+				return err
+			}
+		}
+		if err := update(ctx); err != nil {
+			return err
+		}
+		if s.bus == nil {
+			return nil
+		}
+		return s.bus.Publish(ctx, entity.EventOrderProcessed, entity.NewOrderEvent(order))
+	})
+}
+
+// HandleOrderUploaded обработчик события OrderUploaded: заказ отправляется на расчёт
+func HandleOrderUploaded(s Service) eventbus.Handler {
+	return func(ctx context.Context, e *eventbus.Event) error {
+		event, err := eventbus.Decode[entity.OrderEvent](e)
+		if err != nil {
+			return err
+		}
+		return s.Send(ctx, event.Order())
+	}
+}
+
+// logRejected опоздавший или повторный ответ системы расчёта не меняет статус заказа
+func logRejected(order *entity.Order, status entity.OrderStatus) {
+	log.WithField("order", order.Number).WithField("status", status).Debug("Order status transition rejected")
 }
 
 func (s *service) ProcessOrderOnOverload(ctx context.Context, order *entity.Order) {
-	s.processingOrders.Delete(order.Number)
+	s.processingOrders.Delete(newProcessingKey(ctx, order.Number))
 	log.WithField("order", order.Number).Info("Processing order on overload")
 	// Ничего не делаем, эти заказы обработаются позже
 
 	s.overloadCounter++
 	if s.overloadCounter >= s.cfg.OverloadReportCount {
-		if float64(s.overloadCounter)/time.Since(s.overloadStartTime).Seconds() > s.cfg.OverloadReportRPS {
+		if float64(s.overloadCounter)/s.now().Sub(s.overloadStartTime).Seconds() > s.cfg.OverloadReportRPS {
 			log.WithField("order", order.Number).Error("Too many overload events")
 		}
 		s.overloadCounter = 0
-		s.overloadStartTime = time.Now()
+		s.overloadStartTime = s.now()
 	}
 }
 
 func (s *service) getResponse(ctx context.Context, order *entity.Order) (int, *accrualResponse) {
-	url := fmt.Sprintf("%s/api/orders/%s", s.cfg.AccrualSystemAddress, order.Number)
-	resp, err := http.Get(url) //nolint:gosec // Variable url is fine here (number is validated)
+	url := fmt.Sprintf("%s/api/orders/%s", s.accrualAddress(ctx), order.Number)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil) //nolint:gosec // Variable url is fine here (number is validated)
+	if err != nil {
+		s.breaker.Abandon()
+		s.processError(ctx, err, order, "Failed to build accrual request")
+		return 0, nil
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if errors.Is(err, context.Canceled) {
+		// отмена с нашей стороны (остановка сервиса) не говорит о состоянии системы расчёта
+		s.breaker.Abandon()
+		log.WithError(err).WithField("order", order.Number).Info("Accrual request canceled")
+		return 0, nil
+	}
 	if err != nil {
+		s.breaker.Failure()
+		workers.MarkFailed(ctx)
 		s.processError(ctx, err, order, "Failed to send request to accrual")
 		return 0, nil
 	}
 	defer utils.CloseWithLogging(resp.Body)
+	// 429 означает, что система расчёта жива, его обрабатывает пауза по Retry-After,
+	// но одновременных запросов при этом стоит делать меньше
+	if resp.StatusCode >= http.StatusInternalServerError {
+		s.breaker.Failure()
+	} else {
+		s.breaker.Success()
+	}
+	if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+		workers.MarkFailed(ctx)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		s.pause(resp.Header.Get("Retry-After"))
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		s.archiveResponse(ctx, order.Number, resp.StatusCode, nil, nil)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return resp.StatusCode, nil
 	}
 
-	data, err := io.ReadAll(resp.Body)
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
 	if err != nil {
 		s.processError(ctx, err, order, "Failed to read response body")
 		return 0, nil
 	}
-	var response accrualResponse
-	if err := json.Unmarshal(data, &response); err != nil {
-		s.processError(ctx, err, order, "Failed to parse response body")
+	response, err := parseResponse(data, order.Number)
+	s.archiveResponse(ctx, order.Number, resp.StatusCode, data, err)
+	if err != nil {
+		// заказ остаётся в прежнем статусе и будет запрошен снова
+		log.WithError(err).WithField("order", order.Number).WithField("body", string(data)).Warn("Accrual response quarantined")
+		return 0, nil
 	}
 
-	return resp.StatusCode, &response
+	return resp.StatusCode, response
+}
+
+// archiveResponse сохраняет ответ по заказу; cause - ошибка проверки, с которой ответ помещается в карантин.
+// Ответы в карантин сохраняются и с выключенным архивом, иначе по ним не разобраться, почему заказ не меняет статус.
+func (s *service) archiveResponse(ctx context.Context, number string, code int, payload []byte, cause error) {
+	if s.archive == nil || (!s.cfg.Archive.Enabled && cause == nil) {
+		return
+	}
+	record := &entity.AccrualResponse{OrderNumber: number, StatusCode: code, Payload: payload}
+	if cause != nil {
+		record.Error = utils.ToPointer(cause.Error())
+	}
+	if err := s.archive.Insert(ctx, record); err != nil {
+		log.WithError(err).WithField("order", number).Error("Failed to archive accrual response")
+	}
+}
+
+// pause приостанавливает запросы на время из Retry-After (в секундах), по умолчанию на интервал опроса
+func (s *service) pause(retryAfter string) {
+	delay := s.cfg.PollingInterval
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		delay = time.Duration(seconds) * time.Second
+	}
+	log.WithField("retry_after", delay).Warn("Accrual system rate limit exceeded")
+	s.pausedUntil.Store(s.now().Add(delay).UnixNano())
+}
+
+func (s *service) paused() bool {
+	return s.now().UnixNano() < s.pausedUntil.Load()
 }
 
 func (s *service) processError(ctx context.Context, err error, order *entity.Order, msg string) {
@@ -172,28 +507,95 @@ func (s *service) processError(ctx context.Context, err error, order *entity.Ord
 	// }
 }
 
+// reverifyTick перезапрашивает пачку рассчитанных заказов; ошибки связи и 429 оставляют заказ до следующего прохода
+func (s *service) reverifyTick() {
+	if !s.isLeader() || s.paused() || s.breaker.State() == CircuitOpen {
+		return
+	}
+
+	for _, id := range s.tenantIDs() {
+		if !s.reverifyTenant(tenant.ToContext(context.Background(), id)) {
+			return
+		}
+	}
+}
+
+// reverifyTenant сверяет заказы тенанта из ctx, false - система расчёта недоступна и сверку пора прервать
+func (s *service) reverifyTenant(ctx context.Context) bool {
+	now := s.now()
+	orders, err := s.orderRepo.GetOrdersToReverify(ctx, now.AddDate(0, 0, -s.cfg.Reverify.WindowDays),
+		now.Add(-s.cfg.Reverify.Interval), s.cfg.Reverify.BatchSize)
+	if err != nil {
+		log.WithError(err).WithField("tenant", tenant.FromContext(ctx)).Error("Failed to get orders to reverify")
+		return true
+	}
+	for i := range orders {
+		if s.paused() || !s.breaker.Allow() {
+			return false
+		}
+		s.reverify(ctx, &orders[i])
+	}
+
+	return true
+}
+
+func (s *service) reverify(ctx context.Context, order *entity.Order) {
+	status, resp := s.getResponse(ctx, order)
+	switch {
+	case status == http.StatusOK && resp != nil:
+	case status == http.StatusNoContent:
+		log.WithField("order", order.Number).Warn("Processed order is no longer registered in accrual system")
+	default:
+		return
+	}
+
+	err := s.trx.Transaction(ctx, func(ctx context.Context) error {
+		if resp != nil && resp.Status == statusProcessed {
+			if err := s.changedHook.AccrualChanged(ctx, order, utils.FromPointer(resp.Accrual)); err != nil {
+				return err
+			}
+		} else if resp != nil {
+			// статус в PROCESSED окончательный, у нас он не меняется
+			log.WithField("order", order.Number).WithField("status", resp.Status).Warn("Processed order changed status in accrual system")
+		}
+		return s.orderRepo.SetVerifiedAt(ctx, order.ID, s.now())
+	})
+	if err != nil {
+		log.WithError(err).WithField("order", order.Number).Error("Failed to reverify order")
+	}
+}
+
 func (s *service) processTick() {
 	// only one active processor
 	if !s.tickMu.TryLock() {
 		return
 	}
 	defer s.tickMu.Unlock()
+	if !s.isLeader() || s.paused() || s.breaker.State() == CircuitOpen {
+		return
+	}
+
+	for _, id := range s.tenantIDs() {
+		s.processTenant(tenant.ToContext(context.Background(), id))
+	}
+}
 
+// processTenant ставит в очередь необработанные заказы тенанта из ctx, кроме уже стоящих в ней
+func (s *service) processTenant(ctx context.Context) {
+	id := tenant.FromContext(ctx)
 	var activeOrderNumbers []string
 	s.processingOrders.Range(func(k, v interface{}) bool {
-		activeOrderNumbers = append(activeOrderNumbers, k.(string))
+		if key := k.(processingKey); key.tenant == id {
+			activeOrderNumbers = append(activeOrderNumbers, key.number)
+		}
 		return true
 	})
-	ctx := context.Background()
 	statuses := []string{string(entity.OrderStatusNew), string(entity.OrderStatusProcessing)}
 	orders, err := s.orderRepo.GetOrdersByStatuses(ctx, statuses, activeOrderNumbers, s.cfg.PollingCount)
 	if err != nil {
-		log.WithError(err).Error("Failed to orders in accrual processing tick")
+		log.WithError(err).WithField("tenant", id).Error("Failed to orders in accrual processing tick")
 	}
 	for _, order := range orders {
-		err := s.Send(ctx, &order)
-		if err != nil {
-			log.WithError(err).WithField("order", order).Error("Failed to send order")
-		}
+		s.enqueue(ctx, &order, workers.PriorityLow)
 	}
 }
@@ -0,0 +1,482 @@
+package accrual_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/accrualfake"
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/memstore"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/accrual"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/eventbus"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	waitFor = 5 * time.Second
+	tick    = 10 * time.Millisecond
+)
+
+type AccrualTestSuite struct {
+	suite.Suite
+
+	store     *memstore.Store
+	fake      *accrualfake.Handler
+	server    *httptest.Server
+	orderRepo repository.Order
+	archive   repository.AccrualArchive
+	service   accrual.Service
+	bus       *eventbus.InProcess
+	hook      *bonusHook
+	userID    uuid.UUID
+}
+
+func TestAccrualSuite(t *testing.T) {
+	suite.Run(t, new(AccrualTestSuite))
+}
+
+// bonusHook начисляет бонус в 10% к каждому обработанному заказу и запоминает номера заказов
+type bonusHook struct {
+	processed sync.Map
+}
+
+func (h *bonusHook) OrderProcessed(ctx context.Context, order *entity.Order) error {
+	h.processed.Store(order.Number, struct{}{})
+	if order.Accrual == nil {
+		return nil
+	}
+	bonus := *order.Accrual / 10
+	order.Bonus = &bonus
+	return nil
+}
+
+func (s *AccrualTestSuite) SetupTest() {
+	s.store = memstore.New()
+	s.orderRepo = memstore.NewOrderRepository(s.store)
+	s.archive = memstore.NewAccrualArchive(s.store)
+	s.userID = uuid.Must(uuid.NewV6())
+	s.Require().NoError(memstore.NewUserRepository(s.store).Insert(context.Background(), &entity.User{ID: s.userID, Login: "test"}))
+
+	s.bus = eventbus.NewInProcess(&config.EventBus{MaxAttempts: 3, RetryDelay: tick})
+	s.hook = &bonusHook{}
+	trx := s.bus.Transactional(memstore.NewTransactor(s.store))
+
+	s.fake = accrualfake.NewHandler(accrualfake.WithRules(accrualfake.Unregistered()))
+	s.server = httptest.NewServer(s.fake)
+	s.service = accrual.NewService(&config.Accrual{
+		AccrualSystemAddress: s.server.URL,
+		MaxActiveWorkers:     10,
+		OverloadReportCount:  1000,
+		OverloadReportRPS:    50,
+		PollingInterval:      tick,
+		PollingCount:         100,
+		Archive:              config.AccrualArchive{Enabled: true},
+	}, s.orderRepo,
+		accrual.WithProcessedHook(trx, s.hook),
+		accrual.WithEventBus(trx, s.bus),
+		accrual.WithArchive(s.archive),
+	)
+}
+
+func (s *AccrualTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *AccrualTestSuite) send(number string) {
+	order := &entity.Order{UserID: s.userID, Number: number, Status: entity.OrderStatusNew}
+	s.Require().NoError(s.orderRepo.Insert(context.Background(), order))
+	s.Require().NoError(s.service.Send(context.Background(), order))
+}
+
+func (s *AccrualTestSuite) requireStatus(number string, status entity.OrderStatus) *entity.Order {
+	var order *entity.Order
+	s.Require().Eventually(func() bool {
+		var err error
+		order, err = s.orderRepo.FindByNumber(context.Background(), number)
+		s.Require().NoError(err)
+		return order.Status == status
+	}, waitFor, tick)
+
+	return order
+}
+
+func (s *AccrualTestSuite) TestLifecycle() {
+	s.fake.Script("3413042486",
+		accrualfake.Response{Status: accrualfake.StatusRegistered},
+		accrualfake.Response{Status: accrualfake.StatusProcessing},
+		accrualfake.Processed(500),
+	)
+	s.send("3413042486")
+
+	order := s.requireStatus("3413042486", entity.OrderStatusProcessed)
+	s.Require().NotNil(order.Accrual)
+	s.Require().Equal(500.0, *order.Accrual)
+	s.Require().GreaterOrEqual(s.fake.Attempts("3413042486"), 3)
+}
+
+func (s *AccrualTestSuite) TestProcessedHook() {
+	s.fake.Script("3413042486", accrualfake.Processed(500))
+	s.send("3413042486")
+
+	order := s.requireStatus("3413042486", entity.OrderStatusProcessed)
+	s.Require().Equal(500.0, *order.Accrual)
+	s.Require().NotNil(order.Bonus)
+	s.Require().Equal(50.0, *order.Bonus)
+}
+
+func (s *AccrualTestSuite) TestProcessedWithoutAccrual() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	published := make(chan string, 10)
+	s.Require().NoError(s.bus.Subscribe(ctx, entity.EventOrderProcessed, "test", func(ctx context.Context, e *eventbus.Event) error {
+		event, err := eventbus.Decode[entity.OrderEvent](e)
+		if err != nil {
+			return err
+		}
+		published <- event.Number
+		return nil
+	}))
+	s.fake.Script("3413042486", accrualfake.Response{Status: accrualfake.StatusProcessed})
+	s.fake.Script("5798116405", accrualfake.Processed(0))
+	s.send("3413042486")
+	s.send("5798116405")
+
+	// заказ без начисления проходит тот же путь, что и с начислением: hook и событие OrderProcessed
+	for _, number := range []string{"3413042486", "5798116405"} {
+		order := s.requireStatus(number, entity.OrderStatusProcessed)
+		s.Require().Zero(utils.FromPointer(order.Accrual))
+		s.Require().NotNil(order.ProcessedAt)
+		_, ok := s.hook.processed.Load(number)
+		s.Require().True(ok, number)
+	}
+	var got []string
+	s.Require().Eventually(func() bool {
+		select {
+		case number := <-published:
+			got = append(got, number)
+		default:
+		}
+		return len(got) == 2
+	}, waitFor, tick)
+	s.Require().ElementsMatch([]string{"3413042486", "5798116405"}, got)
+}
+
+func (s *AccrualTestSuite) TestUnregisteredOrderIsInvalid() {
+	s.send("5798116405")
+
+	order := s.requireStatus("5798116405", entity.OrderStatusInvalid)
+	s.Require().Nil(order.Accrual)
+}
+
+func (s *AccrualTestSuite) TestInvalid() {
+	s.fake.Script("9155976989", accrualfake.Response{Status: accrualfake.StatusInvalid})
+	s.send("9155976989")
+
+	s.requireStatus("9155976989", entity.OrderStatusInvalid)
+}
+
+func (s *AccrualTestSuite) TestQuarantine() {
+	s.fake.Script("3413042486",
+		accrualfake.Response{Body: `{"order":"5798116405","status":"PROCESSED","accrual":500}`},
+		accrualfake.Response{Body: `{"order":"3413042486","status":"DONE"}`},
+		accrualfake.Response{Body: `{"order":"3413042486","status":"PROCESSED","accrual":-500}`},
+		accrualfake.Response{Body: `{"order":"3413042486","status":"INVALID","accrual":500}`},
+		accrualfake.Response{Body: `<html>Bad Gateway</html>`},
+		accrualfake.Processed(500),
+	)
+	s.send("3413042486")
+
+	order := s.requireStatus("3413042486", entity.OrderStatusProcessed)
+	s.Require().Equal(500.0, *order.Accrual)
+	quarantined, err := s.archive.GetQuarantined(context.Background(), 100)
+	s.Require().NoError(err)
+	s.Require().Len(quarantined, 5)
+	for _, resp := range quarantined {
+		s.Require().Contains(*resp.Error, "bad accrual response")
+	}
+	responses, err := s.archive.GetOrderResponses(context.Background(), "3413042486")
+	s.Require().NoError(err)
+	s.Require().Len(responses, 6)
+	s.Require().Equal(`<html>Bad Gateway</html>`, string(responses[4].Payload))
+	s.Require().Nil(responses[5].Error)
+	// ответы из карантина не попадают в историю статусов
+	history, err := s.orderRepo.GetOrderStatusHistory(context.Background(), order.ID)
+	s.Require().NoError(err)
+	s.Require().Len(history, 2)
+}
+
+func (s *AccrualTestSuite) TestQuarantineWithArchiveDisabled() {
+	// отдельное хранилище, чтобы заказ не забрал опросом сервис из SetupTest
+	store := memstore.New()
+	orderRepo := memstore.NewOrderRepository(store)
+	archive := memstore.NewAccrualArchive(store)
+	service := accrual.NewService(&config.Accrual{
+		AccrualSystemAddress: s.server.URL,
+		MaxActiveWorkers:     10,
+		OverloadReportCount:  1000,
+		OverloadReportRPS:    50,
+		PollingInterval:      tick,
+		PollingCount:         100,
+	}, orderRepo, accrual.WithArchive(archive))
+	s.fake.Script("3413042486",
+		accrualfake.Response{Body: `{"order":"3413042486","status":"DONE"}`},
+		accrualfake.Processed(500),
+	)
+	order := &entity.Order{UserID: s.userID, Number: "3413042486", Status: entity.OrderStatusNew}
+	s.Require().NoError(orderRepo.Insert(context.Background(), order))
+	s.Require().NoError(service.Send(context.Background(), order))
+	s.Require().Eventually(func() bool {
+		found, err := orderRepo.FindByNumber(context.Background(), order.Number)
+		s.Require().NoError(err)
+		return found.Status == entity.OrderStatusProcessed
+	}, waitFor, tick)
+
+	// без архива сохраняется только ответ из карантина
+	responses, err := archive.GetOrderResponses(context.Background(), "3413042486")
+	s.Require().NoError(err)
+	s.Require().Len(responses, 1)
+	s.Require().NotNil(responses[0].Error)
+}
+
+func (s *AccrualTestSuite) TestUnregisteredResponseIsArchived() {
+	s.send("5798116405")
+	s.requireStatus("5798116405", entity.OrderStatusInvalid)
+
+	responses, err := s.archive.GetOrderResponses(context.Background(), "5798116405")
+	s.Require().NoError(err)
+	s.Require().NotEmpty(responses)
+	s.Require().Equal(http.StatusNoContent, responses[0].StatusCode)
+}
+
+func (s *AccrualTestSuite) TestSendSurvivesCanceledContext() {
+	processed := accrualfake.Processed(40)
+	processed.Latency = 5 * tick
+	s.fake.Script("4561261212345467", processed)
+	order := &entity.Order{UserID: s.userID, Number: "4561261212345467", Status: entity.OrderStatusNew}
+	s.Require().NoError(s.orderRepo.Insert(context.Background(), order))
+	// как RetriggerAccrual из HTTP-обработчика: контекст отменяется сразу после возврата из Send
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Require().NoError(s.service.Send(ctx, order))
+	cancel()
+
+	found := s.requireStatus(order.Number, entity.OrderStatusProcessed)
+	s.Require().Equal(40.0, *found.Accrual)
+	s.Require().Equal(1, s.fake.Attempts(order.Number))
+	s.Require().Equal(accrual.CircuitClosed, s.service.CircuitState())
+	s.Require().Zero(s.service.QueueStats().Dropped)
+}
+
+func (s *AccrualTestSuite) TestRecoversAfterFailure() {
+	s.fake.Script("1587579366",
+		accrualfake.Response{Code: http.StatusInternalServerError},
+		accrualfake.Response{Code: http.StatusInternalServerError},
+		accrualfake.Processed(10),
+	)
+	s.send("1587579366")
+
+	order := s.requireStatus("1587579366", entity.OrderStatusProcessed)
+	s.Require().Equal(10.0, *order.Accrual)
+}
+
+// fakeClock часы, которые двигает тест
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func (s *AccrualTestSuite) TestCircuitBreaker() {
+	// отдельное хранилище, чтобы заказ не забрал сервис без breaker из SetupTest
+	store := memstore.New()
+	orderRepo := memstore.NewOrderRepository(store)
+	s.Require().NoError(memstore.NewUserRepository(store).Insert(context.Background(), &entity.User{ID: s.userID, Login: "test"}))
+	clock := &fakeClock{now: time.Now()}
+	service := accrual.NewService(&config.Accrual{
+		AccrualSystemAddress: s.server.URL,
+		MaxActiveWorkers:     10,
+		OverloadReportCount:  1000,
+		OverloadReportRPS:    50,
+		PollingInterval:      tick,
+		PollingCount:         100,
+		CircuitBreaker:       config.CircuitBreaker{FailureThreshold: 2, ProbeInterval: time.Minute},
+	}, orderRepo, accrual.WithClock(clock.Now))
+	s.fake.Script("6409723027",
+		accrualfake.Response{Code: http.StatusInternalServerError},
+		accrualfake.Response{Code: http.StatusInternalServerError},
+		accrualfake.Response{Code: http.StatusServiceUnavailable},
+		accrualfake.Processed(30),
+	)
+	order := &entity.Order{UserID: s.userID, Number: "6409723027", Status: entity.OrderStatusNew}
+	s.Require().NoError(orderRepo.Insert(context.Background(), order))
+	s.Require().Equal(accrual.CircuitClosed, service.CircuitState())
+	s.Require().NoError(service.Send(context.Background(), order))
+
+	s.Require().Eventually(func() bool { return service.CircuitState() == accrual.CircuitOpen }, waitFor, tick)
+	// пока цепь разомкнута, ни воркеры, ни таймер запросов не отправляют
+	s.Require().Never(func() bool { return s.fake.Attempts("6409723027") > 2 }, 20*tick, tick)
+
+	// неудачная проба снова размыкает цепь
+	clock.Advance(time.Minute)
+	s.Require().Eventually(func() bool { return s.fake.Attempts("6409723027") == 3 }, waitFor, tick)
+	s.Require().Eventually(func() bool { return service.CircuitState() == accrual.CircuitOpen }, waitFor, tick)
+	s.Require().Never(func() bool { return s.fake.Attempts("6409723027") > 3 }, 20*tick, tick)
+
+	clock.Advance(time.Minute)
+	s.Require().Eventually(func() bool {
+		found, err := orderRepo.FindByNumber(context.Background(), order.Number)
+		s.Require().NoError(err)
+		return found.Status == entity.OrderStatusProcessed
+	}, waitFor, tick)
+	s.Require().Equal(accrual.CircuitClosed, service.CircuitState())
+}
+
+func (s *AccrualTestSuite) TestRetryAfterPauseUsesClock() {
+	store := memstore.New()
+	orderRepo := memstore.NewOrderRepository(store)
+	s.Require().NoError(memstore.NewUserRepository(store).Insert(context.Background(), &entity.User{ID: s.userID, Login: "test"}))
+	clock := &fakeClock{now: time.Now()}
+	service := accrual.NewService(&config.Accrual{
+		AccrualSystemAddress: s.server.URL,
+		MaxActiveWorkers:     10,
+		OverloadReportCount:  1000,
+		OverloadReportRPS:    50,
+		PollingInterval:      tick,
+		PollingCount:         100,
+	}, orderRepo, accrual.WithClock(clock.Now))
+	s.fake.Script("7992739871",
+		accrualfake.Response{Code: http.StatusTooManyRequests, RetryAfter: time.Minute},
+		accrualfake.Processed(12),
+	)
+	order := &entity.Order{UserID: s.userID, Number: "7992739871", Status: entity.OrderStatusNew}
+	s.Require().NoError(orderRepo.Insert(context.Background(), order))
+	s.Require().NoError(service.Send(context.Background(), order))
+
+	// пауза по Retry-After отсчитывается по часам сервиса
+	s.Require().Eventually(func() bool { return s.fake.Attempts("7992739871") == 1 }, waitFor, tick)
+	s.Require().Never(func() bool { return s.fake.Attempts("7992739871") > 1 }, 20*tick, tick)
+
+	clock.Advance(time.Minute)
+	s.Require().Eventually(func() bool {
+		found, err := orderRepo.FindByNumber(context.Background(), order.Number)
+		s.Require().NoError(err)
+		return found.Status == entity.OrderStatusProcessed
+	}, waitFor, tick)
+}
+
+func (s *AccrualTestSuite) TestPollerRunsOnlyOnLeader() {
+	store := memstore.New()
+	orderRepo := memstore.NewOrderRepository(store)
+	s.Require().NoError(memstore.NewUserRepository(store).Insert(context.Background(), &entity.User{ID: s.userID, Login: "test"}))
+	var isLeader atomic.Bool
+	accrual.NewService(&config.Accrual{
+		AccrualSystemAddress: s.server.URL,
+		MaxActiveWorkers:     10,
+		PollingInterval:      tick,
+		PollingCount:         100,
+	}, orderRepo, accrual.WithLeadership(isLeader.Load))
+	s.fake.Script("7950385429", accrualfake.Processed(15))
+	// заказ без Send подхватывается только опросом по таймеру
+	order := &entity.Order{UserID: s.userID, Number: "7950385429", Status: entity.OrderStatusNew}
+	s.Require().NoError(orderRepo.Insert(context.Background(), order))
+
+	s.Require().Never(func() bool { return s.fake.Attempts(order.Number) > 0 }, 20*tick, tick)
+	isLeader.Store(true)
+	s.Require().Eventually(func() bool {
+		found, err := orderRepo.FindByNumber(context.Background(), order.Number)
+		s.Require().NoError(err)
+		return found.Status == entity.OrderStatusProcessed
+	}, waitFor, tick)
+}
+
+// changedHook запоминает начисления, полученные при повторной сверке
+type changedHook struct {
+	mu       sync.Mutex
+	accruals map[string]float64
+}
+
+func (h *changedHook) AccrualChanged(ctx context.Context, order *entity.Order, accrual float64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.accruals[order.Number] = accrual
+	return nil
+}
+
+func (h *changedHook) Accrual(number string) (float64, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	accrual, ok := h.accruals[number]
+	return accrual, ok
+}
+
+func (s *AccrualTestSuite) TestReverification() {
+	store := memstore.New()
+	orderRepo := memstore.NewOrderRepository(store)
+	s.Require().NoError(memstore.NewUserRepository(store).Insert(context.Background(), &entity.User{ID: s.userID, Login: "test"}))
+	hook := &changedHook{accruals: make(map[string]float64)}
+	accrual.NewService(&config.Accrual{
+		AccrualSystemAddress: s.server.URL,
+		MaxActiveWorkers:     10,
+		PollingInterval:      tick,
+		PollingCount:         100,
+		Reverify:             config.Reverify{WindowDays: 30, Interval: tick, BatchSize: 10},
+	}, orderRepo, accrual.WithReverification(memstore.NewTransactor(store), hook))
+
+	recent := &entity.Order{UserID: s.userID, Number: "2377225624", Status: entity.OrderStatusProcessed,
+		Accrual: utils.ToPointer(500.0), ProcessedAt: utils.ToPointer(time.Now())}
+	s.Require().NoError(orderRepo.Insert(context.Background(), recent))
+	old := &entity.Order{UserID: s.userID, Number: "9325279751", Status: entity.OrderStatusProcessed,
+		Accrual: utils.ToPointer(500.0), ProcessedAt: utils.ToPointer(time.Now().AddDate(0, 0, -31))}
+	s.Require().NoError(orderRepo.Insert(context.Background(), old))
+	s.fake.Script(recent.Number, accrualfake.Processed(450))
+	s.fake.Script(old.Number, accrualfake.Processed(450))
+
+	s.Require().Eventually(func() bool {
+		accrual, ok := hook.Accrual(recent.Number)
+		return ok && accrual == 450
+	}, waitFor, tick)
+	found, err := orderRepo.FindByNumber(context.Background(), recent.Number)
+	s.Require().NoError(err)
+	s.Require().NotNil(found.VerifiedAt)
+	// начисление заказа не перезаписывается, исправление остаётся за hook
+	s.Require().Equal(500.0, *found.Accrual)
+	// заказы за пределами окна не перезапрашиваются
+	s.Require().Equal(0, s.fake.Attempts(old.Number))
+}
+
+func (s *AccrualTestSuite) TestRespectsRetryAfter() {
+	s.fake.Script("3203697697",
+		accrualfake.Response{Code: http.StatusTooManyRequests, RetryAfter: time.Second},
+		accrualfake.Processed(20),
+	)
+	start := time.Now()
+	s.send("3203697697")
+
+	s.requireStatus("3203697697", entity.OrderStatusProcessed)
+	s.Require().GreaterOrEqual(time.Since(start), time.Second)
+	// во время паузы запросы не отправлялись
+	s.Require().Equal(2, s.fake.Attempts("3203697697"))
+}
@@ -0,0 +1,112 @@
+package accrual
+
+import (
+	"sync"
+	"time"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+type CircuitState string
+
+const (
+	// CircuitClosed запросы к системе расчёта идут как обычно
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen система расчёта недоступна, запросы не отправляются
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen интервал ожидания истёк, пропускается один пробный запрос
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// circuitBreaker считает подряд идущие отказы системы расчёта и размыкает цепь при превышении порога
+type circuitBreaker struct {
+	cfg *config.CircuitBreaker
+	now func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newCircuitBreaker(cfg *config.CircuitBreaker, now func() time.Time) *circuitBreaker {
+	return &circuitBreaker{
+		cfg:   cfg,
+		now:   now,
+		state: CircuitClosed,
+	}
+}
+
+func (b *circuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.current()
+}
+
+// current открытая цепь становится полуоткрытой по истечении ProbeInterval
+func (b *circuitBreaker) current() CircuitState {
+	if b.state == CircuitOpen && !b.now().Before(b.openedAt.Add(b.cfg.ProbeInterval)) {
+		return CircuitHalfOpen
+	}
+
+	return b.state
+}
+
+// Allow можно ли отправить запрос; в полуоткрытом состоянии разрешается только один пробный запрос
+func (b *circuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.current() {
+	case CircuitClosed:
+		return true
+	case CircuitOpen:
+		return false
+	default:
+		if b.probing {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return true
+	}
+}
+
+// Success система расчёта ответила, цепь замыкается
+func (b *circuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != CircuitClosed {
+		log.Info("Accrual system is available again, circuit closed")
+	}
+	b.state = CircuitClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Abandon запрос прерван нашей стороной, ответ системы расчёта неизвестен: проба может повториться
+func (b *circuitBreaker) Abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// Failure отказ системы расчёта; неудачная проба снова размыкает цепь
+func (b *circuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == CircuitHalfOpen || (b.state == CircuitClosed && b.cfg.FailureThreshold > 0 && b.failures >= b.cfg.FailureThreshold) {
+		log.WithField("failures", b.failures).WithField("probe_interval", b.cfg.ProbeInterval).
+			Warn("Accrual system is unavailable, circuit opened")
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	}
+	b.probing = false
+}
@@ -1,15 +1,21 @@
 package auth
 
 import (
+	"crypto/subtle"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
 	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
 	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
 )
 
+// ServiceLoginPrefix префикс имени доверенного сервиса в журнале аудита
+const ServiceLoginPrefix = "service:"
+
 type Service struct {
 	cfg          *config.Auth
 	jwtParser    *jwt.Parser
@@ -37,10 +43,25 @@ func (s *Service) Authenticate(r *http.Request) (*JWTClaims, error) {
 	return s.ParseToken(strToken)
 }
 
+// AuthenticateService проверяет токен доверенного сервиса из заголовка Authorization
+func (s *Service) AuthenticateService(r *http.Request) (*JWTClaims, error) {
+	token, ok := strings.CutPrefix(r.Header.Get(utils.AuthorizationHeader), "Bearer ")
+	if !ok || token == "" {
+		return nil, domain.ErrTokenNotProvided
+	}
+	for name, expected := range s.cfg.ServiceTokens {
+		if expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1 {
+			return &JWTClaims{Login: ServiceLoginPrefix + name, Role: entity.UserRoleService}, nil
+		}
+	}
+
+	return nil, domain.ErrInvalidToken
+}
+
 func (s *Service) GetToken(r *http.Request) (string, error) {
 	c, err := r.Cookie(s.cfg.CookieName)
 	if err != nil {
-		return "", err
+		return "", domain.ErrTokenNotProvided
 	}
 
 	return c.Value, nil
@@ -65,13 +86,32 @@ func (s *Service) ParseToken(token string) (*JWTClaims, error) {
 	return claims, nil
 }
 
+// VerifyUser сверяет токен с текущим состоянием пользователя в БД и обновляет роль в claims
+func VerifyUser(claims *JWTClaims, user *entity.User) error {
+	if user.DeletedAt != nil {
+		return domain.ErrUserDeleted
+	}
+	if user.Blocked {
+		return domain.ErrUserBlocked
+	}
+	if claims.SessionVersion != user.SessionVersion {
+		return domain.ErrSessionRevoked
+	}
+	claims.Role = user.Role
+
+	return nil
+}
+
 func (s *Service) NewClaims(user *entity.User) *JWTClaims {
 	return &JWTClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.cfg.ExpiresIn)),
 		},
-		UserID: user.ID,
-		Login:  user.Login,
+		UserID:         user.ID,
+		Login:          user.Login,
+		Role:           user.Role,
+		SessionVersion: user.SessionVersion,
+		TenantID:       user.TenantID,
 	}
 }
 
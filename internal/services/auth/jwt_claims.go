@@ -5,6 +5,9 @@ import (
 
 	"github.com/gofrs/uuid"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
 )
 
 var jwtClaims = struct{}{}
@@ -12,8 +15,52 @@ var jwtClaims = struct{}{}
 type JWTClaims struct {
 	jwt.RegisteredClaims
 
-	UserID uuid.UUID `json:"uid"`
-	Login  string    `json:"login"`
+	UserID         uuid.UUID       `json:"uid"`
+	Login          string          `json:"login"`
+	Role           entity.UserRole `json:"role,omitempty"`
+	SessionVersion int             `json:"sv"`
+	// TenantID тенант пользователя, пустой в токенах, выданных до появления тенантов
+	TenantID string `json:"tid,omitempty"`
+}
+
+func (c *JWTClaims) Actor() *entity.Actor {
+	return &entity.Actor{
+		ID:    c.UserID,
+		Login: c.Login,
+		Role:  c.Role,
+	}
+}
+
+// HasRole проверяет, что роль пользователя входит в перечисленные
+func (c *JWTClaims) HasRole(roles ...entity.UserRole) bool {
+	for _, role := range roles {
+		if c.Role == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Tenant тенант, для которого выдан токен
+func (c *JWTClaims) Tenant() string {
+	if c.TenantID == "" {
+		return tenant.Default
+	}
+
+	return c.TenantID
+}
+
+// WithTenant кладёт в ctx тенант токена; тенант, уже определённый по хосту или заголовку, должен с ним совпадать
+func WithTenant(ctx context.Context, claims *JWTClaims) (context.Context, error) {
+	if id, ok := tenant.Lookup(ctx); ok {
+		if id != claims.Tenant() {
+			return nil, domain.ErrTenantMismatch
+		}
+		return ctx, nil
+	}
+
+	return tenant.ToContext(ctx, claims.Tenant()), nil
 }
 
 func FromContext(ctx context.Context) *JWTClaims {
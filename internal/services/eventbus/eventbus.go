@@ -0,0 +1,68 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+/*
+Шина доменных событий. Каждое событие топика доставляется один раз в каждую группу потребителей,
+внутри группы обработчики конкурируют за события. Доставка не реже одного раза, поэтому обработчики
+должны быть идемпотентными. Публикация внутри транзакции хранилища выполняется последним шагом:
+Postgres-реализация пишет событие в той же транзакции, встроенная откладывает доставку до фиксации
+транзакции, если та запущена через InProcess.Transactional, и иначе отдаёт событие обработчикам сразу.
+*/
+
+type Event struct {
+	ID        uuid.UUID
+	Topic     string
+	Payload   json.RawMessage
+	CreatedAt time.Time
+	// Attempt номер попытки доставки, начиная с 1
+	Attempt int
+}
+
+// Handler nil подтверждает событие (ack), ошибка возвращает его группе для повторной доставки (nack)
+type Handler func(ctx context.Context, e *Event) error
+
+type Bus interface {
+	// Publish сериализует payload в JSON и публикует событие в топик
+	Publish(ctx context.Context, topic string, payload any) error
+
+	// Subscribe добавляет обработчик в группу потребителей топика, события обрабатываются до отмены ctx
+	Subscribe(ctx context.Context, topic, group string, h Handler) error
+}
+
+// Cleaner шина, которая хранит доставленные события
+type Cleaner interface {
+	// DeleteDelivered удаляет события старше before, подтверждённые всеми группами, и возвращает их число.
+	// События с неподтверждёнными или брошенными после MaxAttempts доставками остаются для разбора.
+	DeleteDelivered(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Decode разбирает данные события в v
+func Decode[T any](e *Event) (*T, error) {
+	var v T
+	if err := json.Unmarshal(e.Payload, &v); err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+// NewEvent событие с новым ID и данными в JSON
+func NewEvent(topic string, payload any) (*Event, error) {
+	id, err := uuid.NewV6()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Event{ID: id, Topic: topic, Payload: data, CreatedAt: time.Now()}, nil
+}
@@ -0,0 +1,181 @@
+package eventbus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ Bus = (*InProcess)(nil)
+
+// InProcess шина в памяти процесса: события не переживают перезапуск и теряются, если у топика нет групп.
+// В транзакции, запущенной через Transactional, события копятся и доставляются только после её фиксации.
+type InProcess struct {
+	cfg *config.EventBus
+
+	mu     sync.Mutex
+	groups map[string]map[string]*queue
+}
+
+func NewInProcess(cfg *config.EventBus) *InProcess {
+	return &InProcess{
+		cfg:    cfg,
+		groups: make(map[string]map[string]*queue),
+	}
+}
+
+// Transactor запускает транзакции хранилища
+type Transactor interface {
+	Transaction(ctx context.Context, f func(ctx context.Context) error) error
+}
+
+type pendingKey struct{}
+
+// pending события, опубликованные в ещё не зафиксированной транзакции
+type pending struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+type transactional struct {
+	trx Transactor
+	bus *InProcess
+}
+
+// Transactional оборачивает trx так, что события, опубликованные внутри транзакции, доставляются после её фиксации,
+// а при откате отбрасываются - как в Postgres-реализации, где событие пишется в той же транзакции
+func (b *InProcess) Transactional(trx Transactor) Transactor {
+	return &transactional{trx: trx, bus: b}
+}
+
+func (t *transactional) Transaction(ctx context.Context, f func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(pendingKey{}).(*pending); ok {
+		return t.trx.Transaction(ctx, f)
+	}
+
+	p := &pending{}
+	if err := t.trx.Transaction(context.WithValue(ctx, pendingKey{}, p), f); err != nil {
+		return err
+	}
+	for _, e := range p.events {
+		t.bus.deliver(e)
+	}
+
+	return nil
+}
+
+func (b *InProcess) Publish(ctx context.Context, topic string, payload any) error {
+	e, err := NewEvent(topic, payload)
+	if err != nil {
+		return err
+	}
+	if p, ok := ctx.Value(pendingKey{}).(*pending); ok {
+		p.mu.Lock()
+		p.events = append(p.events, e)
+		p.mu.Unlock()
+		return nil
+	}
+	b.deliver(e)
+
+	return nil
+}
+
+func (b *InProcess) deliver(e *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, q := range b.groups[e.Topic] {
+		// у каждой группы своя копия, номер попытки считается отдельно
+		c := *e
+		q.push(&c)
+	}
+}
+
+func (b *InProcess) Subscribe(ctx context.Context, topic, group string, h Handler) error {
+	b.mu.Lock()
+	if b.groups[topic] == nil {
+		b.groups[topic] = make(map[string]*queue)
+	}
+	q, ok := b.groups[topic][group]
+	if !ok {
+		q = newQueue()
+		b.groups[topic][group] = q
+	}
+	b.mu.Unlock()
+
+	go func() {
+		for {
+			e, ok := q.pop(ctx)
+			if !ok {
+				return
+			}
+			e.Attempt++
+			if err := h(ctx, e); err != nil {
+				b.nack(q, e, group, err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (b *InProcess) nack(q *queue, e *Event, group string, err error) {
+	logger := log.WithError(err).WithField("event", e.ID).WithField("topic", e.Topic).WithField("group", group)
+	if b.cfg.MaxAttempts > 0 && e.Attempt >= b.cfg.MaxAttempts {
+		logger.WithField("payload", string(e.Payload)).Error("Event handling failed, giving up")
+		return
+	}
+	logger.WithField("attempt", e.Attempt).Warn("Event handling failed, will retry")
+	time.AfterFunc(b.cfg.RetryDelay, func() { q.push(e) })
+}
+
+// queue неограниченная очередь событий группы
+type queue struct {
+	mu     sync.Mutex
+	events []*Event
+	ready  chan struct{}
+}
+
+func newQueue() *queue {
+	return &queue{ready: make(chan struct{}, 1)}
+}
+
+func (q *queue) push(e *Event) {
+	q.mu.Lock()
+	q.events = append(q.events, e)
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *queue) pop(ctx context.Context) (*Event, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.events) > 0 {
+			e := q.events[0]
+			q.events = q.events[1:]
+			more := len(q.events) > 0
+			q.mu.Unlock()
+			// будим следующего обработчика группы, если в очереди ещё есть события
+			if more {
+				select {
+				case q.ready <- struct{}{}:
+				default:
+				}
+			}
+			return e, true
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-q.ready:
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package eventbus_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/eventbus"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	waitFor = time.Second
+	tick    = 5 * time.Millisecond
+)
+
+type payload struct {
+	N int `json:"n"`
+}
+
+func TestInProcessConsumerGroups(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := eventbus.NewInProcess(&config.EventBus{MaxAttempts: 3, RetryDelay: tick})
+
+	var mu sync.Mutex
+	received := map[string][]int{}
+	handler := func(name string) eventbus.Handler {
+		return func(ctx context.Context, e *eventbus.Event) error {
+			p, err := eventbus.Decode[payload](e)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			received[name] = append(received[name], p.N)
+			return nil
+		}
+	}
+	// два обработчика одной группы делят события, другая группа получает все
+	require.NoError(t, bus.Subscribe(ctx, "topic", "a", handler("a1")))
+	require.NoError(t, bus.Subscribe(ctx, "topic", "a", handler("a2")))
+	require.NoError(t, bus.Subscribe(ctx, "topic", "b", handler("b")))
+	require.NoError(t, bus.Subscribe(ctx, "other", "a", handler("other")))
+	for i := 0; i < 10; i++ {
+		require.NoError(t, bus.Publish(ctx, "topic", &payload{N: i}))
+	}
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received["a1"])+len(received["a2"]) == 10 && len(received["b"]) == 10
+	}, waitFor, tick)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Empty(t, received["other"])
+}
+
+func TestInProcessNack(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := eventbus.NewInProcess(&config.EventBus{MaxAttempts: 3, RetryDelay: tick})
+
+	var retried, failed atomic.Int32
+	require.NoError(t, bus.Subscribe(ctx, "retry", "g", func(ctx context.Context, e *eventbus.Event) error {
+		if e.Attempt < 2 {
+			return errors.New("temporary")
+		}
+		retried.Store(int32(e.Attempt))
+		return nil
+	}))
+	require.NoError(t, bus.Subscribe(ctx, "fail", "g", func(ctx context.Context, e *eventbus.Event) error {
+		failed.Add(1)
+		return errors.New("permanent")
+	}))
+	require.NoError(t, bus.Publish(ctx, "retry", &payload{}))
+	require.NoError(t, bus.Publish(ctx, "fail", &payload{}))
+
+	require.Eventually(t, func() bool { return retried.Load() == 2 }, waitFor, tick)
+	// после MaxAttempts событие больше не доставляется
+	require.Eventually(t, func() bool { return failed.Load() == 3 }, waitFor, tick)
+	require.Never(t, func() bool { return failed.Load() > 3 }, 10*tick, tick)
+}
+
+// trxFunc транзакция без хранилища: ошибка f означает откат
+type trxFunc struct{}
+
+func (trxFunc) Transaction(ctx context.Context, f func(ctx context.Context) error) error {
+	return f(ctx)
+}
+
+func TestInProcessTransactional(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := eventbus.NewInProcess(&config.EventBus{MaxAttempts: 3, RetryDelay: tick})
+	trx := bus.Transactional(trxFunc{})
+
+	var received atomic.Int32
+	require.NoError(t, bus.Subscribe(ctx, "topic", "g", func(ctx context.Context, e *eventbus.Event) error {
+		p, err := eventbus.Decode[payload](e)
+		if err != nil {
+			return err
+		}
+		received.Store(int32(p.N))
+		return nil
+	}))
+
+	rollback := errors.New("rollback")
+	err := trx.Transaction(ctx, func(ctx context.Context) error {
+		require.NoError(t, bus.Publish(ctx, "topic", &payload{N: 1}))
+		return rollback
+	})
+	require.ErrorIs(t, err, rollback)
+	require.Never(t, func() bool { return received.Load() != 0 }, 10*tick, tick)
+
+	require.NoError(t, trx.Transaction(ctx, func(ctx context.Context) error {
+		// вложенная транзакция публикует в ту же внешнюю
+		return trx.Transaction(ctx, func(ctx context.Context) error {
+			require.NoError(t, bus.Publish(ctx, "topic", &payload{N: 2}))
+			require.Never(t, func() bool { return received.Load() != 0 }, 10*tick, tick)
+			return nil
+		})
+	}))
+	require.Eventually(t, func() bool { return received.Load() == 2 }, waitFor, tick)
+}
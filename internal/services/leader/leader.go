@@ -0,0 +1,118 @@
+package leader
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+Выбор лидера среди реплик. Блокировка держится, пока жива сессия, в которой она взята (advisory lock в Postgres),
+поэтому при падении лидера её сразу может захватить другая реплика. Лидер раз в RenewInterval проверяет,
+что блокировка всё ещё за ним, и при ошибке перестаёт быть лидером. Между потерей сессии и следующей проверкой
+два экземпляра могут считать себя лидерами, поэтому фоновые задачи должны быть идемпотентными.
+*/
+
+// Lock межпроцессная блокировка лидера
+type Lock interface {
+	// TryAcquire пытается захватить блокировку без ожидания
+	TryAcquire(ctx context.Context) (bool, error)
+	// Renew проверяет, что блокировка всё ещё удерживается
+	Renew(ctx context.Context) error
+	Release(ctx context.Context) error
+	// Holder экземпляр, удерживающий блокировку; пустая строка, если лидера нет
+	Holder(ctx context.Context) (string, error)
+}
+
+type Elector interface {
+	// Run участвует в выборах до отмены ctx, после чего освобождает блокировку
+	Run(ctx context.Context)
+	IsLeader() bool
+	// Instance идентификатор этого экземпляра
+	Instance() string
+	// Leader идентификатор текущего лидера
+	Leader(ctx context.Context) (string, error)
+}
+
+type elector struct {
+	cfg      *config.Leader
+	lock     Lock
+	isLeader atomic.Bool
+}
+
+func NewElector(cfg *config.Leader, lock Lock) Elector {
+	return &elector{cfg: cfg, lock: lock}
+}
+
+func (e *elector) Run(ctx context.Context) {
+	e.elect(ctx)
+	for {
+		interval := e.cfg.RetryInterval
+		if e.isLeader.Load() {
+			interval = e.cfg.RenewInterval
+		}
+		select {
+		case <-ctx.Done():
+			e.stepDown()
+			return
+		case <-time.After(interval):
+			if e.isLeader.Load() {
+				e.renew(ctx)
+			} else {
+				e.elect(ctx)
+			}
+		}
+	}
+}
+
+func (e *elector) elect(ctx context.Context) {
+	acquired, err := e.lock.TryAcquire(ctx)
+	if err != nil {
+		log.WithError(err).Error("Failed to acquire leader lock")
+		return
+	}
+	if acquired {
+		log.WithField("instance", e.cfg.Instance).Info("Became leader")
+		e.isLeader.Store(true)
+	}
+}
+
+func (e *elector) renew(ctx context.Context) {
+	if err := e.lock.Renew(ctx); err != nil {
+		log.WithError(err).WithField("instance", e.cfg.Instance).Warn("Leader lease lost")
+		e.stepDown()
+	}
+}
+
+// stepDown снимает признак лидерства до освобождения блокировки, чтобы задачи не стартовали без неё
+func (e *elector) stepDown() {
+	if !e.isLeader.Swap(false) {
+		return
+	}
+	// ctx Run к этому моменту может быть уже отменён
+	ctx, cancel := context.WithTimeout(context.Background(), e.cfg.RenewInterval)
+	defer cancel()
+	if err := e.lock.Release(ctx); err != nil {
+		log.WithError(err).Error("Failed to release leader lock")
+	}
+	log.WithField("instance", e.cfg.Instance).Info("Stepped down from leadership")
+}
+
+func (e *elector) IsLeader() bool {
+	return e.isLeader.Load()
+}
+
+func (e *elector) Instance() string {
+	return e.cfg.Instance
+}
+
+func (e *elector) Leader(ctx context.Context) (string, error) {
+	if e.isLeader.Load() {
+		return e.cfg.Instance, nil
+	}
+
+	return e.lock.Holder(ctx)
+}
@@ -0,0 +1,69 @@
+package leader_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/memstore"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/leader"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	waitFor = time.Second
+	tick    = 5 * time.Millisecond
+)
+
+func newElector(store *memstore.Store, instance string) leader.Elector {
+	cfg := &config.Leader{Instance: instance, RenewInterval: tick, RetryInterval: tick}
+	return leader.NewElector(cfg, memstore.NewLeaderLock(store, instance))
+}
+
+func TestElectorFailover(t *testing.T) {
+	store := memstore.New()
+	first := newElector(store, "first")
+	second := newElector(store, "second")
+
+	firstCtx, stopFirst := context.WithCancel(context.Background())
+	firstDone := make(chan struct{})
+	go func() {
+		first.Run(firstCtx)
+		close(firstDone)
+	}()
+	require.Eventually(t, first.IsLeader, waitFor, tick)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go second.Run(ctx)
+	require.Never(t, second.IsLeader, 10*tick, tick)
+	current, err := second.Leader(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "first", current)
+
+	// остановленный лидер освобождает блокировку, и её подхватывает другой экземпляр
+	stopFirst()
+	<-firstDone
+	require.False(t, first.IsLeader())
+	require.Eventually(t, second.IsLeader, waitFor, tick)
+	current, err = first.Leader(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "second", current)
+}
+
+func TestElectorStepsDownOnLostLease(t *testing.T) {
+	store := memstore.New()
+	elector := newElector(store, "first")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go elector.Run(ctx)
+	require.Eventually(t, elector.IsLeader, waitFor, tick)
+
+	// блокировку перехватили, например после обрыва сессии
+	require.NoError(t, memstore.NewLeaderLock(store, "first").Release(ctx))
+	ok, err := memstore.NewLeaderLock(store, "second").TryAcquire(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Eventually(t, func() bool { return !elector.IsLeader() }, waitFor, tick)
+}
@@ -0,0 +1,31 @@
+// Package tenant магазин (тенант), к которому относятся запрос и данные
+package tenant
+
+import (
+	"context"
+)
+
+// Default тенант развёртывания с одним магазином и данных, созданных до появления тенантов
+const Default = "default"
+
+type ctxKey struct{}
+
+func ToContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// Lookup тенант, явно заданный в ctx
+func Lookup(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(ctxKey{}).(string)
+
+	return id, ok && id != ""
+}
+
+// FromContext тенант из ctx или Default, если он не задан
+func FromContext(ctx context.Context) string {
+	if id, ok := Lookup(ctx); ok {
+		return id
+	}
+
+	return Default
+}
@@ -2,34 +2,85 @@ package utils
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 
 	log "github.com/sirupsen/logrus"
 )
 
 const (
-	ContentType         = "Content-Type"
-	ContentTypeText     = "text/plain"
-	ContentTypeTextHTML = "text/html"
-	ContentTypeJSON     = "application/json"
-	ContentTypeJSONUTF8 = "application/json; charset=utf-8"
-	ContentEncoding     = "Content-Encoding"
-	AcceptEncoding      = "Accept-Encoding"
+	ContentType            = "Content-Type"
+	ContentTypeText        = "text/plain"
+	ContentTypeTextHTML    = "text/html"
+	ContentTypeJSON        = "application/json"
+	ContentTypeJSONUTF8    = "application/json; charset=utf-8"
+	ContentTypeProblemJSON = "application/problem+json"
+	ContentTypeCSV         = "text/csv; charset=utf-8"
+	ContentDisposition     = "Content-Disposition"
+	ContentEncoding        = "Content-Encoding"
+	AcceptEncoding         = "Accept-Encoding"
+	RequestIDHeader        = "X-Request-ID"
+	IdempotencyKeyHeader   = "Idempotency-Key"
+	AuthorizationHeader    = "Authorization"
 )
 
-func JSONError(w http.ResponseWriter, msg string, status int) {
-	w.Header().Set(ContentType, ContentTypeJSONUTF8)
-	w.Header().Set("X-Content-Type-Options", "nosniff")
-	w.WriteHeader(status)
-	_, err := fmt.Fprintf(w, `{"error": "%s"}`, msg)
+const (
+	ProblemTypePrefix = "urn:gophermart:problem:"
+
+	ProblemCodeBadRequest      = "bad_request"
+	ProblemCodeUnauthenticated = "unauthenticated"
+	ProblemCodeForbidden       = "forbidden"
+	ProblemCodeNotFound        = "not_found"
+	ProblemCodeConflict        = "conflict"
+	ProblemCodeUnprocessable   = "unprocessable"
+	ProblemCodeInternal        = "internal_error"
+	ProblemCodeUnknown         = "error"
+)
+
+// InternalErrorDetail единственное, что клиент узнаёт о внутренней ошибке; подробности только в логе
+const InternalErrorDetail = "internal server error"
+
+// Problem ответ об ошибке по RFC 7807 (application/problem+json), Code - стабильный машиночитаемый код
+type Problem struct {
+	Type       string `json:"type"`
+	Title      string `json:"title"`
+	Status     int    `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	Code       string `json:"code"`
+	RequestID  string `json:"request_id,omitempty"`
+	Violations any    `json:"violations,omitempty"`
+}
+
+func NewProblem(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   ProblemTypePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// SendProblem отправляет ошибку, request ID берётся из заголовка ответа, выставленного middleware
+func SendProblem(w http.ResponseWriter, p *Problem) {
+	if p.RequestID == "" {
+		p.RequestID = w.Header().Get(RequestIDHeader)
+	}
+	data, err := json.Marshal(p)
 	if err != nil {
-		log.WithError(err).WithField("msg", msg).Error("unable to write json error")
+		log.WithError(err).WithField("problem", p).Error("unable to marshal problem")
+		data = []byte(`{"type":"` + ProblemTypePrefix + ProblemCodeInternal + `","status":500,"code":"` + ProblemCodeInternal + `"}`)
+		p.Status = http.StatusInternalServerError
+	}
+	w.Header().Set(ContentType, ContentTypeProblemJSON)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	if _, err := w.Write(data); err != nil {
+		log.WithError(err).WithField("problem", p).Error("unable to write problem")
 	}
 }
 
-func SendDomainError(w http.ResponseWriter, err error, status int) {
-	SendErrorMsg(w, err, err.Error(), status)
+func JSONError(w http.ResponseWriter, msg string, status int) {
+	SendProblem(w, NewProblem(status, ProblemCodeForStatus(status), msg))
 }
 
 func SendErrorMsg(w http.ResponseWriter, err error, msg string, status int) {
@@ -45,21 +96,44 @@ func SentNotFound(w http.ResponseWriter, err error, msg string) {
 	SendErrorMsg(w, err, msg, http.StatusNotFound)
 }
 
+// SendInternalError логирует ошибку и сообщение, клиенту уходит только InternalErrorDetail
 func SendInternalError(w http.ResponseWriter, err error, msg string) {
-	log.WithError(err).Error(msg)
-	JSONError(w, msg, http.StatusInternalServerError)
+	log.WithError(err).WithField("request_id", w.Header().Get(RequestIDHeader)).Error(msg)
+	SendProblem(w, NewProblem(http.StatusInternalServerError, ProblemCodeInternal, InternalErrorDetail))
 }
 
 func SendResponse(w http.ResponseWriter, obj any, status int) {
-	w.Header().Set(ContentType, ContentTypeJSONUTF8)
-	w.WriteHeader(status)
 	data, err := json.Marshal(obj)
 	if err != nil {
 		SendInternalError(w, err, "unable to marshal object")
 		return
 	}
+	w.Header().Set(ContentType, ContentTypeJSONUTF8)
+	w.WriteHeader(status)
 	if _, err := w.Write(data); err != nil {
-		SendInternalError(w, err, "unable to write response")
+		log.WithError(err).Error("unable to write response")
 		return
 	}
 }
+
+// ProblemCodeForStatus общий код для ошибок, не связанных с конкретной ошибкой домена
+func ProblemCodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return ProblemCodeBadRequest
+	case http.StatusUnauthorized:
+		return ProblemCodeUnauthenticated
+	case http.StatusForbidden:
+		return ProblemCodeForbidden
+	case http.StatusNotFound:
+		return ProblemCodeNotFound
+	case http.StatusConflict:
+		return ProblemCodeConflict
+	case http.StatusUnprocessableEntity:
+		return ProblemCodeUnprocessable
+	case http.StatusInternalServerError:
+		return ProblemCodeInternal
+	default:
+		return ProblemCodeUnknown
+	}
+}
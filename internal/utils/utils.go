@@ -20,7 +20,7 @@ func Contains[T comparable](s []T, e T) bool {
 	return false
 }
 
-func ContainsWhere[T comparable](s []T, predicate func(e T) bool) bool {
+func ContainsWhere[T any](s []T, predicate func(e T) bool) bool {
 	for _, v := range s {
 		if predicate(v) {
 			return true
@@ -0,0 +1,102 @@
+package workers
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBackoff во сколько раз уменьшается предел после отказа, если Backoff не задан
+const DefaultBackoff = 0.9
+
+var (
+	_ Limiter = FixedLimit(0)
+	_ Limiter = (*AIMDLimiter)(nil)
+)
+
+// Limiter предел числа одновременных обработчиков OverloadableWorker
+type Limiter interface {
+	Limit() int
+	// Observe результат обработки, начатой в start и длившейся latency
+	Observe(start time.Time, latency time.Duration, failed bool)
+}
+
+// FixedLimit постоянный предел
+type FixedLimit int
+
+func (l FixedLimit) Limit() int {
+	return int(l)
+}
+
+func (l FixedLimit) Observe(time.Time, time.Duration, bool) {}
+
+/*
+AIMDLimiter подстраивает предел по результатам обработки: каждый успешный ответ быстрее latencyThreshold
+увеличивает предел на 1/предел (около единицы за каждые limit ответов), отказ или медленный ответ умножает
+его на backoff. Отказы обработок, начатых до последнего уменьшения, предел повторно не уменьшают,
+иначе одна волна отказов среди одновременных запросов сбрасывала бы его сразу до минимума.
+*/
+type AIMDLimiter struct {
+	min              float64
+	max              float64
+	latencyThreshold time.Duration
+	backoff          float64
+
+	mu           sync.Mutex
+	limit        float64
+	lastDecrease time.Time
+}
+
+// NewAIMDLimiter предел от minLimit до maxLimit, начиная с initial; нулевой latencyThreshold не учитывает время ответа
+func NewAIMDLimiter(minLimit, maxLimit, initial int, latencyThreshold time.Duration, backoff float64) *AIMDLimiter {
+	minLimit = max(minLimit, 1)
+	maxLimit = max(maxLimit, minLimit)
+	if backoff <= 0 || backoff >= 1 {
+		backoff = DefaultBackoff
+	}
+
+	return &AIMDLimiter{
+		min:              float64(minLimit),
+		max:              float64(maxLimit),
+		latencyThreshold: latencyThreshold,
+		backoff:          backoff,
+		limit:            float64(min(max(initial, minLimit), maxLimit)),
+	}
+}
+
+func (l *AIMDLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int(l.limit)
+}
+
+func (l *AIMDLimiter) Observe(start time.Time, latency time.Duration, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !failed && (l.latencyThreshold <= 0 || latency <= l.latencyThreshold) {
+		l.limit = math.Min(l.max, l.limit+1/l.limit)
+		return
+	}
+	if start.Before(l.lastDecrease) {
+		return
+	}
+	l.limit = math.Max(l.min, math.Floor(l.limit*l.backoff))
+	l.lastDecrease = start.Add(latency)
+}
+
+type outcomeKey struct{}
+
+type outcome struct {
+	failed atomic.Bool
+}
+
+// MarkFailed сообщает Limiter, что обработка в ctx завершилась отказом; вне воркера ничего не делает
+func MarkFailed(ctx context.Context) {
+	if o, ok := ctx.Value(outcomeKey{}).(*outcome); ok {
+		o.failed.Store(true)
+	}
+}
@@ -2,44 +2,45 @@ package workers
 
 import (
 	"context"
-	"sync"
-	"sync/atomic"
 )
 
 type Handler[T any] func(ctx context.Context, arg T)
 
+// OverloadableWorker обрабатывает задачи в Queue, а не принятые очередью передаёт overloadHandler
 type OverloadableWorker[T any] struct {
-	maxWorkers      int32
-	workersNum      atomic.Int32
-	handler         Handler[T]
-	overloadHandler Handler[T]
-	wg              sync.WaitGroup
+	queue *Queue[T]
 }
 
 func NewOverloadableWorker[T any](maxWorkers int, handler Handler[T], onOverload Handler[T]) *OverloadableWorker[T] {
+	return NewAdaptiveWorker(FixedLimit(maxWorkers), handler, onOverload)
+}
+
+// NewAdaptiveWorker воркер с пределом от limiter; handler сообщает об отказе через MarkFailed(ctx)
+func NewAdaptiveWorker[T any](limiter Limiter, handler Handler[T], onOverload Handler[T]) *OverloadableWorker[T] {
+	return NewQueuedWorker(QueueConfig{}, limiter, handler, onOverload)
+}
+
+// NewQueuedWorker воркер, задачи которого при занятых обработчиках ждут в очереди по cfg
+func NewQueuedWorker[T any](cfg QueueConfig, limiter Limiter, handler Handler[T], onOverload Handler[T]) *OverloadableWorker[T] {
 	return &OverloadableWorker[T]{
-		maxWorkers:      int32(maxWorkers),
-		handler:         handler,
-		overloadHandler: onOverload,
+		queue: NewQueue(cfg, limiter, handler, onOverload),
 	}
 }
 
 func (p *OverloadableWorker[T]) Add(ctx context.Context, arg T) {
-	if p.workersNum.Load() < p.maxWorkers {
-		p.wg.Add(1)
-		p.workersNum.Add(1)
-		go func() {
-			defer func() {
-				p.workersNum.Add(-1)
-				p.wg.Done()
-			}()
-			p.handler(ctx, arg)
-		}()
-	} else {
-		p.overloadHandler(ctx, arg)
+	p.AddWithPriority(ctx, arg, PriorityHigh)
+}
+
+func (p *OverloadableWorker[T]) AddWithPriority(ctx context.Context, arg T, priority Priority) {
+	if err := p.queue.TrySubmit(ctx, arg, priority); err != nil {
+		p.queue.onDrop(ctx, arg)
 	}
 }
 
+func (p *OverloadableWorker[T]) Stats() QueueStats {
+	return p.queue.Stats()
+}
+
 func (p *OverloadableWorker[T]) Wait() {
-	p.wg.Wait()
+	p.queue.Wait()
 }
@@ -1,7 +1,7 @@
 package workers
 
 import (
-	"sync"
+	"context"
 )
 
 type Task func()
@@ -10,17 +10,19 @@ type Waiter interface {
 	Wait()
 }
 
+// Pool выполняет задачи не более чем в size горутинах, остальные ждут в очереди
 type Pool struct {
-	size    int
-	jobs    chan Task
-	wg      sync.WaitGroup
-	started bool
-	mu      sync.Mutex
+	queue *Queue[Task]
 }
 
+// NewPool пул с очередью на size задач, Run ждёт места в очереди
 func NewPool(size int) *Pool {
+	return NewBoundedPool(size, QueueConfig{Size: size})
+}
+
+func NewBoundedPool(size int, cfg QueueConfig) *Pool {
 	return &Pool{
-		size: size,
+		queue: NewQueue(cfg, FixedLimit(size), runTask, func(context.Context, Task) {}),
 	}
 }
 
@@ -31,49 +33,31 @@ func Start(poolSize int) *Pool {
 	return p
 }
 
-func (p *Pool) Start() {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+// Start оставлен для совместимости: горутины запускаются по мере поступления задач
+func (p *Pool) Start() {}
 
-	p.start()
+func (p *Pool) Run(task Task) {
+	_ = p.Submit(context.Background(), task)
 }
 
-func (p *Pool) start() {
-	if p.started {
-		return
-	}
-	p.jobs = make(chan Task)
-	for i := 0; i < p.size; i++ {
-		p.wg.Add(1)
-		go func() {
-			defer p.wg.Done()
-			for job := range p.jobs {
-				job()
-			}
-		}()
-	}
-	p.started = true
+// TrySubmit ставит задачу без ожидания; ErrQueueFull, если очередь заполнена
+func (p *Pool) TrySubmit(task Task) error {
+	return p.queue.TrySubmit(context.Background(), task, PriorityHigh)
 }
 
-func (p *Pool) Run(task Task) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+// Submit ждёт места в очереди, пока не отменён ctx
+func (p *Pool) Submit(ctx context.Context, task Task) error {
+	return p.queue.Submit(ctx, task, PriorityHigh)
+}
 
-	if !p.started {
-		p.start()
-	}
-	p.jobs <- task
+func (p *Pool) Stats() QueueStats {
+	return p.queue.Stats()
 }
 
 func (p *Pool) Wait() {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+	p.queue.Wait()
+}
 
-	if !p.started {
-		return
-	}
-	close(p.jobs)
-	// ждем завершения воркеров, иначе send on closed channel
-	p.wg.Wait()
-	p.started = false
+func runTask(_ context.Context, task Task) {
+	task()
 }
@@ -0,0 +1,292 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Priority полоса очереди: задачи старшей полосы забираются обработчиками первыми
+type Priority int
+
+const (
+	// PriorityHigh новые задачи, например только что загруженные заказы
+	PriorityHigh Priority = iota
+	// PriorityLow повторы, например заказы, подобранные опросом по таймеру
+	PriorityLow
+
+	priorities = 2
+)
+
+// OverflowPolicy поведение при заполненной очереди
+type OverflowPolicy string
+
+const (
+	// OverflowReject новая задача отклоняется с ErrQueueFull
+	OverflowReject = OverflowPolicy("reject")
+	// OverflowDropOldest вытесняется самая старая задача из самой младшей полосы, не старше новой задачи;
+	// если такой нет, новая задача отклоняется
+	OverflowDropOldest = OverflowPolicy("drop_oldest")
+)
+
+var ErrQueueFull = errors.New("queue is full")
+
+// QueueConfig Size - сколько задач может ждать свободного обработчика, 0 - задача принимается,
+// только если обработчик свободен сразу. TaskTimeout ограничивает контекст обработки, 0 - без ограничения.
+type QueueConfig struct {
+	Size        int
+	Overflow    OverflowPolicy
+	TaskTimeout time.Duration
+}
+
+// QueueStats состояние очереди и счётчики с момента создания
+type QueueStats struct {
+	QueuedHigh int `json:"queued_high"`
+	QueuedLow  int `json:"queued_low"`
+	Capacity   int `json:"capacity"`
+	Active     int `json:"active"`
+	Limit      int `json:"limit"`
+
+	Submitted uint64 `json:"submitted"`
+	Rejected  uint64 `json:"rejected"`
+	// Dropped вытесненные задачи и задачи, контекст которых отменён до начала обработки
+	Dropped   uint64 `json:"dropped"`
+	Completed uint64 `json:"completed"`
+	Panicked  uint64 `json:"panicked"`
+	TimedOut  uint64 `json:"timed_out"`
+}
+
+type queuedTask[T any] struct {
+	ctx context.Context
+	arg T
+}
+
+/*
+Queue ограниченная очередь с обработчиками по требованию: пока обработчиков меньше предела limiter, задача
+стартует сразу, иначе ждёт в своей полосе. Освободившийся обработчик забирает следующую задачу, старшие полосы
+первыми. Задачи, которые так и не будут обработаны (вытесненные или с отменённым контекстом), передаются onDrop,
+чтобы вызывающий мог вернуть их позже. Паника обработчика перехватывается и считается отказом.
+*/
+type Queue[T any] struct {
+	cfg     QueueConfig
+	limiter Limiter
+	handler Handler[T]
+	onDrop  Handler[T]
+
+	mu     sync.Mutex
+	lanes  [priorities][]queuedTask[T]
+	queued int
+	active int
+	stats  QueueStats
+	// space закрывается и заменяется, когда в очереди освобождается место
+	space chan struct{}
+	wg    sync.WaitGroup
+}
+
+func NewQueue[T any](cfg QueueConfig, limiter Limiter, handler Handler[T], onDrop Handler[T]) *Queue[T] {
+	if cfg.Overflow == "" {
+		cfg.Overflow = OverflowReject
+	}
+
+	return &Queue[T]{
+		cfg:     cfg,
+		limiter: limiter,
+		handler: handler,
+		onDrop:  onDrop,
+		space:   make(chan struct{}),
+	}
+}
+
+// TrySubmit ставит задачу в очередь без ожидания; ErrQueueFull, если места нет
+func (q *Queue[T]) TrySubmit(ctx context.Context, arg T, priority Priority) error {
+	q.mu.Lock()
+	ok, dropped := q.enqueue(queuedTask[T]{ctx: ctx, arg: arg}, priority)
+	if !ok {
+		q.stats.Rejected++
+	}
+	q.mu.Unlock()
+
+	if dropped != nil {
+		q.drop(dropped)
+	}
+	if !ok {
+		return ErrQueueFull
+	}
+
+	return nil
+}
+
+// Submit ждёт места в очереди, пока не отменён ctx
+func (q *Queue[T]) Submit(ctx context.Context, arg T, priority Priority) error {
+	for {
+		q.mu.Lock()
+		ok, dropped := q.enqueue(queuedTask[T]{ctx: ctx, arg: arg}, priority)
+		space := q.space
+		q.mu.Unlock()
+
+		if dropped != nil {
+			q.drop(dropped)
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			q.mu.Lock()
+			q.stats.Rejected++
+			q.mu.Unlock()
+			return ctx.Err()
+		case <-space:
+		}
+	}
+}
+
+// Wait ждёт завершения всех принятых задач; новые задачи во время ожидания ставить нельзя
+func (q *Queue[T]) Wait() {
+	q.wg.Wait()
+}
+
+func (q *Queue[T]) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := q.stats
+	stats.QueuedHigh = len(q.lanes[PriorityHigh])
+	stats.QueuedLow = len(q.lanes[PriorityLow])
+	stats.Capacity = q.cfg.Size
+	stats.Active = q.active
+	stats.Limit = q.limiter.Limit()
+
+	return stats
+}
+
+// enqueue запускает задачу или ставит её в полосу; dropped - вытесненная задача. Вызывается под mu.
+func (q *Queue[T]) enqueue(t queuedTask[T], priority Priority) (ok bool, dropped *queuedTask[T]) {
+	priority = min(max(priority, PriorityHigh), PriorityLow)
+	if q.active < q.limiter.Limit() {
+		q.stats.Submitted++
+		q.active++
+		q.wg.Add(1)
+		go q.work(t)
+		return true, nil
+	}
+	if q.queued >= q.cfg.Size {
+		if q.cfg.Overflow != OverflowDropOldest {
+			return false, nil
+		}
+		if dropped = q.evict(priority); dropped == nil {
+			return false, nil
+		}
+	}
+
+	q.stats.Submitted++
+	q.lanes[priority] = append(q.lanes[priority], t)
+	q.queued++
+	q.wg.Add(1)
+
+	return true, dropped
+}
+
+// evict вынимает самую старую задачу из самой младшей непустой полосы не старше priority
+func (q *Queue[T]) evict(priority Priority) *queuedTask[T] {
+	for p := PriorityLow; p >= priority; p-- {
+		if len(q.lanes[p]) == 0 {
+			continue
+		}
+		t := q.lanes[p][0]
+		q.lanes[p] = q.lanes[p][1:]
+		q.queued--
+		q.stats.Dropped++
+		return &t
+	}
+
+	return nil
+}
+
+// next следующая задача для освободившегося обработчика; false - обработчик завершается. Вызывается под mu.
+func (q *Queue[T]) next() (queuedTask[T], bool) {
+	if q.active <= q.limiter.Limit() {
+		for p := range q.lanes {
+			if len(q.lanes[p]) == 0 {
+				continue
+			}
+			t := q.lanes[p][0]
+			q.lanes[p] = q.lanes[p][1:]
+			q.queued--
+			q.freeSpace()
+			return t, true
+		}
+	}
+	q.active--
+
+	return queuedTask[T]{}, false
+}
+
+func (q *Queue[T]) freeSpace() {
+	close(q.space)
+	q.space = make(chan struct{})
+}
+
+func (q *Queue[T]) work(t queuedTask[T]) {
+	for {
+		if t.ctx.Err() != nil {
+			q.mu.Lock()
+			q.stats.Dropped++
+			q.mu.Unlock()
+			q.onDrop(t.ctx, t.arg)
+		} else {
+			panicked, timedOut := q.run(t)
+			q.mu.Lock()
+			q.stats.Completed++
+			if panicked {
+				q.stats.Panicked++
+			}
+			if timedOut {
+				q.stats.TimedOut++
+			}
+			q.mu.Unlock()
+		}
+		q.wg.Done()
+
+		q.mu.Lock()
+		var ok bool
+		t, ok = q.next()
+		q.mu.Unlock()
+		if !ok {
+			return
+		}
+	}
+}
+
+func (q *Queue[T]) run(t queuedTask[T]) (panicked, timedOut bool) {
+	ctx := t.ctx
+	if q.cfg.TaskTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.cfg.TaskTimeout)
+		defer cancel()
+	}
+	o := &outcome{}
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			o.failed.Store(true)
+			log.WithField("stack", string(debug.Stack())).Error(fmt.Sprintf("Worker task panicked: %v", r))
+		}
+		timedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+		q.limiter.Observe(start, time.Since(start), o.failed.Load() || timedOut)
+	}()
+	q.handler(context.WithValue(ctx, outcomeKey{}, o), t.arg)
+
+	return false, false
+}
+
+func (q *Queue[T]) drop(t *queuedTask[T]) {
+	q.onDrop(t.ctx, t.arg)
+	q.wg.Done()
+}
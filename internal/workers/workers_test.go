@@ -5,6 +5,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/k-zavarnitsyn/gophermart/internal/workers"
 	"github.com/stretchr/testify/assert"
@@ -61,3 +62,248 @@ func Test_workers_OverloadableWorker(t *testing.T) {
 		assert.Equal(t, int64(total), counter.Load()+overloadCounter.Load())
 	})
 }
+
+func Test_workers_AIMDLimiter(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ok := 100 * time.Millisecond
+	slow := 3 * time.Second
+
+	t.Run("Grows while healthy up to max", func(t *testing.T) {
+		limiter := workers.NewAIMDLimiter(2, 6, 4, time.Second, 0.5)
+		// на каждую единицу роста нужно чуть больше limit успешных ответов
+		for i := 0; i < 4; i++ {
+			limiter.Observe(start, ok, false)
+		}
+		assert.Equal(t, 4, limiter.Limit())
+		limiter.Observe(start, ok, false)
+		assert.Equal(t, 5, limiter.Limit())
+		for i := 0; i < 100; i++ {
+			limiter.Observe(start, ok, false)
+		}
+		assert.Equal(t, 6, limiter.Limit())
+	})
+
+	t.Run("Shrinks on failures and slow responses down to min", func(t *testing.T) {
+		limiter := workers.NewAIMDLimiter(2, 20, 20, time.Second, 0.5)
+		limiter.Observe(start, ok, true)
+		assert.Equal(t, 10, limiter.Limit())
+		limiter.Observe(start.Add(time.Second), slow, false)
+		assert.Equal(t, 5, limiter.Limit())
+		for i := 0; i < 5; i++ {
+			limiter.Observe(start.Add(time.Duration(10+i*10)*time.Second), ok, true)
+		}
+		assert.Equal(t, 2, limiter.Limit())
+	})
+
+	t.Run("Requests started before decrease do not shrink again", func(t *testing.T) {
+		limiter := workers.NewAIMDLimiter(1, 20, 16, time.Second, 0.5)
+		// волна одновременных отказов уменьшает предел один раз
+		for i := 0; i < 10; i++ {
+			limiter.Observe(start.Add(time.Duration(i)*time.Millisecond), ok, true)
+		}
+		assert.Equal(t, 8, limiter.Limit())
+		limiter.Observe(start.Add(time.Second), ok, true)
+		assert.Equal(t, 4, limiter.Limit())
+	})
+
+	t.Run("Zero latency threshold ignores latency", func(t *testing.T) {
+		limiter := workers.NewAIMDLimiter(1, 10, 1, 0, 0)
+		limiter.Observe(start, time.Hour, false)
+		assert.Equal(t, 2, limiter.Limit())
+	})
+}
+
+// recordingLimiter постоянный предел, запоминающий результаты обработок
+type recordingLimiter struct {
+	limit  int
+	mu     sync.Mutex
+	failed []bool
+}
+
+func (l *recordingLimiter) Limit() int {
+	return l.limit
+}
+
+func (l *recordingLimiter) Observe(start time.Time, latency time.Duration, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.failed = append(l.failed, failed)
+}
+
+func Test_workers_AdaptiveWorker(t *testing.T) {
+	limiter := &recordingLimiter{limit: 2}
+	release := make(chan struct{})
+	started := make(chan int, 2)
+	overloaded := make(chan int, 1)
+	pool := workers.NewAdaptiveWorker[int](limiter, func(ctx context.Context, arg int) {
+		started <- arg
+		<-release
+		if arg == 1 {
+			workers.MarkFailed(ctx)
+		}
+	}, func(ctx context.Context, arg int) {
+		overloaded <- arg
+	})
+
+	ctx := context.Background()
+	pool.Add(ctx, 1)
+	pool.Add(ctx, 2)
+	<-started
+	<-started
+	// оба места заняты, третий уходит в обработку перегрузки
+	pool.Add(ctx, 3)
+	assert.Equal(t, 3, <-overloaded)
+
+	close(release)
+	pool.Wait()
+	assert.ElementsMatch(t, []bool{true, false}, limiter.failed)
+	// MarkFailed вне воркера ничего не делает
+	workers.MarkFailed(ctx)
+}
+
+// blockingQueue очередь с одним обработчиком, который ждёт release и записывает обработанные задачи
+type blockingQueue struct {
+	queue   *workers.Queue[int]
+	release chan struct{}
+	started chan int
+	mu      sync.Mutex
+	done    []int
+	dropped []int
+}
+
+func newBlockingQueue(cfg workers.QueueConfig) *blockingQueue {
+	q := &blockingQueue{
+		release: make(chan struct{}),
+		started: make(chan int, 100),
+	}
+	q.queue = workers.NewQueue[int](cfg, workers.FixedLimit(1), func(ctx context.Context, arg int) {
+		q.started <- arg
+		<-q.release
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.done = append(q.done, arg)
+	}, func(ctx context.Context, arg int) {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.dropped = append(q.dropped, arg)
+	})
+
+	return q
+}
+
+func Test_workers_Queue(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("High priority lane goes first", func(t *testing.T) {
+		q := newBlockingQueue(workers.QueueConfig{Size: 10})
+		assert.NoError(t, q.queue.TrySubmit(ctx, 1, workers.PriorityLow))
+		<-q.started
+		assert.NoError(t, q.queue.TrySubmit(ctx, 2, workers.PriorityLow))
+		assert.NoError(t, q.queue.TrySubmit(ctx, 3, workers.PriorityHigh))
+		assert.NoError(t, q.queue.TrySubmit(ctx, 4, workers.PriorityLow))
+		assert.NoError(t, q.queue.TrySubmit(ctx, 5, workers.PriorityHigh))
+
+		stats := q.queue.Stats()
+		assert.Equal(t, 2, stats.QueuedHigh)
+		assert.Equal(t, 2, stats.QueuedLow)
+		assert.Equal(t, 1, stats.Active)
+
+		close(q.release)
+		q.queue.Wait()
+		assert.Equal(t, []int{1, 3, 5, 2, 4}, q.done)
+		assert.Equal(t, uint64(5), q.queue.Stats().Completed)
+	})
+
+	t.Run("Reject when full", func(t *testing.T) {
+		q := newBlockingQueue(workers.QueueConfig{Size: 1})
+		assert.NoError(t, q.queue.TrySubmit(ctx, 1, workers.PriorityHigh))
+		<-q.started
+		assert.NoError(t, q.queue.TrySubmit(ctx, 2, workers.PriorityHigh))
+		assert.ErrorIs(t, q.queue.TrySubmit(ctx, 3, workers.PriorityHigh), workers.ErrQueueFull)
+
+		close(q.release)
+		q.queue.Wait()
+		assert.Equal(t, []int{1, 2}, q.done)
+		assert.Empty(t, q.dropped)
+		assert.Equal(t, uint64(1), q.queue.Stats().Rejected)
+	})
+
+	t.Run("Drop oldest of lower or equal priority", func(t *testing.T) {
+		q := newBlockingQueue(workers.QueueConfig{Size: 2, Overflow: workers.OverflowDropOldest})
+		assert.NoError(t, q.queue.TrySubmit(ctx, 1, workers.PriorityHigh))
+		<-q.started
+		assert.NoError(t, q.queue.TrySubmit(ctx, 2, workers.PriorityHigh))
+		assert.NoError(t, q.queue.TrySubmit(ctx, 3, workers.PriorityLow))
+		// вытесняется младшая задача 3, а не более старая 2
+		assert.NoError(t, q.queue.TrySubmit(ctx, 4, workers.PriorityHigh))
+		// младшая задача не вытесняет старшие
+		assert.ErrorIs(t, q.queue.TrySubmit(ctx, 5, workers.PriorityLow), workers.ErrQueueFull)
+		assert.NoError(t, q.queue.TrySubmit(ctx, 6, workers.PriorityHigh))
+
+		close(q.release)
+		q.queue.Wait()
+		assert.Equal(t, []int{1, 4, 6}, q.done)
+		assert.Equal(t, []int{3, 2}, q.dropped)
+		stats := q.queue.Stats()
+		assert.Equal(t, uint64(2), stats.Dropped)
+		assert.Equal(t, uint64(1), stats.Rejected)
+	})
+
+	t.Run("Submit waits for space until context is done", func(t *testing.T) {
+		q := newBlockingQueue(workers.QueueConfig{Size: 1})
+		assert.NoError(t, q.queue.Submit(ctx, 1, workers.PriorityHigh))
+		<-q.started
+		assert.NoError(t, q.queue.Submit(ctx, 2, workers.PriorityHigh))
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, q.queue.Submit(timeoutCtx, 3, workers.PriorityHigh), context.DeadlineExceeded)
+
+		submitted := make(chan error)
+		go func() {
+			submitted <- q.queue.Submit(ctx, 4, workers.PriorityHigh)
+		}()
+		close(q.release)
+		assert.NoError(t, <-submitted)
+		q.queue.Wait()
+		assert.Equal(t, []int{1, 2, 4}, q.done)
+	})
+
+	t.Run("Cancelled queued task is dropped", func(t *testing.T) {
+		q := newBlockingQueue(workers.QueueConfig{Size: 1})
+		assert.NoError(t, q.queue.TrySubmit(ctx, 1, workers.PriorityHigh))
+		<-q.started
+		cancelCtx, cancel := context.WithCancel(ctx)
+		assert.NoError(t, q.queue.TrySubmit(cancelCtx, 2, workers.PriorityHigh))
+		cancel()
+
+		close(q.release)
+		q.queue.Wait()
+		assert.Equal(t, []int{1}, q.done)
+		assert.Equal(t, []int{2}, q.dropped)
+	})
+
+	t.Run("Task timeout and panic recovery", func(t *testing.T) {
+		limiter := &recordingLimiter{limit: 1}
+		q := workers.NewQueue[string](workers.QueueConfig{Size: 10, TaskTimeout: 10 * time.Millisecond}, limiter,
+			func(ctx context.Context, arg string) {
+				switch arg {
+				case "panic":
+					panic("bad handler")
+				case "slow":
+					<-ctx.Done()
+				}
+			}, func(ctx context.Context, arg string) {})
+		assert.NoError(t, q.TrySubmit(ctx, "panic", workers.PriorityHigh))
+		assert.NoError(t, q.TrySubmit(ctx, "slow", workers.PriorityHigh))
+		assert.NoError(t, q.TrySubmit(ctx, "ok", workers.PriorityHigh))
+		q.Wait()
+
+		stats := q.Stats()
+		assert.Equal(t, uint64(3), stats.Completed)
+		assert.Equal(t, uint64(1), stats.Panicked)
+		assert.Equal(t, uint64(1), stats.TimedOut)
+		assert.Equal(t, []bool{true, true, false}, limiter.failed)
+	})
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"context"
+	"math"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/accrual"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+)
+
+var _ accrual.AccrualChangedHook = (*accrualCorrector)(nil)
+
+// AccrualCorrector исправление начислений, изменённых системой расчёта после перехода заказа в PROCESSED
+type AccrualCorrector interface {
+	// AccrualChanged проводит разницу между новым начислением и учтённым по заказу (начисление и прежние исправления)
+	// системной корректировкой баланса; само начисление заказа и бонус уровня не меняются.
+	// Корректировка, после которой баланс становится отрицательным, помечается для проверки поддержкой.
+	AccrualChanged(ctx context.Context, order *entity.Order, accrual float64) error
+}
+
+type accrualCorrector struct {
+	gophermart Gophermart
+
+	orderRepo repository.Order
+}
+
+func NewAccrualCorrector(gophermart Gophermart, orderRepo repository.Order) AccrualCorrector {
+	return &accrualCorrector{
+		gophermart: gophermart,
+		orderRepo:  orderRepo,
+	}
+}
+
+func (c *accrualCorrector) AccrualChanged(ctx context.Context, order *entity.Order, accrual float64) error {
+	booked := 0.0
+	if order.Accrual != nil {
+		booked = *order.Accrual
+	}
+	corrected, err := c.orderRepo.GetOrderAdjustmentsSum(ctx, order.Number)
+	if err != nil {
+		return err
+	}
+	diff := roundPoints(accrual - booked - corrected)
+	if math.Abs(diff) < pointsEpsilon {
+		return nil
+	}
+
+	adjustment := &entity.BalanceAdjustment{
+		UserID:      order.UserID,
+		Amount:      diff,
+		Reason:      "accrual correction for order " + order.Number,
+		CreatedBy:   uuid.Nil,
+		OrderNumber: &order.Number,
+	}
+	if diff < 0 {
+		balance, err := c.gophermart.GetBalance(ctx, order.UserID)
+		if err != nil {
+			return err
+		}
+		adjustment.NeedsReview = balance.Current+diff < 0
+	}
+
+	return c.orderRepo.AddBalanceAdjustment(ctx, adjustment)
+}
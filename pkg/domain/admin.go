@@ -0,0 +1,642 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/accrual"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/internal/workers"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+)
+
+const (
+	AuditActionFindUser         = "find_user"
+	AuditActionGetOrders        = "get_orders"
+	AuditActionGetWithdrawals   = "get_withdrawals"
+	AuditActionRetriggerAccrual = "retrigger_accrual"
+	AuditActionAdjustBalance    = "adjust_balance"
+	AuditActionBlockUser        = "block_user"
+	AuditActionUnblockUser      = "unblock_user"
+	AuditActionCreateUser       = "create_user"
+	AuditActionResetPassword    = "reset_password"
+	AuditActionReconcile        = "reconcile_balances"
+	AuditActionCreateCampaign   = "create_campaign"
+	AuditActionUpdateCampaign   = "update_campaign"
+	AuditActionDeleteCampaign   = "delete_campaign"
+	AuditActionRefund           = "refund_withdrawal"
+	AuditActionGetReview        = "get_review_adjustments"
+	AuditActionReviewAdjustment = "review_adjustment"
+	AuditActionGetAccrualLog    = "get_accrual_responses"
+	AuditActionGetQuarantine    = "get_accrual_quarantine"
+)
+
+// FieldRole поле роли при создании пользователя
+const FieldRole = "role"
+
+const (
+	// ReconcileNegativeBalance сумма начислений и корректировок меньше суммы списаний и сгораний
+	ReconcileNegativeBalance = "negative_balance"
+	// ReconcileProcessedWithoutAccrual по заказу в статусе PROCESSED начислен бонус, а самого начисления нет.
+	// Заказ без начисления сам по себе корректен: система расчёта не начислила по нему баллов.
+	ReconcileProcessedWithoutAccrual = "processed_without_accrual"
+	// ReconcileNegativeAccrual отрицательное начисление по заказу
+	ReconcileNegativeAccrual = "negative_accrual"
+)
+
+var _ Admin = (*adminService)(nil)
+
+// Admin операции поддержки. Каждое действие, включая просмотр, записывается в журнал аудита
+// в той же транзакции, что и само действие.
+type Admin interface {
+	// FindUser поиск пользователя по логину вместе с балансом
+	FindUser(ctx context.Context, actor *entity.Actor, login string) (*entity.User, *entity.Balance, error)
+
+	// GetUserOrders заказы пользователя
+	GetUserOrders(ctx context.Context, actor *entity.Actor, login string) ([]entity.Order, error)
+
+	// GetUserWithdrawals списания пользователя
+	GetUserWithdrawals(ctx context.Context, actor *entity.Actor, login string) ([]entity.Withdraw, error)
+
+	// RetriggerAccrual повторная отправка заказа в систему расчёта начислений
+	RetriggerAccrual(ctx context.Context, actor *entity.Actor, orderNumber string) error
+
+	// AdjustBalance ручная корректировка баланса с обязательным указанием причины
+	AdjustBalance(ctx context.Context, actor *entity.Actor, login string, req *entity.BalanceAdjustmentRequest) error
+
+	// RefundWithdrawal возврат баллов по списанию в счёт заказа, полный или частичный, не больше списанного.
+	// Повторный возврат по тому же списанию невозможен.
+	RefundWithdrawal(ctx context.Context, actor *entity.Actor, orderNumber string, req *entity.RefundRequest) (*entity.Withdraw, error)
+
+	// SetUserBlocked блокировка и разблокировка пользователя
+	SetUserBlocked(ctx context.Context, actor *entity.Actor, login string, blocked bool) error
+
+	// GetAuditLog последние записи журнала аудита
+	GetAuditLog(ctx context.Context, limit int) ([]entity.AuditRecord, error)
+
+	// GetAccrualQueueStats состояние очереди запросов в систему расчёта
+	GetAccrualQueueStats(ctx context.Context) workers.QueueStats
+
+	// GetOrderAccrualResponses сохранённые ответы системы расчёта по заказу для разбора спора
+	GetOrderAccrualResponses(ctx context.Context, actor *entity.Actor, orderNumber string) ([]entity.AccrualResponse, error)
+
+	// GetQuarantinedAccrualResponses последние ответы системы расчёта, не прошедшие проверку
+	GetQuarantinedAccrualResponses(ctx context.Context, actor *entity.Actor, limit int) ([]entity.AccrualResponse, error)
+
+	// CreateUser регистрация пользователя с заданной ролью
+	CreateUser(ctx context.Context, actor *entity.Actor, req *entity.RegisterRequest, role entity.UserRole) (*entity.User, error)
+
+	// ResetPassword установка нового пароля без проверки старого, выданные токены отзываются
+	ResetPassword(ctx context.Context, actor *entity.Actor, login, password string) error
+
+	// ReconcileBalances сверка балансов всех пользователей, возвращает только расхождения
+	ReconcileBalances(ctx context.Context, actor *entity.Actor) ([]entity.BalanceReport, error)
+
+	// ListCampaigns все промо-акции
+	ListCampaigns(ctx context.Context) ([]entity.Campaign, error)
+
+	// CreateCampaign создание промо-акции
+	CreateCampaign(ctx context.Context, actor *entity.Actor, req *entity.CampaignRequest) (*entity.Campaign, error)
+
+	// UpdateCampaign изменение промо-акции, уже начисленные бонусы не пересчитываются
+	UpdateCampaign(ctx context.Context, actor *entity.Actor, id uuid.UUID, req *entity.CampaignRequest) (*entity.Campaign, error)
+
+	// DeleteCampaign удаление промо-акции, начисленные по ней бонусы сохраняются
+	DeleteCampaign(ctx context.Context, actor *entity.Actor, id uuid.UUID) error
+
+	// GetAdjustmentsForReview исправления начислений, после которых баланс пользователя стал отрицательным
+	GetAdjustmentsForReview(ctx context.Context, actor *entity.Actor) ([]entity.AdjustmentReview, error)
+
+	// MarkAdjustmentReviewed снимает корректировку с проверки, сама корректировка остаётся в силе
+	MarkAdjustmentReviewed(ctx context.Context, actor *entity.Actor, id uuid.UUID) error
+}
+
+type adminService struct {
+	trx        Transactor
+	gophermart Gophermart
+	accrual    accrual.Service
+	hasher     *hasher
+	policy     *CredentialsPolicy
+
+	orderRepo    repository.Order
+	userRepo     repository.User
+	auditRepo    repository.Audit
+	campaignRepo repository.Campaign
+	archive      repository.AccrualArchive
+}
+
+func NewAdmin(
+	cfg *config.Config,
+	trx Transactor,
+	gophermart Gophermart,
+	accrual accrual.Service,
+	policy *CredentialsPolicy,
+	orderRepo repository.Order,
+	userRepo repository.User,
+	auditRepo repository.Audit,
+	campaignRepo repository.Campaign,
+	archive repository.AccrualArchive,
+) Admin {
+	return &adminService{
+		trx:          trx,
+		gophermart:   gophermart,
+		accrual:      accrual,
+		hasher:       &hasher{cfg: &cfg.Auth},
+		policy:       policy,
+		orderRepo:    orderRepo,
+		userRepo:     userRepo,
+		auditRepo:    auditRepo,
+		campaignRepo: campaignRepo,
+		archive:      archive,
+	}
+}
+
+func (s *adminService) FindUser(ctx context.Context, actor *entity.Actor, login string) (*entity.User, *entity.Balance, error) {
+	var user *entity.User
+	var balance *entity.Balance
+	err := s.trx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if user, err = s.userRepo.FindByLogin(ctx, login); err != nil {
+			return err
+		}
+		if balance, err = s.gophermart.GetBalance(ctx, user.ID); err != nil {
+			return err
+		}
+
+		return s.audit(ctx, actor, AuditActionFindUser, login, nil)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, balance, nil
+}
+
+func (s *adminService) GetUserOrders(ctx context.Context, actor *entity.Actor, login string) ([]entity.Order, error) {
+	var orders []entity.Order
+	err := s.trx.Transaction(ctx, func(ctx context.Context) error {
+		user, err := s.userRepo.FindByLogin(ctx, login)
+		if err != nil {
+			return err
+		}
+		if orders, err = s.orderRepo.GetUserOrders(ctx, user.ID); err != nil {
+			return err
+		}
+
+		return s.audit(ctx, actor, AuditActionGetOrders, login, nil)
+	})
+
+	return orders, err
+}
+
+func (s *adminService) GetUserWithdrawals(ctx context.Context, actor *entity.Actor, login string) ([]entity.Withdraw, error) {
+	var withdrawals []entity.Withdraw
+	err := s.trx.Transaction(ctx, func(ctx context.Context) error {
+		user, err := s.userRepo.FindByLogin(ctx, login)
+		if err != nil {
+			return err
+		}
+		if withdrawals, err = s.orderRepo.GetUserWithdrawals(ctx, user.ID); err != nil {
+			return err
+		}
+
+		return s.audit(ctx, actor, AuditActionGetWithdrawals, login, nil)
+	})
+
+	return withdrawals, err
+}
+
+func (s *adminService) RetriggerAccrual(ctx context.Context, actor *entity.Actor, orderNumber string) error {
+	orderNumber = NormalizeOrderNumber(orderNumber)
+	var order *entity.Order
+	err := s.trx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if order, err = s.orderRepo.FindByNumber(ctx, orderNumber); err != nil {
+			return err
+		}
+		details := map[string]any{"previous_status": order.Status}
+		switch {
+		case order.Status == entity.OrderStatusNew || order.Status == entity.OrderStatusProcessing:
+			// заказ и так ожидает ответа системы расчёта, отправляется повторно без смены статуса
+		case CanTransition(order.Status, entity.OrderStatusNew):
+			updated, err := s.orderRepo.SetOrderStatus(ctx, order.Number, entity.OrderStatusNew, entity.OrderSourceAdmin, nil)
+			if err != nil {
+				return err
+			}
+			if !updated {
+				return ErrIllegalTransition
+			}
+			order.Status = entity.OrderStatusNew
+		default:
+			return ErrIllegalTransition
+		}
+
+		return s.audit(ctx, actor, AuditActionRetriggerAccrual, orderNumber, details)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.accrual.Send(ctx, order)
+}
+
+func (s *adminService) AdjustBalance(ctx context.Context, actor *entity.Actor, login string, req *entity.BalanceAdjustmentRequest) error {
+	if req.Reason == "" {
+		return ErrReasonRequired
+	}
+	if req.Amount == 0 {
+		return ErrZeroAmount
+	}
+
+	return s.trx.Transaction(ctx, func(ctx context.Context) error {
+		user, err := s.userRepo.FindByLogin(ctx, login)
+		if err != nil {
+			return err
+		}
+		err = s.orderRepo.AddBalanceAdjustment(ctx, &entity.BalanceAdjustment{
+			UserID:    user.ID,
+			Amount:    req.Amount,
+			Reason:    req.Reason,
+			CreatedBy: actor.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return s.audit(ctx, actor, AuditActionAdjustBalance, login, req)
+	})
+}
+
+func (s *adminService) RefundWithdrawal(
+	ctx context.Context,
+	actor *entity.Actor,
+	orderNumber string,
+	req *entity.RefundRequest,
+) (*entity.Withdraw, error) {
+	if req.Amount < 0 {
+		return nil, ErrBadAmount
+	}
+	orderNumber = NormalizeOrderNumber(orderNumber)
+
+	var withdrawal *entity.Withdraw
+	err := s.trx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if withdrawal, err = s.orderRepo.FindWithdrawalByNumber(ctx, orderNumber); err != nil {
+			return err
+		}
+		if withdrawal.Refunded != nil {
+			return ErrAlreadyRefunded
+		}
+		amount := req.Amount
+		if amount == 0 {
+			amount = withdrawal.Value
+		}
+		if amount > withdrawal.Value {
+			return ErrRefundExceedsWithdrawal
+		}
+		refunded, err := s.orderRepo.RefundWithdrawal(ctx, withdrawal.ID, amount)
+		if err != nil {
+			return err
+		}
+		if !refunded {
+			return ErrAlreadyRefunded
+		}
+		now := time.Now()
+		withdrawal.Refunded, withdrawal.RefundedAt = &amount, &now
+
+		return s.audit(ctx, actor, AuditActionRefund, orderNumber, map[string]any{
+			"amount": amount,
+			"reason": req.Reason,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return withdrawal, nil
+}
+
+func (s *adminService) SetUserBlocked(ctx context.Context, actor *entity.Actor, login string, blocked bool) error {
+	action := AuditActionUnblockUser
+	if blocked {
+		action = AuditActionBlockUser
+	}
+
+	return s.trx.Transaction(ctx, func(ctx context.Context) error {
+		user, err := s.userRepo.FindByLogin(ctx, login)
+		if err != nil {
+			return err
+		}
+		if err := s.userRepo.SetBlocked(ctx, user.ID, blocked); err != nil {
+			return err
+		}
+
+		return s.audit(ctx, actor, action, login, nil)
+	})
+}
+
+func (s *adminService) GetAuditLog(ctx context.Context, limit int) ([]entity.AuditRecord, error) {
+	return s.auditRepo.GetLast(ctx, limit)
+}
+
+func (s *adminService) GetAccrualQueueStats(ctx context.Context) workers.QueueStats {
+	return s.accrual.QueueStats()
+}
+
+func (s *adminService) GetOrderAccrualResponses(
+	ctx context.Context,
+	actor *entity.Actor,
+	orderNumber string,
+) ([]entity.AccrualResponse, error) {
+	orderNumber = NormalizeOrderNumber(orderNumber)
+	var responses []entity.AccrualResponse
+	err := s.trx.Transaction(ctx, func(ctx context.Context) error {
+		if _, err := s.orderRepo.FindByNumber(ctx, orderNumber); err != nil {
+			return err
+		}
+		var err error
+		if responses, err = s.archive.GetOrderResponses(ctx, orderNumber); err != nil {
+			return err
+		}
+
+		return s.audit(ctx, actor, AuditActionGetAccrualLog, orderNumber, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+func (s *adminService) GetQuarantinedAccrualResponses(
+	ctx context.Context,
+	actor *entity.Actor,
+	limit int,
+) ([]entity.AccrualResponse, error) {
+	var responses []entity.AccrualResponse
+	err := s.trx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if responses, err = s.archive.GetQuarantined(ctx, limit); err != nil {
+			return err
+		}
+
+		return s.audit(ctx, actor, AuditActionGetQuarantine, "", map[string]any{"limit": limit})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+func (s *adminService) CreateUser(ctx context.Context, actor *entity.Actor, req *entity.RegisterRequest, role entity.UserRole) (*entity.User, error) {
+	if role != entity.UserRoleUser && role != entity.UserRoleSupport && role != entity.UserRoleAdmin {
+		verr := &ValidationError{}
+		verr.Add(FieldRole, ViolationUnknownValue, "unknown role %q", role)
+		return nil, verr
+	}
+
+	var user *entity.User
+	err := s.trx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if user, err = s.gophermart.Register(ctx, req); err != nil {
+			return err
+		}
+		if role != entity.UserRoleUser {
+			if err := s.userRepo.SetRole(ctx, user.ID, role); err != nil {
+				return err
+			}
+		}
+		user.Role = role
+
+		return s.audit(ctx, actor, AuditActionCreateUser, req.Login, map[string]any{"role": role})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *adminService) ResetPassword(ctx context.Context, actor *entity.Actor, login, password string) error {
+	if err := s.policy.ValidatePassword(FieldPassword, password); err != nil {
+		return err
+	}
+
+	return s.trx.Transaction(ctx, func(ctx context.Context) error {
+		user, err := s.userRepo.FindByLogin(ctx, login)
+		if err != nil {
+			return err
+		}
+		if _, err := s.userRepo.UpdatePassword(ctx, user.ID, s.hasher.GenerateSHA([]byte(password))); err != nil {
+			return err
+		}
+
+		return s.audit(ctx, actor, AuditActionResetPassword, login, nil)
+	})
+}
+
+func (s *adminService) ReconcileBalances(ctx context.Context, actor *entity.Actor) ([]entity.BalanceReport, error) {
+	var reports []entity.BalanceReport
+	err := s.trx.Transaction(ctx, func(ctx context.Context) error {
+		users, err := s.userRepo.List(ctx)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			report, err := s.reconcile(ctx, &user)
+			if err != nil {
+				return err
+			}
+			if len(report.Problems) > 0 {
+				reports = append(reports, *report)
+			}
+		}
+
+		// фоновая сверка без расхождений не засоряет журнал аудита
+		if len(reports) == 0 && actor.ID.IsNil() {
+			return nil
+		}
+		return s.audit(ctx, actor, AuditActionReconcile, "", map[string]any{"discrepancies": len(reports)})
+	})
+
+	return reports, err
+}
+
+func (s *adminService) reconcile(ctx context.Context, user *entity.User) (*entity.BalanceReport, error) {
+	report := &entity.BalanceReport{UserID: user.ID, Login: user.Login}
+	var err error
+	if report.Accrued, err = s.orderRepo.GetAccrualsSum(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if report.Adjusted, err = s.orderRepo.GetAdjustmentsSum(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if report.Withdrawn, err = s.orderRepo.GetWithdrawnSum(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if report.Expired, err = s.orderRepo.GetExpiredSum(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if report.Credited, err = s.orderRepo.GetCampaignCreditsSum(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if report.Transferred, err = s.orderRepo.GetTransfersSum(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	report.Current = report.Accrued + report.Adjusted + report.Credited + report.Transferred - report.Withdrawn - report.Expired
+	if report.Current < 0 {
+		report.Problems = append(report.Problems, ReconcileNegativeBalance)
+	}
+
+	orders, err := s.orderRepo.GetUserOrders(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, order := range orders {
+		if order.Status == entity.OrderStatusProcessed && order.Accrual == nil && utils.FromPointer(order.Bonus) != 0 {
+			report.Problems = append(report.Problems, ReconcileProcessedWithoutAccrual+":"+order.Number)
+		}
+		if order.Accrual != nil && *order.Accrual < 0 {
+			report.Problems = append(report.Problems, ReconcileNegativeAccrual+":"+order.Number)
+		}
+	}
+
+	return report, nil
+}
+
+func (s *adminService) ListCampaigns(ctx context.Context) ([]entity.Campaign, error) {
+	return s.campaignRepo.List(ctx)
+}
+
+func (s *adminService) CreateCampaign(ctx context.Context, actor *entity.Actor, req *entity.CampaignRequest) (*entity.Campaign, error) {
+	campaign := campaignFromRequest(req)
+	if err := ValidateCampaign(campaign); err != nil {
+		return nil, err
+	}
+
+	err := s.trx.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.campaignRepo.Insert(ctx, campaign); err != nil {
+			return err
+		}
+
+		return s.audit(ctx, actor, AuditActionCreateCampaign, campaign.ID.String(), req)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return campaign, nil
+}
+
+func (s *adminService) UpdateCampaign(ctx context.Context, actor *entity.Actor, id uuid.UUID, req *entity.CampaignRequest) (*entity.Campaign, error) {
+	campaign := campaignFromRequest(req)
+	campaign.ID = id
+	if err := ValidateCampaign(campaign); err != nil {
+		return nil, err
+	}
+
+	err := s.trx.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.campaignRepo.Update(ctx, campaign); err != nil {
+			return err
+		}
+
+		return s.audit(ctx, actor, AuditActionUpdateCampaign, id.String(), req)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.campaignRepo.FindByID(ctx, id)
+}
+
+func (s *adminService) DeleteCampaign(ctx context.Context, actor *entity.Actor, id uuid.UUID) error {
+	return s.trx.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.campaignRepo.Delete(ctx, id); err != nil {
+			return err
+		}
+
+		return s.audit(ctx, actor, AuditActionDeleteCampaign, id.String(), nil)
+	})
+}
+
+func (s *adminService) GetAdjustmentsForReview(ctx context.Context, actor *entity.Actor) ([]entity.AdjustmentReview, error) {
+	var reviews []entity.AdjustmentReview
+	err := s.trx.Transaction(ctx, func(ctx context.Context) error {
+		adjustments, err := s.orderRepo.GetAdjustmentsForReview(ctx)
+		if err != nil {
+			return err
+		}
+		reviews = make([]entity.AdjustmentReview, len(adjustments))
+		for i, a := range adjustments {
+			user, err := s.userRepo.FindByID(ctx, a.UserID)
+			if err != nil {
+				return err
+			}
+			reviews[i] = entity.AdjustmentReview{
+				ID:          a.ID,
+				Login:       user.Login,
+				Amount:      a.Amount,
+				Reason:      a.Reason,
+				OrderNumber: a.OrderNumber,
+				CreatedAt:   a.CreatedAt,
+			}
+		}
+
+		return s.audit(ctx, actor, AuditActionGetReview, "", nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+func (s *adminService) MarkAdjustmentReviewed(ctx context.Context, actor *entity.Actor, id uuid.UUID) error {
+	return s.trx.Transaction(ctx, func(ctx context.Context) error {
+		reviewed, err := s.orderRepo.MarkAdjustmentReviewed(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !reviewed {
+			return ErrNotFound
+		}
+
+		return s.audit(ctx, actor, AuditActionReviewAdjustment, id.String(), nil)
+	})
+}
+
+func campaignFromRequest(req *entity.CampaignRequest) *entity.Campaign {
+	return &entity.Campaign{
+		Name:           req.Name,
+		Kind:           req.Kind,
+		Value:          req.Value,
+		StartsAt:       req.StartsAt,
+		EndsAt:         req.EndsAt,
+		FirstOrderOnly: req.FirstOrderOnly,
+		MinOrderCount:  req.MinOrderCount,
+		UserCap:        req.UserCap,
+		Enabled:        req.Enabled,
+	}
+}
+
+func (s *adminService) audit(ctx context.Context, actor *entity.Actor, action, target string, details any) error {
+	record := &entity.AuditRecord{
+		ActorID:    actor.ID,
+		ActorLogin: actor.Login,
+		Action:     action,
+		Target:     target,
+	}
+	if details != nil {
+		var err error
+		if record.Details, err = json.Marshal(details); err != nil {
+			return err
+		}
+	}
+
+	return s.auditRepo.Insert(ctx, record)
+}
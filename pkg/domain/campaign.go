@@ -0,0 +1,203 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/accrual"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+)
+
+const (
+	FieldCampaignName     = "name"
+	FieldCampaignKind     = "kind"
+	FieldCampaignValue    = "value"
+	FieldCampaignEndsAt   = "ends_at"
+	FieldCampaignMinCount = "min_order_count"
+	FieldCampaignUserCap  = "user_cap"
+)
+
+// CampaignOrder заказ, для которого рассчитываются бонусы по акциям
+type CampaignOrder struct {
+	UserID      uuid.UUID
+	Number      string
+	Accrual     float64
+	ProcessedAt time.Time
+	// Sequence порядковый номер заказа среди обработанных заказов пользователя, начиная с 1
+	Sequence int
+}
+
+// EvaluateCampaigns рассчитывает бонусы по акциям для заказа. granted - сколько баллов пользователь уже получил
+// по каждой акции, нужно для ограничения UserCap. Результат не зависит от порядка campaigns:
+// акции применяются по возрастанию времени создания.
+func EvaluateCampaigns(campaigns []entity.Campaign, order CampaignOrder, granted map[uuid.UUID]float64) []entity.CampaignCredit {
+	campaigns = slices.Clone(campaigns)
+	slices.SortFunc(campaigns, func(a, b entity.Campaign) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.ID.Bytes(), b.ID.Bytes())
+	})
+
+	var credits []entity.CampaignCredit
+	for _, c := range campaigns {
+		if !campaignApplies(&c, &order) {
+			continue
+		}
+		var amount float64
+		switch c.Kind {
+		case entity.CampaignKindFixed:
+			amount = c.Value
+		case entity.CampaignKindMultiplier:
+			amount = order.Accrual * (c.Value - 1)
+		}
+		if c.UserCap > 0 {
+			amount = math.Min(amount, c.UserCap-granted[c.ID])
+		}
+		amount = roundPoints(amount)
+		if amount < pointsEpsilon {
+			continue
+		}
+		credits = append(credits, entity.CampaignCredit{
+			CampaignID:  c.ID,
+			UserID:      order.UserID,
+			OrderNumber: order.Number,
+			Amount:      amount,
+		})
+	}
+
+	return credits
+}
+
+func campaignApplies(c *entity.Campaign, order *CampaignOrder) bool {
+	switch {
+	case !c.Enabled:
+		return false
+	case c.StartsAt != nil && order.ProcessedAt.Before(*c.StartsAt):
+		return false
+	case c.EndsAt != nil && !order.ProcessedAt.Before(*c.EndsAt):
+		return false
+	case c.FirstOrderOnly && order.Sequence != 1:
+		return false
+	case order.Sequence < c.MinOrderCount:
+		return false
+	}
+
+	return true
+}
+
+// ValidateCampaign проверка акции перед сохранением
+func ValidateCampaign(c *entity.Campaign) error {
+	verr := &ValidationError{}
+	if c.Name == "" {
+		verr.Add(FieldCampaignName, ViolationRequired, "name is required")
+	}
+	switch c.Kind {
+	case entity.CampaignKindFixed:
+		if c.Value <= 0 {
+			verr.Add(FieldCampaignValue, ViolationOutOfRange, "bonus must be positive")
+		}
+	case entity.CampaignKindMultiplier:
+		if c.Value <= 1 {
+			verr.Add(FieldCampaignValue, ViolationOutOfRange, "multiplier must be greater than 1")
+		}
+	default:
+		verr.Add(FieldCampaignKind, ViolationUnknownValue, "unknown campaign kind %q", c.Kind)
+	}
+	if c.StartsAt != nil && c.EndsAt != nil && !c.EndsAt.After(*c.StartsAt) {
+		verr.Add(FieldCampaignEndsAt, ViolationOutOfRange, "campaign must end after it starts")
+	}
+	if c.MinOrderCount < 0 {
+		verr.Add(FieldCampaignMinCount, ViolationOutOfRange, "must not be negative")
+	}
+	if c.UserCap < 0 {
+		verr.Add(FieldCampaignUserCap, ViolationOutOfRange, "must not be negative")
+	}
+
+	return verr.OrNil()
+}
+
+var _ accrual.OrderProcessedHook = (*campaignEngine)(nil)
+
+// CampaignEngine начисление бонусов по акциям за обработанные заказы
+type CampaignEngine interface {
+	// OrderProcessed сохраняет бонусы по действующим акциям за сохранённый заказ, обработчик OrderProcessed.
+	// Бонус за заказ начисляется не больше одного раза, повторная доставка события его не удваивает.
+	OrderProcessed(ctx context.Context, order *entity.Order) error
+}
+
+type campaignEngine struct {
+	orderRepo    repository.Order
+	userRepo     repository.User
+	campaignRepo repository.Campaign
+}
+
+func NewCampaignEngine(orderRepo repository.Order, userRepo repository.User, campaignRepo repository.Campaign) CampaignEngine {
+	return &campaignEngine{
+		orderRepo:    orderRepo,
+		userRepo:     userRepo,
+		campaignRepo: campaignRepo,
+	}
+}
+
+// OrderProcessed заказ без начисления считается заказом с нулевым начислением: фиксированный бонус
+// и правила по номеру заказа к нему применяются, умножение ничего не даёт
+func (e *campaignEngine) OrderProcessed(ctx context.Context, order *entity.Order) error {
+	campaigns, err := e.campaignRepo.List(ctx)
+	if err != nil || len(campaigns) == 0 {
+		return err
+	}
+	// заказы пользователя обрабатываются параллельно: без блокировки обе транзакции
+	// увидят прежнюю сумму бонусов и обе начислят до UserCap
+	if err := e.userRepo.LockForUpdate(ctx, order.UserID); err != nil {
+		return err
+	}
+
+	orders, err := e.orderRepo.GetUserOrders(ctx, order.UserID)
+	if err != nil {
+		return err
+	}
+	// событие доставляется после сохранения заказа, поэтому время обработки берётся из хранилища,
+	// а номер считается только по заказам, обработанным раньше этого
+	processedAt := time.Now()
+	for _, o := range orders {
+		if o.Number == order.Number && o.ProcessedAt != nil {
+			processedAt = *o.ProcessedAt
+		}
+	}
+	sequence := 1
+	for _, o := range orders {
+		if o.Status == entity.OrderStatusProcessed && o.Number != order.Number &&
+			(o.ProcessedAt == nil || o.ProcessedAt.Before(processedAt)) {
+			sequence++
+		}
+	}
+	credits, err := e.orderRepo.GetUserCampaignCredits(ctx, order.UserID)
+	if err != nil {
+		return err
+	}
+	granted := make(map[uuid.UUID]float64)
+	for _, c := range credits {
+		granted[c.CampaignID] += c.Amount
+	}
+
+	for _, credit := range EvaluateCampaigns(campaigns, CampaignOrder{
+		UserID:      order.UserID,
+		Number:      order.Number,
+		Accrual:     utils.FromPointer(order.Accrual),
+		ProcessedAt: processedAt,
+		Sequence:    sequence,
+	}, granted) {
+		if err := e.orderRepo.AddCampaignCredit(ctx, &credit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package domain_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_domain_EvaluateCampaigns(t *testing.T) {
+	saturday := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	monday := saturday.AddDate(0, 0, 2)
+	weekend := entity.Campaign{
+		ID:        uuid.Must(uuid.NewV6()),
+		Name:      "double points weekend",
+		Kind:      entity.CampaignKindMultiplier,
+		Value:     2,
+		StartsAt:  &saturday,
+		EndsAt:    &monday,
+		Enabled:   true,
+		CreatedAt: saturday.AddDate(0, 0, -7),
+	}
+	welcome := entity.Campaign{
+		ID:             uuid.Must(uuid.NewV6()),
+		Name:           "+100 on first order",
+		Kind:           entity.CampaignKindFixed,
+		Value:          100,
+		FirstOrderOnly: true,
+		Enabled:        true,
+		CreatedAt:      saturday.AddDate(0, 0, -30),
+	}
+	loyal := entity.Campaign{
+		ID:            uuid.Must(uuid.NewV6()),
+		Name:          "+50 from fifth order, up to 120",
+		Kind:          entity.CampaignKindFixed,
+		Value:         50,
+		MinOrderCount: 5,
+		UserCap:       120,
+		Enabled:       true,
+		CreatedAt:     saturday.AddDate(0, 0, -1),
+	}
+	disabled := welcome
+	disabled.ID = uuid.Must(uuid.NewV6())
+	disabled.Enabled = false
+	campaigns := []entity.Campaign{loyal, weekend, disabled, welcome}
+
+	testCases := []struct {
+		name     string
+		order    domain.CampaignOrder
+		granted  map[uuid.UUID]float64
+		expected map[uuid.UUID]float64
+	}{
+		{
+			name:     "First order on weekend",
+			order:    domain.CampaignOrder{Accrual: 30, ProcessedAt: saturday.Add(time.Hour), Sequence: 1},
+			expected: map[uuid.UUID]float64{welcome.ID: 100, weekend.ID: 30},
+		},
+		{
+			name:  "Window end is exclusive",
+			order: domain.CampaignOrder{Accrual: 30, ProcessedAt: monday, Sequence: 2},
+		},
+		{
+			name:     "Order count reached",
+			order:    domain.CampaignOrder{Accrual: 30, ProcessedAt: monday, Sequence: 5},
+			expected: map[uuid.UUID]float64{loyal.ID: 50},
+		},
+		{
+			name:     "Cap partially used",
+			order:    domain.CampaignOrder{Accrual: 30, ProcessedAt: monday, Sequence: 7},
+			granted:  map[uuid.UUID]float64{loyal.ID: 100},
+			expected: map[uuid.UUID]float64{loyal.ID: 20},
+		},
+		{
+			name:    "Cap exhausted",
+			order:   domain.CampaignOrder{Accrual: 30, ProcessedAt: monday, Sequence: 8},
+			granted: map[uuid.UUID]float64{loyal.ID: 120},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			credits := domain.EvaluateCampaigns(campaigns, tc.order, tc.granted)
+			actual := make(map[uuid.UUID]float64)
+			for _, c := range credits {
+				actual[c.CampaignID] = c.Amount
+			}
+			if tc.expected == nil {
+				tc.expected = map[uuid.UUID]float64{}
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+
+	// порядок акций на входе не влияет на результат
+	first := domain.EvaluateCampaigns(campaigns, testCases[0].order, nil)
+	second := domain.EvaluateCampaigns([]entity.Campaign{welcome, weekend, loyal, disabled}, testCases[0].order, nil)
+	require.Equal(t, first, second)
+	require.Equal(t, welcome.ID, first[0].CampaignID)
+}
+
+func Test_domain_ValidateCampaign(t *testing.T) {
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, -1)
+
+	assert.NoError(t, domain.ValidateCampaign(&entity.Campaign{Name: "x", Kind: entity.CampaignKindFixed, Value: 10}))
+
+	err := domain.ValidateCampaign(&entity.Campaign{
+		Kind:     entity.CampaignKindMultiplier,
+		Value:    1,
+		StartsAt: &start,
+		EndsAt:   &end,
+		UserCap:  -1,
+	})
+	require.ErrorIs(t, err, domain.ErrValidation)
+	var verr *domain.ValidationError
+	require.True(t, errors.As(err, &verr))
+	actual := make(map[string]string)
+	for _, v := range verr.Violations {
+		actual[v.Field] = v.Code
+	}
+	assert.Equal(t, map[string]string{
+		domain.FieldCampaignName:    domain.ViolationRequired,
+		domain.FieldCampaignValue:   domain.ViolationOutOfRange,
+		domain.FieldCampaignEndsAt:  domain.ViolationOutOfRange,
+		domain.FieldCampaignUserCap: domain.ViolationOutOfRange,
+	}, actual)
+
+	err = domain.ValidateCampaign(&entity.Campaign{Name: "x", Kind: "cashback", Value: 10})
+	require.ErrorIs(t, err, domain.ErrValidation)
+}
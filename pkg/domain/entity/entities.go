@@ -18,8 +18,42 @@ const (
 	OrderStatusProcessed = OrderStatus("PROCESSED")
 )
 
+const (
+	// UserRoleUser обычный пользователь программы лояльности
+	UserRoleUser = UserRole("user")
+	// UserRoleSupport сотрудник поддержки: просмотр данных пользователей, повтор расчёта, блокировка
+	UserRoleSupport = UserRole("support")
+	// UserRoleAdmin администратор: всё, что доступно поддержке, и корректировка баланса
+	UserRoleAdmin = UserRole("admin")
+	// UserRoleService доверенный сервис, аутентифицированный по токену из конфигурации; пользователям не назначается
+	UserRoleService = UserRole("service")
+)
+
+const (
+	// CampaignKindMultiplier начисление к заказу умножается на Value: 2 - двойные баллы
+	CampaignKindMultiplier = CampaignKind("multiplier")
+	// CampaignKindFixed фиксированный бонус Value баллов за заказ
+	CampaignKindFixed = CampaignKind("fixed")
+)
+
+const (
+	StatementAccrual    = StatementEntryKind("accrual")
+	StatementWithdrawal = StatementEntryKind("withdrawal")
+	StatementAdjustment = StatementEntryKind("adjustment")
+	StatementExpiry     = StatementEntryKind("expiry")
+	StatementCampaign   = StatementEntryKind("campaign")
+	StatementTransfer   = StatementEntryKind("transfer")
+	StatementRefund     = StatementEntryKind("refund")
+)
+
 type OrderStatus string
 
+type UserRole string
+
+type CampaignKind string
+
+type StatementEntryKind string
+
 type JwtClaims struct {
 	jwt.RegisteredClaims
 
@@ -27,9 +61,19 @@ type JwtClaims struct {
 }
 
 type User struct {
-	ID          uuid.UUID `db:"id"`
-	Login       string    `db:"login"`
-	PasswordSHA []byte    `db:"password_sha"`
+	ID             uuid.UUID  `db:"id"`
+	Login          string     `db:"login"`
+	PasswordSHA    []byte     `db:"password_sha"`
+	Role           UserRole   `db:"role"`
+	Blocked        bool       `db:"blocked"`
+	SessionVersion int        `db:"session_version"`
+	DeletedAt      *time.Time `db:"deleted_at"`
+	// Tier уровень лояльности, пересчитывается при каждом окончательном начислении
+	Tier string `db:"tier"`
+	// ReferralCode код для приглашения других пользователей, выдаётся при создании
+	ReferralCode string `db:"referral_code"`
+	// TenantID магазин пользователя, задаётся хранилищем по тенанту из контекста
+	TenantID string `db:"tenant_id"`
 }
 
 type Order struct {
@@ -39,11 +83,70 @@ type Order struct {
 	Status    OrderStatus `db:"status"`
 	CreatedAt time.Time   `db:"created_at"`
 	Accrual   *float64    `db:"accrual"`
+	// ProcessedAt момент перехода в PROCESSED, от него отсчитывается срок сгорания начисления
+	ProcessedAt *time.Time `db:"processed_at"`
+	// Bonus начисление сверх Accrual по коэффициенту уровня лояльности
+	Bonus *float64 `db:"bonus"`
+	// VerifiedAt время последней повторной сверки начисления с системой расчёта
+	VerifiedAt *time.Time `db:"verified_at"`
+	// TenantID магазин заказа, номер уникален в пределах магазина
+	TenantID string `db:"tenant_id"`
 }
 
 type Balance struct {
-	Current   float64 `db:"current" json:"current"`
-	Withdrawn float64 `db:"withdrawn" json:"withdrawn"`
+	Current      float64         `db:"current" json:"current"`
+	Withdrawn    float64         `db:"withdrawn" json:"withdrawn"`
+	ExpiringSoon *ExpiringPoints `db:"-" json:"expiring_soon,omitempty"`
+}
+
+// ExpiringPoints баллы, которые сгорят в ближайшее время, и дата ближайшего сгорания
+type ExpiringPoints struct {
+	Sum       float64   `json:"sum"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PointsExpiry сгорание остатка начисления по заказу
+type PointsExpiry struct {
+	ID          uuid.UUID `db:"id"`
+	UserID      uuid.UUID `db:"user_id"`
+	OrderNumber string    `db:"order_number"`
+	Value       float64   `db:"value"`
+	ExpiredAt   time.Time `db:"expired_at"`
+	CreatedAt   time.Time `db:"created_at"`
+	TenantID    string    `db:"tenant_id"`
+}
+
+const (
+	// OrderSourceUser заказ загружен пользователем
+	OrderSourceUser = "user"
+	// OrderSourceAccrual статус получен от системы расчёта начислений
+	OrderSourceAccrual = "accrual"
+	// OrderSourceAdmin статус изменён поддержкой
+	OrderSourceAdmin = "admin"
+)
+
+// OrderStatusChange запись истории статусов заказа. FromStatus пуст при загрузке заказа,
+// Payload - исходный ответ системы расчёта, если переход вызван им.
+type OrderStatusChange struct {
+	ID         uuid.UUID    `db:"id"`
+	OrderID    uuid.UUID    `db:"order_id"`
+	FromStatus *OrderStatus `db:"from_status"`
+	ToStatus   OrderStatus  `db:"to_status"`
+	Source     string       `db:"source"`
+	Payload    []byte       `db:"payload"`
+	CreatedAt  time.Time    `db:"created_at"`
+}
+
+// AccrualResponse ответ системы расчёта по заказу в исходном виде для разбора споров.
+// Error заполнен у ответов, не прошедших проверку: они помещаются в карантин и не меняют заказ.
+type AccrualResponse struct {
+	ID          uuid.UUID `db:"id"`
+	TenantID    string    `db:"tenant_id"`
+	OrderNumber string    `db:"order_number"`
+	StatusCode  int       `db:"status_code"`
+	Payload     []byte    `db:"payload"`
+	Error       *string   `db:"error"`
+	CreatedAt   time.Time `db:"created_at"`
 }
 
 type Withdraw struct {
@@ -52,4 +155,131 @@ type Withdraw struct {
 	OrderNumber string    `db:"order_number"`
 	Value       float64   `db:"value"`
 	CreatedAt   time.Time `db:"created_at"`
+	// Refunded возвращённая сумма, nil - возврата не было. Возврат по списанию возможен только один.
+	Refunded   *float64   `db:"refunded"`
+	RefundedAt *time.Time `db:"refunded_at"`
+	TenantID   string     `db:"tenant_id"`
+}
+
+// WithdrawalStatus состояние списания с учётом возврата
+type WithdrawalStatus string
+
+const (
+	WithdrawalStatusCompleted         = WithdrawalStatus("COMPLETED")
+	WithdrawalStatusPartiallyRefunded = WithdrawalStatus("PARTIALLY_REFUNDED")
+	WithdrawalStatusRefunded          = WithdrawalStatus("REFUNDED")
+)
+
+func (w *Withdraw) Status() WithdrawalStatus {
+	switch {
+	case w.Refunded == nil:
+		return WithdrawalStatusCompleted
+	case *w.Refunded < w.Value:
+		return WithdrawalStatusPartiallyRefunded
+	default:
+		return WithdrawalStatusRefunded
+	}
+}
+
+type BalanceAdjustment struct {
+	ID        uuid.UUID `db:"id"`
+	UserID    uuid.UUID `db:"user_id"`
+	Amount    float64   `db:"amount"`
+	Reason    string    `db:"reason"`
+	CreatedBy uuid.UUID `db:"created_by"`
+	CreatedAt time.Time `db:"created_at"`
+	// OrderNumber заказ, начисление по которому исправляет корректировка
+	OrderNumber *string `db:"order_number"`
+	// NeedsReview корректировка увела баланс в минус и ждёт проверки поддержкой
+	NeedsReview bool   `db:"needs_review"`
+	TenantID    string `db:"tenant_id"`
+}
+
+// Transfer перевод баллов другому пользователю. IdempotencyKey уникален в пределах отправителя.
+type Transfer struct {
+	ID             uuid.UUID `db:"id"`
+	SenderID       uuid.UUID `db:"sender_id"`
+	RecipientID    uuid.UUID `db:"recipient_id"`
+	Amount         float64   `db:"amount"`
+	IdempotencyKey string    `db:"idempotency_key"`
+	CreatedAt      time.Time `db:"created_at"`
+	// SenderLogin и RecipientLogin заполняются только в истории переводов
+	SenderLogin    string `db:"sender_login"`
+	RecipientLogin string `db:"recipient_login"`
+	TenantID       string `db:"tenant_id"`
+}
+
+// Actor пользователь, от имени которого выполняется административное действие
+type Actor struct {
+	ID    uuid.UUID
+	Login string
+	Role  UserRole
+}
+
+type AuditRecord struct {
+	ID         uuid.UUID `db:"id"`
+	ActorID    uuid.UUID `db:"actor_id"`
+	ActorLogin string    `db:"actor_login"`
+	Action     string    `db:"action"`
+	Target     string    `db:"target"`
+	Details    []byte    `db:"details"`
+	CreatedAt  time.Time `db:"created_at"`
+	// TenantID магазин, в котором выполнено действие, задаётся хранилищем по тенанту из контекста
+	TenantID string `db:"tenant_id"`
+}
+
+// Campaign промо-акция с бонусными баллами за обработанные заказы
+type Campaign struct {
+	ID    uuid.UUID    `db:"id"`
+	Name  string       `db:"name"`
+	Kind  CampaignKind `db:"kind"`
+	Value float64      `db:"value"`
+	// StartsAt и EndsAt окно действия [StartsAt, EndsAt) по времени обработки заказа, nil - без ограничения
+	StartsAt *time.Time `db:"starts_at"`
+	EndsAt   *time.Time `db:"ends_at"`
+	// FirstOrderOnly только первый обработанный заказ пользователя
+	FirstOrderOnly bool `db:"first_order_only"`
+	// MinOrderCount заказ должен быть не раньше MinOrderCount-го обработанного заказа пользователя
+	MinOrderCount int `db:"min_order_count"`
+	// UserCap максимум баллов по акции на одного пользователя, 0 - без ограничения
+	UserCap   float64   `db:"user_cap"`
+	Enabled   bool      `db:"enabled"`
+	CreatedAt time.Time `db:"created_at"`
+	// TenantID магазин акции, задаётся хранилищем по тенанту из контекста
+	TenantID string `db:"tenant_id"`
+}
+
+// CampaignCredit бонус по акции, начисляется отдельно от начисления по заказу
+type CampaignCredit struct {
+	ID          uuid.UUID `db:"id"`
+	CampaignID  uuid.UUID `db:"campaign_id"`
+	UserID      uuid.UUID `db:"user_id"`
+	OrderNumber string    `db:"order_number"`
+	Amount      float64   `db:"amount"`
+	CreatedAt   time.Time `db:"created_at"`
+	TenantID    string    `db:"tenant_id"`
+}
+
+// Referral приглашение пользователя. Бонусы фиксируются в момент начисления по первому заказу приглашённого.
+type Referral struct {
+	ID            uuid.UUID  `db:"id"`
+	ReferrerID    uuid.UUID  `db:"referrer_id"`
+	RefereeID     uuid.UUID  `db:"referee_id"`
+	OrderNumber   *string    `db:"order_number"`
+	ReferrerBonus float64    `db:"referrer_bonus"`
+	RefereeBonus  float64    `db:"referee_bonus"`
+	CreatedAt     time.Time  `db:"created_at"`
+	RewardedAt    *time.Time `db:"rewarded_at"`
+	// RefereeLogin заполняется только в списке приглашений пользователя
+	RefereeLogin string `db:"referee_login"`
+	TenantID     string `db:"tenant_id"`
+}
+
+// StatementEntry движение по счёту баллов; Amount со знаком, Balance - остаток после движения
+type StatementEntry struct {
+	At          time.Time          `json:"at"`
+	Kind        StatementEntryKind `json:"kind"`
+	OrderNumber string             `json:"order,omitempty"`
+	Amount      float64            `json:"amount"`
+	Balance     float64            `json:"balance"`
 }
@@ -0,0 +1,55 @@
+package entity
+
+import (
+	"github.com/gofrs/uuid"
+)
+
+// Топики доменных событий
+const (
+	EventOrderUploaded   = "order.uploaded"
+	EventOrderProcessed  = "order.processed"
+	EventPointsWithdrawn = "points.withdrawn"
+)
+
+// OrderEvent данные событий о заказе: загрузка пользователем и окончательный расчёт
+type OrderEvent struct {
+	OrderID uuid.UUID   `json:"order_id"`
+	UserID  uuid.UUID   `json:"user_id"`
+	Number  string      `json:"number"`
+	Status  OrderStatus `json:"status"`
+	Accrual *float64    `json:"accrual,omitempty"`
+	Bonus   *float64    `json:"bonus,omitempty"`
+	// TenantID пустой у событий, записанных до появления тенантов
+	TenantID string `json:"tenant_id,omitempty"`
+}
+
+func NewOrderEvent(order *Order) *OrderEvent {
+	return &OrderEvent{
+		OrderID:  order.ID,
+		UserID:   order.UserID,
+		Number:   order.Number,
+		Status:   order.Status,
+		Accrual:  order.Accrual,
+		Bonus:    order.Bonus,
+		TenantID: order.TenantID,
+	}
+}
+
+func (e *OrderEvent) Order() *Order {
+	return &Order{
+		ID:       e.OrderID,
+		UserID:   e.UserID,
+		Number:   e.Number,
+		Status:   e.Status,
+		Accrual:  e.Accrual,
+		Bonus:    e.Bonus,
+		TenantID: e.TenantID,
+	}
+}
+
+// PointsWithdrawnEvent списание баллов в счёт заказа
+type PointsWithdrawnEvent struct {
+	UserID      uuid.UUID `json:"user_id"`
+	OrderNumber string    `json:"order"`
+	Sum         float64   `json:"sum"`
+}
@@ -1,8 +1,12 @@
 package entity
 
+import "time"
+
 type RegisterRequest struct {
 	Login    string `json:"login"`
 	Password string `json:"password"`
+	// Referral реферальный код пригласившего пользователя
+	Referral string `json:"referral,omitempty"`
 }
 
 type LoginRequest struct {
@@ -10,7 +14,40 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
 type WithdrawRequest struct {
 	OrderNumber string  `json:"order"`
 	Sum         float64 `json:"sum"`
 }
+
+type TransferRequest struct {
+	Login  string  `json:"login"`
+	Amount float64 `json:"amount"`
+}
+
+// RefundRequest возврат баллов по списанию, нулевая сумма означает полный возврат
+type RefundRequest struct {
+	Amount float64 `json:"amount"`
+	Reason string  `json:"reason"`
+}
+
+type BalanceAdjustmentRequest struct {
+	Amount float64 `json:"amount"`
+	Reason string  `json:"reason"`
+}
+
+type CampaignRequest struct {
+	Name           string       `json:"name"`
+	Kind           CampaignKind `json:"kind"`
+	Value          float64      `json:"value"`
+	StartsAt       *time.Time   `json:"starts_at"`
+	EndsAt         *time.Time   `json:"ends_at"`
+	FirstOrderOnly bool         `json:"first_order_only"`
+	MinOrderCount  int          `json:"min_order_count"`
+	UserCap        float64      `json:"user_cap"`
+	Enabled        bool         `json:"enabled"`
+}
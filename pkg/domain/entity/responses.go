@@ -1,18 +1,184 @@
 package entity
 
 import (
+	"encoding/json"
 	"time"
+
+	"github.com/gofrs/uuid"
 )
 
+type ReadinessResponse struct {
+	Database string `json:"database"`
+	Accrual  string `json:"accrual"`
+	Instance string `json:"instance"`
+	// Leader экземпляр, который опрашивает систему расчёта и запускает фоновые задачи
+	Leader string `json:"leader,omitempty"`
+}
+
 type OrderResponse struct {
 	Number    string      `json:"number"`
 	Status    OrderStatus `json:"status"`
 	CreatedAt time.Time   `json:"uploaded_at"`
 	Accrual   *float64    `json:"accrual,omitempty"`
+	Bonus     *float64    `json:"bonus,omitempty"`
+}
+
+type OrderDetailsResponse struct {
+	OrderResponse
+	History []OrderStatusChangeResponse `json:"history"`
+}
+
+type OrderStatusChangeResponse struct {
+	From    *OrderStatus    `json:"from,omitempty"`
+	To      OrderStatus     `json:"to"`
+	Source  string          `json:"source"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	At      time.Time       `json:"at"`
+}
+
+// AccrualResponseRecord Payload - тело ответа как есть, оно может быть и не JSON
+type AccrualResponseRecord struct {
+	OrderNumber string    `json:"order"`
+	StatusCode  int       `json:"status_code"`
+	Payload     string    `json:"payload,omitempty"`
+	Quarantined bool      `json:"quarantined"`
+	Error       string    `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func NewAccrualResponseRecord(r *AccrualResponse) AccrualResponseRecord {
+	record := AccrualResponseRecord{
+		OrderNumber: r.OrderNumber,
+		StatusCode:  r.StatusCode,
+		Payload:     string(r.Payload),
+		Quarantined: r.Error != nil,
+		CreatedAt:   r.CreatedAt,
+	}
+	if r.Error != nil {
+		record.Error = *r.Error
+	}
+
+	return record
 }
 
 type WithdrawalsResponse struct {
+	OrderNumber string           `json:"order"`
+	Sum         float64          `json:"sum"`
+	ProcessedAt time.Time        `json:"processed_at"`
+	Status      WithdrawalStatus `json:"status"`
+	Refunded    *float64         `json:"refunded,omitempty"`
+	RefundedAt  *time.Time       `json:"refunded_at,omitempty"`
+}
+
+func NewWithdrawalsResponse(w *Withdraw) WithdrawalsResponse {
+	return WithdrawalsResponse{
+		OrderNumber: w.OrderNumber,
+		Sum:         w.Value,
+		ProcessedAt: w.CreatedAt,
+		Status:      w.Status(),
+		Refunded:    w.Refunded,
+		RefundedAt:  w.RefundedAt,
+	}
+}
+
+type ExpirationResponse struct {
 	OrderNumber string    `json:"order"`
 	Sum         float64   `json:"sum"`
-	ProcessedAt time.Time `json:"processed_at"`
+	ExpiredAt   time.Time `json:"expired_at"`
+}
+
+type TierResponse struct {
+	Tier            string  `json:"tier"`
+	Multiplier      float64 `json:"multiplier"`
+	RollingAccruals float64 `json:"rolling_accruals"`
+	NextTier        string  `json:"next_tier,omitempty"`
+	ToNextTier      float64 `json:"to_next_tier,omitempty"`
+}
+
+type UserInfoResponse struct {
+	ID      uuid.UUID `json:"id"`
+	Login   string    `json:"login"`
+	Role    UserRole  `json:"role"`
+	Blocked bool      `json:"blocked"`
+	Balance Balance   `json:"balance"`
+}
+
+type AuditRecordResponse struct {
+	ActorLogin string          `json:"actor"`
+	Action     string          `json:"action"`
+	Target     string          `json:"target"`
+	Details    json.RawMessage `json:"details,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// BalanceReport результат сверки баланса пользователя
+type BalanceReport struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Login     string    `json:"login"`
+	Accrued   float64   `json:"accrued"`
+	Adjusted  float64   `json:"adjusted"`
+	Withdrawn float64   `json:"withdrawn"`
+	Expired   float64   `json:"expired"`
+	Credited  float64   `json:"credited"`
+	// Transferred получено переводами за вычетом отправленного
+	Transferred float64  `json:"transferred"`
+	Current     float64  `json:"current"`
+	Problems    []string `json:"problems"`
+}
+
+// AdjustmentReview корректировка, ожидающая проверки поддержкой
+type AdjustmentReview struct {
+	ID          uuid.UUID `json:"id"`
+	Login       string    `json:"login"`
+	Amount      float64   `json:"amount"`
+	Reason      string    `json:"reason"`
+	OrderNumber *string   `json:"order,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Violation нарушение правила валидации конкретного поля запроса
+type Violation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type CampaignResponse struct {
+	ID             uuid.UUID    `json:"id"`
+	Name           string       `json:"name"`
+	Kind           CampaignKind `json:"kind"`
+	Value          float64      `json:"value"`
+	StartsAt       *time.Time   `json:"starts_at,omitempty"`
+	EndsAt         *time.Time   `json:"ends_at,omitempty"`
+	FirstOrderOnly bool         `json:"first_order_only"`
+	MinOrderCount  int          `json:"min_order_count"`
+	UserCap        float64      `json:"user_cap"`
+	Enabled        bool         `json:"enabled"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+type ReferralsResponse struct {
+	Code      string             `json:"code"`
+	Referrals []ReferralResponse `json:"referrals"`
+}
+
+type ReferralResponse struct {
+	Login      string     `json:"login"`
+	Bonus      float64    `json:"bonus"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RewardedAt *time.Time `json:"rewarded_at,omitempty"`
+}
+
+const (
+	TransferDirectionIn  = "in"
+	TransferDirectionOut = "out"
+)
+
+type TransferResponse struct {
+	ID uuid.UUID `json:"id"`
+	// Direction TransferDirectionIn - получен, TransferDirectionOut - отправлен
+	Direction string    `json:"direction"`
+	Login     string    `json:"login"`
+	Amount    float64   `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
 }
@@ -7,33 +7,90 @@ import (
 	"strings"
 
 	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	log "github.com/sirupsen/logrus"
 )
 
-var ErrNotFound = NewError("not found")
-var ErrBadRequest = NewError("bad request")
-var ErrAuthentication = NewError("authentication error")
-var ErrBadOrderNumber = fmt.Errorf("%w: bad order number format", ErrBadRequest)
-var ErrOrderNumberTooLong = fmt.Errorf("%w: order number too long", ErrBadOrderNumber)
-var ErrOrderNumberExists = NewError("order number already exists")
-var ErrOrderCreatedByCurrentUser = fmt.Errorf("%w: created by current user", ErrOrderNumberExists)
-var ErrOrderCreatedByOtherUser = fmt.Errorf("%w: created by other user", ErrOrderNumberExists)
-var ErrNotEnoughAccruals = NewError("insufficient funds in the account")
-var ErrRegister = NewError("register error")
-var ErrLoginExists = fmt.Errorf("%w: login already exists", ErrRegister)
-var ErrInvalidToken = fmt.Errorf("%w: invalid token", ErrAuthentication)
-var ErrTokenNotProvided = fmt.Errorf("%w: no token provided", ErrAuthentication)
-var ErrUserIDNotProvided = fmt.Errorf("%w: no token user ID provided", ErrBadRequest)
-var ErrUserLoginNotProvided = fmt.Errorf("%w: no token user login provided", ErrBadRequest)
-var ErrTokenExpirationNotProvided = fmt.Errorf("%w: no token expiration time provided", ErrBadRequest)
+// Коды ошибок стабильны и входят в контракт API: клиенты сравнивают их вместо текста сообщений
+var ErrNotFound = NewError("not found").WithCode("not_found")
+var ErrBadRequest = NewError("bad request").WithCode("bad_request")
+var ErrAuthentication = NewError("authentication error").WithCode("unauthenticated")
+var ErrBadOrderNumber = ErrBadRequest.Wrap("invalid_order_number", "bad order number format")
+var ErrOrderNumberTooLong = ErrBadOrderNumber.Wrap("order_number_too_long", "order number too long")
+var ErrOrderNumberExists = NewError("order number already exists").WithCode("order_exists")
+var ErrOrderCreatedByCurrentUser = ErrOrderNumberExists.Wrap("order_already_uploaded", "created by current user")
+var ErrOrderCreatedByOtherUser = ErrOrderNumberExists.Wrap("order_uploaded_by_other_user", "created by other user")
+var ErrNotEnoughAccruals = NewError("insufficient funds in the account").WithCode("insufficient_funds")
+var ErrRegister = NewError("register error").WithCode("registration_failed")
+var ErrLoginExists = ErrRegister.Wrap("login_taken", "login already exists")
+var ErrInvalidCredentials = ErrAuthentication.Wrap("invalid_credentials", "invalid login or password")
+var ErrInvalidToken = ErrAuthentication.Wrap("invalid_token", "invalid token")
+var ErrTokenNotProvided = ErrAuthentication.Wrap("token_missing", "no token provided")
+var ErrUserIDNotProvided = ErrBadRequest.Wrap("token_user_id_missing", "no token user ID provided")
+var ErrUserLoginNotProvided = ErrBadRequest.Wrap("token_user_login_missing", "no token user login provided")
+var ErrTokenExpirationNotProvided = ErrBadRequest.Wrap("token_expiration_missing", "no token expiration time provided")
+var ErrForbidden = NewError("access denied").WithCode("forbidden")
+var ErrTenantMismatch = ErrForbidden.Wrap("tenant_mismatch", "token was issued for another tenant")
+var ErrUserBlocked = ErrForbidden.Wrap("user_blocked", "user is blocked")
+var ErrWrongPassword = ErrForbidden.Wrap("wrong_password", "wrong password")
+var ErrSessionRevoked = ErrAuthentication.Wrap("session_revoked", "session revoked")
+var ErrUserDeleted = ErrAuthentication.Wrap("user_deleted", "user deleted")
+var ErrReasonRequired = ErrBadRequest.Wrap("reason_required", "reason is required")
+var ErrZeroAmount = ErrBadRequest.Wrap("zero_amount", "amount must not be zero")
+var ErrBadAmount = ErrBadRequest.Wrap("bad_amount", "amount must be positive")
+var ErrTransferToSelf = ErrBadRequest.Wrap("transfer_to_self", "unable to transfer to yourself")
+var ErrUnknownRecipient = ErrBadRequest.Wrap("unknown_recipient", "recipient not found")
+var ErrIdempotencyKeyRequired = ErrBadRequest.Wrap("idempotency_key_required", "idempotency key is required")
+var ErrIdempotencyConflict = NewError("idempotency key already used with other parameters").WithCode("idempotency_conflict")
+var ErrTransferLimitExceeded = ErrForbidden.Wrap("transfer_limit_exceeded", "daily transfer limit exceeded")
+var ErrAlreadyRefunded = NewError("withdrawal already refunded").WithCode("already_refunded")
+var ErrAmbiguousWithdrawal = NewError("several withdrawals for the order number").WithCode("ambiguous_withdrawal")
+var ErrRefundExceedsWithdrawal = ErrBadRequest.Wrap("refund_exceeds_withdrawal", "refund exceeds withdrawn amount")
+var ErrIllegalTransition = NewError("order status does not allow this operation").WithCode("illegal_transition")
+var ErrUnknownTenant = ErrBadRequest.Wrap("unknown_tenant", "unknown tenant")
+var ErrBadPeriod = ErrBadRequest.Wrap("bad_period", "period end must be after its start")
+
+// errorStatuses единая таблица соответствия ошибок домена HTTP-статусам.
+// Проверяется сверху вниз, поэтому более частные ошибки должны идти раньше общих.
+var errorStatuses = []struct {
+	err    error
+	status int
+}{
+	{ErrInvalidCredentials, http.StatusUnauthorized},
+	{ErrBadOrderNumber, http.StatusUnprocessableEntity},
+	{ErrOrderNumberExists, http.StatusConflict},
+	{ErrLoginExists, http.StatusConflict},
+	{ErrIdempotencyConflict, http.StatusConflict},
+	{ErrAlreadyRefunded, http.StatusConflict},
+	{ErrAmbiguousWithdrawal, http.StatusConflict},
+	{ErrIllegalTransition, http.StatusConflict},
+	{ErrNotEnoughAccruals, http.StatusPaymentRequired},
+	{ErrNotFound, http.StatusNotFound},
+	{ErrAuthentication, http.StatusUnauthorized},
+	{ErrForbidden, http.StatusForbidden},
+	{ErrBadRequest, http.StatusBadRequest},
+}
 
 type Err struct {
-	err error
+	err  error
+	code string
 }
 
 func NewError(format string, args ...any) *Err {
 	return &Err{err: fmt.Errorf(format, args...)}
 }
 
+// WithCode задаёт машиночитаемый код ошибки
+func (e *Err) WithCode(code string) *Err {
+	e.code = code
+
+	return e
+}
+
+// Wrap создаёт более частную ошибку со своим кодом, для которой errors.Is(err, e) истинно
+func (e *Err) Wrap(code, msg string) *Err {
+	return &Err{err: fmt.Errorf("%w: %s", e, msg), code: code}
+}
+
 func (e *Err) Error() string {
 	return e.err.Error()
 }
@@ -42,21 +99,48 @@ func (e *Err) Unwrap() error {
 	return e.err
 }
 
+// Code код ближайшей в цепочке ошибки домена, у которой он задан, иначе общий код по HTTP-статусу
+func Code(err error) string {
+	var e *Err
+	for current := err; errors.As(current, &e); current = e.err {
+		if e.code != "" {
+			return e.code
+		}
+	}
+
+	return utils.ProblemCodeForStatus(HTTPStatus(err))
+}
+
+// HTTPStatus статус ответа для ошибки; ошибки вне домена считаются внутренними
+func HTTPStatus(err error) int {
+	var e *Err
+	if !errors.As(err, &e) {
+		return http.StatusInternalServerError
+	}
+	for _, s := range errorStatuses {
+		if errors.Is(err, s.err) {
+			return s.status
+		}
+	}
+
+	return http.StatusBadRequest
+}
+
 func SendError(w http.ResponseWriter, err error, msg ...string) {
 	if msg != nil {
 		err = fmt.Errorf(strings.Join(msg, ": ")+": %w", err)
 	}
-	var e *Err
-	if errors.As(err, &e) {
-		switch {
-		case errors.Is(err, ErrNotFound):
-			utils.SendDomainError(w, err, http.StatusNotFound)
-		case errors.Is(err, ErrAuthentication):
-			utils.SendDomainError(w, err, http.StatusUnauthorized)
-		default:
-			utils.SendDomainError(w, err, http.StatusBadRequest)
-		}
-	} else {
+	status := HTTPStatus(err)
+	if status == http.StatusInternalServerError {
 		utils.SendInternalError(w, err, err.Error())
+		return
+	}
+
+	log.WithError(err).WithField("request_id", w.Header().Get(utils.RequestIDHeader)).Info(err.Error())
+	problem := utils.NewProblem(status, Code(err), err.Error())
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		problem.Violations = verr.Violations
 	}
+	utils.SendProblem(w, problem)
 }
@@ -0,0 +1,103 @@
+package domain_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_domain_SendError(t *testing.T) {
+	testCases := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   string
+		expectedDetail string
+	}{
+		{
+			name:           "Bad order number",
+			err:            domain.ErrBadOrderNumber,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "invalid_order_number",
+		},
+		{
+			name:           "Order number too long inherits status",
+			err:            domain.ErrOrderNumberTooLong,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "order_number_too_long",
+		},
+		{
+			name:           "Order of other user",
+			err:            fmt.Errorf("wrapped: %w", domain.ErrOrderCreatedByOtherUser),
+			expectedStatus: http.StatusConflict,
+			expectedCode:   "order_uploaded_by_other_user",
+		},
+		{name: "Insufficient funds", err: domain.ErrNotEnoughAccruals, expectedStatus: http.StatusPaymentRequired, expectedCode: "insufficient_funds"},
+		{name: "Login exists", err: domain.ErrLoginExists, expectedStatus: http.StatusConflict, expectedCode: "login_taken"},
+		{
+			name:           "Ambiguous withdrawal",
+			err:            domain.ErrAmbiguousWithdrawal,
+			expectedStatus: http.StatusConflict,
+			expectedCode:   "ambiguous_withdrawal",
+		},
+		{
+			name:           "Invalid credentials wins over not found",
+			err:            fmt.Errorf("%w: %w", domain.ErrInvalidCredentials, domain.ErrNotFound),
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   "invalid_credentials",
+		},
+		{name: "Ad-hoc domain error", err: domain.NewError("oops"), expectedStatus: http.StatusBadRequest, expectedCode: "bad_request"},
+		{
+			name:           "Internal error does not leak",
+			err:            errors.New(`secret "dsn" details`),
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   utils.ProblemCodeInternal,
+			expectedDetail: utils.InternalErrorDetail,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			w.Header().Set(utils.RequestIDHeader, "req-1")
+			domain.SendError(w, tc.err)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, utils.ContentTypeProblemJSON, w.Header().Get(utils.ContentType))
+			var problem utils.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, tc.expectedStatus, problem.Status)
+			assert.Equal(t, tc.expectedCode, problem.Code)
+			assert.Equal(t, utils.ProblemTypePrefix+tc.expectedCode, problem.Type)
+			assert.Equal(t, "req-1", problem.RequestID)
+			if tc.expectedDetail != "" {
+				assert.Equal(t, tc.expectedDetail, problem.Detail)
+			}
+		})
+	}
+}
+
+func Test_domain_SendValidationError(t *testing.T) {
+	verr := &domain.ValidationError{}
+	verr.Add(domain.FieldLogin, domain.ViolationInvalidChar, `login contains "quotes"`)
+	w := httptest.NewRecorder()
+	domain.SendError(w, verr, "error registering user")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var problem struct {
+		Code       string             `json:"code"`
+		Violations []entity.Violation `json:"violations"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "validation_failed", problem.Code)
+	assert.Equal(t, verr.Violations, problem.Violations)
+}
@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"context"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/services/accrual"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/eventbus"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+// HandleOrderProcessed обработчик события OrderProcessed, h вызывается в транзакции в контексте тенанта заказа
+func HandleOrderProcessed(trx Transactor, h accrual.OrderProcessedHook) eventbus.Handler {
+	return func(ctx context.Context, e *eventbus.Event) error {
+		event, err := eventbus.Decode[entity.OrderEvent](e)
+		if err != nil {
+			return err
+		}
+		ctx = tenant.ToContext(ctx, event.TenantID)
+		return trx.Transaction(ctx, func(ctx context.Context) error {
+			return h.OrderProcessed(ctx, event.Order())
+		})
+	}
+}
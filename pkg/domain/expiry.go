@@ -0,0 +1,320 @@
+package domain
+
+import (
+	"context"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+	log "github.com/sirupsen/logrus"
+)
+
+// pointsEpsilon остатки меньше этого значения считаются нулевыми, чтобы не сжигать погрешность float64
+const pointsEpsilon = 0.005
+
+// ExpiryPolicy правила сгорания начислений
+type ExpiryPolicy struct {
+	cfg *config.Expiry
+}
+
+func NewExpiryPolicy(cfg *config.Expiry) *ExpiryPolicy {
+	return &ExpiryPolicy{cfg: cfg}
+}
+
+func (p *ExpiryPolicy) Enabled() bool {
+	return p.cfg.LifetimeMonths > 0 || p.cfg.LifetimeDays > 0
+}
+
+// ExpiresAt момент сгорания начисления, рассчитанного в accruedAt
+func (p *ExpiryPolicy) ExpiresAt(accruedAt time.Time) time.Time {
+	expiresAt := accruedAt.AddDate(0, p.cfg.LifetimeMonths, p.cfg.LifetimeDays)
+	if p.cfg.RoundToMonthEnd {
+		y, m, _ := expiresAt.Date()
+		expiresAt = time.Date(y, m+1, 1, 0, 0, 0, 0, expiresAt.Location()).Add(-time.Nanosecond)
+	}
+
+	return expiresAt
+}
+
+// PointsLot партия баллов: начисление по заказу вместе с бонусом или положительная корректировка (не сгорает)
+type PointsLot struct {
+	OrderNumber string
+	AccruedAt   time.Time
+	// ExpiresAt нулевое значение означает, что партия не сгорает
+	ExpiresAt time.Time
+	Amount    float64
+	Remaining float64
+}
+
+// PointsLedger состояние партий баллов пользователя на момент расчёта
+type PointsLedger struct {
+	Lots []PointsLot
+	// Due сгорания, которые наступили, но ещё не сохранены
+	Due []entity.PointsExpiry
+}
+
+type ledgerEventKind int
+
+// порядок важен: при совпадении времени сначала начисления, затем сгорания, затем списания
+const (
+	ledgerCredit ledgerEventKind = iota
+	ledgerExpiry
+	ledgerDebit
+)
+
+type ledgerEvent struct {
+	at     time.Time
+	kind   ledgerEventKind
+	lot    int
+	amount float64
+}
+
+// BuildLedger воспроизводит историю баллов пользователя на момент now: списания и отрицательные корректировки
+// расходуют партии в порядке начисления (FIFO), остаток партии сгорает в момент ExpiresAt.
+// Уже сохранённые сгорания booked учитываются в сохранённом размере.
+func (p *ExpiryPolicy) BuildLedger(
+	now time.Time,
+	orders []entity.Order,
+	adjustments []entity.BalanceAdjustment,
+	withdrawals []entity.Withdraw,
+	booked []entity.PointsExpiry,
+) *PointsLedger {
+	ledger := &PointsLedger{}
+	bookedByNumber := make(map[string]entity.PointsExpiry, len(booked))
+	for _, e := range booked {
+		bookedByNumber[e.OrderNumber] = e
+	}
+
+	var events []ledgerEvent
+	for _, o := range orders {
+		if o.Status != entity.OrderStatusProcessed || o.Accrual == nil || *o.Accrual <= 0 {
+			continue
+		}
+		accruedAt := o.CreatedAt
+		if o.ProcessedAt != nil {
+			accruedAt = *o.ProcessedAt
+		}
+		amount := *o.Accrual
+		if o.Bonus != nil {
+			amount += *o.Bonus
+		}
+		ledger.Lots = append(ledger.Lots, PointsLot{
+			OrderNumber: o.Number,
+			AccruedAt:   accruedAt,
+			ExpiresAt:   p.ExpiresAt(accruedAt),
+			Amount:      amount,
+		})
+	}
+	for _, a := range adjustments {
+		if a.Amount > 0 {
+			ledger.Lots = append(ledger.Lots, PointsLot{AccruedAt: a.CreatedAt, Amount: a.Amount})
+		} else {
+			events = append(events, ledgerEvent{at: a.CreatedAt, kind: ledgerDebit, amount: -a.Amount})
+		}
+	}
+	for _, w := range withdrawals {
+		events = append(events, ledgerEvent{at: w.CreatedAt, kind: ledgerDebit, amount: w.Value})
+	}
+	slices.SortStableFunc(ledger.Lots, func(a, b PointsLot) int {
+		return a.AccruedAt.Compare(b.AccruedAt)
+	})
+	for i, lot := range ledger.Lots {
+		events = append(events, ledgerEvent{at: lot.AccruedAt, kind: ledgerCredit, lot: i})
+		if !lot.ExpiresAt.IsZero() && !lot.ExpiresAt.After(now) {
+			events = append(events, ledgerEvent{at: lot.ExpiresAt, kind: ledgerExpiry, lot: i})
+		}
+	}
+	slices.SortStableFunc(events, func(a, b ledgerEvent) int {
+		if c := a.at.Compare(b.at); c != 0 {
+			return c
+		}
+		return int(a.kind) - int(b.kind)
+	})
+
+	credited := make([]bool, len(ledger.Lots))
+	for _, e := range events {
+		switch e.kind {
+		case ledgerCredit:
+			credited[e.lot] = true
+			ledger.Lots[e.lot].Remaining = ledger.Lots[e.lot].Amount
+		case ledgerExpiry:
+			lot := &ledger.Lots[e.lot]
+			expired := lot.Remaining
+			if b, ok := bookedByNumber[lot.OrderNumber]; ok {
+				expired = math.Min(b.Value, lot.Remaining)
+			} else if expired >= pointsEpsilon {
+				ledger.Due = append(ledger.Due, entity.PointsExpiry{
+					OrderNumber: lot.OrderNumber,
+					Value:       roundPoints(expired),
+					ExpiredAt:   lot.ExpiresAt,
+				})
+			}
+			lot.Remaining -= expired
+		case ledgerDebit:
+			rest := e.amount
+			for i := range ledger.Lots {
+				lot := &ledger.Lots[i]
+				if rest < pointsEpsilon {
+					break
+				}
+				if !credited[i] || lot.Remaining < pointsEpsilon {
+					continue
+				}
+				spent := math.Min(rest, lot.Remaining)
+				lot.Remaining -= spent
+				rest -= spent
+			}
+		}
+	}
+
+	return ledger
+}
+
+// ExpiringSoon баллы, которые сгорят в интервале (now, now+window]
+func (l *PointsLedger) ExpiringSoon(now time.Time, window time.Duration) *entity.ExpiringPoints {
+	var result *entity.ExpiringPoints
+	for _, lot := range l.Lots {
+		if lot.ExpiresAt.IsZero() || !lot.ExpiresAt.After(now) || lot.ExpiresAt.After(now.Add(window)) || lot.Remaining < pointsEpsilon {
+			continue
+		}
+		if result == nil {
+			result = &entity.ExpiringPoints{ExpiresAt: lot.ExpiresAt}
+		}
+		result.Sum += lot.Remaining
+		if lot.ExpiresAt.Before(result.ExpiresAt) {
+			result.ExpiresAt = lot.ExpiresAt
+		}
+	}
+	if result != nil {
+		result.Sum = roundPoints(result.Sum)
+	}
+
+	return result
+}
+
+func roundPoints(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// PointsExpirer фоновое списание сгоревших баллов
+type PointsExpirer interface {
+	// ExpirePoints сохраняет наступившие сгорания по всем пользователям, возвращает их число
+	ExpirePoints(ctx context.Context) (int, error)
+}
+
+type pointsExpirer struct {
+	trx    Transactor
+	policy *ExpiryPolicy
+
+	orderRepo repository.Order
+	userRepo  repository.User
+}
+
+func NewPointsExpirer(cfg *config.Config, trx Transactor, orderRepo repository.Order, userRepo repository.User) PointsExpirer {
+	return &pointsExpirer{
+		trx:       trx,
+		policy:    NewExpiryPolicy(&cfg.Expiry),
+		orderRepo: orderRepo,
+		userRepo:  userRepo,
+	}
+}
+
+func (e *pointsExpirer) ExpirePoints(ctx context.Context) (int, error) {
+	if !e.policy.Enabled() {
+		return 0, nil
+	}
+	users, err := e.userRepo.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	now := time.Now()
+	for _, user := range users {
+		// каждый пользователь в своей транзакции, чтобы ошибка по одному не откатывала остальных
+		err := e.trx.Transaction(ctx, func(ctx context.Context) error {
+			ledger, err := bookExpiries(ctx, e.policy, e.orderRepo, e.userRepo, user.ID, now)
+			if err == nil {
+				total += len(ledger.Due)
+			}
+			return err
+		})
+		if err != nil {
+			log.WithError(err).WithField("user", user.ID).Error("Failed to expire points")
+		}
+	}
+
+	return total, nil
+}
+
+// bookExpiries строит журнал партий пользователя и сохраняет наступившие сгорания. Должна вызываться внутри транзакции.
+// Пользователь блокируется до чтения партий: иначе параллельные запросы баланса посчитали бы одни и те же сгорания
+// и столкнулись бы на уникальном индексе при их записи.
+func bookExpiries(
+	ctx context.Context,
+	policy *ExpiryPolicy,
+	orderRepo repository.Order,
+	userRepo repository.User,
+	userID uuid.UUID,
+	now time.Time,
+) (*PointsLedger, error) {
+	if err := userRepo.LockForUpdate(ctx, userID); err != nil {
+		return nil, err
+	}
+	orders, err := orderRepo.GetUserOrders(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	adjustments, err := orderRepo.GetUserAdjustments(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	credits, err := orderRepo.GetUserCampaignCredits(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	// бонусы акций, как и положительные корректировки, не сгорают, но расходуются списаниями по очереди
+	for _, c := range credits {
+		adjustments = append(adjustments, entity.BalanceAdjustment{UserID: userID, Amount: c.Amount, CreatedAt: c.CreatedAt})
+	}
+	transfers, err := orderRepo.GetUserTransfers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	// полученные переводы не сгорают, отправленные расходуют партии как отрицательные корректировки
+	for _, t := range transfers {
+		amount := t.Amount
+		if t.SenderID == userID {
+			amount = -amount
+		}
+		adjustments = append(adjustments, entity.BalanceAdjustment{UserID: userID, Amount: amount, CreatedAt: t.CreatedAt})
+	}
+	withdrawals, err := orderRepo.GetUserWithdrawals(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	// возвращённые баллы не сгорают
+	for _, w := range withdrawals {
+		if w.Refunded != nil {
+			adjustments = append(adjustments, entity.BalanceAdjustment{UserID: userID, Amount: *w.Refunded, CreatedAt: *w.RefundedAt})
+		}
+	}
+	booked, err := orderRepo.GetUserExpirations(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger := policy.BuildLedger(now, orders, adjustments, withdrawals, booked)
+	for i := range ledger.Due {
+		ledger.Due[i].UserID = userID
+		if err := orderRepo.AddExpiry(ctx, &ledger.Due[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return ledger, nil
+}
@@ -0,0 +1,98 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func processed(number string, accrual float64, at time.Time) entity.Order {
+	return entity.Order{Number: number, Status: entity.OrderStatusProcessed, Accrual: &accrual, CreatedAt: at, ProcessedAt: utils.ToPointer(at)}
+}
+
+func Test_domain_ExpiryPolicy(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 12, 0, 0, 0, time.UTC) }
+
+	t.Run("Expires at", func(t *testing.T) {
+		policy := domain.NewExpiryPolicy(&config.Expiry{LifetimeMonths: 12})
+		assert.True(t, policy.Enabled())
+		assert.Equal(t, day(2025, 3, 15), policy.ExpiresAt(day(2024, 3, 15)))
+
+		policy = domain.NewExpiryPolicy(&config.Expiry{LifetimeMonths: 1, RoundToMonthEnd: true})
+		assert.Equal(t, time.Date(2024, 4, 30, 23, 59, 59, 999999999, time.UTC), policy.ExpiresAt(day(2024, 3, 15)))
+
+		assert.False(t, domain.NewExpiryPolicy(&config.Expiry{}).Enabled())
+	})
+
+	policy := domain.NewExpiryPolicy(&config.Expiry{LifetimeMonths: 12})
+	orders := []entity.Order{
+		processed("1", 100, day(2024, 1, 10)),
+		processed("2", 50, day(2024, 3, 10)),
+		processed("3", 30, day(2024, 6, 10)),
+		{Number: "4", Status: entity.OrderStatusProcessing, CreatedAt: day(2024, 1, 1)},
+	}
+
+	t.Run("FIFO consumption", func(t *testing.T) {
+		withdrawals := []entity.Withdraw{
+			{Value: 120, CreatedAt: day(2024, 4, 1)},
+			// до начисления по заказу 3 списание может взять только остаток заказа 2
+			{Value: 20, CreatedAt: day(2024, 5, 1)},
+		}
+		ledger := policy.BuildLedger(day(2024, 12, 1), orders, nil, withdrawals, nil)
+		require.Len(t, ledger.Lots, 3)
+		assert.InDelta(t, 0, ledger.Lots[0].Remaining, 0.001)
+		assert.InDelta(t, 10, ledger.Lots[1].Remaining, 0.001)
+		assert.InDelta(t, 30, ledger.Lots[2].Remaining, 0.001)
+		assert.Empty(t, ledger.Due)
+
+		// заказ 1 израсходован полностью, поэтому ближайшее сгорание - остаток заказа 2
+		assert.Nil(t, ledger.ExpiringSoon(day(2024, 12, 1), 60*24*time.Hour))
+		soon := ledger.ExpiringSoon(day(2024, 12, 1), 100*24*time.Hour)
+		require.NotNil(t, soon)
+		assert.Equal(t, 10.0, soon.Sum)
+		assert.Equal(t, day(2025, 3, 10), soon.ExpiresAt)
+	})
+
+	t.Run("Expiry of remaining points", func(t *testing.T) {
+		withdrawals := []entity.Withdraw{{Value: 40, CreatedAt: day(2024, 2, 1)}}
+		ledger := policy.BuildLedger(day(2025, 3, 20), orders, nil, withdrawals, nil)
+		require.Len(t, ledger.Due, 2)
+		assert.Equal(t, "1", ledger.Due[0].OrderNumber)
+		assert.Equal(t, 60.0, ledger.Due[0].Value)
+		assert.Equal(t, day(2025, 1, 10), ledger.Due[0].ExpiredAt)
+		assert.Equal(t, "2", ledger.Due[1].OrderNumber)
+		assert.Equal(t, 50.0, ledger.Due[1].Value)
+
+		soon := ledger.ExpiringSoon(day(2025, 3, 20), 90*24*time.Hour)
+		require.NotNil(t, soon)
+		assert.Equal(t, 30.0, soon.Sum)
+		assert.Equal(t, day(2025, 6, 10), soon.ExpiresAt)
+
+		// после сохранения сгорания повторно не начисляются, а списание после сгорания расходует только живые партии
+		booked := []entity.PointsExpiry{ledger.Due[0], ledger.Due[1]}
+		withdrawals = append(withdrawals, entity.Withdraw{Value: 10, CreatedAt: day(2025, 3, 25)})
+		ledger = policy.BuildLedger(day(2025, 4, 1), orders, nil, withdrawals, booked)
+		assert.Empty(t, ledger.Due)
+		assert.InDelta(t, 20, ledger.Lots[2].Remaining, 0.001)
+	})
+
+	t.Run("Adjustments", func(t *testing.T) {
+		adjustments := []entity.BalanceAdjustment{
+			{Amount: 25, CreatedAt: day(2023, 12, 1)},
+			{Amount: -110, CreatedAt: day(2024, 2, 1)},
+		}
+		ledger := policy.BuildLedger(day(2025, 7, 1), orders, adjustments, nil, nil)
+		// положительная корректировка старше заказа 1 расходуется первой и не сгорает
+		require.Len(t, ledger.Lots, 4)
+		assert.True(t, ledger.Lots[0].ExpiresAt.IsZero())
+		assert.InDelta(t, 0, ledger.Lots[0].Remaining, 0.001)
+		require.Len(t, ledger.Due, 3)
+		assert.Equal(t, 15.0, ledger.Due[0].Value)
+	})
+}
@@ -2,17 +2,24 @@ package domain
 
 import (
 	"context"
-	"regexp"
-	"strconv"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/k-zavarnitsyn/gophermart/internal/config"
-	"github.com/k-zavarnitsyn/gophermart/internal/services/accrual"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/eventbus"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
 	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
 	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
 )
 
-const OrderNumberMaxLength = 65535
+const (
+	OrderNumberMaxLength = 65535
+	// DeletedLoginPrefix префикс логина удалённого пользователя, за ним следует ID
+	DeletedLoginPrefix = "deleted:"
+)
 
 var _ Gophermart = (*service)(nil)
 
@@ -23,20 +30,49 @@ type Gophermart interface {
 	// Login аутентификация пользователя
 	Login(context.Context, *entity.LoginRequest) (*entity.User, error)
 
+	// ChangePassword смена пароля с отзывом всех выданных ранее токенов
+	ChangePassword(ctx context.Context, userID uuid.UUID, req *entity.ChangePasswordRequest) (*entity.User, error)
+
+	// DeleteUser удаление учётной записи: логин обезличивается, история начислений и списаний сохраняется
+	DeleteUser(ctx context.Context, userID uuid.UUID) error
+
 	// PostOrder загрузка пользователем номера заказа для расчёта
 	PostOrder(ctx context.Context, order *entity.Order) error
 
 	// GetOrders получение списка загруженных пользователем номеров заказов, статусов их обработки и информации о начислениях
 	GetOrders(ctx context.Context, userID uuid.UUID) ([]entity.Order, error)
 
+	// GetOrder заказ пользователя вместе с историей его статусов
+	GetOrder(ctx context.Context, userID uuid.UUID, number string) (*entity.Order, []entity.OrderStatusChange, error)
+
 	// GetBalance получение текущего баланса счёта баллов лояльности пользователя
 	GetBalance(ctx context.Context, userID uuid.UUID) (*entity.Balance, error)
 
 	// Withdraw запрос на списание баллов с накопительного счёта в счёт оплаты нового заказа
 	Withdraw(ctx context.Context, w *entity.Withdraw) error
 
+	// Transfer перевод баллов другому пользователю по логину. Повтор запроса с тем же ключом идемпотентности
+	// возвращает ранее созданный перевод, с другими параметрами - ErrIdempotencyConflict.
+	Transfer(ctx context.Context, senderID uuid.UUID, key string, req *entity.TransferRequest) (*entity.Transfer, error)
+
+	// GetTransfers отправленные и полученные пользователем переводы
+	GetTransfers(ctx context.Context, userID uuid.UUID) ([]entity.Transfer, error)
+
 	// GetWithdrawals - получение информации о выводе средств с накопительного счёта пользователем
 	GetWithdrawals(ctx context.Context, userID uuid.UUID) ([]entity.Withdraw, error)
+
+	// GetExpirations история сгорания баллов пользователя
+	GetExpirations(ctx context.Context, userID uuid.UUID) ([]entity.PointsExpiry, error)
+
+	// GetTier текущий уровень лояльности пользователя
+	GetTier(ctx context.Context, userID uuid.UUID) (*entity.TierResponse, error)
+
+	// GetReferrals реферальный код пользователя и приглашённые им пользователи
+	GetReferrals(ctx context.Context, userID uuid.UUID) (*entity.ReferralsResponse, error)
+
+	// StreamStatement выписка по счёту за период [from, to) с нарастающим остатком, строки передаются в f по одной.
+	// Нулевой to означает текущий момент.
+	StreamStatement(ctx context.Context, userID uuid.UUID, from, to time.Time, f func(e *entity.StatementEntry) error) error
 }
 
 type Transactor interface {
@@ -44,11 +80,15 @@ type Transactor interface {
 }
 
 type service struct {
-	cfg           *config.Config
-	trx           Transactor
-	hasher        *hasher
-	orderNumRegex *regexp.Regexp
-	accrual       accrual.Service
+	cfg       *config.Config
+	trx       Transactor
+	hasher    *hasher
+	validator *TenantValidators
+	bus       eventbus.Bus
+	policy    *CredentialsPolicy
+	expiry    *ExpiryPolicy
+	loyalty   Loyalty
+	referrals ReferralProgram
 
 	orderRepo repository.Order
 	userRepo  repository.User
@@ -57,22 +97,32 @@ type service struct {
 func NewGophermart(
 	cfg *config.Config,
 	trx Transactor,
-	accrual accrual.Service,
+	bus eventbus.Bus,
+	policy *CredentialsPolicy,
+	validator *TenantValidators,
 	orderRepo repository.Order,
 	userRepo repository.User,
+	referralRepo repository.Referral,
 ) Gophermart {
 	return &service{
-		cfg:           cfg,
-		trx:           trx,
-		accrual:       accrual,
-		hasher:        &hasher{cfg: &cfg.Auth},
-		orderNumRegex: regexp.MustCompile(`^\s*\d+\s*$`),
-		orderRepo:     orderRepo,
-		userRepo:      userRepo,
+		cfg:       cfg,
+		trx:       trx,
+		bus:       bus,
+		policy:    policy,
+		expiry:    NewExpiryPolicy(&cfg.Expiry),
+		loyalty:   NewLoyalty(cfg, orderRepo, userRepo),
+		referrals: NewReferralProgram(cfg, orderRepo, userRepo, referralRepo),
+		hasher:    &hasher{cfg: &cfg.Auth},
+		validator: validator,
+		orderRepo: orderRepo,
+		userRepo:  userRepo,
 	}
 }
 
 func (s *service) Register(ctx context.Context, req *entity.RegisterRequest) (*entity.User, error) {
+	if err := s.policy.Validate(req.Login, req.Password); err != nil {
+		return nil, err
+	}
 	exists, err := s.userRepo.LoginExists(ctx, req.Login)
 	if err != nil {
 		return nil, err
@@ -87,11 +137,20 @@ func (s *service) Register(ctx context.Context, req *entity.RegisterRequest) (*e
 	}
 	hashedPwd := s.hasher.GenerateSHA([]byte(req.Password))
 	user := &entity.User{
-		ID:          id,
-		Login:       req.Login,
-		PasswordSHA: hashedPwd,
+		ID:           id,
+		Login:        req.Login,
+		PasswordSHA:  hashedPwd,
+		ReferralCode: NewReferralCode(),
 	}
-	err = s.userRepo.Insert(ctx, user)
+	err = s.trx.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Insert(ctx, user); err != nil {
+			return err
+		}
+		if req.Referral != "" {
+			return s.referrals.Attach(ctx, user, req.Referral)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -103,77 +162,172 @@ func (s *service) Login(ctx context.Context, req *entity.LoginRequest) (*entity.
 	hashedPwd := s.hasher.GenerateSHA([]byte(req.Password))
 	user, err := s.userRepo.FindByLoginAndPassword(ctx, req.Login, hashedPwd)
 	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+		}
 		return nil, err
 	}
+	if user.Blocked {
+		return nil, ErrUserBlocked
+	}
 
 	return user, nil
 }
 
+func (s *service) ChangePassword(ctx context.Context, userID uuid.UUID, req *entity.ChangePasswordRequest) (*entity.User, error) {
+	var user *entity.User
+	if err := s.trx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		if user, err = s.userRepo.FindByID(ctx, userID); err != nil {
+			return err
+		}
+		if !hmac.Equal(user.PasswordSHA, s.hasher.GenerateSHA([]byte(req.OldPassword))) {
+			return ErrWrongPassword
+		}
+		if err := s.policy.ValidatePassword(FieldNewPassword, req.NewPassword); err != nil {
+			return err
+		}
+		user.PasswordSHA = s.hasher.GenerateSHA([]byte(req.NewPassword))
+		user.SessionVersion, err = s.userRepo.UpdatePassword(ctx, userID, user.PasswordSHA)
+
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *service) DeleteUser(ctx context.Context, userID uuid.UUID) error {
+	return s.userRepo.Anonymize(ctx, userID, DeletedLoginPrefix+userID.String())
+}
+
 func (s *service) PostOrder(ctx context.Context, order *entity.Order) error {
-	if ok, err := s.CheckOrderNumber(order.Number); err != nil {
+	order.Number = NormalizeOrderNumber(order.Number)
+	if ok, err := s.CheckOrderNumber(ctx, order.Number); err != nil {
 		return err
 	} else if !ok {
 		return ErrBadOrderNumber
 	}
 
 	order.Status = entity.OrderStatusNew
-	err := s.orderRepo.Insert(ctx, order)
-	if err != nil {
-		return err
-	}
-
-	if err := s.accrual.Send(ctx, order); err != nil {
-		return err
-	}
 
-	return nil
+	// на расчёт заказ отправляет подписчик OrderUploaded
+	return s.trx.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.orderRepo.Insert(ctx, order); err != nil {
+			return err
+		}
+		return s.bus.Publish(ctx, entity.EventOrderUploaded, entity.NewOrderEvent(order))
+	})
 }
 
 func (s *service) GetOrders(ctx context.Context, userID uuid.UUID) ([]entity.Order, error) {
 	return s.orderRepo.GetUserOrders(ctx, userID)
 }
 
+func (s *service) GetOrder(ctx context.Context, userID uuid.UUID, number string) (*entity.Order, []entity.OrderStatusChange, error) {
+	order, err := s.orderRepo.FindByNumber(ctx, NormalizeOrderNumber(number))
+	if err != nil {
+		return nil, nil, err
+	}
+	// чужой заказ не отличается от несуществующего
+	if order.UserID != userID {
+		return nil, nil, ErrNotFound
+	}
+	history, err := s.orderRepo.GetOrderStatusHistory(ctx, order.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return order, history, nil
+}
+
 func (s *service) GetBalance(ctx context.Context, userID uuid.UUID) (*entity.Balance, error) {
-	var balance entity.Balance
+	var balance *entity.Balance
 	if err := s.trx.Transaction(ctx, func(ctx context.Context) error {
 		var err error
-		balance.Current, err = s.orderRepo.GetAccrualsSum(ctx, userID)
-		if err != nil {
-			return err
-		}
-		balance.Withdrawn, err = s.orderRepo.GetWithdrawnSum(ctx, userID)
-		if err != nil {
-			return err
-		}
-		balance.Current -= balance.Withdrawn
-		return nil
+		balance, err = s.balance(ctx, userID)
+		return err
 	}); err != nil {
 		return nil, err
 	}
 
+	return balance, nil
+}
+
+// balance считает баланс пользователя: начисления, корректировки, бонусы акций и переводы за вычетом списаний и сгоревших баллов.
+// Наступившие сгорания сохраняются сразу, не дожидаясь фоновой задачи. Должна вызываться внутри транзакции.
+func (s *service) balance(ctx context.Context, userID uuid.UUID) (*entity.Balance, error) {
+	var balance entity.Balance
+	now := time.Now()
+	var ledger *PointsLedger
+	if s.expiry.Enabled() {
+		var err error
+		if ledger, err = bookExpiries(ctx, s.expiry, s.orderRepo, s.userRepo, userID, now); err != nil {
+			return nil, err
+		}
+	}
+	accruals, err := s.orderRepo.GetAccrualsSum(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	adjustments, err := s.orderRepo.GetAdjustmentsSum(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	balance.Withdrawn, err = s.orderRepo.GetWithdrawnSum(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	expired, err := s.orderRepo.GetExpiredSum(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	credited, err := s.orderRepo.GetCampaignCreditsSum(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	transferred, err := s.orderRepo.GetTransfersSum(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	balance.Current = accruals + adjustments + credited + transferred - balance.Withdrawn - expired
+	if ledger != nil {
+		balance.ExpiringSoon = ledger.ExpiringSoon(now, s.cfg.Expiry.NotifyBefore)
+	}
+
 	return &balance, nil
 }
 
 func (s *service) Withdraw(ctx context.Context, w *entity.Withdraw) error {
-	if ok, err := s.CheckOrderNumber(w.OrderNumber); err != nil {
+	w.OrderNumber = NormalizeOrderNumber(w.OrderNumber)
+	if ok, err := s.CheckOrderNumber(ctx, w.OrderNumber); err != nil {
 		return err
 	} else if !ok {
 		return ErrBadOrderNumber
 	}
 
 	if err := s.trx.Transaction(ctx, func(ctx context.Context) error {
-		sum, err := s.orderRepo.GetAccrualsSum(ctx, w.UserID)
+		// списания и переводы пользователя проверяют баланс по одному, как в Transfer
+		if err := s.userRepo.LockForUpdate(ctx, w.UserID); err != nil {
+			return err
+		}
+		balance, err := s.balance(ctx, w.UserID)
 		if err != nil {
 			return err
 		}
-		if sum < w.Value {
+		if balance.Current < w.Value {
 			return ErrNotEnoughAccruals
 		}
 		if err := s.orderRepo.Withdraw(ctx, w); err != nil {
 			return err
 		}
 
-		return nil
+		return s.bus.Publish(ctx, entity.EventPointsWithdrawn, &entity.PointsWithdrawnEvent{
+			UserID:      w.UserID,
+			OrderNumber: w.OrderNumber,
+			Sum:         w.Value,
+		})
 	}); err != nil {
 		return err
 	}
@@ -181,39 +335,170 @@ func (s *service) Withdraw(ctx context.Context, w *entity.Withdraw) error {
 	return nil
 }
 
-func (s *service) GetWithdrawals(ctx context.Context, userID uuid.UUID) ([]entity.Withdraw, error) {
-	return s.orderRepo.GetUserWithdrawals(ctx, userID)
+func (s *service) Transfer(ctx context.Context, senderID uuid.UUID, key string, req *entity.TransferRequest) (*entity.Transfer, error) {
+	if key == "" {
+		return nil, ErrIdempotencyKeyRequired
+	}
+	if req.Amount <= 0 {
+		return nil, ErrBadAmount
+	}
+
+	var transfer *entity.Transfer
+	var recipient *entity.User
+	err := s.trx.Transaction(ctx, func(ctx context.Context) error {
+		// параллельные переводы отправителя идут по одной, иначе оба пройдут проверку баланса и лимитов
+		if err := s.userRepo.LockForUpdate(ctx, senderID); err != nil {
+			return err
+		}
+		var err error
+		recipient, err = s.userRepo.FindByLogin(ctx, req.Login)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		existing, err := s.orderRepo.FindTransferByKey(ctx, senderID, key)
+		switch {
+		case err == nil:
+			transfer, err = sameTransfer(existing, recipient, req)
+			return err
+		case !errors.Is(err, ErrNotFound):
+			return err
+		}
+
+		switch {
+		case recipient == nil || recipient.Blocked || recipient.DeletedAt != nil:
+			return ErrUnknownRecipient
+		case recipient.ID == senderID:
+			return ErrTransferToSelf
+		}
+		if err := s.checkTransferLimits(ctx, senderID, req.Amount); err != nil {
+			return err
+		}
+		balance, err := s.balance(ctx, senderID)
+		if err != nil {
+			return err
+		}
+		if balance.Current < req.Amount {
+			return ErrNotEnoughAccruals
+		}
+
+		transfer = &entity.Transfer{
+			SenderID:       senderID,
+			RecipientID:    recipient.ID,
+			Amount:         req.Amount,
+			IdempotencyKey: key,
+		}
+		if err := s.orderRepo.AddTransfer(ctx, transfer); errors.Is(err, ErrIdempotencyConflict) {
+			return errTransferKeyTaken
+		} else if err != nil {
+			return err
+		}
+		return nil
+	})
+	if errors.Is(err, errTransferKeyTaken) {
+		// ключ занял параллельный запрос; транзакция уже откатилась, перевод читается заново
+		existing, err := s.orderRepo.FindTransferByKey(ctx, senderID, key)
+		if err != nil {
+			return nil, err
+		}
+		return sameTransfer(existing, recipient, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
 }
 
-func (s *service) CheckOrderNumber(number string) (bool, error) {
-	if len(number) > OrderNumberMaxLength {
-		return false, ErrOrderNumberTooLong
+// errTransferKeyTaken перевод с тем же ключом идемпотентности сохранён параллельным запросом
+var errTransferKeyTaken = errors.New("transfer idempotency key taken")
+
+// sameTransfer повтор запроса с ключом existing должен совпадать с ним по получателю и сумме
+func sameTransfer(existing *entity.Transfer, recipient *entity.User, req *entity.TransferRequest) (*entity.Transfer, error) {
+	if recipient == nil || existing.RecipientID != recipient.ID || existing.Amount != req.Amount {
+		return nil, ErrIdempotencyConflict
 	}
-	if !s.orderNumRegex.MatchString(number) {
-		return false, ErrBadOrderNumber
+
+	return existing, nil
+}
+
+// checkTransferLimits дневные ограничения считаются с начала текущих суток по времени сервера
+func (s *service) checkTransferLimits(ctx context.Context, senderID uuid.UUID, amount float64) error {
+	limits := &s.cfg.Transfer
+	if t := s.cfg.Tenant(tenant.FromContext(ctx)); t != nil && t.Transfer != nil {
+		limits = t.Transfer
+	}
+	if limits.DailyCount <= 0 && limits.DailyAmount <= 0 {
+		return nil
+	}
+	y, m, d := time.Now().Date()
+	count, sum, err := s.orderRepo.GetSentTransfersSince(ctx, senderID, time.Date(y, m, d, 0, 0, 0, 0, time.Local))
+	if err != nil {
+		return err
+	}
+	if limits.DailyCount > 0 && count >= limits.DailyCount {
+		return ErrTransferLimitExceeded
+	}
+	if limits.DailyAmount > 0 && sum+amount > limits.DailyAmount+pointsEpsilon {
+		return ErrTransferLimitExceeded
 	}
 
-	return s.CheckLuhn(number), nil
+	return nil
 }
 
-func (s *service) CheckLuhn(number string) bool {
-	sum := 0
-	isEven := false
-	for i := len(number) - 1; i >= 0; i-- {
-		digit, err := strconv.Atoi(string(number[i]))
-		if err != nil {
-			return false
-		}
+func (s *service) GetTransfers(ctx context.Context, userID uuid.UUID) ([]entity.Transfer, error) {
+	return s.orderRepo.GetUserTransfers(ctx, userID)
+}
+
+func (s *service) GetWithdrawals(ctx context.Context, userID uuid.UUID) ([]entity.Withdraw, error) {
+	return s.orderRepo.GetUserWithdrawals(ctx, userID)
+}
+
+func (s *service) GetExpirations(ctx context.Context, userID uuid.UUID) ([]entity.PointsExpiry, error) {
+	return s.orderRepo.GetUserExpirations(ctx, userID)
+}
+
+func (s *service) GetTier(ctx context.Context, userID uuid.UUID) (*entity.TierResponse, error) {
+	return s.loyalty.GetTier(ctx, userID)
+}
+
+func (s *service) GetReferrals(ctx context.Context, userID uuid.UUID) (*entity.ReferralsResponse, error) {
+	return s.referrals.GetReferrals(ctx, userID)
+}
 
-		if isEven {
-			digit *= 2
-			if digit > 9 {
-				digit -= 9
-			}
+func (s *service) StreamStatement(
+	ctx context.Context,
+	userID uuid.UUID,
+	from, to time.Time,
+	f func(e *entity.StatementEntry) error,
+) error {
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if !to.After(from) {
+		return ErrBadPeriod
+	}
+
+	// остаток на начало периода набирается по движениям до from, они сами в выписку не попадают
+	var balance float64
+	return s.orderRepo.StreamStatement(ctx, userID, to, func(e *entity.StatementEntry) error {
+		balance += e.Amount
+		if e.At.Before(from) {
+			return nil
 		}
-		sum += digit
-		isEven = !isEven
+		e.Balance = roundPoints(balance)
+		return f(e)
+	})
+}
+
+// CheckOrderNumber проверяет уже нормализованный номер валидатором тенанта из ctx
+func (s *service) CheckOrderNumber(ctx context.Context, number string) (bool, error) {
+	if len(number) > OrderNumberMaxLength {
+		return false, ErrOrderNumberTooLong
+	}
+	if number == "" {
+		return false, ErrBadOrderNumber
 	}
 
-	return sum%10 == 0
+	return s.validator.For(ctx).Validate(number), nil
 }
@@ -0,0 +1,194 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+)
+
+const (
+	OrderNumberValidatorLuhn  = "luhn"
+	OrderNumberValidatorGTIN  = "gtin"
+	OrderNumberValidatorRegex = "regex"
+	OrderNumberValidatorAny   = "any"
+)
+
+var (
+	_ OrderNumberValidator = LuhnValidator{}
+	_ OrderNumberValidator = GTINValidator{}
+	_ OrderNumberValidator = (*RegexValidator)(nil)
+	_ OrderNumberValidator = AnyOfValidator(nil)
+)
+
+// OrderNumberValidator проверяет уже нормализованный номер заказа
+type OrderNumberValidator interface {
+	Validate(number string) bool
+}
+
+// NewOrderNumberValidator собирает валидатор по правилу из конфига
+func NewOrderNumberValidator(cfg *config.OrderNumberValidator) (OrderNumberValidator, error) {
+	switch cfg.Type {
+	case OrderNumberValidatorLuhn:
+		return LuhnValidator{}, nil
+	case OrderNumberValidatorGTIN:
+		return GTINValidator{}, nil
+	case OrderNumberValidatorRegex:
+		v, err := NewRegexValidator(cfg.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	case OrderNumberValidatorAny:
+		if len(cfg.AnyOf) == 0 {
+			return nil, fmt.Errorf("order number validator %q: anyOf is empty", cfg.Type)
+		}
+		validators := make([]OrderNumberValidator, len(cfg.AnyOf))
+		for i := range cfg.AnyOf {
+			var err error
+			if validators[i], err = NewOrderNumberValidator(&cfg.AnyOf[i]); err != nil {
+				return nil, err
+			}
+		}
+		return AnyOfValidator(validators), nil
+	default:
+		return nil, fmt.Errorf("unknown order number validator %q", cfg.Type)
+	}
+}
+
+// TenantValidators валидаторы номеров заказов по тенантам; тенант без своего правила проверяется общим
+type TenantValidators struct {
+	common  OrderNumberValidator
+	tenants map[string]OrderNumberValidator
+}
+
+func NewTenantValidators(cfg *config.Config) (*TenantValidators, error) {
+	common, err := NewOrderNumberValidator(&cfg.OrderNumbers)
+	if err != nil {
+		return nil, err
+	}
+	v := &TenantValidators{
+		common:  common,
+		tenants: make(map[string]OrderNumberValidator),
+	}
+	for _, t := range cfg.Tenants {
+		if t.OrderNumbers == nil {
+			continue
+		}
+		if v.tenants[t.ID], err = NewOrderNumberValidator(t.OrderNumbers); err != nil {
+			return nil, fmt.Errorf("tenant %q: %w", t.ID, err)
+		}
+	}
+
+	return v, nil
+}
+
+// For валидатор тенанта из ctx
+func (v *TenantValidators) For(ctx context.Context) OrderNumberValidator {
+	if validator, ok := v.tenants[tenant.FromContext(ctx)]; ok {
+		return validator
+	}
+
+	return v.common
+}
+
+// NormalizeOrderNumber убирает пробелы и разделители (дефисы, точки, подчёркивания) и приводит буквы
+// к верхнему регистру, чтобы один и тот же номер в разном написании не сохранился дважды
+func NormalizeOrderNumber(number string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '-' || r == '.' || r == '_' {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, number)
+}
+
+// LuhnValidator номера банковских карт и исходный формат заказов: только цифры с контрольной суммой Луна
+type LuhnValidator struct{}
+
+func (LuhnValidator) Validate(number string) bool {
+	if number == "" {
+		return false
+	}
+	sum := 0
+	isEven := false
+	for i := len(number) - 1; i >= 0; i-- {
+		if number[i] < '0' || number[i] > '9' {
+			return false
+		}
+		digit := int(number[i] - '0')
+		if isEven {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		isEven = !isEven
+	}
+
+	return sum%10 == 0
+}
+
+// GTINValidator номера чеков на основе EAN/GTIN: 8, 12, 13 или 14 цифр, веса 3 и 1 справа налево
+type GTINValidator struct{}
+
+func (GTINValidator) Validate(number string) bool {
+	switch len(number) {
+	case 8, 12, 13, 14:
+	default:
+		return false
+	}
+	sum := 0
+	for i := len(number) - 1; i >= 0; i-- {
+		if number[i] < '0' || number[i] > '9' {
+			return false
+		}
+		digit := int(number[i] - '0')
+		// контрольная цифра с весом 1, перед ней вес 3
+		if (len(number)-1-i)%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+
+	return sum%10 == 0
+}
+
+// RegexValidator буквенно-цифровые номера партнёров, шаблон должен описывать номер целиком
+type RegexValidator struct {
+	pattern *regexp.Regexp
+}
+
+func NewRegexValidator(pattern string) (*RegexValidator, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("order number validator %q: pattern is empty", OrderNumberValidatorRegex)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("order number validator %q: %w", OrderNumberValidatorRegex, err)
+	}
+
+	return &RegexValidator{pattern: re}, nil
+}
+
+func (v *RegexValidator) Validate(number string) bool {
+	return v.pattern.MatchString(number)
+}
+
+// AnyOfValidator номер подходит, если его принимает хотя бы один из валидаторов
+type AnyOfValidator []OrderNumberValidator
+
+func (v AnyOfValidator) Validate(number string) bool {
+	for _, validator := range v {
+		if validator.Validate(number) {
+			return true
+		}
+	}
+
+	return false
+}
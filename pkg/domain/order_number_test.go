@@ -0,0 +1,89 @@
+package domain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeOrderNumber(t *testing.T) {
+	assert.Equal(t, "3413042486", domain.NormalizeOrderNumber(" 3413-042 486\n"))
+	assert.Equal(t, "AB12CD34", domain.NormalizeOrderNumber("ab12_cd.34"))
+}
+
+func TestOrderNumberValidators(t *testing.T) {
+	partner, err := domain.NewOrderNumberValidator(&config.OrderNumberValidator{
+		Type: domain.OrderNumberValidatorAny,
+		AnyOf: []config.OrderNumberValidator{
+			{Type: domain.OrderNumberValidatorGTIN},
+			{Type: domain.OrderNumberValidatorRegex, Pattern: `^[A-Z]{2}\d{6}$`},
+		},
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		validator domain.OrderNumberValidator
+		number    string
+		expected  bool
+	}{
+		{"Luhn", domain.LuhnValidator{}, "3413042486", true},
+		{"Luhn bad checksum", domain.LuhnValidator{}, "3413042466", false},
+		{"Luhn letters", domain.LuhnValidator{}, "34130424A6", false},
+		{"EAN-13", domain.GTINValidator{}, "4006381333931", true},
+		{"EAN-8", domain.GTINValidator{}, "96385074", true},
+		{"EAN-13 bad checksum", domain.GTINValidator{}, "4006381333932", false},
+		{"GTIN bad length", domain.GTINValidator{}, "40063813339", false},
+		{"Any of: GTIN", partner, "4006381333931", true},
+		{"Any of: regex", partner, "AB123456", true},
+		{"Any of: none", partner, "3413042486", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.validator.Validate(tc.number))
+		})
+	}
+}
+
+func TestNewOrderNumberValidatorErrors(t *testing.T) {
+	for _, cfg := range []config.OrderNumberValidator{
+		{Type: "crc32"},
+		{Type: domain.OrderNumberValidatorRegex},
+		{Type: domain.OrderNumberValidatorRegex, Pattern: "("},
+		{Type: domain.OrderNumberValidatorAny},
+		{Type: domain.OrderNumberValidatorAny, AnyOf: []config.OrderNumberValidator{{Type: "crc32"}}},
+	} {
+		_, err := domain.NewOrderNumberValidator(&cfg)
+		assert.Error(t, err, "%+v", cfg)
+	}
+}
+
+func TestTenantValidators(t *testing.T) {
+	validators, err := domain.NewTenantValidators(&config.Config{
+		OrderNumbers: config.OrderNumberValidator{Type: domain.OrderNumberValidatorLuhn},
+		Tenants: []config.Tenant{
+			{ID: "partner", OrderNumbers: &config.OrderNumberValidator{Type: domain.OrderNumberValidatorGTIN}},
+			{ID: "shop"},
+		},
+	})
+	require.NoError(t, err)
+
+	partner := tenant.ToContext(context.Background(), "partner")
+	assert.True(t, validators.For(partner).Validate("4006381333931"))
+	assert.False(t, validators.For(partner).Validate("3413042486"))
+	for _, ctx := range []context.Context{context.Background(), tenant.ToContext(context.Background(), "shop")} {
+		assert.True(t, validators.For(ctx).Validate("3413042486"))
+		assert.False(t, validators.For(ctx).Validate("4006381333931"))
+	}
+
+	_, err = domain.NewTenantValidators(&config.Config{
+		OrderNumbers: config.OrderNumberValidator{Type: domain.OrderNumberValidatorLuhn},
+		Tenants:      []config.Tenant{{ID: "bad", OrderNumbers: &config.OrderNumberValidator{Type: "crc32"}}},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"slices"
+
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+// orderStatuses все статусы заказа в порядке обработки
+var orderStatuses = []entity.OrderStatus{
+	entity.OrderStatusNew,
+	entity.OrderStatusProcessing,
+	entity.OrderStatusInvalid,
+	entity.OrderStatusProcessed,
+}
+
+// orderTransitions конечный автомат статусов заказа: в какие статусы возможен переход из каждого.
+// PROCESSED конечный, из INVALID заказ возвращается в NEW только для повторного расчёта.
+var orderTransitions = map[entity.OrderStatus][]entity.OrderStatus{
+	entity.OrderStatusNew:        {entity.OrderStatusProcessing, entity.OrderStatusInvalid, entity.OrderStatusProcessed},
+	entity.OrderStatusProcessing: {entity.OrderStatusInvalid, entity.OrderStatusProcessed},
+	entity.OrderStatusInvalid:    {entity.OrderStatusNew},
+}
+
+// CanTransition допустим ли переход заказа из from в to. Сохранение того же статуса переходом не считается.
+func CanTransition(from, to entity.OrderStatus) bool {
+	return slices.Contains(orderTransitions[from], to)
+}
+
+// AllowedFrom статусы, из которых допустим переход в to. Хранилище обновляет статус только при совпадении
+// текущего с одним из них, поэтому опоздавший ответ системы расчёта не откатывает заказ назад.
+func AllowedFrom(to entity.OrderStatus) []entity.OrderStatus {
+	var result []entity.OrderStatus
+	for _, from := range orderStatuses {
+		if CanTransition(from, to) {
+			result = append(result, from)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,37 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	testCases := []struct {
+		from, to entity.OrderStatus
+		expected bool
+	}{
+		{entity.OrderStatusNew, entity.OrderStatusProcessing, true},
+		{entity.OrderStatusNew, entity.OrderStatusProcessed, true},
+		{entity.OrderStatusProcessing, entity.OrderStatusProcessed, true},
+		{entity.OrderStatusProcessing, entity.OrderStatusInvalid, true},
+		{entity.OrderStatusInvalid, entity.OrderStatusNew, true},
+		{entity.OrderStatusProcessing, entity.OrderStatusNew, false},
+		{entity.OrderStatusProcessed, entity.OrderStatusProcessing, false},
+		{entity.OrderStatusProcessed, entity.OrderStatusInvalid, false},
+		{entity.OrderStatusProcessed, entity.OrderStatusNew, false},
+		{entity.OrderStatusInvalid, entity.OrderStatusProcessed, false},
+		{entity.OrderStatusNew, entity.OrderStatusNew, false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, domain.CanTransition(tc.from, tc.to), "%s -> %s", tc.from, tc.to)
+	}
+}
+
+func TestAllowedFrom(t *testing.T) {
+	assert.Equal(t, []entity.OrderStatus{entity.OrderStatusNew, entity.OrderStatusProcessing}, domain.AllowedFrom(entity.OrderStatusProcessed))
+	assert.Equal(t, []entity.OrderStatus{entity.OrderStatusInvalid}, domain.AllowedFrom(entity.OrderStatusNew))
+	assert.Equal(t, []entity.OrderStatus{entity.OrderStatusNew}, domain.AllowedFrom(entity.OrderStatusProcessing))
+}
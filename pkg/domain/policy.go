@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+)
+
+const (
+	FieldLogin       = "login"
+	FieldPassword    = "password"
+	FieldNewPassword = "new_password"
+)
+
+// CredentialsPolicy проверяет логины и пароли по правилам из config.CredentialsPolicy
+type CredentialsPolicy struct {
+	cfg          *config.CredentialsPolicy
+	loginPattern *regexp.Regexp
+	blocklist    map[string]struct{}
+}
+
+func NewCredentialsPolicy(cfg *config.CredentialsPolicy) (*CredentialsPolicy, error) {
+	p := &CredentialsPolicy{cfg: cfg}
+	if cfg.LoginPattern != "" {
+		var err error
+		if p.loginPattern, err = regexp.Compile(cfg.LoginPattern); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.PasswordBlocklistFile != "" {
+		var err error
+		if p.blocklist, err = readBlocklist(cfg.PasswordBlocklistFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// Validate проверяет пару логин/пароль, возвращает *ValidationError со всеми найденными нарушениями
+func (p *CredentialsPolicy) Validate(login, password string) error {
+	verr := &ValidationError{}
+	p.validateLogin(verr, login)
+	p.validatePassword(verr, FieldPassword, password)
+
+	return verr.OrNil()
+}
+
+// ValidatePassword проверяет только пароль, например при его смене
+func (p *CredentialsPolicy) ValidatePassword(field, password string) error {
+	verr := &ValidationError{}
+	p.validatePassword(verr, field, password)
+
+	return verr.OrNil()
+}
+
+func (p *CredentialsPolicy) validateLogin(verr *ValidationError, login string) {
+	length := utf8.RuneCountInString(login)
+	switch {
+	case length == 0:
+		verr.Add(FieldLogin, ViolationRequired, "login is required")
+		return
+	case length < p.cfg.LoginMinLength:
+		verr.Add(FieldLogin, ViolationTooShort, "login must be at least %d characters", p.cfg.LoginMinLength)
+	case p.cfg.LoginMaxLength > 0 && length > p.cfg.LoginMaxLength:
+		verr.Add(FieldLogin, ViolationTooLong, "login must be at most %d characters", p.cfg.LoginMaxLength)
+	}
+	if !utf8.ValidString(login) || (p.loginPattern != nil && !p.loginPattern.MatchString(login)) {
+		verr.Add(FieldLogin, ViolationInvalidChar, "login contains forbidden characters")
+	}
+}
+
+func (p *CredentialsPolicy) validatePassword(verr *ValidationError, field, password string) {
+	if password == "" {
+		verr.Add(field, ViolationRequired, "password is required")
+		return
+	}
+	if utf8.RuneCountInString(password) < p.cfg.PasswordMinLength {
+		verr.Add(field, ViolationTooShort, "password must be at least %d characters", p.cfg.PasswordMinLength)
+	}
+	if classes := charClasses(password); classes < p.cfg.PasswordMinClasses {
+		verr.Add(field, ViolationTooWeak,
+			"password must contain at least %d of: lowercase, uppercase, digits, other characters", p.cfg.PasswordMinClasses)
+	}
+	if _, ok := p.blocklist[strings.ToLower(password)]; ok {
+		verr.Add(field, ViolationBreached, "password is found in a list of breached passwords")
+	}
+}
+
+func charClasses(s string) int {
+	var lower, upper, digit, other int
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+
+	return lower + upper + digit + other
+}
+
+func readBlocklist(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	blocklist := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			blocklist[strings.ToLower(line)] = struct{}{}
+		}
+	}
+
+	return blocklist, scanner.Err()
+}
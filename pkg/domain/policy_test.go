@@ -0,0 +1,72 @@
+package domain_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_domain_CredentialsPolicy(t *testing.T) {
+	blocklistFile := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(blocklistFile, []byte("Password1!\nqwerty\n"), 0o600))
+
+	policy, err := domain.NewCredentialsPolicy(&config.CredentialsPolicy{
+		LoginMinLength:        3,
+		LoginMaxLength:        64,
+		LoginPattern:          `^[a-zA-Z0-9._-]+$`,
+		PasswordMinLength:     8,
+		PasswordMinClasses:    3,
+		PasswordBlocklistFile: blocklistFile,
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		login    string
+		password string
+		expected map[string]string
+	}{
+		{name: "Valid", login: "john.doe", password: "Secr3t-pass"},
+		{
+			name:     "Empty",
+			expected: map[string]string{domain.FieldLogin: domain.ViolationRequired, domain.FieldPassword: domain.ViolationRequired},
+		},
+		{name: "Short login", login: "jo", password: "Secr3t-pass", expected: map[string]string{domain.FieldLogin: domain.ViolationTooShort}},
+		{
+			name:     "Long login",
+			login:    strings.Repeat("a", 65),
+			password: "Secr3t-pass",
+			expected: map[string]string{domain.FieldLogin: domain.ViolationTooLong},
+		},
+		{name: "Bad chars", login: "john doe", password: "Secr3t-pass", expected: map[string]string{domain.FieldLogin: domain.ViolationInvalidChar}},
+		{name: "Short password", login: "john", password: "S3c-t", expected: map[string]string{domain.FieldPassword: domain.ViolationTooShort}},
+		{name: "Weak password", login: "john", password: "secretpass", expected: map[string]string{domain.FieldPassword: domain.ViolationTooWeak}},
+		{name: "Breached password", login: "john", password: "password1!", expected: map[string]string{domain.FieldPassword: domain.ViolationBreached}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.Validate(tc.login, tc.password)
+			if tc.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, err, domain.ErrBadRequest)
+			var verr *domain.ValidationError
+			require.True(t, errors.As(err, &verr))
+			actual := make(map[string]string)
+			for _, v := range verr.Violations {
+				actual[v.Field] = v.Code
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
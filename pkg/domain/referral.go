@@ -0,0 +1,171 @@
+package domain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+)
+
+const (
+	// FieldReferral поле реферального кода при регистрации
+	FieldReferral = "referral"
+	// ViolationSelfReferral пользователь указал собственный код
+	ViolationSelfReferral = "self_referral"
+)
+
+// NewReferralCode случайный код из 10 шестнадцатеричных символов в верхнем регистре
+func NewReferralCode() string {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Errorf("unable to generate referral code: %w", err))
+	}
+
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+// ReferralProgram приглашения пользователей и бонусы за них
+type ReferralProgram interface {
+	// Attach привязывает нового пользователя к пригласившему по коду
+	Attach(ctx context.Context, user *entity.User, code string) error
+
+	// OrderProcessed начисляет бонусы обоим участникам, если это первый обработанный заказ приглашённого
+	OrderProcessed(ctx context.Context, order *entity.Order) error
+
+	// GetReferrals код пользователя и его приглашения
+	GetReferrals(ctx context.Context, userID uuid.UUID) (*entity.ReferralsResponse, error)
+}
+
+type referralProgram struct {
+	cfg *config.Referral
+
+	orderRepo    repository.Order
+	userRepo     repository.User
+	referralRepo repository.Referral
+}
+
+func NewReferralProgram(
+	cfg *config.Config,
+	orderRepo repository.Order,
+	userRepo repository.User,
+	referralRepo repository.Referral,
+) ReferralProgram {
+	return &referralProgram{
+		cfg:          &cfg.Referral,
+		orderRepo:    orderRepo,
+		userRepo:     userRepo,
+		referralRepo: referralRepo,
+	}
+}
+
+func (p *referralProgram) Attach(ctx context.Context, user *entity.User, code string) error {
+	verr := &ValidationError{}
+	referrer, err := p.userRepo.FindByReferralCode(ctx, code)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		verr.Add(FieldReferral, ViolationUnknownValue, "unknown referral code")
+		return verr
+	case err != nil:
+		return err
+	case referrer.ID == user.ID:
+		verr.Add(FieldReferral, ViolationSelfReferral, "own referral code")
+		return verr
+	case referrer.Blocked || referrer.DeletedAt != nil:
+		verr.Add(FieldReferral, ViolationUnknownValue, "unknown referral code")
+		return verr
+	}
+
+	return p.referralRepo.Insert(ctx, &entity.Referral{ReferrerID: referrer.ID, RefereeID: user.ID})
+}
+
+func (p *referralProgram) OrderProcessed(ctx context.Context, order *entity.Order) error {
+	referral, err := p.referralRepo.FindByReferee(ctx, order.UserID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	// вознаграждается только первый обработанный заказ
+	if referral.RewardedAt != nil || referral.ReferrerID == referral.RefereeID {
+		return nil
+	}
+
+	referral.OrderNumber = &order.Number
+	referral.RefereeBonus = p.cfg.RefereeBonus
+	referral.ReferrerBonus = 0
+	// заказы разных приглашённых обрабатываются параллельно: без блокировки пригласившего
+	// обе транзакции увидят счётчик ниже лимита и обе начислят бонус
+	if err := p.userRepo.LockForUpdate(ctx, referral.ReferrerID); err != nil {
+		return err
+	}
+	referrer, err := p.userRepo.FindByID(ctx, referral.ReferrerID)
+	if err != nil {
+		return err
+	}
+	if !referrer.Blocked && referrer.DeletedAt == nil {
+		rewarded, err := p.referralRepo.CountRewarded(ctx, referrer.ID)
+		if err != nil {
+			return err
+		}
+		if p.cfg.MaxPerReferrer == 0 || rewarded < p.cfg.MaxPerReferrer {
+			referral.ReferrerBonus = p.cfg.ReferrerBonus
+		}
+	}
+	if ok, err := p.referralRepo.MarkRewarded(ctx, referral); err != nil || !ok {
+		return err
+	}
+
+	if err := p.credit(ctx, referral.RefereeID, referral.RefereeBonus, order.Number); err != nil {
+		return err
+	}
+
+	return p.credit(ctx, referral.ReferrerID, referral.ReferrerBonus, order.Number)
+}
+
+// credit бонус проводится системной корректировкой баланса без автора
+func (p *referralProgram) credit(ctx context.Context, userID uuid.UUID, amount float64, orderNumber string) error {
+	if amount <= 0 {
+		return nil
+	}
+
+	return p.orderRepo.AddBalanceAdjustment(ctx, &entity.BalanceAdjustment{
+		UserID:    userID,
+		Amount:    amount,
+		Reason:    "referral bonus for order " + orderNumber,
+		CreatedBy: uuid.Nil,
+	})
+}
+
+func (p *referralProgram) GetReferrals(ctx context.Context, userID uuid.UUID) (*entity.ReferralsResponse, error) {
+	user, err := p.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	referrals, err := p.referralRepo.GetUserReferrals(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &entity.ReferralsResponse{
+		Code:      user.ReferralCode,
+		Referrals: make([]entity.ReferralResponse, len(referrals)),
+	}
+	for i, r := range referrals {
+		resp.Referrals[i] = entity.ReferralResponse{
+			Login:      r.RefereeLogin,
+			Bonus:      r.ReferrerBonus,
+			CreatedAt:  r.CreatedAt,
+			RewardedAt: r.RewardedAt,
+		}
+	}
+
+	return resp, nil
+}
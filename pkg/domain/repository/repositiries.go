@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
@@ -11,17 +12,111 @@ type Order interface {
 	Insert(ctx context.Context, user *entity.Order) error
 	FindByNumber(ctx context.Context, orderNumber string) (*entity.Order, error)
 	GetUserOrders(ctx context.Context, userID uuid.UUID) ([]entity.Order, error)
+	// GetAccrualsSum сумма начислений с бонусами по заказам в статусе PROCESSED
 	GetAccrualsSum(ctx context.Context, userID uuid.UUID) (float64, error)
+	// GetAccrualsSumSince сумма начислений без бонусов по заказам, рассчитанным не раньше since
+	GetAccrualsSumSince(ctx context.Context, userID uuid.UUID, since time.Time) (float64, error)
+	// GetWithdrawnSum сумма списаний за вычетом возвратов
 	GetWithdrawnSum(ctx context.Context, userID uuid.UUID) (float64, error)
+	GetAdjustmentsSum(ctx context.Context, userID uuid.UUID) (float64, error)
+	AddBalanceAdjustment(ctx context.Context, a *entity.BalanceAdjustment) error
+	GetUserAdjustments(ctx context.Context, userID uuid.UUID) ([]entity.BalanceAdjustment, error)
+	// GetOrderAdjustmentsSum сумма корректировок, исправляющих начисление по заказу
+	GetOrderAdjustmentsSum(ctx context.Context, orderNumber string) (float64, error)
+	// GetAdjustmentsForReview корректировки, ожидающие проверки поддержкой, по времени создания
+	GetAdjustmentsForReview(ctx context.Context) ([]entity.BalanceAdjustment, error)
+	// MarkAdjustmentReviewed снимает отметку о проверке, false - корректировка не найдена или уже проверена
+	MarkAdjustmentReviewed(ctx context.Context, id uuid.UUID) (bool, error)
+	GetExpiredSum(ctx context.Context, userID uuid.UUID) (float64, error)
+	// AddExpiry сохраняет сгорание; повторное сгорание того же заказа игнорируется
+	AddExpiry(ctx context.Context, e *entity.PointsExpiry) error
+	GetUserExpirations(ctx context.Context, userID uuid.UUID) ([]entity.PointsExpiry, error)
+	// AddCampaignCredit сохраняет бонус по акции; повторный бонус той же акции за тот же заказ игнорируется
+	AddCampaignCredit(ctx context.Context, c *entity.CampaignCredit) error
+	GetCampaignCreditsSum(ctx context.Context, userID uuid.UUID) (float64, error)
+	GetUserCampaignCredits(ctx context.Context, userID uuid.UUID) ([]entity.CampaignCredit, error)
 	Withdraw(ctx context.Context, w *entity.Withdraw) error
 	GetUserWithdrawals(ctx context.Context, userID uuid.UUID) ([]entity.Withdraw, error)
-	SetOrderStatus(ctx context.Context, orderNumber string, status entity.OrderStatus) error
-	UpdateAttributes(ctx context.Context, order *entity.Order) error
+	// FindWithdrawalByNumber списание в счёт заказа; domain.ErrAmbiguousWithdrawal, если списаний по номеру несколько
+	FindWithdrawalByNumber(ctx context.Context, orderNumber string) (*entity.Withdraw, error)
+	// RefundWithdrawal фиксирует возврат, false - по списанию уже был возврат
+	RefundWithdrawal(ctx context.Context, id uuid.UUID, amount float64) (bool, error)
+	// SetOrderStatus переводит заказ в status, если переход допустим по domain.AllowedFrom, и записывает его в историю.
+	// false - текущий статус заказа не допускает перехода.
+	SetOrderStatus(ctx context.Context, orderNumber string, status entity.OrderStatus, source string, payload []byte) (bool, error)
+	// UpdateAttributes сохраняет статус, начисление и бонус по тем же правилам, что и SetOrderStatus
+	UpdateAttributes(ctx context.Context, order *entity.Order, source string, payload []byte) (bool, error)
+	// GetOrderStatusHistory переходы заказа в порядке времени, начиная с загрузки
+	GetOrderStatusHistory(ctx context.Context, orderID uuid.UUID) ([]entity.OrderStatusChange, error)
 	GetOrdersByStatuses(ctx context.Context, statuses []string, exceptNumbers []string, limit int) ([]entity.Order, error)
+	// GetOrdersToReverify заказы в PROCESSED, рассчитанные не раньше processedSince и не сверявшиеся после verifiedBefore,
+	// начиная с давно не сверявшихся
+	GetOrdersToReverify(ctx context.Context, processedSince, verifiedBefore time.Time, limit int) ([]entity.Order, error)
+	SetVerifiedAt(ctx context.Context, orderID uuid.UUID, at time.Time) error
+	// AddTransfer сохраняет перевод; повтор ключа идемпотентности отправителя возвращает domain.ErrIdempotencyConflict
+	AddTransfer(ctx context.Context, t *entity.Transfer) error
+	FindTransferByKey(ctx context.Context, senderID uuid.UUID, key string) (*entity.Transfer, error)
+	// GetTransfersSum полученные переводы за вычетом отправленных
+	GetTransfersSum(ctx context.Context, userID uuid.UUID) (float64, error)
+	// GetSentTransfersSince количество и сумма переводов отправителя не раньше since
+	GetSentTransfersSince(ctx context.Context, senderID uuid.UUID, since time.Time) (count int, sum float64, err error)
+	// GetUserTransfers отправленные и полученные переводы с логинами сторон, по времени создания
+	GetUserTransfers(ctx context.Context, userID uuid.UUID) ([]entity.Transfer, error)
+	// StreamStatement передаёт в f движения по счёту раньше before в хронологическом порядке, не загружая их целиком.
+	// Balance не заполняется. Ошибка из f прерывает чтение и возвращается как есть.
+	StreamStatement(ctx context.Context, userID uuid.UUID, before time.Time, f func(e *entity.StatementEntry) error) error
 }
 
 type User interface {
 	Insert(ctx context.Context, user *entity.User) error
 	LoginExists(ctx context.Context, login string) (bool, error)
 	FindByLoginAndPassword(ctx context.Context, login string, hashedPassword []byte) (*entity.User, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*entity.User, error)
+	FindByLogin(ctx context.Context, login string) (*entity.User, error)
+	SetBlocked(ctx context.Context, id uuid.UUID, blocked bool) error
+	SetRole(ctx context.Context, id uuid.UUID, role entity.UserRole) error
+	UpdatePassword(ctx context.Context, id uuid.UUID, hashedPassword []byte) (sessionVersion int, err error)
+	Anonymize(ctx context.Context, id uuid.UUID, login string) error
+	// List все пользователи, включая удалённых, по возрастанию логина
+	List(ctx context.Context) ([]entity.User, error)
+	SetTier(ctx context.Context, id uuid.UUID, tier string) error
+	FindByReferralCode(ctx context.Context, code string) (*entity.User, error)
+	// LockForUpdate блокирует строку пользователя до конца транзакции, чтобы проверки баланса,
+	// лимитов и счётчиков по нему шли по одной
+	LockForUpdate(ctx context.Context, id uuid.UUID) error
+}
+
+type Referral interface {
+	Insert(ctx context.Context, r *entity.Referral) error
+	FindByReferee(ctx context.Context, refereeID uuid.UUID) (*entity.Referral, error)
+	// MarkRewarded сохраняет номер заказа и бонусы; false, если приглашение уже было вознаграждено
+	MarkRewarded(ctx context.Context, r *entity.Referral) (bool, error)
+	// GetUserReferrals приглашения пользователя с логинами приглашённых, по времени создания
+	GetUserReferrals(ctx context.Context, referrerID uuid.UUID) ([]entity.Referral, error)
+	CountRewarded(ctx context.Context, referrerID uuid.UUID) (int, error)
+}
+
+type Campaign interface {
+	Insert(ctx context.Context, c *entity.Campaign) error
+	Update(ctx context.Context, c *entity.Campaign) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	FindByID(ctx context.Context, id uuid.UUID) (*entity.Campaign, error)
+	// List все акции по возрастанию времени создания
+	List(ctx context.Context) ([]entity.Campaign, error)
+}
+
+// AccrualArchive исходные ответы системы расчёта, включая помещённые в карантин
+type AccrualArchive interface {
+	Insert(ctx context.Context, r *entity.AccrualResponse) error
+	// GetOrderResponses ответы по заказу по времени получения
+	GetOrderResponses(ctx context.Context, orderNumber string) ([]entity.AccrualResponse, error)
+	// GetQuarantined последние ответы, не прошедшие проверку, начиная с новых
+	GetQuarantined(ctx context.Context, limit int) ([]entity.AccrualResponse, error)
+	// DeleteBefore удаляет ответы тенанта из контекста, полученные раньше before
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type Audit interface {
+	Insert(ctx context.Context, record *entity.AuditRecord) error
+	GetLast(ctx context.Context, limit int) ([]entity.AuditRecord, error)
 }
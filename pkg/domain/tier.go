@@ -0,0 +1,156 @@
+package domain
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/accrual"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+)
+
+// TierPolicy определение уровня лояльности по сумме начислений за скользящее окно
+type TierPolicy struct {
+	windowMonths int
+	// tiers по возрастанию порога
+	tiers []config.Tier
+}
+
+func NewTierPolicy(cfg *config.Loyalty) *TierPolicy {
+	tiers := slices.Clone(cfg.Tiers)
+	slices.SortStableFunc(tiers, func(a, b config.Tier) int {
+		switch {
+		case a.Threshold < b.Threshold:
+			return -1
+		case a.Threshold > b.Threshold:
+			return 1
+		}
+		return 0
+	})
+
+	return &TierPolicy{windowMonths: cfg.WindowMonths, tiers: tiers}
+}
+
+func (p *TierPolicy) Enabled() bool {
+	return len(p.tiers) > 0
+}
+
+// WindowStart начало окна, за которое суммируются начисления
+func (p *TierPolicy) WindowStart(now time.Time) time.Time {
+	return now.AddDate(0, -p.windowMonths, 0)
+}
+
+// TierFor уровень для суммы начислений и следующий уровень; nil, если подходящего уровня нет
+func (p *TierPolicy) TierFor(accruals float64) (current, next *config.Tier) {
+	for i := range p.tiers {
+		if accruals >= p.tiers[i].Threshold {
+			current = &p.tiers[i]
+		} else {
+			return current, &p.tiers[i]
+		}
+	}
+
+	return current, nil
+}
+
+// Multiplier коэффициент уровня, 1 если уровней нет или коэффициент не задан
+func (p *TierPolicy) Multiplier(tier *config.Tier) float64 {
+	if tier == nil || tier.Multiplier <= 0 {
+		return 1
+	}
+
+	return tier.Multiplier
+}
+
+var _ accrual.OrderProcessedHook = (*loyaltyService)(nil)
+
+// Loyalty начисление бонусов по уровню лояльности
+type Loyalty interface {
+	// ApplyBonus рассчитывает бонус к заказу по уровню пользователя до этого заказа.
+	// Вызывается до сохранения заказа со статусом PROCESSED, сохранение остаётся за вызывающим.
+	ApplyBonus(ctx context.Context, order *entity.Order) error
+
+	// OrderProcessed пересчитывает уровень пользователя с учётом уже сохранённого заказа, обработчик OrderProcessed
+	OrderProcessed(ctx context.Context, order *entity.Order) error
+
+	// GetTier текущий уровень пользователя
+	GetTier(ctx context.Context, userID uuid.UUID) (*entity.TierResponse, error)
+}
+
+type loyaltyService struct {
+	policy *TierPolicy
+
+	orderRepo repository.Order
+	userRepo  repository.User
+}
+
+func NewLoyalty(cfg *config.Config, orderRepo repository.Order, userRepo repository.User) Loyalty {
+	return &loyaltyService{
+		policy:    NewTierPolicy(&cfg.Loyalty),
+		orderRepo: orderRepo,
+		userRepo:  userRepo,
+	}
+}
+
+func (s *loyaltyService) ApplyBonus(ctx context.Context, order *entity.Order) error {
+	if !s.policy.Enabled() || order.Accrual == nil {
+		return nil
+	}
+
+	// заказ ещё не сохранён как PROCESSED, поэтому в сумму окна не входит
+	before, err := s.orderRepo.GetAccrualsSumSince(ctx, order.UserID, s.policy.WindowStart(time.Now()))
+	if err != nil {
+		return err
+	}
+	tier, _ := s.policy.TierFor(before)
+	order.Bonus = nil
+	if bonus := roundPoints(*order.Accrual * (s.policy.Multiplier(tier) - 1)); bonus > 0 {
+		order.Bonus = &bonus
+	}
+
+	return nil
+}
+
+// OrderProcessed уровень считается по текущей сумме окна, поэтому повторная доставка события ничего не меняет
+func (s *loyaltyService) OrderProcessed(ctx context.Context, order *entity.Order) error {
+	if !s.policy.Enabled() {
+		return nil
+	}
+
+	accruals, err := s.orderRepo.GetAccrualsSumSince(ctx, order.UserID, s.policy.WindowStart(time.Now()))
+	if err != nil {
+		return err
+	}
+	tier, _ := s.policy.TierFor(accruals)
+	name := ""
+	if tier != nil {
+		name = tier.Name
+	}
+
+	return s.userRepo.SetTier(ctx, order.UserID, name)
+}
+
+func (s *loyaltyService) GetTier(ctx context.Context, userID uuid.UUID) (*entity.TierResponse, error) {
+	accruals, err := s.orderRepo.GetAccrualsSumSince(ctx, userID, s.policy.WindowStart(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+
+	current, next := s.policy.TierFor(accruals)
+	resp := &entity.TierResponse{
+		Multiplier:      s.policy.Multiplier(current),
+		RollingAccruals: roundPoints(accruals),
+	}
+	if current != nil {
+		resp.Tier = current.Name
+	}
+	if next != nil {
+		resp.NextTier = next.Name
+		resp.ToNextTier = roundPoints(next.Threshold - accruals)
+	}
+
+	return resp, nil
+}
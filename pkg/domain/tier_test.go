@@ -0,0 +1,62 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/config"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_domain_TierPolicy(t *testing.T) {
+	policy := domain.NewTierPolicy(&config.Loyalty{
+		WindowMonths: 12,
+		// порядок в конфиге не важен
+		Tiers: []config.Tier{
+			{Name: "Gold", Threshold: 5000, Multiplier: 1.25},
+			{Name: "Bronze", Threshold: 0},
+			{Name: "Silver", Threshold: 1000, Multiplier: 1.1},
+		},
+	})
+	require.True(t, policy.Enabled())
+
+	testCases := []struct {
+		name       string
+		accruals   float64
+		tier       string
+		next       string
+		multiplier float64
+	}{
+		{name: "Empty", accruals: 0, tier: "Bronze", next: "Silver", multiplier: 1},
+		{name: "Below silver", accruals: 999.99, tier: "Bronze", next: "Silver", multiplier: 1},
+		{name: "Silver", accruals: 1000, tier: "Silver", next: "Gold", multiplier: 1.1},
+		{name: "Gold", accruals: 10000, tier: "Gold", multiplier: 1.25},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			current, next := policy.TierFor(tc.accruals)
+			require.NotNil(t, current)
+			assert.Equal(t, tc.tier, current.Name)
+			assert.Equal(t, tc.multiplier, policy.Multiplier(current))
+			if tc.next == "" {
+				assert.Nil(t, next)
+			} else {
+				require.NotNil(t, next)
+				assert.Equal(t, tc.next, next.Name)
+			}
+		})
+	}
+
+	now := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2023, 3, 15, 0, 0, 0, 0, time.UTC), policy.WindowStart(now))
+
+	disabled := domain.NewTierPolicy(&config.Loyalty{})
+	assert.False(t, disabled.Enabled())
+	current, next := disabled.TierFor(100)
+	assert.Nil(t, current)
+	assert.Nil(t, next)
+	assert.Equal(t, 1.0, disabled.Multiplier(current))
+}
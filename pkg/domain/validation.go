@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+const (
+	ViolationRequired     = "required"
+	ViolationTooShort     = "too_short"
+	ViolationTooLong      = "too_long"
+	ViolationInvalidChar  = "invalid_chars"
+	ViolationTooWeak      = "too_weak"
+	ViolationBreached     = "breached"
+	ViolationUnknownValue = "unknown_value"
+	ViolationOutOfRange   = "out_of_range"
+)
+
+var ErrValidation = ErrBadRequest.Wrap("validation_failed", "validation failed")
+
+// ValidationError набор нарушений, отдаётся клиенту целиком со статусом 400
+type ValidationError struct {
+	Violations []entity.Violation
+}
+
+func (e *ValidationError) Add(field, code, format string, args ...any) {
+	e.Violations = append(e.Violations, entity.Violation{
+		Field:   field,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// OrNil возвращает nil, если нарушений нет, чтобы не получить ненулевой интерфейс error с пустым значением
+func (e *ValidationError) OrNil() error {
+	if len(e.Violations) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Field + ": " + v.Message
+	}
+
+	return fmt.Sprintf("%s: %s", ErrValidation.Error(), strings.Join(msgs, "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
@@ -0,0 +1,246 @@
+package tests
+
+import (
+	"context"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+func (s *GophermartTestSuite) NewAdmin() *entity.Actor {
+	u := s.NewUser()
+	s.Require().NoError(s.cnt.UserRepo().SetRole(context.Background(), u.ID, entity.UserRoleAdmin))
+
+	return &entity.Actor{ID: u.ID, Login: u.Login, Role: entity.UserRoleAdmin}
+}
+
+func (s *GophermartTestSuite) TestAdminAdjustBalance() {
+	ctx := context.Background()
+	admin := s.NewAdmin()
+	u := s.NewUser()
+	err := s.cnt.OrderRepo().Insert(ctx, &entity.Order{
+		ID:      uuid.Must(uuid.NewV6()),
+		UserID:  u.ID,
+		Number:  "7950385429",
+		Status:  entity.OrderStatusProcessed,
+		Accrual: utils.ToPointer(50.00),
+	})
+	s.Require().NoError(err)
+
+	err = s.cnt.Admin().AdjustBalance(ctx, admin, u.Login, &entity.BalanceAdjustmentRequest{Amount: -20})
+	s.Require().ErrorIs(err, domain.ErrReasonRequired)
+
+	err = s.cnt.Admin().AdjustBalance(ctx, admin, u.Login, &entity.BalanceAdjustmentRequest{
+		Amount: -20,
+		Reason: "duplicate accrual",
+	})
+	s.Require().NoError(err)
+
+	_, balance, err := s.cnt.Admin().FindUser(ctx, admin, u.Login)
+	s.Require().NoError(err)
+	s.Require().Equal(30.00, balance.Current)
+
+	err = s.cnt.Gophermart().Withdraw(ctx, &entity.Withdraw{UserID: u.ID, OrderNumber: "2377225624", Value: 40})
+	s.Require().ErrorIs(err, domain.ErrNotEnoughAccruals)
+
+	records, err := s.cnt.Admin().GetAuditLog(ctx, 10)
+	s.Require().NoError(err)
+	s.Require().True(utils.ContainsWhere(records, func(r entity.AuditRecord) bool {
+		return r.Action == domain.AuditActionAdjustBalance && r.Target == u.Login && r.ActorID == admin.ID
+	}))
+	s.Require().True(utils.ContainsWhere(records, func(r entity.AuditRecord) bool {
+		return r.Action == domain.AuditActionFindUser && r.Target == u.Login
+	}))
+}
+
+func (s *GophermartTestSuite) TestAdminBlockUser() {
+	ctx := context.Background()
+	admin := s.NewAdmin()
+	u := s.NewUser()
+
+	s.Require().NoError(s.cnt.Admin().SetUserBlocked(ctx, admin, u.Login, true))
+	_, err := s.cnt.Gophermart().Login(ctx, &entity.LoginRequest{Login: u.Login, Password: "test"})
+	s.Require().ErrorIs(err, domain.ErrUserBlocked)
+
+	s.Require().NoError(s.cnt.Admin().SetUserBlocked(ctx, admin, u.Login, false))
+	_, err = s.cnt.Gophermart().Login(ctx, &entity.LoginRequest{Login: u.Login, Password: "test"})
+	s.Require().NoError(err)
+
+	err = s.cnt.Admin().SetUserBlocked(ctx, admin, "no such login", true)
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+}
+
+func (s *GophermartTestSuite) TestAdminRetriggerAccrual() {
+	ctx := context.Background()
+	admin := s.NewAdmin()
+	u := s.NewUser()
+	order := &entity.Order{
+		ID:     uuid.Must(uuid.NewV6()),
+		UserID: u.ID,
+		Number: "4561261212345467",
+		Status: entity.OrderStatusInvalid,
+	}
+	s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, order))
+
+	s.Require().NoError(s.cnt.Admin().RetriggerAccrual(ctx, admin, order.Number))
+	stored, err := s.cnt.OrderRepo().FindByNumber(ctx, order.Number)
+	s.Require().NoError(err)
+	s.Require().Equal(entity.OrderStatusNew, stored.Status)
+
+	history, err := s.cnt.OrderRepo().GetOrderStatusHistory(ctx, order.ID)
+	s.Require().NoError(err)
+	s.Require().Len(history, 2)
+	s.Require().Equal(entity.OrderSourceAdmin, history[1].Source)
+
+	orders, err := s.cnt.Admin().GetUserOrders(ctx, admin, u.Login)
+	s.Require().NoError(err)
+	s.Require().Len(orders, 1)
+}
+
+func (s *GophermartTestSuite) TestAdminGetOrderAccrualResponses() {
+	ctx := context.Background()
+	admin := s.NewAdmin()
+	u := s.NewUser()
+	order := &entity.Order{UserID: u.ID, Number: "1234567004", Status: entity.OrderStatusProcessing}
+	s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, order))
+	archive := s.cnt.AccrualArchive()
+	s.Require().NoError(archive.Insert(ctx, &entity.AccrualResponse{
+		OrderNumber: order.Number,
+		StatusCode:  200,
+		Payload:     []byte(`{"order":"1234567004","status":"PROCESSING","accrual":-1}`),
+		Error:       utils.ToPointer("bad accrual response: negative accrual -1"),
+	}))
+	s.Require().NoError(archive.Insert(ctx, &entity.AccrualResponse{
+		OrderNumber: order.Number,
+		StatusCode:  200,
+		Payload:     []byte(`{"order":"1234567004","status":"PROCESSING"}`),
+	}))
+
+	responses, err := s.cnt.Admin().GetOrderAccrualResponses(ctx, admin, "1234-5670-04")
+	s.Require().NoError(err)
+	s.Require().Len(responses, 2)
+	s.Require().NotNil(responses[0].Error)
+	s.Require().Nil(responses[1].Error)
+
+	quarantined, err := s.cnt.Admin().GetQuarantinedAccrualResponses(ctx, admin, 100)
+	s.Require().NoError(err)
+	s.Require().True(utils.ContainsWhere(quarantined, func(r entity.AccrualResponse) bool {
+		return r.OrderNumber == order.Number
+	}))
+
+	_, err = s.cnt.Admin().GetOrderAccrualResponses(ctx, admin, "5555555555554444")
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+
+	records, err := s.cnt.Admin().GetAuditLog(ctx, 10)
+	s.Require().NoError(err)
+	s.Require().True(utils.ContainsWhere(records, func(r entity.AuditRecord) bool {
+		return r.Action == domain.AuditActionGetAccrualLog && r.Target == order.Number && r.ActorID == admin.ID
+	}))
+	s.Require().True(utils.ContainsWhere(records, func(r entity.AuditRecord) bool {
+		return r.Action == domain.AuditActionGetQuarantine && r.ActorID == admin.ID
+	}))
+}
+
+func (s *GophermartTestSuite) TestAuditLogImmutable() {
+	if !s.cfg.UseDB() {
+		s.T().Skip("audit_log trigger requires postgres")
+	}
+	ctx := context.Background()
+	admin := s.NewAdmin()
+	_, _, err := s.cnt.Admin().FindUser(ctx, admin, admin.Login)
+	s.Require().NoError(err)
+
+	_, err = s.cnt.DB().Exec(ctx, `UPDATE audit_log SET action = 'changed'`)
+	s.Require().Error(err)
+	_, err = s.cnt.DB().Exec(ctx, `DELETE FROM audit_log`)
+	s.Require().Error(err)
+}
+
+func (s *GophermartTestSuite) TestAdminCreateUserAndResetPassword() {
+	ctx := context.Background()
+	admin := s.NewAdmin()
+
+	_, err := s.cnt.Admin().CreateUser(ctx, admin, &entity.RegisterRequest{Login: "operator", Password: "old"}, "root")
+	s.Require().ErrorIs(err, domain.ErrValidation)
+
+	u, err := s.cnt.Admin().CreateUser(ctx, admin, &entity.RegisterRequest{Login: "operator", Password: "old"}, entity.UserRoleSupport)
+	s.Require().NoError(err)
+	stored, err := s.cnt.UserRepo().FindByID(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(entity.UserRoleSupport, stored.Role)
+
+	s.Require().NoError(s.cnt.Admin().ResetPassword(ctx, admin, "operator", "new"))
+	_, err = s.cnt.Gophermart().Login(ctx, &entity.LoginRequest{Login: "operator", Password: "old"})
+	s.Require().ErrorIs(err, domain.ErrInvalidCredentials)
+	loggedIn, err := s.cnt.Gophermart().Login(ctx, &entity.LoginRequest{Login: "operator", Password: "new"})
+	s.Require().NoError(err)
+	s.Require().Equal(stored.SessionVersion+1, loggedIn.SessionVersion)
+
+	s.Require().ErrorIs(s.cnt.Admin().ResetPassword(ctx, admin, "missing", "new"), domain.ErrNotFound)
+
+	records, err := s.cnt.Admin().GetAuditLog(ctx, 10)
+	s.Require().NoError(err)
+	s.Require().True(utils.ContainsWhere(records, func(r entity.AuditRecord) bool {
+		return r.Action == domain.AuditActionResetPassword && r.Target == "operator"
+	}))
+}
+
+func (s *GophermartTestSuite) TestAdminReconcileBalances() {
+	ctx := context.Background()
+	admin := s.NewAdmin()
+	u := s.NewUser()
+	s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, &entity.Order{
+		UserID: u.ID,
+		Number: "4111111111111111",
+		Status: entity.OrderStatusProcessed,
+	}))
+	s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, &entity.Order{
+		UserID: u.ID,
+		Number: "4000056655665556",
+		Status: entity.OrderStatusProcessed,
+		Bonus:  utils.ToPointer(5.0),
+	}))
+	err := s.cnt.Admin().AdjustBalance(ctx, admin, u.Login, &entity.BalanceAdjustmentRequest{Amount: -10, Reason: "test"})
+	s.Require().NoError(err)
+
+	reports, err := s.cnt.Admin().ReconcileBalances(ctx, admin)
+	s.Require().NoError(err)
+	idx := -1
+	for i, r := range reports {
+		s.Require().NotEqual(admin.ID, r.UserID)
+		if r.UserID == u.ID {
+			idx = i
+		}
+	}
+	s.Require().NotEqual(-1, idx)
+	s.Require().Equal(-10.0, reports[idx].Current)
+	// заказ без начисления и без бонуса не считается расхождением
+	s.Require().ElementsMatch([]string{
+		domain.ReconcileNegativeBalance,
+		domain.ReconcileProcessedWithoutAccrual + ":4000056655665556",
+	}, reports[idx].Problems)
+}
+
+func (s *GophermartTestSuite) TestSchedulerReconcileWithoutDiscrepancies() {
+	ctx := tenant.ToContext(context.Background(), "reconcile-empty")
+	scheduler := &entity.Actor{ID: uuid.Nil, Login: "scheduler", Role: entity.UserRoleAdmin}
+	admin := &entity.Actor{ID: uuid.Must(uuid.NewV6()), Login: "admin", Role: entity.UserRoleAdmin}
+
+	reports, err := s.cnt.Admin().ReconcileBalances(ctx, scheduler)
+	s.Require().NoError(err)
+	s.Require().Empty(reports)
+	records, err := s.cnt.AuditRepo().GetLast(ctx, 10)
+	s.Require().NoError(err)
+	s.Require().Empty(records)
+
+	// ручная сверка попадает в журнал и без расхождений
+	_, err = s.cnt.Admin().ReconcileBalances(ctx, admin)
+	s.Require().NoError(err)
+	records, err = s.cnt.AuditRepo().GetLast(ctx, 10)
+	s.Require().NoError(err)
+	s.Require().Len(records, 1)
+	s.Require().Equal(domain.AuditActionReconcile, records[0].Action)
+}
@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+func (s *GophermartTestSuite) TestCampaigns() {
+	ctx := context.Background()
+	admin := s.NewAdmin()
+	u := s.NewUser()
+
+	_, err := s.cnt.Admin().CreateCampaign(ctx, admin, &entity.CampaignRequest{Name: "broken", Kind: entity.CampaignKindMultiplier, Value: 0.5})
+	s.Require().ErrorIs(err, domain.ErrValidation)
+
+	welcome, err := s.cnt.Admin().CreateCampaign(ctx, admin, &entity.CampaignRequest{
+		Name:           "+100 on first order",
+		Kind:           entity.CampaignKindFixed,
+		Value:          100,
+		FirstOrderOnly: true,
+		Enabled:        true,
+	})
+	s.Require().NoError(err)
+	ended := time.Now().Add(-time.Hour)
+	expired, err := s.cnt.Admin().CreateCampaign(ctx, admin, &entity.CampaignRequest{
+		Name:    "past double points",
+		Kind:    entity.CampaignKindMultiplier,
+		Value:   2,
+		EndsAt:  &ended,
+		Enabled: true,
+	})
+	s.Require().NoError(err)
+
+	first := &entity.Order{UserID: u.ID, Number: "4532015112830366"}
+	s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, first))
+	s.processOrder(first, 40)
+	second := &entity.Order{UserID: u.ID, Number: "6011111111111117"}
+	s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, second))
+	s.processOrder(second, 10)
+
+	// бонусы по акциям начисляет подписчик OrderProcessed
+	var credits []entity.CampaignCredit
+	s.Require().Eventually(func() bool {
+		credits, err = s.cnt.OrderRepo().GetUserCampaignCredits(ctx, u.ID)
+		s.Require().NoError(err)
+		return len(credits) > 0
+	}, time.Second, 10*time.Millisecond)
+	s.Require().Never(func() bool {
+		credits, err = s.cnt.OrderRepo().GetUserCampaignCredits(ctx, u.ID)
+		s.Require().NoError(err)
+		return len(credits) > 1
+	}, 100*time.Millisecond, 10*time.Millisecond)
+	s.Require().Len(credits, 1)
+	s.Require().Equal(welcome.ID, credits[0].CampaignID)
+	s.Require().Equal(first.Number, credits[0].OrderNumber)
+
+	balance, err := s.cnt.Gophermart().GetBalance(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(150.0, balance.Current)
+
+	s.Require().NoError(s.cnt.Admin().DeleteCampaign(ctx, admin, expired.ID))
+	s.Require().ErrorIs(s.cnt.Admin().DeleteCampaign(ctx, admin, expired.ID), domain.ErrNotFound)
+	updated, err := s.cnt.Admin().UpdateCampaign(ctx, admin, welcome.ID, &entity.CampaignRequest{
+		Name:  welcome.Name,
+		Kind:  welcome.Kind,
+		Value: 50,
+	})
+	s.Require().NoError(err)
+	s.Require().False(updated.Enabled)
+
+	records, err := s.cnt.Admin().GetAuditLog(ctx, 10)
+	s.Require().NoError(err)
+	s.Require().True(utils.ContainsWhere(records, func(r entity.AuditRecord) bool {
+		return r.Action == domain.AuditActionDeleteCampaign && r.Target == expired.ID.String()
+	}))
+}
+
+func (s *GophermartTestSuite) TestCampaignFirstOrderWithoutAccrual() {
+	ctx := context.Background()
+	admin := s.NewAdmin()
+	u := s.NewUser()
+	welcome, err := s.cnt.Admin().CreateCampaign(ctx, admin, &entity.CampaignRequest{
+		Name:           "+70 on first order",
+		Kind:           entity.CampaignKindFixed,
+		Value:          70,
+		FirstOrderOnly: true,
+		Enabled:        true,
+	})
+	s.Require().NoError(err)
+	defer func() { s.Require().NoError(s.cnt.Admin().DeleteCampaign(ctx, admin, welcome.ID)) }()
+
+	first := &entity.Order{UserID: u.ID, Number: "4024007134564842"}
+	s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, first))
+	s.processOrderWith(first, nil)
+
+	// первый заказ без начисления всё равно первый: фиксированный бонус начисляется
+	s.Require().Eventually(func() bool {
+		credits, err := s.cnt.OrderRepo().GetUserCampaignCredits(ctx, u.ID)
+		s.Require().NoError(err)
+		return len(credits) == 1 && credits[0].OrderNumber == first.Number && credits[0].Amount == 70
+	}, time.Second, 10*time.Millisecond)
+
+	second := &entity.Order{UserID: u.ID, Number: "4716108999716531"}
+	s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, second))
+	s.processOrder(second, 10)
+	s.Require().Eventually(func() bool {
+		balance, err := s.cnt.Gophermart().GetBalance(ctx, u.ID)
+		s.Require().NoError(err)
+		return balance.Current == 80
+	}, time.Second, 10*time.Millisecond)
+	credits, err := s.cnt.OrderRepo().GetUserCampaignCredits(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Len(credits, 1)
+}
+
+func (s *GophermartTestSuite) TestConcurrentCampaignUserCap() {
+	ctx := context.Background()
+	admin := s.NewAdmin()
+	u := s.NewUser()
+	bonus, err := s.cnt.Admin().CreateCampaign(ctx, admin, &entity.CampaignRequest{
+		Name:    "+30 per order, up to 50",
+		Kind:    entity.CampaignKindFixed,
+		Value:   30,
+		UserCap: 50,
+		Enabled: true,
+	})
+	s.Require().NoError(err)
+	defer func() { s.Require().NoError(s.cnt.Admin().DeleteCampaign(ctx, admin, bonus.ID)) }()
+
+	orders := make([]*entity.Order, 4)
+	for i := range orders {
+		orders[i] = &entity.Order{
+			UserID:  u.ID,
+			Number:  "cap-" + strconv.Itoa(i),
+			Status:  entity.OrderStatusProcessed,
+			Accrual: utils.ToPointer(10.0),
+		}
+		s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, orders[i]))
+	}
+
+	// заказы одного пользователя обрабатываются одновременно
+	var wg sync.WaitGroup
+	for _, order := range orders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.cnt.Transactor().Transaction(ctx, func(ctx context.Context) error {
+				return s.cnt.CampaignEngine().OrderProcessed(ctx, order)
+			})
+			s.NoError(err)
+		}()
+	}
+	wg.Wait()
+
+	sum, err := s.cnt.OrderRepo().GetCampaignCreditsSum(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(50.0, sum)
+}
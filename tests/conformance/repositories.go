@@ -0,0 +1,1115 @@
+// Package conformance общий набор тестов репозиториев, который прогоняется на всех реализациях хранилища
+package conformance
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal"
+	"github.com/k-zavarnitsyn/gophermart/internal/pg"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/eventbus"
+	"github.com/k-zavarnitsyn/gophermart/internal/tenant"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/repository"
+	"github.com/stretchr/testify/suite"
+)
+
+// Backend набор репозиториев одной реализации хранилища
+type Backend struct {
+	Transactor pg.Transactor
+	Orders     repository.Order
+	Users      repository.User
+	Audit      repository.Audit
+	Campaigns  repository.Campaign
+	Referrals  repository.Referral
+	Events     eventbus.Bus
+	Archive    repository.AccrualArchive
+	Resetter   internal.Resetter
+}
+
+// RepositorySuite запускается через suite.Run(t, conformance.NewRepositorySuite(backend))
+type RepositorySuite struct {
+	suite.Suite
+
+	backend *Backend
+}
+
+func NewRepositorySuite(backend *Backend) *RepositorySuite {
+	return &RepositorySuite{backend: backend}
+}
+
+func (s *RepositorySuite) SetupTest() {
+	s.Require().NoError(s.backend.Resetter.Reset())
+}
+
+func (s *RepositorySuite) newUser(login string) *entity.User {
+	u := &entity.User{
+		ID:          uuid.Must(uuid.NewV6()),
+		Login:       login,
+		PasswordSHA: []byte("sha"),
+	}
+	s.Require().NoError(s.backend.Users.Insert(context.Background(), u))
+
+	return u
+}
+
+func (s *RepositorySuite) newOrder(userID uuid.UUID, number string, status entity.OrderStatus, accrual *float64) *entity.Order {
+	o := &entity.Order{UserID: userID, Number: number, Status: status, Accrual: accrual}
+	s.Require().NoError(s.backend.Orders.Insert(context.Background(), o))
+	s.Require().False(o.ID.IsNil())
+
+	return o
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
+
+func ptrString(v string) *string {
+	return &v
+}
+
+func (s *RepositorySuite) TestUserInsertAndFind() {
+	ctx := context.Background()
+	u := s.newUser("Alice")
+
+	found, err := s.backend.Users.FindByID(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal("Alice", found.Login)
+	s.Require().Equal(entity.UserRoleUser, found.Role)
+	s.Require().False(found.Blocked)
+
+	found, err = s.backend.Users.FindByLogin(ctx, "alice")
+	s.Require().NoError(err)
+	s.Require().Equal(u.ID, found.ID)
+
+	exists, err := s.backend.Users.LoginExists(ctx, "ALICE")
+	s.Require().NoError(err)
+	s.Require().True(exists)
+
+	exists, err = s.backend.Users.LoginExists(ctx, "bob")
+	s.Require().NoError(err)
+	s.Require().False(exists)
+
+	_, err = s.backend.Users.FindByID(ctx, uuid.Must(uuid.NewV6()))
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+	_, err = s.backend.Users.FindByLogin(ctx, "bob")
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+}
+
+func (s *RepositorySuite) TestUserLoginUniqueIgnoringCase() {
+	s.newUser("Alice")
+	err := s.backend.Users.Insert(context.Background(), &entity.User{
+		ID:          uuid.Must(uuid.NewV6()),
+		Login:       "aLiCe",
+		PasswordSHA: []byte("sha"),
+	})
+	s.Require().ErrorIs(err, domain.ErrLoginExists)
+}
+
+func (s *RepositorySuite) TestUserFindByLoginAndPassword() {
+	ctx := context.Background()
+	u := s.newUser("alice")
+
+	found, err := s.backend.Users.FindByLoginAndPassword(ctx, "ALICE", []byte("sha"))
+	s.Require().NoError(err)
+	s.Require().Equal(u.ID, found.ID)
+
+	_, err = s.backend.Users.FindByLoginAndPassword(ctx, "alice", []byte("wrong"))
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+}
+
+func (s *RepositorySuite) TestUserUpdates() {
+	ctx := context.Background()
+	u := s.newUser("alice")
+
+	s.Require().NoError(s.backend.Users.SetBlocked(ctx, u.ID, true))
+	s.Require().NoError(s.backend.Users.SetRole(ctx, u.ID, entity.UserRoleAdmin))
+	version, err := s.backend.Users.UpdatePassword(ctx, u.ID, []byte("new"))
+	s.Require().NoError(err)
+	s.Require().Equal(1, version)
+
+	found, err := s.backend.Users.FindByID(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().True(found.Blocked)
+	s.Require().Equal(entity.UserRoleAdmin, found.Role)
+	s.Require().Equal([]byte("new"), found.PasswordSHA)
+	s.Require().Equal(1, found.SessionVersion)
+
+	missing := uuid.Must(uuid.NewV6())
+	s.Require().ErrorIs(s.backend.Users.SetBlocked(ctx, missing, true), domain.ErrNotFound)
+	s.Require().ErrorIs(s.backend.Users.SetRole(ctx, missing, entity.UserRoleAdmin), domain.ErrNotFound)
+	_, err = s.backend.Users.UpdatePassword(ctx, missing, []byte("new"))
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+}
+
+func (s *RepositorySuite) TestUserAnonymize() {
+	ctx := context.Background()
+	u := s.newUser("alice")
+
+	s.Require().NoError(s.backend.Users.Anonymize(ctx, u.ID, "deleted:"+u.ID.String()))
+	s.Require().ErrorIs(s.backend.Users.Anonymize(ctx, u.ID, "again"), domain.ErrNotFound)
+	_, err := s.backend.Users.UpdatePassword(ctx, u.ID, []byte("new"))
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+
+	found, err := s.backend.Users.FindByID(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().NotNil(found.DeletedAt)
+	s.Require().Equal("deleted:"+u.ID.String(), found.Login)
+	s.Require().Empty(found.PasswordSHA)
+	s.Require().Equal(1, found.SessionVersion)
+
+	// логин освобождается для новой регистрации
+	exists, err := s.backend.Users.LoginExists(ctx, "alice")
+	s.Require().NoError(err)
+	s.Require().False(exists)
+	s.newUser("alice")
+}
+
+func (s *RepositorySuite) TestUserList() {
+	s.newUser("bob")
+	s.newUser("alice")
+
+	users, err := s.backend.Users.List(context.Background())
+	s.Require().NoError(err)
+	s.Require().Len(users, 2)
+	s.Require().Equal("alice", users[0].Login)
+	s.Require().Equal("bob", users[1].Login)
+}
+
+func (s *RepositorySuite) TestOrderInsertDuplicate() {
+	ctx := context.Background()
+	u1 := s.newUser("alice")
+	u2 := s.newUser("bob")
+	s.newOrder(u1.ID, "3413042486", entity.OrderStatusNew, nil)
+
+	err := s.backend.Orders.Insert(ctx, &entity.Order{UserID: u1.ID, Number: "3413042486", Status: entity.OrderStatusNew})
+	s.Require().ErrorIs(err, domain.ErrOrderCreatedByCurrentUser)
+	err = s.backend.Orders.Insert(ctx, &entity.Order{UserID: u2.ID, Number: "3413042486", Status: entity.OrderStatusNew})
+	s.Require().ErrorIs(err, domain.ErrOrderCreatedByOtherUser)
+}
+
+func (s *RepositorySuite) TestOrderFindAndUpdate() {
+	ctx := context.Background()
+	u := s.newUser("alice")
+	o := s.newOrder(u.ID, "3413042486", entity.OrderStatusNew, nil)
+
+	found, err := s.backend.Orders.FindByNumber(ctx, o.Number)
+	s.Require().NoError(err)
+	s.Require().Equal(o.ID, found.ID)
+	s.Require().Equal(entity.OrderStatusNew, found.Status)
+	s.Require().False(found.CreatedAt.IsZero())
+	s.Require().Nil(found.Accrual)
+
+	_, err = s.backend.Orders.FindByNumber(ctx, "5798116405")
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+
+	updated, err := s.backend.Orders.SetOrderStatus(ctx, o.Number, entity.OrderStatusProcessing, entity.OrderSourceAccrual, nil)
+	s.Require().NoError(err)
+	s.Require().True(updated)
+	found, err = s.backend.Orders.FindByNumber(ctx, o.Number)
+	s.Require().NoError(err)
+	s.Require().Equal(entity.OrderStatusProcessing, found.Status)
+
+	found.Status = entity.OrderStatusProcessed
+	found.Accrual = ptr(100.5)
+	updated, err = s.backend.Orders.UpdateAttributes(ctx, found, entity.OrderSourceAccrual, nil)
+	s.Require().NoError(err)
+	s.Require().True(updated)
+	found, err = s.backend.Orders.FindByNumber(ctx, o.Number)
+	s.Require().NoError(err)
+	s.Require().Equal(entity.OrderStatusProcessed, found.Status)
+	s.Require().Equal(100.5, *found.Accrual)
+	s.Require().NotNil(found.ProcessedAt)
+
+	_, err = s.backend.Orders.UpdateAttributes(ctx, &entity.Order{Number: o.Number}, entity.OrderSourceAccrual, nil)
+	s.Require().Error(err)
+}
+
+func (s *RepositorySuite) TestTenantIsolation() {
+	shopA := tenant.ToContext(context.Background(), "shop-a")
+	shopB := tenant.ToContext(context.Background(), "shop-b")
+	alice := &entity.User{ID: uuid.Must(uuid.NewV6()), Login: "alice", PasswordSHA: []byte("sha")}
+	s.Require().NoError(s.backend.Users.Insert(shopA, alice))
+	s.Require().Equal("shop-a", alice.TenantID)
+	bob := &entity.User{ID: uuid.Must(uuid.NewV6()), Login: "bob", PasswordSHA: []byte("sha")}
+	s.Require().NoError(s.backend.Users.Insert(shopB, bob))
+
+	// логин уникален только в пределах тенанта
+	exists, err := s.backend.Users.LoginExists(shopB, "alice")
+	s.Require().NoError(err)
+	s.Require().False(exists)
+	_, err = s.backend.Users.FindByID(shopB, alice.ID)
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+	_, err = s.backend.Users.FindByLogin(shopB, "alice")
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+	err = s.backend.Users.Insert(shopA, &entity.User{ID: uuid.Must(uuid.NewV6()), Login: "ALICE", PasswordSHA: []byte("sha")})
+	s.Require().ErrorIs(err, domain.ErrLoginExists)
+	aliceB := &entity.User{ID: uuid.Must(uuid.NewV6()), Login: "Alice", PasswordSHA: []byte("sha")}
+	s.Require().NoError(s.backend.Users.Insert(shopB, aliceB))
+	byLogin, err := s.backend.Users.FindByLogin(shopB, "alice")
+	s.Require().NoError(err)
+	s.Require().Equal(aliceB.ID, byLogin.ID)
+	byLogin, err = s.backend.Users.FindByLoginAndPassword(shopA, "alice", []byte("sha"))
+	s.Require().NoError(err)
+	s.Require().Equal(alice.ID, byLogin.ID)
+	users, err := s.backend.Users.List(shopA)
+	s.Require().NoError(err)
+	s.Require().Len(users, 1)
+
+	// номер заказа уникален только в пределах тенанта
+	s.Require().NoError(s.backend.Orders.Insert(shopA, &entity.Order{UserID: alice.ID, Number: "3413042486", Status: entity.OrderStatusProcessed, Accrual: ptr(100)}))
+	s.Require().NoError(s.backend.Orders.Insert(shopB, &entity.Order{UserID: bob.ID, Number: "3413042486", Status: entity.OrderStatusProcessed, Accrual: ptr(10)}))
+	err = s.backend.Orders.Insert(shopB, &entity.Order{UserID: bob.ID, Number: "3413042486", Status: entity.OrderStatusNew})
+	s.Require().ErrorIs(err, domain.ErrOrderCreatedByCurrentUser)
+
+	found, err := s.backend.Orders.FindByNumber(shopA, "3413042486")
+	s.Require().NoError(err)
+	s.Require().Equal(alice.ID, found.UserID)
+	s.Require().Equal("shop-a", found.TenantID)
+	found, err = s.backend.Orders.FindByNumber(shopB, "3413042486")
+	s.Require().NoError(err)
+	s.Require().Equal(bob.ID, found.UserID)
+	_, err = s.backend.Orders.FindByNumber(context.Background(), "3413042486")
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+
+	sum, err := s.backend.Orders.GetAccrualsSum(shopA, alice.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(100.0, sum)
+	sum, err = s.backend.Orders.GetAccrualsSum(shopB, alice.ID)
+	s.Require().NoError(err)
+	s.Require().Zero(sum)
+
+	s.Require().NoError(s.backend.Orders.Withdraw(shopA, &entity.Withdraw{UserID: alice.ID, OrderNumber: "2377225624", Value: 30}))
+	withdrawals, err := s.backend.Orders.GetUserWithdrawals(shopA, alice.ID)
+	s.Require().NoError(err)
+	s.Require().Len(withdrawals, 1)
+	s.Require().Equal("shop-a", withdrawals[0].TenantID)
+	withdrawals, err = s.backend.Orders.GetUserWithdrawals(shopB, alice.ID)
+	s.Require().NoError(err)
+	s.Require().Empty(withdrawals)
+}
+
+func (s *RepositorySuite) TestCampaignAndAuditTenantIsolation() {
+	shopA := tenant.ToContext(context.Background(), "shop-a")
+	shopB := tenant.ToContext(context.Background(), "shop-b")
+	weekend := &entity.Campaign{Name: "weekend", Kind: entity.CampaignKindMultiplier, Value: 2, Enabled: true}
+	s.Require().NoError(s.backend.Campaigns.Insert(shopA, weekend))
+	s.Require().Equal("shop-a", weekend.TenantID)
+
+	campaigns, err := s.backend.Campaigns.List(shopB)
+	s.Require().NoError(err)
+	s.Require().Empty(campaigns)
+	_, err = s.backend.Campaigns.FindByID(shopB, weekend.ID)
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+	s.Require().ErrorIs(s.backend.Campaigns.Update(shopB, &entity.Campaign{ID: weekend.ID, Name: "stolen"}), domain.ErrNotFound)
+	s.Require().ErrorIs(s.backend.Campaigns.Delete(shopB, weekend.ID), domain.ErrNotFound)
+
+	weekend.Value = 3
+	s.Require().NoError(s.backend.Campaigns.Update(shopA, weekend))
+	found, err := s.backend.Campaigns.FindByID(shopA, weekend.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(3.0, found.Value)
+	s.Require().Equal("shop-a", found.TenantID)
+
+	s.Require().NoError(s.backend.Audit.Insert(shopA, &entity.AuditRecord{
+		ActorID: uuid.Must(uuid.NewV6()), ActorLogin: "admin-a", Action: "block_user", Target: "alice", Details: []byte(`{}`),
+	}))
+	s.Require().NoError(s.backend.Audit.Insert(shopB, &entity.AuditRecord{
+		ActorID: uuid.Must(uuid.NewV6()), ActorLogin: "admin-b", Action: "block_user", Target: "bob", Details: []byte(`{}`),
+	}))
+	records, err := s.backend.Audit.GetLast(shopA, 10)
+	s.Require().NoError(err)
+	s.Require().Len(records, 1)
+	s.Require().Equal("admin-a", records[0].ActorLogin)
+	s.Require().Equal("shop-a", records[0].TenantID)
+	records, err = s.backend.Audit.GetLast(context.Background(), 10)
+	s.Require().NoError(err)
+	s.Require().Empty(records)
+}
+
+func (s *RepositorySuite) TestLedgerTenantIsolation() {
+	shopA := tenant.ToContext(context.Background(), "shop-a")
+	shopB := tenant.ToContext(context.Background(), "shop-b")
+	alice := &entity.User{ID: uuid.Must(uuid.NewV6()), Login: "alice", PasswordSHA: []byte("sha")}
+	s.Require().NoError(s.backend.Users.Insert(shopA, alice))
+	bob := &entity.User{ID: uuid.Must(uuid.NewV6()), Login: "bob", PasswordSHA: []byte("sha")}
+	s.Require().NoError(s.backend.Users.Insert(shopA, bob))
+	campaignID := uuid.Must(uuid.NewV6())
+
+	adjustment := &entity.BalanceAdjustment{UserID: alice.ID, Amount: 5, Reason: "fix", CreatedBy: bob.ID,
+		OrderNumber: ptrString("3413042486"), NeedsReview: true}
+	s.Require().NoError(s.backend.Orders.AddBalanceAdjustment(shopA, adjustment))
+	s.Require().Equal("shop-a", adjustment.TenantID)
+	s.Require().NoError(s.backend.Orders.AddExpiry(shopA, &entity.PointsExpiry{UserID: alice.ID, OrderNumber: "3413042486",
+		Value: 2, ExpiredAt: time.Now().Add(-time.Minute)}))
+	s.Require().NoError(s.backend.Orders.AddCampaignCredit(shopA, &entity.CampaignCredit{CampaignID: campaignID,
+		UserID: alice.ID, OrderNumber: "3413042486", Amount: 3}))
+	transfer := &entity.Transfer{SenderID: alice.ID, RecipientID: bob.ID, Amount: 1, IdempotencyKey: "key"}
+	s.Require().NoError(s.backend.Orders.AddTransfer(shopA, transfer))
+	s.Require().Equal("shop-a", transfer.TenantID)
+	s.Require().NoError(s.backend.Referrals.Insert(shopA, &entity.Referral{ReferrerID: alice.ID, RefereeID: bob.ID}))
+
+	sum, err := s.backend.Orders.GetAdjustmentsSum(shopA, alice.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(5.0, sum)
+	sum, err = s.backend.Orders.GetTransfersSum(shopA, bob.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(1.0, sum)
+
+	// в чужом тенанте записи ленты баланса не видны ни по пользователю, ни по номеру заказа
+	sums := map[string]func(ctx context.Context, id uuid.UUID) (float64, error){
+		"adjustments": s.backend.Orders.GetAdjustmentsSum,
+		"expired":     s.backend.Orders.GetExpiredSum,
+		"campaigns":   s.backend.Orders.GetCampaignCreditsSum,
+		"transfers":   s.backend.Orders.GetTransfersSum,
+	}
+	for name, get := range sums {
+		sum, err = get(shopB, alice.ID)
+		s.Require().NoError(err, name)
+		s.Require().Zero(sum, name)
+	}
+	sum, err = s.backend.Orders.GetOrderAdjustmentsSum(shopB, "3413042486")
+	s.Require().NoError(err)
+	s.Require().Zero(sum)
+	adjustments, err := s.backend.Orders.GetUserAdjustments(shopB, alice.ID)
+	s.Require().NoError(err)
+	s.Require().Empty(adjustments)
+	adjustments, err = s.backend.Orders.GetAdjustmentsForReview(shopB)
+	s.Require().NoError(err)
+	s.Require().Empty(adjustments)
+	reviewed, err := s.backend.Orders.MarkAdjustmentReviewed(shopB, adjustment.ID)
+	s.Require().NoError(err)
+	s.Require().False(reviewed)
+	expirations, err := s.backend.Orders.GetUserExpirations(shopB, alice.ID)
+	s.Require().NoError(err)
+	s.Require().Empty(expirations)
+	credits, err := s.backend.Orders.GetUserCampaignCredits(shopB, alice.ID)
+	s.Require().NoError(err)
+	s.Require().Empty(credits)
+	transfers, err := s.backend.Orders.GetUserTransfers(shopB, alice.ID)
+	s.Require().NoError(err)
+	s.Require().Empty(transfers)
+	_, err = s.backend.Orders.FindTransferByKey(shopB, alice.ID, "key")
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+	count, _, err := s.backend.Orders.GetSentTransfersSince(shopB, alice.ID, time.Now().Add(-time.Hour))
+	s.Require().NoError(err)
+	s.Require().Zero(count)
+	var entries int
+	s.Require().NoError(s.backend.Orders.StreamStatement(shopB, alice.ID, time.Now().Add(time.Hour), func(*entity.StatementEntry) error {
+		entries++
+		return nil
+	}))
+	s.Require().Zero(entries)
+
+	_, err = s.backend.Referrals.FindByReferee(shopB, bob.ID)
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+	referrals, err := s.backend.Referrals.GetUserReferrals(shopB, alice.ID)
+	s.Require().NoError(err)
+	s.Require().Empty(referrals)
+	referral, err := s.backend.Referrals.FindByReferee(shopA, bob.ID)
+	s.Require().NoError(err)
+	s.Require().Equal("shop-a", referral.TenantID)
+	referral.OrderNumber = ptrString("3413042486")
+	rewarded, err := s.backend.Referrals.MarkRewarded(shopB, referral)
+	s.Require().NoError(err)
+	s.Require().False(rewarded)
+	rewarded, err = s.backend.Referrals.MarkRewarded(shopA, referral)
+	s.Require().NoError(err)
+	s.Require().True(rewarded)
+	count, err = s.backend.Referrals.CountRewarded(shopB, alice.ID)
+	s.Require().NoError(err)
+	s.Require().Zero(count)
+}
+
+func (s *RepositorySuite) TestAccrualArchive() {
+	ctx := context.Background()
+	shopB := tenant.ToContext(ctx, "shop-b")
+	quarantined := &entity.AccrualResponse{OrderNumber: "3413042486", StatusCode: 200, Payload: []byte("not json"),
+		Error: ptrString("bad accrual response")}
+	s.Require().NoError(s.backend.Archive.Insert(ctx, quarantined))
+	s.Require().False(quarantined.ID.IsNil())
+	s.Require().NoError(s.backend.Archive.Insert(ctx, &entity.AccrualResponse{OrderNumber: "3413042486", StatusCode: 204}))
+	s.Require().NoError(s.backend.Archive.Insert(ctx, &entity.AccrualResponse{OrderNumber: "5798116405", StatusCode: 200,
+		Payload: []byte(`{"order":"5798116405","status":"PROCESSED","accrual":10}`)}))
+	s.Require().NoError(s.backend.Archive.Insert(shopB, &entity.AccrualResponse{OrderNumber: "3413042486", StatusCode: 200,
+		Payload: []byte("{}"), Error: ptrString("bad accrual response")}))
+
+	responses, err := s.backend.Archive.GetOrderResponses(ctx, "3413042486")
+	s.Require().NoError(err)
+	s.Require().Len(responses, 2)
+	s.Require().Equal([]byte("not json"), responses[0].Payload)
+	s.Require().Equal("bad accrual response", *responses[0].Error)
+	s.Require().Equal(tenant.Default, responses[0].TenantID)
+	s.Require().False(responses[0].CreatedAt.IsZero())
+	s.Require().Equal(204, responses[1].StatusCode)
+	s.Require().Empty(responses[1].Payload)
+	s.Require().Nil(responses[1].Error)
+
+	list, err := s.backend.Archive.GetQuarantined(ctx, 10)
+	s.Require().NoError(err)
+	s.Require().Len(list, 1)
+	s.Require().Equal(quarantined.ID, list[0].ID)
+	list, err = s.backend.Archive.GetQuarantined(shopB, 10)
+	s.Require().NoError(err)
+	s.Require().Len(list, 1)
+
+	deleted, err := s.backend.Archive.DeleteBefore(ctx, time.Now().Add(-time.Hour))
+	s.Require().NoError(err)
+	s.Require().Zero(deleted)
+	// очистка выполняется по тенантам, ответы другого магазина остаются
+	deleted, err = s.backend.Archive.DeleteBefore(ctx, time.Now().Add(time.Hour))
+	s.Require().NoError(err)
+	s.Require().Equal(int64(3), deleted)
+	responses, err = s.backend.Archive.GetOrderResponses(ctx, "3413042486")
+	s.Require().NoError(err)
+	s.Require().Empty(responses)
+	list, err = s.backend.Archive.GetQuarantined(shopB, 10)
+	s.Require().NoError(err)
+	s.Require().Len(list, 1)
+	deleted, err = s.backend.Archive.DeleteBefore(shopB, time.Now().Add(time.Hour))
+	s.Require().NoError(err)
+	s.Require().Equal(int64(1), deleted)
+}
+
+func (s *RepositorySuite) TestEventBus() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// топик уникален для запуска: подписки в Postgres переживают Reset
+	topic := "conformance." + uuid.Must(uuid.NewV6()).String()
+
+	received := make(chan string, 10)
+	var failures atomic.Int32
+	s.Require().NoError(s.backend.Events.Subscribe(ctx, topic, "first", func(ctx context.Context, e *eventbus.Event) error {
+		// первая доставка отклоняется и приходит повторно
+		if e.Attempt == 1 {
+			failures.Add(1)
+			return errors.New("temporary")
+		}
+		event, err := eventbus.Decode[entity.OrderEvent](e)
+		if err != nil {
+			return err
+		}
+		received <- "first:" + event.Number
+		return nil
+	}))
+	s.Require().NoError(s.backend.Events.Subscribe(ctx, topic, "second", func(ctx context.Context, e *eventbus.Event) error {
+		event, err := eventbus.Decode[entity.OrderEvent](e)
+		if err != nil {
+			return err
+		}
+		received <- "second:" + event.Number
+		return nil
+	}))
+	s.Require().NoError(s.backend.Events.Publish(ctx, topic, &entity.OrderEvent{Number: "3413042486"}))
+
+	var got []string
+	s.Require().Eventually(func() bool {
+		select {
+		case v := <-received:
+			got = append(got, v)
+		default:
+		}
+		return len(got) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Require().ElementsMatch([]string{"first:3413042486", "second:3413042486"}, got)
+	s.Require().Equal(int32(1), failures.Load())
+	s.Require().Never(func() bool { return len(received) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+}
+
+func (s *RepositorySuite) TestEventBusTransaction() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	topic := "conformance." + uuid.Must(uuid.NewV6()).String()
+
+	received := make(chan string, 10)
+	s.Require().NoError(s.backend.Events.Subscribe(ctx, topic, "group", func(ctx context.Context, e *eventbus.Event) error {
+		event, err := eventbus.Decode[entity.OrderEvent](e)
+		if err != nil {
+			return err
+		}
+		received <- event.Number
+		return nil
+	}))
+
+	rollback := errors.New("rollback")
+	err := s.backend.Transactor.Transaction(ctx, func(ctx context.Context) error {
+		s.Require().NoError(s.backend.Events.Publish(ctx, topic, &entity.OrderEvent{Number: "3413042486"}))
+		return rollback
+	})
+	s.Require().ErrorIs(err, rollback)
+	s.Require().NoError(s.backend.Transactor.Transaction(ctx, func(ctx context.Context) error {
+		return s.backend.Events.Publish(ctx, topic, &entity.OrderEvent{Number: "5798116405"})
+	}))
+
+	// событие из откаченной транзакции не доставляется
+	select {
+	case number := <-received:
+		s.Require().Equal("5798116405", number)
+	case <-time.After(5 * time.Second):
+		s.Fail("event was not delivered")
+	}
+	s.Require().Never(func() bool { return len(received) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+}
+
+func (s *RepositorySuite) TestEventBusDeleteDelivered() {
+	cleaner, ok := s.backend.Events.(eventbus.Cleaner)
+	if !ok {
+		s.T().Skip("event bus does not store delivered events")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	topic := "conformance." + uuid.Must(uuid.NewV6()).String()
+
+	acked := make(chan struct{}, 10)
+	s.Require().NoError(s.backend.Events.Subscribe(ctx, topic, "acking", func(ctx context.Context, e *eventbus.Event) error {
+		acked <- struct{}{}
+		return nil
+	}))
+	s.Require().NoError(s.backend.Events.Publish(ctx, topic, &entity.OrderEvent{Number: "3413042486"}))
+	select {
+	case <-acked:
+	case <-time.After(5 * time.Second):
+		s.Fail("event was not delivered")
+	}
+
+	// доставка подтверждается после возврата обработчика
+	var deleted int64
+	s.Require().Eventually(func() bool {
+		var err error
+		deleted, err = cleaner.DeleteDelivered(ctx, time.Now().Add(time.Minute))
+		s.Require().NoError(err)
+		return deleted > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Require().Equal(int64(1), deleted)
+
+	// событие с неподтверждённой доставкой остаётся
+	s.Require().NoError(s.backend.Events.Subscribe(ctx, topic, "failing", func(ctx context.Context, e *eventbus.Event) error {
+		return errors.New("temporary")
+	}))
+	s.Require().NoError(s.backend.Events.Publish(ctx, topic, &entity.OrderEvent{Number: "5798116405"}))
+	select {
+	case <-acked:
+	case <-time.After(5 * time.Second):
+		s.Fail("event was not delivered")
+	}
+	s.Require().Never(func() bool {
+		deleted, err := cleaner.DeleteDelivered(ctx, time.Now().Add(time.Minute))
+		s.Require().NoError(err)
+		return deleted > 0
+	}, 100*time.Millisecond, 10*time.Millisecond)
+	deleted, err := cleaner.DeleteDelivered(ctx, time.Now().Add(-time.Minute))
+	s.Require().NoError(err)
+	s.Require().Zero(deleted)
+}
+
+func (s *RepositorySuite) TestOrderStatusHistory() {
+	ctx := context.Background()
+	u := s.newUser("alice")
+	o := s.newOrder(u.ID, "3413042486", entity.OrderStatusNew, nil)
+
+	updated, err := s.backend.Orders.SetOrderStatus(ctx, o.Number, entity.OrderStatusProcessing, entity.OrderSourceAccrual,
+		[]byte(`{"order":"3413042486","status":"PROCESSING"}`))
+	s.Require().NoError(err)
+	s.Require().True(updated)
+
+	o.Status = entity.OrderStatusProcessed
+	o.Accrual = ptr(50)
+	updated, err = s.backend.Orders.UpdateAttributes(ctx, o, entity.OrderSourceAccrual, nil)
+	s.Require().NoError(err)
+	s.Require().True(updated)
+
+	// опоздавший ответ не возвращает обработанный заказ назад
+	updated, err = s.backend.Orders.SetOrderStatus(ctx, o.Number, entity.OrderStatusProcessing, entity.OrderSourceAccrual, nil)
+	s.Require().NoError(err)
+	s.Require().False(updated)
+	o.Status = entity.OrderStatusInvalid
+	updated, err = s.backend.Orders.UpdateAttributes(ctx, o, entity.OrderSourceAccrual, nil)
+	s.Require().NoError(err)
+	s.Require().False(updated)
+
+	found, err := s.backend.Orders.FindByNumber(ctx, o.Number)
+	s.Require().NoError(err)
+	s.Require().Equal(entity.OrderStatusProcessed, found.Status)
+	s.Require().Equal(50.0, *found.Accrual)
+
+	history, err := s.backend.Orders.GetOrderStatusHistory(ctx, o.ID)
+	s.Require().NoError(err)
+	s.Require().Len(history, 3)
+	s.Require().Nil(history[0].FromStatus)
+	s.Require().Equal(entity.OrderStatusNew, history[0].ToStatus)
+	s.Require().Equal(entity.OrderSourceUser, history[0].Source)
+	s.Require().Equal(entity.OrderStatusNew, *history[1].FromStatus)
+	s.Require().Equal(entity.OrderStatusProcessing, history[1].ToStatus)
+	s.Require().JSONEq(`{"order":"3413042486","status":"PROCESSING"}`, string(history[1].Payload))
+	s.Require().Equal(entity.OrderStatusProcessing, *history[2].FromStatus)
+	s.Require().Equal(entity.OrderStatusProcessed, history[2].ToStatus)
+	s.Require().Nil(history[2].Payload)
+}
+
+func (s *RepositorySuite) TestBalanceSums() {
+	ctx := context.Background()
+	u := s.newUser("alice")
+	other := s.newUser("bob")
+	s.newOrder(u.ID, "3413042486", entity.OrderStatusProcessed, ptr(100))
+	s.newOrder(u.ID, "5798116405", entity.OrderStatusProcessed, ptr(50))
+	s.newOrder(u.ID, "9155976989", entity.OrderStatusProcessing, ptr(1000))
+	s.newOrder(other.ID, "1587579366", entity.OrderStatusProcessed, ptr(10))
+
+	sum, err := s.backend.Orders.GetAccrualsSum(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(150.0, sum)
+
+	s.Require().NoError(s.backend.Orders.Withdraw(ctx, &entity.Withdraw{UserID: u.ID, OrderNumber: "2377225624", Value: 30}))
+	s.Require().NoError(s.backend.Orders.Withdraw(ctx, &entity.Withdraw{UserID: other.ID, OrderNumber: "2377225624", Value: 5}))
+	sum, err = s.backend.Orders.GetWithdrawnSum(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(30.0, sum)
+
+	withdrawals, err := s.backend.Orders.GetUserWithdrawals(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Len(withdrawals, 1)
+	s.Require().Equal("2377225624", withdrawals[0].OrderNumber)
+	s.Require().False(withdrawals[0].CreatedAt.IsZero())
+
+	s.Require().NoError(s.backend.Orders.AddBalanceAdjustment(ctx, &entity.BalanceAdjustment{UserID: u.ID, Amount: -20, Reason: "fix", CreatedBy: other.ID}))
+	s.Require().NoError(s.backend.Orders.AddBalanceAdjustment(ctx, &entity.BalanceAdjustment{UserID: u.ID, Amount: 5, Reason: "fix", CreatedBy: other.ID}))
+	sum, err = s.backend.Orders.GetAdjustmentsSum(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(-15.0, sum)
+
+	empty := s.newUser("carol")
+	for _, get := range []func(context.Context, uuid.UUID) (float64, error){
+		s.backend.Orders.GetAccrualsSum,
+		s.backend.Orders.GetWithdrawnSum,
+		s.backend.Orders.GetAdjustmentsSum,
+	} {
+		sum, err = get(ctx, empty.ID)
+		s.Require().NoError(err)
+		s.Require().Zero(sum)
+	}
+}
+
+func (s *RepositorySuite) TestAccrualReverification() {
+	ctx := context.Background()
+	u := s.newUser("alice")
+	now := time.Now().Truncate(time.Millisecond)
+	insert := func(number string, status entity.OrderStatus, processedAt time.Time) *entity.Order {
+		o := &entity.Order{UserID: u.ID, Number: number, Status: status, Accrual: ptr(100), ProcessedAt: &processedAt}
+		s.Require().NoError(s.backend.Orders.Insert(ctx, o))
+		return o
+	}
+	older := insert("3413042486", entity.OrderStatusProcessed, now.Add(-3*time.Hour))
+	newer := insert("5798116405", entity.OrderStatusProcessed, now.Add(-2*time.Hour))
+	insert("9155976989", entity.OrderStatusProcessed, now.AddDate(0, 0, -40))
+	insert("1587579366", entity.OrderStatusInvalid, now.Add(-3*time.Hour))
+
+	numbers := func(orders []entity.Order) []string {
+		result := make([]string, len(orders))
+		for i, o := range orders {
+			result[i] = o.Number
+		}
+		return result
+	}
+	orders, err := s.backend.Orders.GetOrdersToReverify(ctx, now.AddDate(0, 0, -30), now.Add(-time.Hour), 10)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{older.Number, newer.Number}, numbers(orders))
+
+	// сверенный заказ уходит в конец очереди, а пока сверка свежая - не выбирается
+	s.Require().NoError(s.backend.Orders.SetVerifiedAt(ctx, older.ID, now.Add(-90*time.Minute)))
+	orders, err = s.backend.Orders.GetOrdersToReverify(ctx, now.AddDate(0, 0, -30), now.Add(-time.Hour), 10)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{newer.Number, older.Number}, numbers(orders))
+	orders, err = s.backend.Orders.GetOrdersToReverify(ctx, now.AddDate(0, 0, -30), now.Add(-100*time.Minute), 1)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{newer.Number}, numbers(orders))
+
+	s.Require().NoError(s.backend.Orders.AddBalanceAdjustment(ctx, &entity.BalanceAdjustment{
+		UserID: u.ID, Amount: 20, Reason: "correction", OrderNumber: &older.Number,
+	}))
+	flagged := &entity.BalanceAdjustment{UserID: u.ID, Amount: -50, Reason: "correction", OrderNumber: &older.Number, NeedsReview: true}
+	s.Require().NoError(s.backend.Orders.AddBalanceAdjustment(ctx, flagged))
+	s.Require().NoError(s.backend.Orders.AddBalanceAdjustment(ctx, &entity.BalanceAdjustment{UserID: u.ID, Amount: 7, Reason: "manual"}))
+	sum, err := s.backend.Orders.GetOrderAdjustmentsSum(ctx, older.Number)
+	s.Require().NoError(err)
+	s.Require().Equal(-30.0, sum)
+
+	review, err := s.backend.Orders.GetAdjustmentsForReview(ctx)
+	s.Require().NoError(err)
+	s.Require().Len(review, 1)
+	s.Require().Equal(flagged.ID, review[0].ID)
+	s.Require().Equal(older.Number, *review[0].OrderNumber)
+	reviewed, err := s.backend.Orders.MarkAdjustmentReviewed(ctx, flagged.ID)
+	s.Require().NoError(err)
+	s.Require().True(reviewed)
+	reviewed, err = s.backend.Orders.MarkAdjustmentReviewed(ctx, flagged.ID)
+	s.Require().NoError(err)
+	s.Require().False(reviewed)
+	review, err = s.backend.Orders.GetAdjustmentsForReview(ctx)
+	s.Require().NoError(err)
+	s.Require().Empty(review)
+}
+
+func (s *RepositorySuite) TestWithdrawalRefund() {
+	ctx := context.Background()
+	u := s.newUser("alice")
+	s.Require().NoError(s.backend.Orders.Withdraw(ctx, &entity.Withdraw{UserID: u.ID, OrderNumber: "2377225624", Value: 30}))
+
+	_, err := s.backend.Orders.FindWithdrawalByNumber(ctx, "3413042486")
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+	w, err := s.backend.Orders.FindWithdrawalByNumber(ctx, "2377225624")
+	s.Require().NoError(err)
+	s.Require().Nil(w.Refunded)
+	s.Require().Equal(entity.WithdrawalStatusCompleted, w.Status())
+
+	ok, err := s.backend.Orders.RefundWithdrawal(ctx, w.ID, 10)
+	s.Require().NoError(err)
+	s.Require().True(ok)
+	// второй возврат по тому же списанию не применяется
+	ok, err = s.backend.Orders.RefundWithdrawal(ctx, w.ID, 20)
+	s.Require().NoError(err)
+	s.Require().False(ok)
+
+	w, err = s.backend.Orders.FindWithdrawalByNumber(ctx, "2377225624")
+	s.Require().NoError(err)
+	s.Require().Equal(10.0, *w.Refunded)
+	s.Require().NotNil(w.RefundedAt)
+	s.Require().Equal(entity.WithdrawalStatusPartiallyRefunded, w.Status())
+	sum, err := s.backend.Orders.GetWithdrawnSum(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(20.0, sum)
+
+	// по номеру с несколькими списаниями нельзя понять, какое из них возвращать
+	s.Require().NoError(s.backend.Orders.Withdraw(ctx, &entity.Withdraw{UserID: u.ID, OrderNumber: "2377225624", Value: 5}))
+	_, err = s.backend.Orders.FindWithdrawalByNumber(ctx, "2377225624")
+	s.Require().ErrorIs(err, domain.ErrAmbiguousWithdrawal)
+}
+
+func (s *RepositorySuite) TestExpirations() {
+	ctx := context.Background()
+	u := s.newUser("alice")
+	expiredAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s.Require().NoError(s.backend.Orders.AddExpiry(ctx, &entity.PointsExpiry{UserID: u.ID, OrderNumber: "3413042486", Value: 10, ExpiredAt: expiredAt}))
+	// повторное сгорание того же заказа игнорируется
+	s.Require().NoError(s.backend.Orders.AddExpiry(ctx, &entity.PointsExpiry{UserID: u.ID, OrderNumber: "3413042486", Value: 10, ExpiredAt: expiredAt}))
+	s.Require().NoError(s.backend.Orders.AddExpiry(ctx, &entity.PointsExpiry{UserID: u.ID, OrderNumber: "5798116405", Value: 5, ExpiredAt: expiredAt.Add(time.Hour)}))
+
+	sum, err := s.backend.Orders.GetExpiredSum(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(15.0, sum)
+
+	expirations, err := s.backend.Orders.GetUserExpirations(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"3413042486", "5798116405"}, []string{expirations[0].OrderNumber, expirations[1].OrderNumber})
+	s.Require().True(expiredAt.Equal(expirations[0].ExpiredAt))
+
+	s.Require().NoError(s.backend.Orders.AddBalanceAdjustment(ctx, &entity.BalanceAdjustment{UserID: u.ID, Amount: 5, Reason: "fix", CreatedBy: u.ID}))
+	adjustments, err := s.backend.Orders.GetUserAdjustments(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Len(adjustments, 1)
+}
+
+func (s *RepositorySuite) TestLoyaltyTier() {
+	ctx := context.Background()
+	u := s.newUser("alice")
+	now := time.Now()
+	old := now.AddDate(-2, 0, 0)
+	s.Require().NoError(s.backend.Orders.Insert(ctx, &entity.Order{
+		UserID: u.ID, Number: "5798116405", Status: entity.OrderStatusProcessed, Accrual: ptr(1000), ProcessedAt: &old,
+	}))
+	o := s.newOrder(u.ID, "3413042486", entity.OrderStatusProcessing, nil)
+	o.Status = entity.OrderStatusProcessed
+	o.Accrual = ptr(100)
+	o.Bonus = ptr(10)
+	updated, err := s.backend.Orders.UpdateAttributes(ctx, o, entity.OrderSourceAccrual, nil)
+	s.Require().NoError(err)
+	s.Require().True(updated)
+
+	found, err := s.backend.Orders.FindByNumber(ctx, o.Number)
+	s.Require().NoError(err)
+	s.Require().Equal(10.0, *found.Bonus)
+
+	// в окно уровня идёт только сырое начисление, бонус входит лишь в баланс
+	sum, err := s.backend.Orders.GetAccrualsSumSince(ctx, u.ID, now.AddDate(-1, 0, 0))
+	s.Require().NoError(err)
+	s.Require().Equal(100.0, sum)
+	sum, err = s.backend.Orders.GetAccrualsSum(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(1110.0, sum)
+
+	s.Require().NoError(s.backend.Users.SetTier(ctx, u.ID, "Silver"))
+	user, err := s.backend.Users.FindByID(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal("Silver", user.Tier)
+	s.Require().ErrorIs(s.backend.Users.SetTier(ctx, uuid.Must(uuid.NewV6()), "Gold"), domain.ErrNotFound)
+}
+
+func (s *RepositorySuite) TestCampaigns() {
+	ctx := context.Background()
+	first := &entity.Campaign{Name: "weekend", Kind: entity.CampaignKindMultiplier, Value: 2, Enabled: true}
+	s.Require().NoError(s.backend.Campaigns.Insert(ctx, first))
+	s.Require().False(first.ID.IsNil())
+	s.Require().False(first.CreatedAt.IsZero())
+	ends := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	second := &entity.Campaign{Name: "welcome", Kind: entity.CampaignKindFixed, Value: 100, FirstOrderOnly: true, EndsAt: &ends}
+	s.Require().NoError(s.backend.Campaigns.Insert(ctx, second))
+
+	second.UserCap = 500
+	s.Require().NoError(s.backend.Campaigns.Update(ctx, second))
+	found, err := s.backend.Campaigns.FindByID(ctx, second.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(500.0, found.UserCap)
+	s.Require().True(found.FirstOrderOnly)
+	s.Require().True(ends.Equal(*found.EndsAt))
+
+	campaigns, err := s.backend.Campaigns.List(ctx)
+	s.Require().NoError(err)
+	s.Require().Len(campaigns, 2)
+	s.Require().Equal(first.ID, campaigns[0].ID)
+
+	s.Require().NoError(s.backend.Campaigns.Delete(ctx, first.ID))
+	_, err = s.backend.Campaigns.FindByID(ctx, first.ID)
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+	s.Require().ErrorIs(s.backend.Campaigns.Delete(ctx, first.ID), domain.ErrNotFound)
+	s.Require().ErrorIs(s.backend.Campaigns.Update(ctx, first), domain.ErrNotFound)
+}
+
+func (s *RepositorySuite) TestCampaignCredits() {
+	ctx := context.Background()
+	u := s.newUser("alice")
+	campaignID := uuid.Must(uuid.NewV6())
+	credit := func(number string, amount float64) *entity.CampaignCredit {
+		return &entity.CampaignCredit{CampaignID: campaignID, UserID: u.ID, OrderNumber: number, Amount: amount}
+	}
+
+	s.Require().NoError(s.backend.Orders.AddCampaignCredit(ctx, credit("3413042486", 100)))
+	// повторный бонус за тот же заказ игнорируется
+	s.Require().NoError(s.backend.Orders.AddCampaignCredit(ctx, credit("3413042486", 100)))
+	s.Require().NoError(s.backend.Orders.AddCampaignCredit(ctx, credit("5798116405", 20)))
+
+	sum, err := s.backend.Orders.GetCampaignCreditsSum(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(120.0, sum)
+	credits, err := s.backend.Orders.GetUserCampaignCredits(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Len(credits, 2)
+	s.Require().False(credits[0].CreatedAt.IsZero())
+
+	sum, err = s.backend.Orders.GetCampaignCreditsSum(ctx, s.newUser("bob").ID)
+	s.Require().NoError(err)
+	s.Require().Zero(sum)
+}
+
+func (s *RepositorySuite) TestTransfers() {
+	ctx := context.Background()
+	alice := s.newUser("alice")
+	bob := s.newUser("bob")
+	start := time.Now().Add(-time.Second)
+
+	t := &entity.Transfer{SenderID: alice.ID, RecipientID: bob.ID, Amount: 30, IdempotencyKey: "k1"}
+	s.Require().NoError(s.backend.Orders.AddTransfer(ctx, t))
+	s.Require().False(t.ID.IsNil())
+	s.Require().False(t.CreatedAt.IsZero())
+	s.Require().ErrorIs(s.backend.Orders.AddTransfer(ctx, &entity.Transfer{
+		SenderID: alice.ID, RecipientID: bob.ID, Amount: 30, IdempotencyKey: "k1",
+	}), domain.ErrIdempotencyConflict)
+	// ключ уникален только в пределах отправителя
+	s.Require().NoError(s.backend.Orders.AddTransfer(ctx, &entity.Transfer{
+		SenderID: bob.ID, RecipientID: alice.ID, Amount: 5, IdempotencyKey: "k1",
+	}))
+
+	found, err := s.backend.Orders.FindTransferByKey(ctx, alice.ID, "k1")
+	s.Require().NoError(err)
+	s.Require().Equal(t.ID, found.ID)
+	s.Require().Equal(30.0, found.Amount)
+	_, err = s.backend.Orders.FindTransferByKey(ctx, alice.ID, "k2")
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+
+	sum, err := s.backend.Orders.GetTransfersSum(ctx, alice.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(-25.0, sum)
+	sum, err = s.backend.Orders.GetTransfersSum(ctx, bob.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(25.0, sum)
+
+	count, sent, err := s.backend.Orders.GetSentTransfersSince(ctx, alice.ID, start)
+	s.Require().NoError(err)
+	s.Require().Equal(1, count)
+	s.Require().Equal(30.0, sent)
+	count, sent, err = s.backend.Orders.GetSentTransfersSince(ctx, alice.ID, time.Now().Add(time.Hour))
+	s.Require().NoError(err)
+	s.Require().Zero(count)
+	s.Require().Zero(sent)
+
+	transfers, err := s.backend.Orders.GetUserTransfers(ctx, bob.ID)
+	s.Require().NoError(err)
+	s.Require().Len(transfers, 2)
+	s.Require().Equal("alice", transfers[0].SenderLogin)
+	s.Require().Equal("bob", transfers[0].RecipientLogin)
+	s.Require().Equal("alice", transfers[1].RecipientLogin)
+}
+
+func (s *RepositorySuite) TestReferrals() {
+	ctx := context.Background()
+	referrer := s.newUser("alice")
+	referee := s.newUser("bob")
+	s.Require().NotEmpty(referrer.ReferralCode)
+	s.Require().NotEqual(referrer.ReferralCode, referee.ReferralCode)
+
+	found, err := s.backend.Users.FindByReferralCode(ctx, strings.ToLower(referrer.ReferralCode))
+	s.Require().NoError(err)
+	s.Require().Equal(referrer.ID, found.ID)
+	_, err = s.backend.Users.FindByReferralCode(ctx, "NOSUCHCODE")
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+
+	_, err = s.backend.Referrals.FindByReferee(ctx, referee.ID)
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+	s.Require().NoError(s.backend.Referrals.Insert(ctx, &entity.Referral{ReferrerID: referrer.ID, RefereeID: referee.ID}))
+	s.Require().Error(s.backend.Referrals.Insert(ctx, &entity.Referral{ReferrerID: referrer.ID, RefereeID: referee.ID}))
+
+	referral, err := s.backend.Referrals.FindByReferee(ctx, referee.ID)
+	s.Require().NoError(err)
+	s.Require().Nil(referral.RewardedAt)
+	number := "3413042486"
+	referral.OrderNumber = &number
+	referral.ReferrerBonus = 100
+	referral.RefereeBonus = 50
+	ok, err := s.backend.Referrals.MarkRewarded(ctx, referral)
+	s.Require().NoError(err)
+	s.Require().True(ok)
+	ok, err = s.backend.Referrals.MarkRewarded(ctx, referral)
+	s.Require().NoError(err)
+	s.Require().False(ok)
+
+	referrals, err := s.backend.Referrals.GetUserReferrals(ctx, referrer.ID)
+	s.Require().NoError(err)
+	s.Require().Len(referrals, 1)
+	s.Require().Equal("bob", referrals[0].RefereeLogin)
+	s.Require().Equal(100.0, referrals[0].ReferrerBonus)
+	s.Require().Equal(number, *referrals[0].OrderNumber)
+	s.Require().NotNil(referrals[0].RewardedAt)
+
+	count, err := s.backend.Referrals.CountRewarded(ctx, referrer.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(1, count)
+}
+
+func (s *RepositorySuite) TestGetOrders() {
+	ctx := context.Background()
+	u := s.newUser("alice")
+	other := s.newUser("bob")
+	s.newOrder(u.ID, "3413042486", entity.OrderStatusNew, nil)
+	s.newOrder(u.ID, "5798116405", entity.OrderStatusProcessing, nil)
+	s.newOrder(u.ID, "9155976989", entity.OrderStatusProcessed, ptr(1))
+	s.newOrder(other.ID, "1587579366", entity.OrderStatusNew, nil)
+
+	orders, err := s.backend.Orders.GetUserOrders(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Len(orders, 3)
+
+	statuses := []string{string(entity.OrderStatusNew), string(entity.OrderStatusProcessing)}
+	orders, err = s.backend.Orders.GetOrdersByStatuses(ctx, statuses, nil, 10)
+	s.Require().NoError(err)
+	s.Require().ElementsMatch([]string{"3413042486", "5798116405", "1587579366"}, numbers(orders))
+
+	orders, err = s.backend.Orders.GetOrdersByStatuses(ctx, statuses, []string{"3413042486", "1587579366"}, 10)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"5798116405"}, numbers(orders))
+
+	orders, err = s.backend.Orders.GetOrdersByStatuses(ctx, statuses, nil, 2)
+	s.Require().NoError(err)
+	s.Require().Len(orders, 2)
+
+	_, err = s.backend.Orders.GetOrdersByStatuses(ctx, nil, nil, 10)
+	s.Require().Error(err)
+}
+
+func (s *RepositorySuite) TestTransactionRollback() {
+	ctx := context.Background()
+	u := s.newUser("alice")
+	errRollback := errors.New("rollback")
+
+	err := s.backend.Transactor.Transaction(ctx, func(ctx context.Context) error {
+		s.Require().NoError(s.backend.Orders.Withdraw(ctx, &entity.Withdraw{UserID: u.ID, OrderNumber: "2377225624", Value: 30}))
+		s.Require().NoError(s.backend.Users.SetBlocked(ctx, u.ID, true))
+		// вложенная транзакция выполняется в рамках внешней
+		return s.backend.Transactor.Transaction(ctx, func(ctx context.Context) error {
+			s.Require().NoError(s.backend.Orders.Insert(ctx, &entity.Order{UserID: u.ID, Number: "3413042486", Status: entity.OrderStatusNew}))
+			return errRollback
+		})
+	})
+	s.Require().ErrorIs(err, errRollback)
+
+	sum, err := s.backend.Orders.GetWithdrawnSum(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Zero(sum)
+	found, err := s.backend.Users.FindByID(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().False(found.Blocked)
+	_, err = s.backend.Orders.FindByNumber(ctx, "3413042486")
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+}
+
+func (s *RepositorySuite) TestTransactionCommit() {
+	ctx := context.Background()
+	u := s.newUser("alice")
+
+	err := s.backend.Transactor.Transaction(ctx, func(ctx context.Context) error {
+		return s.backend.Orders.Withdraw(ctx, &entity.Withdraw{UserID: u.ID, OrderNumber: "2377225624", Value: 30})
+	})
+	s.Require().NoError(err)
+
+	sum, err := s.backend.Orders.GetWithdrawnSum(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(30.0, sum)
+}
+
+func (s *RepositorySuite) TestTransactionPanicRollback() {
+	ctx := context.Background()
+	u := s.newUser("alice")
+
+	err := s.backend.Transactor.Transaction(ctx, func(ctx context.Context) error {
+		s.Require().NoError(s.backend.Users.SetBlocked(ctx, u.ID, true))
+		panic("boom")
+	})
+	s.Require().Error(err)
+
+	found, err := s.backend.Users.FindByID(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().False(found.Blocked)
+}
+
+func (s *RepositorySuite) TestAuditGetLast() {
+	ctx := context.Background()
+	actor := s.newUser("admin")
+	for _, action := range []string{"first", "second", "third"} {
+		s.Require().NoError(s.backend.Audit.Insert(ctx, &entity.AuditRecord{
+			ActorID:    actor.ID,
+			ActorLogin: actor.Login,
+			Action:     action,
+			Target:     "alice",
+			Details:    []byte(`{}`),
+		}))
+	}
+
+	records, err := s.backend.Audit.GetLast(ctx, 2)
+	s.Require().NoError(err)
+	s.Require().Len(records, 2)
+	s.Require().Equal("third", records[0].Action)
+	s.Require().Equal("second", records[1].Action)
+}
+
+func numbers(orders []entity.Order) []string {
+	values := make([]string, 0, len(orders))
+	for _, o := range orders {
+		values = append(values, o.Number)
+	}
+
+	return values
+}
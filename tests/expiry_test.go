@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+func (s *GophermartTestSuite) TestPointsExpiry() {
+	ctx := context.Background()
+	u := s.NewUser()
+	now := time.Now()
+	for _, o := range []struct {
+		number      string
+		accrual     float64
+		processedAt time.Time
+	}{
+		{number: "5555555555554444", accrual: 100, processedAt: now.AddDate(-1, -1, 0)},
+		{number: "4012888888881881", accrual: 50, processedAt: now.AddDate(0, -1, 0)},
+		{number: "378282246310005", accrual: 30, processedAt: now.AddDate(-1, 0, 14)},
+	} {
+		s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, &entity.Order{
+			UserID:      u.ID,
+			Number:      o.number,
+			Status:      entity.OrderStatusProcessed,
+			Accrual:     utils.ToPointer(o.accrual),
+			ProcessedAt: utils.ToPointer(o.processedAt),
+		}))
+	}
+
+	booked, err := s.cnt.PointsExpirer().ExpirePoints(ctx)
+	s.Require().NoError(err)
+	s.Require().GreaterOrEqual(booked, 1)
+
+	balance, err := s.cnt.Gophermart().GetBalance(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(80.0, balance.Current)
+	s.Require().NotNil(balance.ExpiringSoon)
+	s.Require().Equal(30.0, balance.ExpiringSoon.Sum)
+
+	expirations, err := s.cnt.Gophermart().GetExpirations(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Len(expirations, 1)
+	s.Require().Equal("5555555555554444", expirations[0].OrderNumber)
+	s.Require().Equal(100.0, expirations[0].Value)
+
+	// самая старая живая партия расходуется первой
+	s.Require().NoError(s.cnt.Gophermart().Withdraw(ctx, &entity.Withdraw{UserID: u.ID, OrderNumber: "2377225624", Value: 40}))
+	balance, err = s.cnt.Gophermart().GetBalance(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(40.0, balance.Current)
+	s.Require().Nil(balance.ExpiringSoon)
+
+	err = s.cnt.Gophermart().Withdraw(ctx, &entity.Withdraw{UserID: u.ID, OrderNumber: "2377225624", Value: 41})
+	s.Require().ErrorIs(err, domain.ErrNotEnoughAccruals)
+
+	// повторный запуск задачи не сжигает баллы второй раз
+	_, err = s.cnt.PointsExpirer().ExpirePoints(ctx)
+	s.Require().NoError(err)
+	expirations, err = s.cnt.Gophermart().GetExpirations(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Len(expirations, 1)
+}
+
+func (s *GophermartTestSuite) TestConcurrentBalanceReadsBookExpiryOnce() {
+	ctx := context.Background()
+	u := s.NewUser()
+	s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, &entity.Order{
+		UserID:      u.ID,
+		Number:      "3530111333300000",
+		Status:      entity.OrderStatusProcessed,
+		Accrual:     utils.ToPointer(25.0),
+		ProcessedAt: utils.ToPointer(time.Now().AddDate(-1, -1, 0)),
+	}))
+
+	// каждый запрос баланса записывает наступившие сгорания, параллельные запросы не должны мешать друг другу
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = s.cnt.Gophermart().GetBalance(ctx, u.ID)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		s.Require().NoError(err)
+	}
+
+	expirations, err := s.cnt.Gophermart().GetExpirations(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Len(expirations, 1)
+	balance, err := s.cnt.Gophermart().GetBalance(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Zero(balance.Current)
+}
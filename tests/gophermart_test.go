@@ -26,7 +26,8 @@ type GophermartTestSuite struct {
 	api  internal.API
 	user *entity.User
 
-	userCount int
+	userCount    int
+	stopHandlers context.CancelFunc
 }
 
 func TestSuiteRun(t *testing.T) {
@@ -35,14 +36,32 @@ func TestSuiteRun(t *testing.T) {
 
 func (s *GophermartTestSuite) SetupSuite() {
 	s.cfg = testutils.GetConfig("../" + config.DefaultDir)
+	s.cfg.Expiry = config.Expiry{LifetimeMonths: 12, NotifyBefore: 30 * 24 * time.Hour}
+	s.cfg.Referral = config.Referral{ReferrerBonus: 100, RefereeBonus: 50, MaxPerReferrer: 1}
+	s.cfg.Transfer = config.Transfer{DailyAmount: 300, DailyCount: 3}
+	s.cfg.Loyalty = config.Loyalty{
+		WindowMonths: 12,
+		Tiers: []config.Tier{
+			{Name: "Bronze", Threshold: 0, Multiplier: 1},
+			{Name: "Silver", Threshold: 1000, Multiplier: 1.1},
+			{Name: "Gold", Threshold: 5000, Multiplier: 1.25},
+		},
+	}
 	s.cnt = container.New(s.cfg)
 	s.cnt.SetAccrualService(&stubs.AccrualServiceStub{})
 
-	s.Require().NoError(s.cnt.Resetter().Reset())
+	s.Require().NoError(testutils.PrepareDB(s.cnt))
+	var ctx context.Context
+	ctx, s.stopHandlers = context.WithCancel(context.Background())
+	s.Require().NoError(s.cnt.SubscribeEventHandlers(ctx))
 
 	s.user = s.NewUser()
 }
 
+func (s *GophermartTestSuite) TearDownSuite() {
+	s.stopHandlers()
+}
+
 func (s *GophermartTestSuite) NewUser() *entity.User {
 	s.userCount++
 	u, err := s.cnt.Gophermart().Register(context.Background(), &entity.RegisterRequest{
@@ -83,9 +102,12 @@ func (s *GophermartTestSuite) TestPostOrder() {
 	s.Require().NoError(err)
 	accService, ok := s.cnt.AccrualService().(*stubs.AccrualServiceStub)
 	s.Require().True(ok)
-	s.Require().True(utils.ContainsWhere(accService.Orders(), func(o *entity.Order) bool {
-		return o.Number == "3413042486"
-	}))
+	// на расчёт заказ уходит через событие OrderUploaded
+	s.Require().Eventually(func() bool {
+		return utils.ContainsWhere(accService.Orders(), func(o *entity.Order) bool {
+			return o.Number == "3413042486"
+		})
+	}, time.Second, 10*time.Millisecond)
 
 	err = s.cnt.Gophermart().PostOrder(context.Background(), &entity.Order{
 		UserID:    s.user.ID,
@@ -94,6 +116,13 @@ func (s *GophermartTestSuite) TestPostOrder() {
 		CreatedAt: time.Now(),
 	})
 	s.Require().ErrorIs(err, domain.ErrOrderCreatedByCurrentUser)
+
+	// номер в другом написании нормализуется и считается тем же заказом
+	err = s.cnt.Gophermart().PostOrder(context.Background(), &entity.Order{
+		UserID: s.user.ID,
+		Number: " 3413-042 486\n",
+	})
+	s.Require().ErrorIs(err, domain.ErrOrderCreatedByCurrentUser)
 }
 
 func (s *GophermartTestSuite) TestPostOrderBadNumber() {
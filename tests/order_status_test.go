@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"context"
+
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+func (s *GophermartTestSuite) TestOrderStatusHistory() {
+	ctx := context.Background()
+	u := s.NewUser()
+	other := s.NewUser()
+	admin := s.NewAdmin()
+	order := &entity.Order{UserID: u.ID, Number: "6011000000000004"}
+	s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, order))
+	s.processOrder(order, 30)
+
+	found, history, err := s.cnt.Gophermart().GetOrder(ctx, u.ID, order.Number)
+	s.Require().NoError(err)
+	s.Require().Equal(entity.OrderStatusProcessed, found.Status)
+	s.Require().Len(history, 2)
+	s.Require().Equal(entity.OrderStatusNew, history[0].ToStatus)
+	s.Require().Equal(entity.OrderStatusProcessed, history[1].ToStatus)
+	s.Require().Equal(entity.OrderSourceAccrual, history[1].Source)
+
+	_, _, err = s.cnt.Gophermart().GetOrder(ctx, other.ID, order.Number)
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+
+	// обработанный заказ нельзя отправить на повторный расчёт
+	s.Require().ErrorIs(s.cnt.Admin().RetriggerAccrual(ctx, admin, order.Number), domain.ErrIllegalTransition)
+	_, history, err = s.cnt.Gophermart().GetOrder(ctx, u.ID, order.Number)
+	s.Require().NoError(err)
+	s.Require().Len(history, 2)
+}
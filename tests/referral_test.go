@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+func (s *GophermartTestSuite) TestReferrals() {
+	ctx := context.Background()
+	referrer := s.NewUser()
+	s.Require().NotEmpty(referrer.ReferralCode)
+
+	_, err := s.cnt.Gophermart().Register(ctx, &entity.RegisterRequest{Login: "invited-bad", Password: "test", Referral: "NOSUCHCODE"})
+	s.Require().ErrorIs(err, domain.ErrValidation)
+	exists, err := s.cnt.UserRepo().LoginExists(ctx, "invited-bad")
+	s.Require().NoError(err)
+	s.Require().False(exists)
+
+	first, err := s.cnt.Gophermart().Register(ctx, &entity.RegisterRequest{Login: "invited-1", Password: "test", Referral: referrer.ReferralCode})
+	s.Require().NoError(err)
+	second, err := s.cnt.Gophermart().Register(ctx, &entity.RegisterRequest{Login: "invited-2", Password: "test", Referral: referrer.ReferralCode})
+	s.Require().NoError(err)
+
+	order := &entity.Order{UserID: first.ID, Number: "4916338506082832"}
+	s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, order))
+	s.processOrder(order, 10)
+	// повторная обработка и следующие заказы бонусов не дают
+	s.processOrder(order, 10)
+	order = &entity.Order{UserID: first.ID, Number: "4539578763621486"}
+	s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, order))
+	s.processOrder(order, 10)
+
+	// пригласивший уже получил максимум бонусов, приглашённый получает свой
+	order = &entity.Order{UserID: second.ID, Number: "4556737586899855"}
+	s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, order))
+	s.processOrder(order, 10)
+
+	for _, tc := range []struct {
+		user     *entity.User
+		expected float64
+	}{
+		{referrer, 100},
+		{first, 70},
+		{second, 60},
+	} {
+		// бонусы начисляет подписчик OrderProcessed
+		s.Require().Eventually(func() bool {
+			balance, err := s.cnt.Gophermart().GetBalance(ctx, tc.user.ID)
+			s.Require().NoError(err)
+			return balance.Current == tc.expected
+		}, time.Second, 10*time.Millisecond, tc.user.Login)
+	}
+
+	referrals, err := s.cnt.Gophermart().GetReferrals(ctx, referrer.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(referrer.ReferralCode, referrals.Code)
+	s.Require().Len(referrals.Referrals, 2)
+	s.Require().Equal("invited-1", referrals.Referrals[0].Login)
+	s.Require().Equal(100.0, referrals.Referrals[0].Bonus)
+	s.Require().Zero(referrals.Referrals[1].Bonus)
+	s.Require().NotNil(referrals.Referrals[1].RewardedAt)
+}
+
+func (s *GophermartTestSuite) TestConcurrentReferralRewards() {
+	ctx := context.Background()
+	referrer := s.NewUser()
+	referees := make([]*entity.User, 5)
+	for i := range referees {
+		var err error
+		referees[i], err = s.cnt.Gophermart().Register(ctx, &entity.RegisterRequest{
+			Login:    "race-invited-" + strconv.Itoa(i),
+			Password: "test",
+			Referral: referrer.ReferralCode,
+		})
+		s.Require().NoError(err)
+	}
+
+	// первые заказы всех приглашённых обрабатываются одновременно
+	var wg sync.WaitGroup
+	for i, referee := range referees {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			order := &entity.Order{UserID: referee.ID, Number: "race-" + strconv.Itoa(i), Status: entity.OrderStatusProcessed}
+			err := s.cnt.Transactor().Transaction(ctx, func(ctx context.Context) error {
+				return s.cnt.ReferralProgram().OrderProcessed(ctx, order)
+			})
+			s.NoError(err)
+		}()
+	}
+	wg.Wait()
+
+	balance, err := s.cnt.Gophermart().GetBalance(ctx, referrer.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(float64(s.cfg.Referral.MaxPerReferrer)*s.cfg.Referral.ReferrerBonus, balance.Current)
+}
+
+func (s *GophermartTestSuite) TestReferralFirstOrderWithoutAccrual() {
+	ctx := context.Background()
+	referrer := s.NewUser()
+	referee, err := s.cnt.Gophermart().Register(ctx, &entity.RegisterRequest{
+		Login:    "invited-zero",
+		Password: "test",
+		Referral: referrer.ReferralCode,
+	})
+	s.Require().NoError(err)
+
+	// первый обработанный заказ приглашённого без начисления всё равно вознаграждается
+	order := &entity.Order{UserID: referee.ID, Number: "6011601160116611"}
+	s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, order))
+	s.processOrderWith(order, nil)
+
+	for _, tc := range []struct {
+		user     *entity.User
+		expected float64
+	}{
+		{referrer, s.cfg.Referral.ReferrerBonus},
+		{referee, s.cfg.Referral.RefereeBonus},
+	} {
+		s.Require().Eventually(func() bool {
+			balance, err := s.cnt.Gophermart().GetBalance(ctx, tc.user.ID)
+			s.Require().NoError(err)
+			return balance.Current == tc.expected
+		}, time.Second, 10*time.Millisecond, tc.user.Login)
+	}
+	referrals, err := s.cnt.Gophermart().GetReferrals(ctx, referrer.ID)
+	s.Require().NoError(err)
+	s.Require().Len(referrals.Referrals, 1)
+	s.Require().NotNil(referrals.Referrals[0].RewardedAt)
+}
@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"context"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+func (s *GophermartTestSuite) TestRefundWithdrawal() {
+	ctx := context.Background()
+	u := s.NewUser()
+	admin := s.NewAdmin()
+	service := &entity.Actor{Login: auth.ServiceLoginPrefix + "shop", Role: entity.UserRoleService}
+	s.Require().NoError(s.cnt.Admin().AdjustBalance(ctx, admin, u.Login, &entity.BalanceAdjustmentRequest{Amount: 100, Reason: "gift"}))
+	s.Require().NoError(s.cnt.Gophermart().Withdraw(ctx, &entity.Withdraw{UserID: u.ID, OrderNumber: "5105105105105100", Value: 60}))
+	s.Require().NoError(s.cnt.Gophermart().Withdraw(ctx, &entity.Withdraw{UserID: u.ID, OrderNumber: "4222222222222", Value: 40}))
+
+	_, err := s.cnt.Admin().RefundWithdrawal(ctx, admin, "5105105105105100", &entity.RefundRequest{Amount: 61})
+	s.Require().ErrorIs(err, domain.ErrRefundExceedsWithdrawal)
+	_, err = s.cnt.Admin().RefundWithdrawal(ctx, admin, "5105105105105100", &entity.RefundRequest{Amount: -1})
+	s.Require().ErrorIs(err, domain.ErrBadAmount)
+	_, err = s.cnt.Admin().RefundWithdrawal(ctx, admin, "79927398713", &entity.RefundRequest{})
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+
+	w, err := s.cnt.Admin().RefundWithdrawal(ctx, service, "5105105105105100", &entity.RefundRequest{Amount: 25, Reason: "cancelled item"})
+	s.Require().NoError(err)
+	s.Require().Equal(entity.WithdrawalStatusPartiallyRefunded, w.Status())
+	_, err = s.cnt.Admin().RefundWithdrawal(ctx, admin, "5105105105105100", &entity.RefundRequest{Amount: 10})
+	s.Require().ErrorIs(err, domain.ErrAlreadyRefunded)
+	// без суммы возвращается всё списание
+	w, err = s.cnt.Admin().RefundWithdrawal(ctx, admin, "4222222222222", &entity.RefundRequest{})
+	s.Require().NoError(err)
+	s.Require().Equal(40.0, *w.Refunded)
+	s.Require().Equal(entity.WithdrawalStatusRefunded, w.Status())
+
+	balance, err := s.cnt.Gophermart().GetBalance(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(65.0, balance.Current)
+	s.Require().Equal(35.0, balance.Withdrawn)
+
+	withdrawals, err := s.cnt.Gophermart().GetWithdrawals(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Len(withdrawals, 2)
+	for _, w := range withdrawals {
+		s.Require().NotEqual(entity.WithdrawalStatusCompleted, w.Status(), w.OrderNumber)
+	}
+
+	records, err := s.cnt.Admin().GetAuditLog(ctx, 1)
+	s.Require().NoError(err)
+	s.Require().Equal(domain.AuditActionRefund, records[0].Action)
+	s.Require().Equal("4222222222222", records[0].Target)
+}
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"context"
+	"time"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+func (s *GophermartTestSuite) TestAccrualCorrection() {
+	ctx := context.Background()
+	admin := s.NewAdmin()
+	u := s.NewUser()
+	order := &entity.Order{
+		UserID:      u.ID,
+		Number:      "3566002020360505",
+		Status:      entity.OrderStatusProcessed,
+		Accrual:     utils.ToPointer(100.0),
+		ProcessedAt: utils.ToPointer(time.Now().Add(-time.Hour)),
+	}
+	s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, order))
+
+	// система расчёта увеличила начисление, повторная сверка с тем же ответом ничего не проводит
+	s.Require().NoError(s.cnt.AccrualCorrector().AccrualChanged(ctx, order, 120))
+	s.Require().NoError(s.cnt.AccrualCorrector().AccrualChanged(ctx, order, 120))
+	balance, err := s.cnt.Gophermart().GetBalance(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(120.0, balance.Current)
+
+	// уменьшение после списания уводит баланс в минус
+	s.Require().NoError(s.cnt.Gophermart().Withdraw(ctx, &entity.Withdraw{UserID: u.ID, OrderNumber: "371449635398431", Value: 120}))
+	s.Require().NoError(s.cnt.AccrualCorrector().AccrualChanged(ctx, order, 90))
+	balance, err = s.cnt.Gophermart().GetBalance(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(-30.0, balance.Current)
+
+	// начисление заказа не перезаписывается, исправления видны в выписке
+	found, err := s.cnt.OrderRepo().FindByNumber(ctx, order.Number)
+	s.Require().NoError(err)
+	s.Require().Equal(100.0, *found.Accrual)
+	var corrections []float64
+	s.Require().NoError(s.cnt.Gophermart().StreamStatement(ctx, u.ID, time.Time{}, time.Time{}, func(e *entity.StatementEntry) error {
+		if e.Kind == entity.StatementAdjustment {
+			s.Require().Equal(order.Number, e.OrderNumber)
+			corrections = append(corrections, e.Amount)
+		}
+		return nil
+	}))
+	s.Require().Equal([]float64{20, -30}, corrections)
+
+	reviews, err := s.cnt.Admin().GetAdjustmentsForReview(ctx, admin)
+	s.Require().NoError(err)
+	var review *entity.AdjustmentReview
+	for i := range reviews {
+		if reviews[i].Login == u.Login {
+			s.Require().Nil(review, "only the correction that made the balance negative is flagged")
+			review = &reviews[i]
+		}
+	}
+	s.Require().NotNil(review)
+	s.Require().Equal(-30.0, review.Amount)
+	s.Require().Equal(order.Number, *review.OrderNumber)
+
+	s.Require().NoError(s.cnt.Admin().MarkAdjustmentReviewed(ctx, admin, review.ID))
+	s.Require().ErrorIs(s.cnt.Admin().MarkAdjustmentReviewed(ctx, admin, review.ID), domain.ErrNotFound)
+	reviews, err = s.cnt.Admin().GetAdjustmentsForReview(ctx, admin)
+	s.Require().NoError(err)
+	s.Require().False(utils.ContainsWhere(reviews, func(r entity.AdjustmentReview) bool { return r.Login == u.Login }))
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/k-zavarnitsyn/gophermart/internal/api"
+	"github.com/k-zavarnitsyn/gophermart/internal/middleware"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+func (s *GophermartTestSuite) TestStatement() {
+	ctx := context.Background()
+	u := s.NewUser()
+	day := func(d int) *time.Time {
+		t := time.Date(2024, 1, d, 12, 0, 0, 0, time.UTC)
+		return &t
+	}
+	for _, o := range []*entity.Order{
+		{UserID: u.ID, Number: "30569309025904", Status: entity.OrderStatusProcessed, Accrual: utils.ToPointer(100.0), ProcessedAt: day(1)},
+		{UserID: u.ID, Number: "38520000023237", Status: entity.OrderStatusProcessed, Accrual: utils.ToPointer(50.0), ProcessedAt: day(10)},
+		{UserID: u.ID, Number: "6011000990139424", Status: entity.OrderStatusProcessing},
+	} {
+		s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, o))
+	}
+	s.Require().NoError(s.cnt.OrderRepo().Withdraw(ctx, &entity.Withdraw{UserID: u.ID, OrderNumber: "2377225624", Value: 30}))
+
+	var entries []entity.StatementEntry
+	collect := func(e *entity.StatementEntry) error {
+		entries = append(entries, *e)
+		return nil
+	}
+	s.Require().NoError(s.cnt.Gophermart().StreamStatement(ctx, u.ID, *day(5), time.Time{}, collect))
+	s.Require().Len(entries, 2)
+	// начисление до начала периода входит только в остаток
+	s.Require().Equal(entity.StatementAccrual, entries[0].Kind)
+	s.Require().Equal(150.0, entries[0].Balance)
+	s.Require().Equal(entity.StatementWithdrawal, entries[1].Kind)
+	s.Require().Equal(-30.0, entries[1].Amount)
+	s.Require().Equal(120.0, entries[1].Balance)
+
+	err := s.cnt.Gophermart().StreamStatement(ctx, u.ID, *day(5), *day(1), collect)
+	s.Require().ErrorIs(err, domain.ErrBadPeriod)
+
+	router := chi.NewRouter()
+	router.Use(middleware.WithGzipResponse)
+	router.Get("/api/user/statement", api.New(s.cfg, s.cnt.Auth(), s.cnt.Gophermart(), s.cnt.Pinger(), s.cnt.AccrualService(), s.cnt.Elector()).GetStatement)
+	request := func(query string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/user/statement?"+query, http.NoBody)
+		r = r.WithContext(auth.ToContext(r.Context(), &auth.JWTClaims{UserID: u.ID, Login: u.Login}))
+		r.Header.Set(utils.AcceptEncoding, "gzip")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) io.Reader {
+		s.Require().Equal("gzip", w.Header().Get(utils.ContentEncoding))
+		gz, err := gzip.NewReader(w.Body)
+		s.Require().NoError(err)
+		return gz
+	}
+
+	w := request("format=csv&from=2024-01-01&to=2024-01-01")
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Require().Equal(utils.ContentTypeCSV, w.Header().Get(utils.ContentType))
+	rows, err := csv.NewReader(decode(w)).ReadAll()
+	s.Require().NoError(err)
+	s.Require().Equal([][]string{
+		{"at", "kind", "order", "amount", "balance"},
+		{"2024-01-01T12:00:00Z", "accrual", "30569309025904", "100", "100"},
+	}, rows)
+
+	w = request("format=json")
+	s.Require().Equal(http.StatusOK, w.Code)
+	var all []entity.StatementEntry
+	s.Require().NoError(json.NewDecoder(decode(w)).Decode(&all))
+	s.Require().Len(all, 3)
+	s.Require().Equal(120.0, all[2].Balance)
+
+	s.Require().Equal(http.StatusBadRequest, request("format=xml").Code)
+	s.Require().Equal(http.StatusBadRequest, request("from=yesterday").Code)
+}
@@ -2,22 +2,40 @@ package stubs
 
 import (
 	"context"
+	"slices"
+	"sync"
 
 	"github.com/k-zavarnitsyn/gophermart/internal/services/accrual"
+	"github.com/k-zavarnitsyn/gophermart/internal/workers"
 	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
 )
 
 var _ accrual.Service = (*AccrualServiceStub)(nil)
 
 type AccrualServiceStub struct {
+	mu     sync.Mutex
 	orders []*entity.Order
 }
 
 func (a *AccrualServiceStub) Send(ctx context.Context, order *entity.Order) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	a.orders = append(a.orders, order)
 	return nil
 }
 
+func (a *AccrualServiceStub) CircuitState() accrual.CircuitState {
+	return accrual.CircuitClosed
+}
+
+func (a *AccrualServiceStub) QueueStats() workers.QueueStats {
+	return workers.QueueStats{}
+}
+
 func (a *AccrualServiceStub) Orders() []*entity.Order {
-	return a.orders
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return slices.Clone(a.orders)
 }
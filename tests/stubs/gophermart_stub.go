@@ -0,0 +1,322 @@
+package stubs
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+var _ domain.Gophermart = (*GophermartStub)(nil)
+
+// GophermartStub упрощённая реализация domain.Gophermart в памяти, без проверки номеров заказов
+type GophermartStub struct {
+	mu          sync.Mutex
+	users       map[string]*entity.User
+	passwords   map[string]string
+	orders      []entity.Order
+	withdrawals []entity.Withdraw
+	transfers   []entity.Transfer
+}
+
+func NewGophermartStub() *GophermartStub {
+	return &GophermartStub{
+		users:     make(map[string]*entity.User),
+		passwords: make(map[string]string),
+	}
+}
+
+func (g *GophermartStub) Register(ctx context.Context, req *entity.RegisterRequest) (*entity.User, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.users[req.Login]; ok {
+		return nil, domain.ErrLoginExists
+	}
+	user := &entity.User{ID: uuid.Must(uuid.NewV6()), Login: req.Login}
+	g.users[req.Login] = user
+	g.passwords[req.Login] = req.Password
+
+	return user, nil
+}
+
+func (g *GophermartStub) Login(ctx context.Context, req *entity.LoginRequest) (*entity.User, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	user, ok := g.users[req.Login]
+	if !ok || g.passwords[req.Login] != req.Password {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	return user, nil
+}
+
+func (g *GophermartStub) ChangePassword(ctx context.Context, userID uuid.UUID, req *entity.ChangePasswordRequest) (*entity.User, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for login, user := range g.users {
+		if user.ID != userID {
+			continue
+		}
+		if g.passwords[login] != req.OldPassword {
+			return nil, domain.ErrWrongPassword
+		}
+		g.passwords[login] = req.NewPassword
+		user.SessionVersion++
+		return user, nil
+	}
+
+	return nil, domain.ErrNotFound
+}
+
+func (g *GophermartStub) DeleteUser(ctx context.Context, userID uuid.UUID) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for login, user := range g.users {
+		if user.ID == userID {
+			delete(g.users, login)
+			delete(g.passwords, login)
+			return nil
+		}
+	}
+
+	return domain.ErrNotFound
+}
+
+func (g *GophermartStub) PostOrder(ctx context.Context, order *entity.Order) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, o := range g.orders {
+		if o.Number != order.Number {
+			continue
+		}
+		if o.UserID == order.UserID {
+			return domain.ErrOrderCreatedByCurrentUser
+		}
+		return domain.ErrOrderCreatedByOtherUser
+	}
+	order.Status = entity.OrderStatusNew
+	order.CreatedAt = time.Now()
+	g.orders = append(g.orders, *order)
+
+	return nil
+}
+
+// SetOrder меняет статус и начисление заказа, имитируя ответ системы расчёта
+func (g *GophermartStub) SetOrder(number string, status entity.OrderStatus, accrual *float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for i := range g.orders {
+		if g.orders[i].Number == number {
+			g.orders[i].Status = status
+			g.orders[i].Accrual = accrual
+		}
+	}
+}
+
+func (g *GophermartStub) GetOrders(ctx context.Context, userID uuid.UUID) ([]entity.Order, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var result []entity.Order
+	for _, o := range g.orders {
+		if o.UserID == userID {
+			result = append(result, o)
+		}
+	}
+
+	return result, nil
+}
+
+// GetOrder истории статусов в заглушке нет
+func (g *GophermartStub) GetOrder(ctx context.Context, userID uuid.UUID, number string) (*entity.Order, []entity.OrderStatusChange, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, o := range g.orders {
+		if o.UserID == userID && o.Number == number {
+			return &o, nil, nil
+		}
+	}
+
+	return nil, nil, domain.ErrNotFound
+}
+
+func (g *GophermartStub) GetBalance(ctx context.Context, userID uuid.UUID) (*entity.Balance, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.balance(userID), nil
+}
+
+func (g *GophermartStub) Withdraw(ctx context.Context, w *entity.Withdraw) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.balance(w.UserID).Current < w.Value {
+		return domain.ErrNotEnoughAccruals
+	}
+	w.CreatedAt = time.Now()
+	g.withdrawals = append(g.withdrawals, *w)
+
+	return nil
+}
+
+func (g *GophermartStub) GetWithdrawals(ctx context.Context, userID uuid.UUID) ([]entity.Withdraw, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var result []entity.Withdraw
+	for _, w := range g.withdrawals {
+		if w.UserID == userID {
+			result = append(result, w)
+		}
+	}
+
+	return result, nil
+}
+
+// Transfer перевод в заглушке без дневных ограничений
+func (g *GophermartStub) Transfer(ctx context.Context, senderID uuid.UUID, key string, req *entity.TransferRequest) (*entity.Transfer, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if key == "" {
+		return nil, domain.ErrIdempotencyKeyRequired
+	}
+	if req.Amount <= 0 {
+		return nil, domain.ErrBadAmount
+	}
+	recipient, ok := g.users[req.Login]
+	for _, t := range g.transfers {
+		if t.SenderID == senderID && t.IdempotencyKey == key {
+			if !ok || t.RecipientID != recipient.ID || t.Amount != req.Amount {
+				return nil, domain.ErrIdempotencyConflict
+			}
+			return &t, nil
+		}
+	}
+	switch {
+	case !ok:
+		return nil, domain.ErrUnknownRecipient
+	case recipient.ID == senderID:
+		return nil, domain.ErrTransferToSelf
+	case g.balance(senderID).Current < req.Amount:
+		return nil, domain.ErrNotEnoughAccruals
+	}
+	t := entity.Transfer{
+		ID:             uuid.Must(uuid.NewV6()),
+		SenderID:       senderID,
+		RecipientID:    recipient.ID,
+		Amount:         req.Amount,
+		IdempotencyKey: key,
+		CreatedAt:      time.Now(),
+	}
+	g.transfers = append(g.transfers, t)
+
+	return &t, nil
+}
+
+func (g *GophermartStub) GetTransfers(ctx context.Context, userID uuid.UUID) ([]entity.Transfer, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	logins := make(map[uuid.UUID]string, len(g.users))
+	for login, u := range g.users {
+		logins[u.ID] = login
+	}
+	var result []entity.Transfer
+	for _, t := range g.transfers {
+		if t.SenderID == userID || t.RecipientID == userID {
+			t.SenderLogin, t.RecipientLogin = logins[t.SenderID], logins[t.RecipientID]
+			result = append(result, t)
+		}
+	}
+
+	return result, nil
+}
+
+// GetExpirations баллы в заглушке не сгорают
+func (g *GophermartStub) GetExpirations(ctx context.Context, userID uuid.UUID) ([]entity.PointsExpiry, error) {
+	return nil, nil
+}
+
+// GetTier уровней в заглушке нет
+func (g *GophermartStub) GetTier(ctx context.Context, userID uuid.UUID) (*entity.TierResponse, error) {
+	return &entity.TierResponse{Multiplier: 1}, nil
+}
+
+// GetReferrals приглашений в заглушке нет
+func (g *GophermartStub) GetReferrals(ctx context.Context, userID uuid.UUID) (*entity.ReferralsResponse, error) {
+	return &entity.ReferralsResponse{Referrals: []entity.ReferralResponse{}}, nil
+}
+
+// StreamStatement выписка заглушки: только начисления и списания, без учёта периода
+func (g *GophermartStub) StreamStatement(
+	ctx context.Context,
+	userID uuid.UUID,
+	from, to time.Time,
+	f func(e *entity.StatementEntry) error,
+) error {
+	g.mu.Lock()
+	var entries []entity.StatementEntry
+	for _, o := range g.orders {
+		if o.UserID == userID && o.Status == entity.OrderStatusProcessed && o.Accrual != nil {
+			entries = append(entries, entity.StatementEntry{At: o.CreatedAt, Kind: entity.StatementAccrual, OrderNumber: o.Number, Amount: *o.Accrual})
+		}
+	}
+	for _, w := range g.withdrawals {
+		if w.UserID == userID {
+			entries = append(entries, entity.StatementEntry{At: w.CreatedAt, Kind: entity.StatementWithdrawal, OrderNumber: w.OrderNumber, Amount: -w.Value})
+		}
+	}
+	g.mu.Unlock()
+	slices.SortStableFunc(entries, func(a, b entity.StatementEntry) int {
+		return a.At.Compare(b.At)
+	})
+
+	var balance float64
+	for i := range entries {
+		balance += entries[i].Amount
+		entries[i].Balance = balance
+		if err := f(&entries[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *GophermartStub) balance(userID uuid.UUID) *entity.Balance {
+	var b entity.Balance
+	for _, o := range g.orders {
+		if o.UserID == userID && o.Status == entity.OrderStatusProcessed && o.Accrual != nil {
+			b.Current += *o.Accrual
+		}
+	}
+	for _, w := range g.withdrawals {
+		if w.UserID == userID {
+			b.Withdrawn += w.Value
+		}
+	}
+	for _, t := range g.transfers {
+		if t.RecipientID == userID {
+			b.Current += t.Amount
+		}
+		if t.SenderID == userID {
+			b.Current -= t.Amount
+		}
+	}
+	b.Current -= b.Withdrawn
+
+	return &b
+}
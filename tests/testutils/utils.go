@@ -1,6 +1,8 @@
 package testutils
 
 import (
+	"context"
+
 	"github.com/k-zavarnitsyn/gophermart/internal/config"
 	"github.com/k-zavarnitsyn/gophermart/internal/container"
 	log "github.com/sirupsen/logrus"
@@ -17,6 +19,26 @@ func GetConfig(configDir string) *config.Config {
 
 	return cfg
 }
+
 func GetContainer(configDir string) *container.Container {
 	return container.New(GetConfig(configDir))
 }
+
+// PrepareDB создаёт схему, если её нет, применяет миграции и очищает таблицы
+func PrepareDB(cnt *container.Container) error {
+	ctx := context.Background()
+	defined, err := cnt.SchemaCreator().SchemaDefined(ctx)
+	if err != nil {
+		return err
+	}
+	if !defined {
+		if err := cnt.SchemaCreator().CreateSchema(ctx); err != nil {
+			return err
+		}
+	}
+	if err := cnt.SchemaCreator().MigrateSchema(ctx); err != nil {
+		return err
+	}
+
+	return cnt.Resetter().Reset()
+}
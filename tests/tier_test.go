@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+// errRejected откатывает hook, если заказ уже обработан, как это делает accrual.Service
+var errRejected = errors.New("transition rejected")
+
+// processOrder имитирует окончательный ответ системы расчёта так же, как это делает accrual.Service
+func (s *GophermartTestSuite) processOrder(order *entity.Order, accrual float64) {
+	s.processOrderWith(order, utils.ToPointer(accrual))
+}
+
+// processOrderWith как processOrder, nil - ответ PROCESSED без начисления
+func (s *GophermartTestSuite) processOrderWith(order *entity.Order, accrual *float64) {
+	ctx := context.Background()
+	err := s.cnt.Transactor().Transaction(ctx, func(ctx context.Context) error {
+		order.Status = entity.OrderStatusProcessed
+		order.Accrual = accrual
+		if err := s.cnt.Loyalty().ApplyBonus(ctx, order); err != nil {
+			return err
+		}
+		updated, err := s.cnt.OrderRepo().UpdateAttributes(ctx, order, entity.OrderSourceAccrual, nil)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return errRejected
+		}
+		return s.cnt.EventBus().Publish(ctx, entity.EventOrderProcessed, entity.NewOrderEvent(order))
+	})
+	if !errors.Is(err, errRejected) {
+		s.Require().NoError(err)
+	}
+}
+
+func (s *GophermartTestSuite) TestLoyaltyTiers() {
+	ctx := context.Background()
+	u := s.NewUser()
+
+	tier, err := s.cnt.Gophermart().GetTier(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(&entity.TierResponse{Tier: "Bronze", Multiplier: 1, NextTier: "Silver", ToNextTier: 1000}, tier)
+
+	first := &entity.Order{UserID: u.ID, Number: "1234567812345670"}
+	s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, first))
+	s.processOrder(first, 1200)
+	// коэффициент берётся по уровню до заказа
+	s.Require().Nil(first.Bonus)
+
+	second := &entity.Order{UserID: u.ID, Number: "79927398713"}
+	s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, second))
+	s.processOrder(second, 100)
+	s.Require().NotNil(second.Bonus)
+	s.Require().Equal(10.0, *second.Bonus)
+
+	stored, err := s.cnt.OrderRepo().FindByNumber(ctx, second.Number)
+	s.Require().NoError(err)
+	s.Require().Equal(100.0, *stored.Accrual)
+	s.Require().Equal(10.0, *stored.Bonus)
+
+	// уровень пересчитывает подписчик OrderProcessed
+	s.Require().Eventually(func() bool {
+		user, err := s.cnt.UserRepo().FindByID(ctx, u.ID)
+		s.Require().NoError(err)
+		return user.Tier == "Silver"
+	}, time.Second, 10*time.Millisecond)
+
+	tier, err = s.cnt.Gophermart().GetTier(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(&entity.TierResponse{
+		Tier:            "Silver",
+		Multiplier:      1.1,
+		RollingAccruals: 1300,
+		NextTier:        "Gold",
+		ToNextTier:      3700,
+	}, tier)
+
+	balance, err := s.cnt.Gophermart().GetBalance(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(1310.0, balance.Current)
+}
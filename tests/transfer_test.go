@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+func (s *GophermartTestSuite) TestTransfer() {
+	ctx := context.Background()
+	sender := s.NewUser()
+	recipient := s.NewUser()
+	admin := s.NewAdmin()
+	err := s.cnt.Admin().AdjustBalance(ctx, admin, sender.Login, &entity.BalanceAdjustmentRequest{Amount: 500, Reason: "family pool"})
+	s.Require().NoError(err)
+
+	transfer := func(key string, login string, amount float64) (*entity.Transfer, error) {
+		return s.cnt.Gophermart().Transfer(ctx, sender.ID, key, &entity.TransferRequest{Login: login, Amount: amount})
+	}
+
+	_, err = transfer("", recipient.Login, 10)
+	s.Require().ErrorIs(err, domain.ErrIdempotencyKeyRequired)
+	_, err = transfer("t0", recipient.Login, -10)
+	s.Require().ErrorIs(err, domain.ErrBadAmount)
+	_, err = transfer("t0", "nobody", 10)
+	s.Require().ErrorIs(err, domain.ErrUnknownRecipient)
+	_, err = transfer("t0", sender.Login, 10)
+	s.Require().ErrorIs(err, domain.ErrTransferToSelf)
+
+	first, err := transfer("t1", recipient.Login, 100)
+	s.Require().NoError(err)
+	// повтор с тем же ключом не переводит баллы второй раз
+	again, err := transfer("t1", recipient.Login, 100)
+	s.Require().NoError(err)
+	s.Require().Equal(first.ID, again.ID)
+	_, err = transfer("t1", recipient.Login, 50)
+	s.Require().ErrorIs(err, domain.ErrIdempotencyConflict)
+
+	// дневной лимит суммы 300
+	_, err = transfer("t2", recipient.Login, 250)
+	s.Require().ErrorIs(err, domain.ErrTransferLimitExceeded)
+	_, err = transfer("t2", recipient.Login, 150)
+	s.Require().NoError(err)
+
+	back, err := s.cnt.Gophermart().Transfer(ctx, recipient.ID, "r1", &entity.TransferRequest{Login: sender.Login, Amount: 300})
+	s.Require().ErrorIs(err, domain.ErrNotEnoughAccruals)
+	s.Require().Nil(back)
+	_, err = s.cnt.Gophermart().Transfer(ctx, recipient.ID, "r1", &entity.TransferRequest{Login: sender.Login, Amount: 40})
+	s.Require().NoError(err)
+
+	for _, tc := range []struct {
+		user     *entity.User
+		expected float64
+	}{
+		{sender, 290},
+		{recipient, 210},
+	} {
+		balance, err := s.cnt.Gophermart().GetBalance(ctx, tc.user.ID)
+		s.Require().NoError(err)
+		s.Require().Equal(tc.expected, balance.Current, tc.user.Login)
+	}
+
+	transfers, err := s.cnt.Gophermart().GetTransfers(ctx, recipient.ID)
+	s.Require().NoError(err)
+	s.Require().Len(transfers, 3)
+	s.Require().Equal(sender.Login, transfers[0].SenderLogin)
+	s.Require().Equal(recipient.ID, transfers[2].SenderID)
+
+	var entries []entity.StatementEntry
+	s.Require().NoError(s.cnt.Gophermart().StreamStatement(ctx, recipient.ID, first.CreatedAt.Add(-1), time.Time{}, func(e *entity.StatementEntry) error {
+		entries = append(entries, *e)
+		return nil
+	}))
+	s.Require().Len(entries, 3)
+	s.Require().Equal(entity.StatementTransfer, entries[2].Kind)
+	s.Require().Equal(210.0, entries[2].Balance)
+}
+
+func (s *GophermartTestSuite) TestConcurrentTransfers() {
+	ctx := context.Background()
+	sender := s.NewUser()
+	recipient := s.NewUser()
+	admin := s.NewAdmin()
+	err := s.cnt.Admin().AdjustBalance(ctx, admin, sender.Login, &entity.BalanceAdjustmentRequest{Amount: 100, Reason: "family pool"})
+	s.Require().NoError(err)
+
+	// разные ключи: баланса хватает только на два перевода
+	var wg sync.WaitGroup
+	var succeeded atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.cnt.Gophermart().Transfer(ctx, sender.ID, "c"+strconv.Itoa(i), &entity.TransferRequest{Login: recipient.Login, Amount: 40})
+			if err == nil {
+				succeeded.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	s.Require().Equal(int32(2), succeeded.Load())
+	balance, err := s.cnt.Gophermart().GetBalance(ctx, sender.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(20.0, balance.Current)
+
+	// один ключ: все повторы возвращают один и тот же перевод
+	ids := make([]uuid.UUID, 5)
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			transfer, err := s.cnt.Gophermart().Transfer(ctx, sender.ID, "same", &entity.TransferRequest{Login: recipient.Login, Amount: 10})
+			if s.Assert().NoError(err) {
+				ids[i] = transfer.ID
+			}
+		}()
+	}
+	wg.Wait()
+	for _, id := range ids {
+		s.Require().Equal(ids[0], id)
+	}
+	balance, err = s.cnt.Gophermart().GetBalance(ctx, sender.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(10.0, balance.Current)
+}
+
+func (s *GophermartTestSuite) TestConcurrentWithdrawAndTransfer() {
+	ctx := context.Background()
+	u := s.NewUser()
+	recipient := s.NewUser()
+	admin := s.NewAdmin()
+	err := s.cnt.Admin().AdjustBalance(ctx, admin, u.Login, &entity.BalanceAdjustmentRequest{Amount: 100, Reason: "promo"})
+	s.Require().NoError(err)
+
+	// списания и переводы одновременно: баланса хватает только на две операции
+	var wg sync.WaitGroup
+	var succeeded atomic.Int32
+	for i, number := range []string{"70700000006", "70700000014", "70700000022", "70700000030"} {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if s.cnt.Gophermart().Withdraw(ctx, &entity.Withdraw{UserID: u.ID, OrderNumber: number, Value: 40}) == nil {
+				succeeded.Add(1)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := s.cnt.Gophermart().Transfer(ctx, u.ID, "w"+strconv.Itoa(i), &entity.TransferRequest{Login: recipient.Login, Amount: 40})
+			if err == nil {
+				succeeded.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	s.Require().Equal(int32(2), succeeded.Load())
+	balance, err := s.cnt.Gophermart().GetBalance(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(20.0, balance.Current)
+}
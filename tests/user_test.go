@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"context"
+
+	"github.com/gofrs/uuid"
+	"github.com/k-zavarnitsyn/gophermart/internal/services/auth"
+	"github.com/k-zavarnitsyn/gophermart/internal/utils"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain"
+	"github.com/k-zavarnitsyn/gophermart/pkg/domain/entity"
+)
+
+func (s *GophermartTestSuite) TestChangePassword() {
+	ctx := context.Background()
+	u := s.NewUser()
+	oldClaims := s.cnt.Auth().NewClaims(u)
+
+	_, err := s.cnt.Gophermart().ChangePassword(ctx, u.ID, &entity.ChangePasswordRequest{
+		OldPassword: "wrong",
+		NewPassword: "new",
+	})
+	s.Require().ErrorIs(err, domain.ErrWrongPassword)
+
+	changed, err := s.cnt.Gophermart().ChangePassword(ctx, u.ID, &entity.ChangePasswordRequest{
+		OldPassword: "test",
+		NewPassword: "new",
+	})
+	s.Require().NoError(err)
+	s.Require().Equal(u.SessionVersion+1, changed.SessionVersion)
+
+	_, err = s.cnt.Gophermart().Login(ctx, &entity.LoginRequest{Login: u.Login, Password: "test"})
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+	_, err = s.cnt.Gophermart().Login(ctx, &entity.LoginRequest{Login: u.Login, Password: "new"})
+	s.Require().NoError(err)
+
+	stored, err := s.cnt.UserRepo().FindByID(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().ErrorIs(auth.VerifyUser(oldClaims, stored), domain.ErrSessionRevoked)
+	s.Require().NoError(auth.VerifyUser(s.cnt.Auth().NewClaims(changed), stored))
+}
+
+func (s *GophermartTestSuite) TestDeleteUser() {
+	ctx := context.Background()
+	u := s.NewUser()
+	order := &entity.Order{
+		ID:      uuid.Must(uuid.NewV6()),
+		UserID:  u.ID,
+		Number:  "5062821234567892",
+		Status:  entity.OrderStatusProcessed,
+		Accrual: utils.ToPointer(25.00),
+	}
+	s.Require().NoError(s.cnt.OrderRepo().Insert(ctx, order))
+	s.Require().NoError(s.cnt.Gophermart().Withdraw(ctx, &entity.Withdraw{
+		UserID:      u.ID,
+		OrderNumber: "2377225624",
+		Value:       5,
+	}))
+
+	s.Require().NoError(s.cnt.Gophermart().DeleteUser(ctx, u.ID))
+	s.Require().ErrorIs(s.cnt.Gophermart().DeleteUser(ctx, u.ID), domain.ErrNotFound)
+
+	_, err := s.cnt.Gophermart().Login(ctx, &entity.LoginRequest{Login: u.Login, Password: "test"})
+	s.Require().ErrorIs(err, domain.ErrNotFound)
+	exists, err := s.cnt.UserRepo().LoginExists(ctx, u.Login)
+	s.Require().NoError(err)
+	s.Require().False(exists)
+
+	stored, err := s.cnt.UserRepo().FindByID(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(domain.DeletedLoginPrefix+u.ID.String(), stored.Login)
+	s.Require().NotNil(stored.DeletedAt)
+	s.Require().ErrorIs(auth.VerifyUser(s.cnt.Auth().NewClaims(u), stored), domain.ErrUserDeleted)
+
+	// записи "order" и withdrawn по-прежнему ссылаются на пользователя
+	storedOrder, err := s.cnt.OrderRepo().FindByNumber(ctx, order.Number)
+	s.Require().NoError(err)
+	s.Require().Equal(u.ID, storedOrder.UserID)
+	withdrawals, err := s.cnt.OrderRepo().GetUserWithdrawals(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Len(withdrawals, 1)
+	balance, err := s.cnt.Gophermart().GetBalance(ctx, u.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(20.00, balance.Current)
+
+	// логин освобождён и может быть зарегистрирован заново как новая учётная запись
+	reregistered, err := s.cnt.Gophermart().Register(ctx, &entity.RegisterRequest{Login: u.Login, Password: "test"})
+	s.Require().NoError(err)
+	s.Require().NotEqual(u.ID, reregistered.ID)
+}
+
+func (s *GophermartTestSuite) TestRegisterLoginCaseInsensitive() {
+	ctx := context.Background()
+	u, err := s.cnt.Gophermart().Register(ctx, &entity.RegisterRequest{Login: "CaseUser", Password: "test"})
+	s.Require().NoError(err)
+
+	_, err = s.cnt.Gophermart().Register(ctx, &entity.RegisterRequest{Login: "caseuser", Password: "test"})
+	s.Require().ErrorIs(err, domain.ErrLoginExists)
+
+	// гонка LoginExists + Insert закрыта уникальным индексом по lower(login)
+	err = s.cnt.UserRepo().Insert(ctx, &entity.User{ID: uuid.Must(uuid.NewV6()), Login: "CASEUSER", PasswordSHA: []byte("x")})
+	s.Require().ErrorIs(err, domain.ErrLoginExists)
+
+	logged, err := s.cnt.Gophermart().Login(ctx, &entity.LoginRequest{Login: "caseUSER", Password: "test"})
+	s.Require().NoError(err)
+	s.Require().Equal(u.ID, logged.ID)
+}
+
+func (s *GophermartTestSuite) TestRegisterValidation() {
+	_, err := s.cnt.Gophermart().Register(context.Background(), &entity.RegisterRequest{Login: "", Password: ""})
+	s.Require().ErrorIs(err, domain.ErrValidation)
+}